@@ -3,9 +3,20 @@ package main
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/robfig/cron/v3"
+
+	"geektime-basic-go/webook/internal/events"
+	"geektime-basic-go/webook/internal/job"
+	"geektime-basic-go/webook/ioc"
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/outbox"
 )
 
 type App struct {
-	web  *gin.Engine
-	cron *cron.Cron
+	web       *gin.Engine
+	admin     *ioc.AdminServer
+	cron      *cron.Cron
+	scheduler *job.Scheduler
+	relay     *outbox.Relay
+	consumers []events.Consumer
+	l         logger.Logger
 }
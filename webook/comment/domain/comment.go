@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+type Comment struct {
+	ID int64
+	// Biz 和 BizID 代表评论的对象，和 interactive 的约定保持一致
+	Biz   string
+	BizID int64
+	Uid   int64
+	// RootID 根评论的 ID，根评论自己的 RootID 为 0
+	RootID int64
+	// ParentID 直接回复的评论的 ID，根评论的 ParentID 为 0
+	ParentID int64
+	Content  string
+	// Replies 只有查询根评论列表的时候才会填充，是最早的几条回复
+	Replies  []Comment
+	CreateAt time.Time
+	UpdateAt time.Time
+}
+
+func (c Comment) IsRoot() bool {
+	return c.ParentID == 0
+}
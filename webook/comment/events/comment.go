@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/IBM/sarama"
+
+	"geektime-basic-go/webook/pkg/saramax"
+)
+
+const topicCommentCnt = "comment_cnt_event"
+
+// CommentCntEvent 评论数变化的事件，interactive 消费之后更新评论数
+type CommentCntEvent struct {
+	Biz   string
+	BizID int64
+	// Delta 新增评论是 1，删除评论是负的删除条数
+	Delta int64
+}
+
+type Producer interface {
+	ProduceCommentCntEvent(ctx context.Context, evt CommentCntEvent) error
+}
+
+type saramaSyncProducer struct {
+	producer sarama.SyncProducer
+}
+
+func NewSaramaSyncProducer(producer sarama.SyncProducer) Producer {
+	return &saramaSyncProducer{producer: producer}
+}
+
+func (p *saramaSyncProducer) ProduceCommentCntEvent(ctx context.Context, evt CommentCntEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   topicCommentCnt,
+		Value:   sarama.ByteEncoder(val),
+		Headers: []sarama.RecordHeader{saramax.EventIDHeader("")},
+	})
+	return err
+}
@@ -0,0 +1,2 @@
+// Package events 代表的是领域事件
+package events
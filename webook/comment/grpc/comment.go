@@ -0,0 +1,103 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecodeclub/ekit/slice"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commentv1 "geektime-basic-go/webook/api/proto/gen/comment"
+	"geektime-basic-go/webook/comment/domain"
+	"geektime-basic-go/webook/comment/service"
+)
+
+type CommentServiceServer struct {
+	commentv1.UnimplementedCommentServiceServer
+	svc service.CommentService
+}
+
+func NewCommentServiceServer(svc service.CommentService) *CommentServiceServer {
+	return &CommentServiceServer{svc: svc}
+}
+
+func (c *CommentServiceServer) Register(server grpc.ServiceRegistrar) {
+	commentv1.RegisterCommentServiceServer(server, c)
+}
+
+func (c *CommentServiceServer) CreateComment(ctx context.Context, request *commentv1.CreateCommentRequest) (*commentv1.CreateCommentResponse, error) {
+	id, err := c.svc.Create(ctx, c.toDomain(request.GetComment()))
+	return &commentv1.CreateCommentResponse{Id: id}, c.toStatus(err)
+}
+
+func (c *CommentServiceServer) DeleteComment(ctx context.Context, request *commentv1.DeleteCommentRequest) (*commentv1.DeleteCommentResponse, error) {
+	err := c.svc.Delete(ctx, request.GetId(), request.GetUid())
+	return &commentv1.DeleteCommentResponse{}, c.toStatus(err)
+}
+
+func (c *CommentServiceServer) GetCommentList(ctx context.Context, request *commentv1.GetCommentListRequest) (*commentv1.GetCommentListResponse, error) {
+	res, err := c.svc.List(ctx, request.GetBiz(), request.GetBizId(), request.GetMinId(), int(request.GetLimit()))
+	if err != nil {
+		return &commentv1.GetCommentListResponse{}, err
+	}
+	return &commentv1.GetCommentListResponse{Comments: c.toDTOs(res)}, nil
+}
+
+func (c *CommentServiceServer) GetMoreReplies(ctx context.Context, request *commentv1.GetMoreRepliesRequest) (*commentv1.GetMoreRepliesResponse, error) {
+	res, err := c.svc.Replies(ctx, request.GetRootId(), request.GetMaxId(), int(request.GetLimit()))
+	if err != nil {
+		return &commentv1.GetMoreRepliesResponse{}, err
+	}
+	return &commentv1.GetMoreRepliesResponse{Replies: c.toDTOs(res)}, nil
+}
+
+// toStatus 把业务错误转为 gRPC 的错误码，调用方据此区分
+func (c *CommentServiceServer) toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrCommentNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidParent):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return err
+	}
+}
+
+func (c *CommentServiceServer) toDomain(comment *commentv1.Comment) domain.Comment {
+	return domain.Comment{
+		ID:       comment.GetId(),
+		Biz:      comment.GetBiz(),
+		BizID:    comment.GetBizId(),
+		Uid:      comment.GetUid(),
+		RootID:   comment.GetRootId(),
+		ParentID: comment.GetParentId(),
+		Content:  comment.GetContent(),
+	}
+}
+
+func (c *CommentServiceServer) toDTOs(comments []domain.Comment) []*commentv1.Comment {
+	return slice.Map(comments, func(idx int, src domain.Comment) *commentv1.Comment {
+		return c.toDTO(src)
+	})
+}
+
+func (c *CommentServiceServer) toDTO(comment domain.Comment) *commentv1.Comment {
+	return &commentv1.Comment{
+		Id:       comment.ID,
+		Biz:      comment.Biz,
+		BizId:    comment.BizID,
+		Uid:      comment.Uid,
+		RootId:   comment.RootID,
+		ParentId: comment.ParentID,
+		Content:  comment.Content,
+		CreateAt: comment.CreateAt.UnixMilli(),
+		UpdateAt: comment.UpdateAt.UnixMilli(),
+		Replies:  c.toDTOs(comment.Replies),
+	}
+}
@@ -0,0 +1,248 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+
+	"geektime-basic-go/webook/comment/domain"
+	"geektime-basic-go/webook/comment/integration/startup"
+	"geektime-basic-go/webook/comment/repository/dao"
+	"geektime-basic-go/webook/comment/service"
+)
+
+type CommentTestSuite struct {
+	suite.Suite
+	db  *gorm.DB
+	svc service.CommentService
+}
+
+func TestCommentService(t *testing.T) {
+	suite.Run(t, &CommentTestSuite{})
+}
+
+func (s *CommentTestSuite) SetupSuite() {
+	startup.InitViper()
+	s.db = startup.InitDB()
+	s.svc = startup.InitCommentService()
+}
+
+func (s *CommentTestSuite) TearDownTest() {
+	err := s.db.Exec("TRUNCATE TABLE `comments`").Error
+	assert.NoError(s.T(), err)
+}
+
+func (s *CommentTestSuite) TestCreate() {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T)
+		after  func(t *testing.T, id int64)
+
+		comment domain.Comment
+
+		wantErr error
+	}{
+		{
+			name:   "根评论",
+			before: func(t *testing.T) {},
+			after: func(t *testing.T, id int64) {
+				c := s.findByID(t, id)
+				assert.True(t, c.CreateAt > 0)
+				assert.True(t, c.UpdateAt > 0)
+				c.CreateAt, c.UpdateAt = 0, 0
+				assert.Equal(t, dao.Comment{
+					ID:      id,
+					Biz:     "test",
+					BizID:   1,
+					Uid:     123,
+					Content: "第一条评论",
+				}, c)
+			},
+			comment: domain.Comment{Biz: "test", BizID: 1, Uid: 123, Content: "第一条评论"},
+		},
+		{
+			name: "回复根评论",
+			before: func(t *testing.T) {
+				s.insert(t, dao.Comment{ID: 1, Biz: "test", BizID: 1, Uid: 123, Content: "根评论"})
+			},
+			after: func(t *testing.T, id int64) {
+				c := s.findByID(t, id)
+				assert.Equal(t, int64(1), c.RootID)
+				assert.Equal(t, int64(1), c.ParentID)
+			},
+			comment: domain.Comment{Biz: "test", BizID: 1, Uid: 456, ParentID: 1, Content: "回复"},
+		},
+		{
+			name: "回复回复",
+			before: func(t *testing.T) {
+				s.insert(t, dao.Comment{ID: 1, Biz: "test", BizID: 1, Uid: 123, Content: "根评论"})
+				s.insert(t, dao.Comment{ID: 2, Biz: "test", BizID: 1, Uid: 456, RootID: 1, ParentID: 1, Content: "回复"})
+			},
+			after: func(t *testing.T, id int64) {
+				c := s.findByID(t, id)
+				// 不管嵌套多少层，RootID 都是根评论
+				assert.Equal(t, int64(1), c.RootID)
+				assert.Equal(t, int64(2), c.ParentID)
+			},
+			comment: domain.Comment{Biz: "test", BizID: 1, Uid: 789, ParentID: 2, Content: "回复的回复"},
+		},
+		{
+			name:    "回复的评论不存在",
+			before:  func(t *testing.T) {},
+			after:   func(t *testing.T, id int64) {},
+			comment: domain.Comment{Biz: "test", BizID: 1, Uid: 456, ParentID: 100, Content: "回复"},
+			wantErr: service.ErrCommentNotFound,
+		},
+		{
+			name: "回复的评论属于别的资源",
+			before: func(t *testing.T) {
+				s.insert(t, dao.Comment{ID: 1, Biz: "test", BizID: 2, Uid: 123, Content: "根评论"})
+			},
+			after: func(t *testing.T, id int64) {
+				var cnt int64
+				err := s.db.Model(&dao.Comment{}).Count(&cnt).Error
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), cnt)
+			},
+			comment: domain.Comment{Biz: "test", BizID: 1, Uid: 456, ParentID: 1, Content: "回复"},
+			wantErr: service.ErrInvalidParent,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			defer s.TearDownTest()
+			tc.before(t)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			defer cancel()
+			id, err := s.svc.Create(ctx, tc.comment)
+			assert.ErrorIs(t, err, tc.wantErr)
+			tc.after(t, id)
+		})
+	}
+}
+
+func (s *CommentTestSuite) TestDelete() {
+	// 1 <- 2 <- 3
+	//   <- 4
+	tree := func(t *testing.T) {
+		s.insert(t, dao.Comment{ID: 1, Biz: "test", BizID: 1, Uid: 123, Content: "根评论"})
+		s.insert(t, dao.Comment{ID: 2, Biz: "test", BizID: 1, Uid: 456, RootID: 1, ParentID: 1, Content: "回复"})
+		s.insert(t, dao.Comment{ID: 3, Biz: "test", BizID: 1, Uid: 789, RootID: 1, ParentID: 2, Content: "回复的回复"})
+		s.insert(t, dao.Comment{ID: 4, Biz: "test", BizID: 1, Uid: 789, RootID: 1, ParentID: 1, Content: "另外一条回复"})
+	}
+	testCases := []struct {
+		name   string
+		before func(t *testing.T)
+
+		id  int64
+		uid int64
+
+		wantIDs []int64
+		wantErr error
+	}{
+		{
+			name:    "删除根评论，连带删除所有回复",
+			before:  tree,
+			id:      1,
+			uid:     123,
+			wantIDs: []int64{},
+		},
+		{
+			name:    "删除回复，连带删除它下面的回复",
+			before:  tree,
+			id:      2,
+			uid:     456,
+			wantIDs: []int64{1, 4},
+		},
+		{
+			name:    "不是作者",
+			before:  tree,
+			id:      2,
+			uid:     123,
+			wantIDs: []int64{1, 2, 3, 4},
+			wantErr: service.ErrPermissionDenied,
+		},
+		{
+			name:    "评论不存在",
+			before:  tree,
+			id:      100,
+			uid:     123,
+			wantIDs: []int64{1, 2, 3, 4},
+			wantErr: service.ErrCommentNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			defer s.TearDownTest()
+			tc.before(t)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			defer cancel()
+			err := s.svc.Delete(ctx, tc.id, tc.uid)
+			assert.ErrorIs(t, err, tc.wantErr)
+
+			ids := make([]int64, 0)
+			err = s.db.Model(&dao.Comment{}).Order("id ASC").Pluck("id", &ids).Error
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantIDs, ids)
+		})
+	}
+}
+
+func (s *CommentTestSuite) TestList() {
+	t := s.T()
+	// 三条根评论，第一条根评论下面有四条回复
+	for i := int64(1); i <= 3; i++ {
+		s.insert(t, dao.Comment{ID: i, Biz: "test", BizID: 1, Uid: 123, Content: "根评论"})
+	}
+	for i := int64(4); i <= 7; i++ {
+		s.insert(t, dao.Comment{ID: i, Biz: "test", BizID: 1, Uid: 456, RootID: 1, ParentID: 1, Content: "回复"})
+	}
+	s.insert(t, dao.Comment{ID: 8, Biz: "test", BizID: 2, Uid: 123, Content: "别的资源"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	roots, err := s.svc.List(ctx, "test", 1, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 2}, s.ids(roots))
+	assert.Empty(t, roots[0].Replies)
+
+	roots, err = s.svc.List(ctx, "test", 1, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, s.ids(roots))
+	// 只带上最早的三条回复
+	assert.Equal(t, []int64{4, 5, 6}, s.ids(roots[0].Replies))
+
+	replies, err := s.svc.Replies(ctx, 1, 6, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{7}, s.ids(replies))
+}
+
+func (s *CommentTestSuite) insert(t *testing.T, c dao.Comment) {
+	now := time.Now().UnixMilli()
+	c.CreateAt, c.UpdateAt = now, now
+	err := s.db.Create(&c).Error
+	require.NoError(t, err)
+}
+
+func (s *CommentTestSuite) findByID(t *testing.T, id int64) dao.Comment {
+	var c dao.Comment
+	err := s.db.Where("id = ?", id).First(&c).Error
+	require.NoError(t, err)
+	return c
+}
+
+func (s *CommentTestSuite) ids(comments []domain.Comment) []int64 {
+	res := make([]int64, 0, len(comments))
+	for _, c := range comments {
+		res = append(res, c.ID)
+	}
+	return res
+}
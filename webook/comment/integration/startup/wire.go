@@ -0,0 +1,34 @@
+//go:build wireinject
+
+package startup
+
+import (
+	"github.com/google/wire"
+
+	"geektime-basic-go/webook/comment/events"
+	"geektime-basic-go/webook/comment/repository"
+	"geektime-basic-go/webook/comment/repository/dao"
+	"geektime-basic-go/webook/comment/service"
+)
+
+var thirdProvider = wire.NewSet(
+	InitDB,
+	InitLog,
+	InitKafka,
+	NewSyncProducer,
+)
+
+var commentSvcProvider = wire.NewSet(
+	service.NewCommentService,
+	repository.NewCommentRepository,
+	dao.NewCommentDAO,
+)
+
+func InitCommentService() service.CommentService {
+	wire.Build(
+		thirdProvider,
+		commentSvcProvider,
+		events.NewSaramaSyncProducer,
+	)
+	return service.NewCommentService(nil, nil, nil)
+}
@@ -0,0 +1,32 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+
+	cgrpc "geektime-basic-go/webook/comment/grpc"
+	"geektime-basic-go/webook/pkg/grpcx"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func InitGRPCxServer(comment *cgrpc.CommentServiceServer, l logger.Logger) *grpcx.Server {
+	type Config struct {
+		Port    int   `yaml:"port"`
+		EtcdTTL int64 `yaml:"etcdTTL"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("grpc.server", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer()
+	comment.Register(server)
+	return &grpcx.Server{
+		Server:   server,
+		Port:     cfg.Port,
+		Name:     "comment",
+		L:        l,
+		EtcdTTL:  cfg.EtcdTTL,
+		EtcdAddr: viper.GetString("etcd.addr"),
+	}
+}
@@ -0,0 +1,30 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func InitZapLogger() logger.Logger {
+	cfg := struct {
+		Level    string `yaml:"level"`
+		Encoding string `yaml:"encoding"`
+	}{
+		Encoding: "console",
+	}
+
+	if err := viper.UnmarshalKey("log", &cfg); err != nil {
+		panic(err)
+	}
+
+	zcfg := zap.NewDevelopmentConfig()
+	zcfg.Level = logger.ToZapLevel(cfg.Level)
+	zcfg.Encoding = cfg.Encoding
+	zapLogger, err := zcfg.Build(zap.AddCallerSkip(1))
+	if err != nil {
+		panic(err)
+	}
+	return logger.NewZapLogger(zapLogger, zcfg.Level)
+}
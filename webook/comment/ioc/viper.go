@@ -0,0 +1,15 @@
+package ioc
+
+import (
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func InitViper() {
+	cfile := pflag.String("config", "/etc/webook/config.yaml", "配置文件路径")
+	pflag.Parse()
+	viper.SetConfigFile(*cfile)
+	if err := viper.ReadInConfig(); err != nil {
+		panic(err)
+	}
+}
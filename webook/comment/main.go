@@ -0,0 +1,16 @@
+package main
+
+import (
+	"geektime-basic-go/webook/comment/ioc"
+	"geektime-basic-go/webook/pkg/grpcx"
+)
+
+func main() {
+	ioc.InitViper()
+	app := Init()
+	panic(app.server.Serve())
+}
+
+type App struct {
+	server *grpcx.Server
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"golang.org/x/sync/errgroup"
+
+	"geektime-basic-go/webook/comment/domain"
+	"geektime-basic-go/webook/comment/repository/dao"
+)
+
+var ErrCommentNotFound = dao.ErrDataNotFound
+
+type CommentRepository interface {
+	Create(ctx context.Context, c domain.Comment) (int64, error)
+	FindByID(ctx context.Context, id int64) (domain.Comment, error)
+	// FindByBiz 查询根评论，每条根评论带上最早的 replyCnt 条回复
+	FindByBiz(ctx context.Context, biz string, bizID int64, minID int64, limit int, replyCnt int) ([]domain.Comment, error)
+	FindReplies(ctx context.Context, rootID int64, maxID int64, limit int) ([]domain.Comment, error)
+	// Delete 返回一共删除了多少条评论
+	Delete(ctx context.Context, c domain.Comment) (int64, error)
+}
+
+type commentRepository struct {
+	dao dao.CommentDAO
+}
+
+func NewCommentRepository(dao dao.CommentDAO) CommentRepository {
+	return &commentRepository{dao: dao}
+}
+
+func (repo *commentRepository) Create(ctx context.Context, c domain.Comment) (int64, error) {
+	return repo.dao.Insert(ctx, repo.toEntity(c))
+}
+
+func (repo *commentRepository) FindByID(ctx context.Context, id int64) (domain.Comment, error) {
+	c, err := repo.dao.FindByID(ctx, id)
+	if errors.Is(err, dao.ErrDataNotFound) {
+		return domain.Comment{}, ErrCommentNotFound
+	}
+	if err != nil {
+		return domain.Comment{}, err
+	}
+	return repo.toDomain(c), nil
+}
+
+func (repo *commentRepository) FindByBiz(ctx context.Context, biz string, bizID int64, minID int64, limit int, replyCnt int) ([]domain.Comment, error) {
+	roots, err := repo.dao.FindRootsByBiz(ctx, biz, bizID, minID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	res := slice.Map(roots, func(idx int, src dao.Comment) domain.Comment {
+		return repo.toDomain(src)
+	})
+	if replyCnt <= 0 {
+		return res, nil
+	}
+
+	var eg errgroup.Group
+	for i := range res {
+		i := i
+		eg.Go(func() error {
+			replies, er := repo.dao.FindRepliesByRoot(ctx, res[i].ID, 0, replyCnt)
+			if er != nil {
+				return er
+			}
+			res[i].Replies = slice.Map(replies, func(idx int, src dao.Comment) domain.Comment {
+				return repo.toDomain(src)
+			})
+			return nil
+		})
+	}
+	return res, eg.Wait()
+}
+
+func (repo *commentRepository) FindReplies(ctx context.Context, rootID int64, maxID int64, limit int) ([]domain.Comment, error) {
+	replies, err := repo.dao.FindRepliesByRoot(ctx, rootID, maxID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(replies, func(idx int, src dao.Comment) domain.Comment {
+		return repo.toDomain(src)
+	}), nil
+}
+
+func (repo *commentRepository) Delete(ctx context.Context, c domain.Comment) (int64, error) {
+	return repo.dao.Delete(ctx, repo.toEntity(c))
+}
+
+func (repo *commentRepository) toEntity(c domain.Comment) dao.Comment {
+	return dao.Comment{
+		ID:       c.ID,
+		Biz:      c.Biz,
+		BizID:    c.BizID,
+		Uid:      c.Uid,
+		RootID:   c.RootID,
+		ParentID: c.ParentID,
+		Content:  c.Content,
+		CreateAt: c.CreateAt.UnixMilli(),
+		UpdateAt: c.UpdateAt.UnixMilli(),
+	}
+}
+
+func (repo *commentRepository) toDomain(c dao.Comment) domain.Comment {
+	return domain.Comment{
+		ID:       c.ID,
+		Biz:      c.Biz,
+		BizID:    c.BizID,
+		Uid:      c.Uid,
+		RootID:   c.RootID,
+		ParentID: c.ParentID,
+		Content:  c.Content,
+		CreateAt: time.UnixMilli(c.CreateAt),
+		UpdateAt: time.UnixMilli(c.UpdateAt),
+	}
+}
@@ -0,0 +1,115 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type CommentDAO interface {
+	Insert(ctx context.Context, c Comment) (int64, error)
+	FindByID(ctx context.Context, id int64) (Comment, error)
+	// FindRootsByBiz 按照 ID 倒序查询根评论，minID 为 0 代表第一页
+	FindRootsByBiz(ctx context.Context, biz string, bizID int64, minID int64, limit int) ([]Comment, error)
+	// FindRepliesByRoot 按照 ID 升序查询某条根评论下的回复
+	FindRepliesByRoot(ctx context.Context, rootID int64, maxID int64, limit int) ([]Comment, error)
+	// Delete 删除评论以及它下面所有的回复，返回一共删除了多少条
+	Delete(ctx context.Context, c Comment) (int64, error)
+}
+
+type Comment struct {
+	ID       int64  `gorm:"primaryKey,autoIncrement"`
+	Biz      string `gorm:"type:varchar(128);index:biz_type_id"`
+	BizID    int64  `gorm:"index:biz_type_id"`
+	Uid      int64  `gorm:"index"`
+	RootID   int64  `gorm:"index"`
+	ParentID int64  `gorm:"index"`
+	Content  string `gorm:"type:text"`
+	CreateAt int64
+	UpdateAt int64
+}
+
+type gormCommentDAO struct {
+	db *gorm.DB
+}
+
+func NewCommentDAO(db *gorm.DB) CommentDAO {
+	return &gormCommentDAO{db: db}
+}
+
+func (dao *gormCommentDAO) Insert(ctx context.Context, c Comment) (int64, error) {
+	err := dao.db.WithContext(ctx).Create(&c).Error
+	return c.ID, err
+}
+
+func (dao *gormCommentDAO) FindByID(ctx context.Context, id int64) (Comment, error) {
+	var c Comment
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&c).Error
+	return c, err
+}
+
+func (dao *gormCommentDAO) FindRootsByBiz(ctx context.Context, biz string, bizID int64, minID int64, limit int) ([]Comment, error) {
+	query := dao.db.WithContext(ctx).Where("biz = ? AND biz_id = ? AND parent_id = 0", biz, bizID)
+	if minID > 0 {
+		query = query.Where("id < ?", minID)
+	}
+	var res []Comment
+	err := query.Order("id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *gormCommentDAO) FindRepliesByRoot(ctx context.Context, rootID int64, maxID int64, limit int) ([]Comment, error) {
+	var res []Comment
+	err := dao.db.WithContext(ctx).
+		Where("root_id = ? AND id > ?", rootID, maxID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *gormCommentDAO) Delete(ctx context.Context, c Comment) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids, err := dao.descendants(tx, c)
+		if err != nil {
+			return err
+		}
+		res := tx.Where("id IN ?", append(ids, c.ID)).Delete(&Comment{})
+		cnt = res.RowsAffected
+		return res.Error
+	})
+	return cnt, err
+}
+
+// descendants 找出 c 下面所有回复的 ID
+func (dao *gormCommentDAO) descendants(tx *gorm.DB, c Comment) ([]int64, error) {
+	var ids []int64
+	if c.ParentID == 0 {
+		// 根评论直接按照 root_id 删除整棵树
+		err := tx.Model(&Comment{}).Where("root_id = ?", c.ID).Pluck("id", &ids).Error
+		return ids, err
+	}
+
+	// 回复只能一层一层往下找
+	var nodes []struct {
+		ID       int64
+		ParentID int64
+	}
+	err := tx.Model(&Comment{}).Select("id, parent_id").Where("root_id = ?", c.RootID).Scan(&nodes).Error
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int64][]int64, len(nodes))
+	for _, n := range nodes {
+		children[n.ParentID] = append(children[n.ParentID], n.ID)
+	}
+	queue := children[c.ID]
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		ids = append(ids, id)
+		queue = append(queue, children[id]...)
+	}
+	return ids, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"geektime-basic-go/webook/comment/domain"
+	"geektime-basic-go/webook/comment/events"
+	"geektime-basic-go/webook/comment/repository"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// previewReplyCnt 查询根评论列表的时候，每条根评论带上的回复数
+const previewReplyCnt = 3
+
+var (
+	ErrCommentNotFound = repository.ErrCommentNotFound
+	// ErrInvalidParent 回复的评论和评论对象对不上
+	ErrInvalidParent = errors.New("回复的评论不属于该资源")
+	// ErrPermissionDenied 只有作者可以删除自己的评论
+	ErrPermissionDenied = errors.New("无权删除该评论")
+)
+
+//go:generate mockgen -source=comment.go -package=svcmocks -destination=mocks/comment_mock_gen.go CommentService
+type CommentService interface {
+	// Create 创建评论，ParentID 不为 0 就是回复
+	Create(ctx context.Context, c domain.Comment) (int64, error)
+	Delete(ctx context.Context, id int64, uid int64) error
+	List(ctx context.Context, biz string, bizID int64, minID int64, limit int) ([]domain.Comment, error)
+	Replies(ctx context.Context, rootID int64, maxID int64, limit int) ([]domain.Comment, error)
+}
+
+type commentService struct {
+	repo     repository.CommentRepository
+	producer events.Producer
+	l        logger.Logger
+}
+
+func NewCommentService(repo repository.CommentRepository, producer events.Producer, l logger.Logger) CommentService {
+	return &commentService{repo: repo, producer: producer, l: l}
+}
+
+func (svc *commentService) Create(ctx context.Context, c domain.Comment) (int64, error) {
+	c.RootID = 0
+	if !c.IsRoot() {
+		parent, err := svc.repo.FindByID(ctx, c.ParentID)
+		if err != nil {
+			return 0, err
+		}
+		if parent.Biz != c.Biz || parent.BizID != c.BizID {
+			return 0, ErrInvalidParent
+		}
+		c.RootID = parent.RootID
+		if parent.IsRoot() {
+			c.RootID = parent.ID
+		}
+	}
+
+	now := time.Now()
+	c.CreateAt, c.UpdateAt = now, now
+	id, err := svc.repo.Create(ctx, c)
+	if err != nil {
+		return 0, err
+	}
+	svc.produceCntEvent(ctx, c.Biz, c.BizID, 1)
+	return id, nil
+}
+
+func (svc *commentService) Delete(ctx context.Context, id int64, uid int64) error {
+	c, err := svc.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if c.Uid != uid {
+		return ErrPermissionDenied
+	}
+
+	cnt, err := svc.repo.Delete(ctx, c)
+	if err != nil {
+		return err
+	}
+	if cnt > 0 {
+		svc.produceCntEvent(ctx, c.Biz, c.BizID, -cnt)
+	}
+	return nil
+}
+
+// produceCntEvent 评论数允许短暂不准确，所以发送失败只记录日志
+func (svc *commentService) produceCntEvent(ctx context.Context, biz string, bizID int64, delta int64) {
+	err := svc.producer.ProduceCommentCntEvent(ctx, events.CommentCntEvent{Biz: biz, BizID: bizID, Delta: delta})
+	if err != nil {
+		svc.l.Error("发送评论数变化事件失败",
+			logger.String("biz", biz),
+			logger.Int("bizID", bizID),
+			logger.Int("delta", delta),
+			logger.Error(err),
+		)
+	}
+}
+
+func (svc *commentService) List(ctx context.Context, biz string, bizID int64, minID int64, limit int) ([]domain.Comment, error) {
+	return svc.repo.FindByBiz(ctx, biz, bizID, minID, limit, previewReplyCnt)
+}
+
+func (svc *commentService) Replies(ctx context.Context, rootID int64, maxID int64, limit int) ([]domain.Comment, error) {
+	return svc.repo.FindReplies(ctx, rootID, maxID, limit)
+}
@@ -0,0 +1,40 @@
+//go:build wireinject
+
+package main
+
+import (
+	"github.com/google/wire"
+
+	"geektime-basic-go/webook/comment/events"
+	"geektime-basic-go/webook/comment/grpc"
+	"geektime-basic-go/webook/comment/ioc"
+	"geektime-basic-go/webook/comment/repository"
+	"geektime-basic-go/webook/comment/repository/dao"
+	"geektime-basic-go/webook/comment/service"
+)
+
+var commentSvcProvider = wire.NewSet(
+	service.NewCommentService,
+	repository.NewCommentRepository,
+	dao.NewCommentDAO,
+)
+
+var thirdProvider = wire.NewSet(
+	ioc.InitDB,
+	ioc.InitZapLogger,
+	ioc.InitKafka,
+	ioc.NewSyncProducer,
+)
+
+func Init() *App {
+	wire.Build(
+		thirdProvider,
+		commentSvcProvider,
+		events.NewSaramaSyncProducer,
+
+		grpc.NewCommentServiceServer,
+		ioc.InitGRPCxServer,
+		wire.Struct(new(App), "*"),
+	)
+	return new(App)
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// FeedItem feed 流里面的一条，目前只有发表文章
+type FeedItem struct {
+	ID     int64
+	Author int64
+	Biz    string
+	BizID  int64
+	// Ext 业务自己定义的扩展字段，feed 不关心具体内容
+	Ext      map[string]string
+	CreateAt time.Time
+}
+
+// FeedCursor 按照 (CreateAt, ID) 倒序翻页，同一毫秒发表的也不会漏掉或者重复，零值代表第一页
+type FeedCursor struct {
+	CreateAt time.Time
+	ID       int64
+}
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"geektime-basic-go/webook/feed/domain"
+	"geektime-basic-go/webook/feed/service"
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/saramax"
+)
+
+const (
+	topicArticlePublish  = "article_publish_event"
+	topicArticleWithdraw = "article_withdraw_event"
+	bizArticle           = "article"
+)
+
+// ArticlePublishEvent 由 webook 发表文章的时候发出
+type ArticlePublishEvent struct {
+	Aid       int64
+	Uid       int64
+	Title     string
+	Abstract  string
+	PublishAt int64
+}
+
+var _ Consumer = (*ArticlePublishEventConsumer)(nil)
+
+type ArticlePublishEventConsumer struct {
+	*saramax.Consumer[ArticlePublishEvent]
+	svc service.FeedService
+}
+
+// NewArticlePublishEventConsumer 推模型要写所有粉丝的收件箱，所以超时时间长一点
+func NewArticlePublishEventConsumer(client sarama.Client, svc service.FeedService, l logger.Logger) *ArticlePublishEventConsumer {
+	c := &ArticlePublishEventConsumer{svc: svc}
+	c.Consumer = saramax.NewConsumer[ArticlePublishEvent](client, l, saramax.Each(c.Consume),
+		saramax.WithGroupID("feed"),
+		saramax.WithTopics(topicArticlePublish),
+		saramax.WithTimeout(10*time.Second))
+	return c
+}
+
+func (c *ArticlePublishEventConsumer) Consume(ctx context.Context, msg *sarama.ConsumerMessage, evt ArticlePublishEvent) error {
+	return c.svc.Publish(ctx, c.toDomain(evt))
+}
+
+func (c *ArticlePublishEventConsumer) toDomain(evt ArticlePublishEvent) domain.FeedItem {
+	return domain.FeedItem{
+		Author: evt.Uid,
+		Biz:    bizArticle,
+		BizID:  evt.Aid,
+		Ext: map[string]string{
+			"title":    evt.Title,
+			"abstract": evt.Abstract,
+		},
+		CreateAt: time.UnixMilli(evt.PublishAt),
+	}
+}
+
+// ArticleWithdrawEvent 由 webook 撤回文章的时候发出
+type ArticleWithdrawEvent struct {
+	Aid int64
+	Uid int64
+}
+
+var _ Consumer = (*ArticleWithdrawEventConsumer)(nil)
+
+// ArticleWithdrawEventConsumer 把撤回的文章从收件箱和发件箱里面删掉
+type ArticleWithdrawEventConsumer struct {
+	*saramax.Consumer[ArticleWithdrawEvent]
+	svc service.FeedService
+}
+
+func NewArticleWithdrawEventConsumer(client sarama.Client, svc service.FeedService, l logger.Logger) *ArticleWithdrawEventConsumer {
+	c := &ArticleWithdrawEventConsumer{svc: svc}
+	c.Consumer = saramax.NewConsumer[ArticleWithdrawEvent](client, l, saramax.Each(c.Consume),
+		saramax.WithGroupID("feed_withdraw"),
+		saramax.WithTopics(topicArticleWithdraw))
+	return c
+}
+
+func (c *ArticleWithdrawEventConsumer) Consume(ctx context.Context, msg *sarama.ConsumerMessage, evt ArticleWithdrawEvent) error {
+	return c.svc.Delete(ctx, bizArticle, evt.Aid)
+}
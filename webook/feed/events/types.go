@@ -0,0 +1,9 @@
+package events
+
+import "context"
+
+type Consumer interface {
+	Start() error
+	// Close 停止消费，等待正在处理的消息处理完，ctx 过期了就直接返回
+	Close(ctx context.Context) error
+}
@@ -0,0 +1,47 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"google.golang.org/grpc"
+
+	feedv1 "geektime-basic-go/webook/api/proto/gen/feed"
+	"geektime-basic-go/webook/feed/domain"
+	"geektime-basic-go/webook/feed/service"
+)
+
+type FeedServiceServer struct {
+	feedv1.UnimplementedFeedServiceServer
+	svc service.FeedService
+}
+
+func NewFeedServiceServer(svc service.FeedService) *FeedServiceServer {
+	return &FeedServiceServer{svc: svc}
+}
+
+func (f *FeedServiceServer) Register(server grpc.ServiceRegistrar) {
+	feedv1.RegisterFeedServiceServer(server, f)
+}
+
+func (f *FeedServiceServer) FindFeed(ctx context.Context, request *feedv1.FindFeedRequest) (*feedv1.FindFeedResponse, error) {
+	var cursor domain.FeedCursor
+	if request.GetMaxTime() > 0 {
+		cursor = domain.FeedCursor{CreateAt: time.UnixMilli(request.GetMaxTime()), ID: request.GetMaxId()}
+	}
+	items, err := f.svc.FindFeed(ctx, request.GetUid(), cursor, int(request.GetLimit()))
+	if err != nil {
+		return &feedv1.FindFeedResponse{}, err
+	}
+	return &feedv1.FindFeedResponse{Items: slice.Map(items, func(idx int, src domain.FeedItem) *feedv1.FeedItem {
+		return &feedv1.FeedItem{
+			Id:       src.ID,
+			Author:   src.Author,
+			Biz:      src.Biz,
+			BizId:    src.BizID,
+			Ext:      src.Ext,
+			CreateAt: src.CreateAt.UnixMilli(),
+		}
+	})}, nil
+}
@@ -0,0 +1,215 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
+	"geektime-basic-go/webook/feed/domain"
+	"geektime-basic-go/webook/feed/integration/startup"
+	"geektime-basic-go/webook/feed/repository"
+	"geektime-basic-go/webook/feed/repository/dao"
+	"geektime-basic-go/webook/feed/service"
+	followmocks "geektime-basic-go/webook/internal/repository/rpc/follow/mocks"
+)
+
+type FeedTestSuite struct {
+	suite.Suite
+	db   *gorm.DB
+	repo repository.FeedRepository
+}
+
+func TestFeedService(t *testing.T) {
+	suite.Run(t, &FeedTestSuite{})
+}
+
+func (s *FeedTestSuite) SetupSuite() {
+	startup.InitViper()
+	s.db = startup.InitDB()
+	s.repo = startup.InitFeedRepository()
+}
+
+func (s *FeedTestSuite) TearDownTest() {
+	err := s.db.Exec("TRUNCATE TABLE `feed_inboxes`").Error
+	assert.NoError(s.T(), err)
+	err = s.db.Exec("TRUNCATE TABLE `feed_outboxes`").Error
+	assert.NoError(s.T(), err)
+}
+
+func (s *FeedTestSuite) TestPublish() {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	item := domain.FeedItem{
+		Author:   1,
+		Biz:      "article",
+		BizID:    10,
+		Ext:      map[string]string{"title": "标题"},
+		CreateAt: now,
+	}
+	testCases := []struct {
+		name  string
+		mock  func(ctrl *gomock.Controller) followv1.FollowServiceClient
+		after func(t *testing.T)
+	}{
+		{
+			name: "粉丝少，推到收件箱",
+			mock: func(ctrl *gomock.Controller) followv1.FollowServiceClient {
+				client := followmocks.NewMockFollowServiceClient(ctrl)
+				client.EXPECT().GetFollowStatics(gomock.Any(), &followv1.GetFollowStaticsRequest{Uid: 1}).
+					Return(&followv1.GetFollowStaticsResponse{Statics: &followv1.FollowStatics{Followers: 2}}, nil)
+				client.EXPECT().ListFollowers(gomock.Any(), gomock.Any()).
+					Return(&followv1.ListFollowersResponse{FollowRelations: []*followv1.FollowRelation{
+						{Id: 2, Follower: 3, Followee: 1},
+						{Id: 1, Follower: 2, Followee: 1},
+					}}, nil)
+				return client
+			},
+			after: func(t *testing.T) {
+				var inbox []dao.FeedInbox
+				err := s.db.Order("uid ASC").Find(&inbox).Error
+				require.NoError(t, err)
+				require.Len(t, inbox, 2)
+				assert.Equal(t, int64(2), inbox[0].Uid)
+				assert.Equal(t, int64(3), inbox[1].Uid)
+				assert.Equal(t, now.UnixMilli(), inbox[0].CreateAt)
+
+				var cnt int64
+				err = s.db.Model(&dao.FeedOutbox{}).Count(&cnt).Error
+				require.NoError(t, err)
+				assert.Equal(t, int64(0), cnt)
+			},
+		},
+		{
+			name: "粉丝多，写到发件箱",
+			mock: func(ctrl *gomock.Controller) followv1.FollowServiceClient {
+				client := followmocks.NewMockFollowServiceClient(ctrl)
+				client.EXPECT().GetFollowStatics(gomock.Any(), &followv1.GetFollowStaticsRequest{Uid: 1}).
+					Return(&followv1.GetFollowStaticsResponse{Statics: &followv1.FollowStatics{Followers: 100}}, nil)
+				return client
+			},
+			after: func(t *testing.T) {
+				var outbox []dao.FeedOutbox
+				err := s.db.Find(&outbox).Error
+				require.NoError(t, err)
+				require.Len(t, outbox, 1)
+				assert.Equal(t, int64(1), outbox[0].Author)
+				assert.Equal(t, int64(10), outbox[0].BizID)
+
+				var cnt int64
+				err = s.db.Model(&dao.FeedInbox{}).Count(&cnt).Error
+				require.NoError(t, err)
+				assert.Equal(t, int64(0), cnt)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			defer s.TearDownTest()
+
+			svc := service.NewFeedService(s.repo, tc.mock(ctrl), 10)
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			err := svc.Publish(ctx, item)
+			require.NoError(t, err)
+			tc.after(t)
+		})
+	}
+}
+
+func (s *FeedTestSuite) TestFindFeed() {
+	t := s.T()
+	base := time.Now().Add(-time.Hour).UnixMilli()
+	// uid 100 收件箱里有作者 1 的两条，还有取消关注了的作者 4 的一条；关注了大 V 2，发件箱里有三条
+	// BizID 3 和 8 在同一毫秒发表，翻页的时候不能漏掉
+	err := s.db.Create(&[]dao.FeedInbox{
+		{Uid: 100, Author: 1, Biz: "article", BizID: 1, Ext: "{}", CreateAt: base + 1},
+		{Uid: 100, Author: 1, Biz: "article", BizID: 4, Ext: "{}", CreateAt: base + 4},
+		{Uid: 200, Author: 1, Biz: "article", BizID: 5, Ext: "{}", CreateAt: base + 5},
+		{Uid: 100, Author: 4, Biz: "article", BizID: 9, Ext: "{}", CreateAt: base + 9},
+	}).Error
+	require.NoError(t, err)
+	err = s.db.Create(&[]dao.FeedOutbox{
+		{Author: 2, Biz: "article", BizID: 2, Ext: "{}", CreateAt: base + 2},
+		{Author: 2, Biz: "article", BizID: 3, Ext: "{}", CreateAt: base + 3},
+		{Author: 2, Biz: "article", BizID: 8, Ext: "{}", CreateAt: base + 3},
+		{Author: 2, Biz: "article", BizID: 6, Ext: "{}", CreateAt: base + 6},
+		{Author: 3, Biz: "article", BizID: 7, Ext: "{}", CreateAt: base + 7},
+	}).Error
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := followmocks.NewMockFollowServiceClient(ctrl)
+	client.EXPECT().ListFollowees(gomock.Any(), gomock.Any()).
+		Return(&followv1.ListFolloweesResponse{FollowRelations: []*followv1.FollowRelation{
+			{Id: 2, Follower: 100, Followee: 2},
+			{Id: 1, Follower: 100, Followee: 1},
+		}}, nil).Times(3)
+	svc := service.NewFeedService(s.repo, client, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	items, err := svc.FindFeed(ctx, 100, domain.FeedCursor{}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{6, 4, 8}, bizIDs(items))
+
+	items, err = svc.FindFeed(ctx, 100, nextCursor(items), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 2}, bizIDs(items))
+
+	items, err = svc.FindFeed(ctx, 100, nextCursor(items), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, bizIDs(items))
+}
+
+func (s *FeedTestSuite) TestDelete() {
+	t := s.T()
+	err := s.db.Create(&[]dao.FeedInbox{
+		{Uid: 100, Author: 1, Biz: "article", BizID: 1, Ext: "{}", CreateAt: 1},
+		{Uid: 200, Author: 1, Biz: "article", BizID: 1, Ext: "{}", CreateAt: 1},
+		{Uid: 100, Author: 1, Biz: "article", BizID: 2, Ext: "{}", CreateAt: 2},
+	}).Error
+	require.NoError(t, err)
+	err = s.db.Create(&[]dao.FeedOutbox{
+		{Author: 1, Biz: "article", BizID: 1, Ext: "{}", CreateAt: 1},
+		{Author: 1, Biz: "article", BizID: 2, Ext: "{}", CreateAt: 2},
+	}).Error
+	require.NoError(t, err)
+
+	svc := service.NewFeedService(s.repo, nil, 10)
+	err = svc.Delete(context.Background(), "article", 1)
+	require.NoError(t, err)
+
+	var inbox []dao.FeedInbox
+	err = s.db.Find(&inbox).Error
+	require.NoError(t, err)
+	require.Len(t, inbox, 1)
+	assert.Equal(t, int64(2), inbox[0].BizID)
+	var outbox []dao.FeedOutbox
+	err = s.db.Find(&outbox).Error
+	require.NoError(t, err)
+	require.Len(t, outbox, 1)
+	assert.Equal(t, int64(2), outbox[0].BizID)
+}
+
+func nextCursor(items []domain.FeedItem) domain.FeedCursor {
+	last := items[len(items)-1]
+	return domain.FeedCursor{CreateAt: last.CreateAt, ID: last.ID}
+}
+
+func bizIDs(items []domain.FeedItem) []int64 {
+	res := make([]int64, 0, len(items))
+	for _, item := range items {
+		res = append(res, item.BizID)
+	}
+	return res
+}
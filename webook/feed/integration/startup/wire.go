@@ -0,0 +1,19 @@
+//go:build wireinject
+
+package startup
+
+import (
+	"github.com/google/wire"
+
+	"geektime-basic-go/webook/feed/repository"
+	"geektime-basic-go/webook/feed/repository/dao"
+)
+
+func InitFeedRepository() repository.FeedRepository {
+	wire.Build(
+		InitDB,
+		repository.NewFeedRepository,
+		dao.NewFeedDAO,
+	)
+	return repository.NewFeedRepository(nil)
+}
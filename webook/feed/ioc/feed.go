@@ -0,0 +1,21 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
+	"geektime-basic-go/webook/feed/repository"
+	"geektime-basic-go/webook/feed/service"
+)
+
+func InitFeedService(repo repository.FeedRepository, followRPC followv1.FollowServiceClient) service.FeedService {
+	type Config struct {
+		// Threshold 粉丝数达到这个值的作者使用拉模型
+		Threshold int64 `yaml:"threshold"`
+	}
+	cfg := Config{Threshold: 1000}
+	if err := viper.UnmarshalKey("feed", &cfg); err != nil {
+		panic(err)
+	}
+	return service.NewFeedService(repo, followRPC, cfg.Threshold)
+}
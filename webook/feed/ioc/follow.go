@@ -0,0 +1,53 @@
+package ioc
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/resolver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
+)
+
+func InitEtcd() *clientv3.Client {
+	var cfg clientv3.Config
+	err := viper.UnmarshalKey("etcd", &cfg)
+	if err != nil {
+		panic(fmt.Sprintf("初始化 etcd client 反序列化配置失败: %s", err))
+	}
+	cli, err := clientv3.New(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("初始化 etcd client 失败: %s", err))
+	}
+	return cli
+}
+
+func InitFollowGRPC(client *clientv3.Client) followv1.FollowServiceClient {
+	type Config struct {
+		Name string `json:"name"`
+	}
+
+	var cfg Config
+	if err := viper.UnmarshalKey("grpc.client.follow", &cfg); err != nil {
+		panic(fmt.Sprintf("初始化 grpc client 失败, 反序列化配置失败: %s", err))
+	}
+
+	bd, err := resolver.NewBuilder(client)
+	if err != nil {
+		panic(fmt.Sprintf("初始化 grpc resolver 失败: %s", err))
+	}
+
+	cc, err := grpc.Dial(
+		"etcd:///service/"+cfg.Name,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithResolvers(bd),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("初始化 grpc client 失败, 连接失败: %s", err))
+	}
+
+	return followv1.NewFollowServiceClient(cc)
+}
@@ -0,0 +1,32 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+
+	fgrpc "geektime-basic-go/webook/feed/grpc"
+	"geektime-basic-go/webook/pkg/grpcx"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func InitGRPCxServer(feed *fgrpc.FeedServiceServer, l logger.Logger) *grpcx.Server {
+	type Config struct {
+		Port    int   `yaml:"port"`
+		EtcdTTL int64 `yaml:"etcdTTL"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("grpc.server", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer()
+	feed.Register(server)
+	return &grpcx.Server{
+		Server:   server,
+		Port:     cfg.Port,
+		Name:     "feed",
+		L:        l,
+		EtcdTTL:  cfg.EtcdTTL,
+		EtcdAddr: viper.GetString("etcd.addr"),
+	}
+}
@@ -0,0 +1,37 @@
+package ioc
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/viper"
+
+	"geektime-basic-go/webook/feed/events"
+	"geektime-basic-go/webook/pkg/saramax"
+)
+
+func InitKafka() sarama.Client {
+	type config struct {
+		Addrs []string `yaml:"addrs"`
+		// Metrics 消费者监控的配置，主要是 instanceID
+		Metrics saramax.MetricsConfig `yaml:"metrics"`
+	}
+	saramaCfg := sarama.NewConfig()
+	saramaCfg.Producer.Return.Successes = true
+
+	var cfg config
+	if err := viper.UnmarshalKey("kafka", &cfg); err != nil {
+		panic(fmt.Sprintf("初始化 kafka 失败, 反序列化配置失败: %s", err))
+	}
+	saramax.InitMetrics(cfg.Metrics)
+	client, err := sarama.NewClient(cfg.Addrs, saramaCfg)
+	if err != nil {
+		panic(fmt.Sprintf("初始化 kafka 失败: %s", err))
+	}
+	return client
+}
+
+// NewConsumers 所有的 Consumer 在这里注册一下
+func NewConsumers(c1 *events.ArticlePublishEventConsumer, c2 *events.ArticleWithdrawEventConsumer) []events.Consumer {
+	return []events.Consumer{c1, c2}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"geektime-basic-go/webook/feed/events"
+	"geektime-basic-go/webook/feed/ioc"
+	"geektime-basic-go/webook/pkg/grpcx"
+)
+
+func main() {
+	ioc.InitViper()
+	app := Init()
+	for _, c := range app.consumers {
+		if err := c.Start(); err != nil {
+			panic(err)
+		}
+	}
+	panic(app.server.Serve())
+}
+
+type App struct {
+	server    *grpcx.Server
+	consumers []events.Consumer
+}
@@ -0,0 +1,6 @@
+package dao
+
+import "gorm.io/gorm"
+
+// ErrDataNotFound 通用的数据没找到
+var ErrDataNotFound = gorm.ErrRecordNotFound
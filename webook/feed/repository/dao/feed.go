@@ -0,0 +1,106 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FeedDAO interface {
+	// InsertInbox 推模型，写到每个粉丝的收件箱，重复的会被忽略
+	InsertInbox(ctx context.Context, items []FeedInbox) error
+	// InsertOutbox 拉模型，只写一份到作者的发件箱，重复的会被忽略
+	InsertOutbox(ctx context.Context, item FeedOutbox) error
+	// FindInbox 只返回 authors 发表的，取消关注之后推过来的就不再展示
+	FindInbox(ctx context.Context, uid int64, authors []int64, cursor Cursor, limit int) ([]FeedInbox, error)
+	FindOutbox(ctx context.Context, authors []int64, cursor Cursor, limit int) ([]FeedOutbox, error)
+	// DeleteByBiz 删除收件箱和发件箱里的某个资源，例如撤回的文章
+	DeleteByBiz(ctx context.Context, biz string, bizID int64) error
+}
+
+// Cursor 上一页最后一条的 create_at 和 id，按照 (create_at, id) 倒序翻页
+type Cursor struct {
+	CreateAt int64
+	ID       int64
+}
+
+// FeedInbox 收件箱，推模型使用
+type FeedInbox struct {
+	ID       int64  `gorm:"primaryKey,autoIncrement"`
+	Uid      int64  `gorm:"uniqueIndex:uid_biz_type_id;index:uid_create_at"`
+	Biz      string `gorm:"type:varchar(128);uniqueIndex:uid_biz_type_id;index:biz_type_id"`
+	BizID    int64  `gorm:"uniqueIndex:uid_biz_type_id;index:biz_type_id"`
+	Author   int64
+	Ext      string `gorm:"type:text"`
+	CreateAt int64  `gorm:"index:uid_create_at"`
+}
+
+// FeedOutbox 发件箱，拉模型使用
+type FeedOutbox struct {
+	ID       int64  `gorm:"primaryKey,autoIncrement"`
+	Author   int64  `gorm:"uniqueIndex:author_biz_type_id;index:author_create_at"`
+	Biz      string `gorm:"type:varchar(128);uniqueIndex:author_biz_type_id;index:biz_type_id"`
+	BizID    int64  `gorm:"uniqueIndex:author_biz_type_id;index:biz_type_id"`
+	Ext      string `gorm:"type:text"`
+	CreateAt int64  `gorm:"index:author_create_at"`
+}
+
+type gormFeedDAO struct {
+	db *gorm.DB
+}
+
+func NewFeedDAO(db *gorm.DB) FeedDAO {
+	return &gormFeedDAO{db: db}
+}
+
+func (dao *gormFeedDAO) InsertInbox(ctx context.Context, items []FeedInbox) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+func (dao *gormFeedDAO) InsertOutbox(ctx context.Context, item FeedOutbox) error {
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&item).Error
+}
+
+func (dao *gormFeedDAO) FindInbox(ctx context.Context, uid int64, authors []int64, cursor Cursor, limit int) ([]FeedInbox, error) {
+	if len(authors) == 0 {
+		return nil, nil
+	}
+	var res []FeedInbox
+	err := dao.afterCursor(dao.db.WithContext(ctx), cursor).
+		Where("uid = ? AND author IN ?", uid, authors).
+		Order("create_at DESC, id DESC").
+		Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *gormFeedDAO) FindOutbox(ctx context.Context, authors []int64, cursor Cursor, limit int) ([]FeedOutbox, error) {
+	if len(authors) == 0 {
+		return nil, nil
+	}
+	var res []FeedOutbox
+	err := dao.afterCursor(dao.db.WithContext(ctx), cursor).
+		Where("author IN ?", authors).
+		Order("create_at DESC, id DESC").
+		Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+// afterCursor 只查询排在 cursor 之后的数据，create_at 相同的按照 id 区分
+func (dao *gormFeedDAO) afterCursor(db *gorm.DB, cursor Cursor) *gorm.DB {
+	return db.Where("(create_at < ? OR (create_at = ? AND id < ?))", cursor.CreateAt, cursor.CreateAt, cursor.ID)
+}
+
+func (dao *gormFeedDAO) DeleteByBiz(ctx context.Context, biz string, bizID int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("biz = ? AND biz_id = ?", biz, bizID).Delete(&FeedInbox{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("biz = ? AND biz_id = ?", biz, bizID).Delete(&FeedOutbox{}).Error
+	})
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+
+	"geektime-basic-go/webook/feed/domain"
+	"geektime-basic-go/webook/feed/repository/dao"
+)
+
+type FeedRepository interface {
+	// CreatePushFeed 把 item 写到 uids 这些用户的收件箱
+	CreatePushFeed(ctx context.Context, item domain.FeedItem, uids []int64) error
+	// CreatePullFeed 把 item 写到作者的发件箱
+	CreatePullFeed(ctx context.Context, item domain.FeedItem) error
+	// FindPushFeed 查询收件箱里 authors 发表的内容
+	FindPushFeed(ctx context.Context, uid int64, authors []int64, cursor domain.FeedCursor, limit int) ([]domain.FeedItem, error)
+	FindPullFeed(ctx context.Context, authors []int64, cursor domain.FeedCursor, limit int) ([]domain.FeedItem, error)
+	// DeleteFeed 把某个资源从所有的收件箱和发件箱里删掉
+	DeleteFeed(ctx context.Context, biz string, bizID int64) error
+}
+
+type feedRepository struct {
+	dao dao.FeedDAO
+}
+
+func NewFeedRepository(dao dao.FeedDAO) FeedRepository {
+	return &feedRepository{dao: dao}
+}
+
+func (repo *feedRepository) CreatePushFeed(ctx context.Context, item domain.FeedItem, uids []int64) error {
+	ext, err := json.Marshal(item.Ext)
+	if err != nil {
+		return err
+	}
+	return repo.dao.InsertInbox(ctx, slice.Map(uids, func(idx int, src int64) dao.FeedInbox {
+		return dao.FeedInbox{
+			Uid:      src,
+			Biz:      item.Biz,
+			BizID:    item.BizID,
+			Author:   item.Author,
+			Ext:      string(ext),
+			CreateAt: item.CreateAt.UnixMilli(),
+		}
+	}))
+}
+
+func (repo *feedRepository) CreatePullFeed(ctx context.Context, item domain.FeedItem) error {
+	ext, err := json.Marshal(item.Ext)
+	if err != nil {
+		return err
+	}
+	return repo.dao.InsertOutbox(ctx, dao.FeedOutbox{
+		Author:   item.Author,
+		Biz:      item.Biz,
+		BizID:    item.BizID,
+		Ext:      string(ext),
+		CreateAt: item.CreateAt.UnixMilli(),
+	})
+}
+
+func (repo *feedRepository) FindPushFeed(ctx context.Context, uid int64, authors []int64, cursor domain.FeedCursor, limit int) ([]domain.FeedItem, error) {
+	items, err := repo.dao.FindInbox(ctx, uid, authors, repo.toCursor(cursor), limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(items, func(idx int, src dao.FeedInbox) domain.FeedItem {
+		return repo.toDomain(src.ID, src.Author, src.Biz, src.BizID, src.Ext, src.CreateAt)
+	}), nil
+}
+
+func (repo *feedRepository) FindPullFeed(ctx context.Context, authors []int64, cursor domain.FeedCursor, limit int) ([]domain.FeedItem, error) {
+	items, err := repo.dao.FindOutbox(ctx, authors, repo.toCursor(cursor), limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(items, func(idx int, src dao.FeedOutbox) domain.FeedItem {
+		return repo.toDomain(src.ID, src.Author, src.Biz, src.BizID, src.Ext, src.CreateAt)
+	}), nil
+}
+
+func (repo *feedRepository) DeleteFeed(ctx context.Context, biz string, bizID int64) error {
+	return repo.dao.DeleteByBiz(ctx, biz, bizID)
+}
+
+func (repo *feedRepository) toCursor(cursor domain.FeedCursor) dao.Cursor {
+	return dao.Cursor{CreateAt: cursor.CreateAt.UnixMilli(), ID: cursor.ID}
+}
+
+func (repo *feedRepository) toDomain(id, author int64, biz string, bizID int64, ext string, createAt int64) domain.FeedItem {
+	var m map[string]string
+	// 扩展字段是我们自己序列化的，解析失败就当作没有
+	_ = json.Unmarshal([]byte(ext), &m)
+	return domain.FeedItem{
+		ID:       id,
+		Author:   author,
+		Biz:      biz,
+		BizID:    bizID,
+		Ext:      m,
+		CreateAt: time.UnixMilli(createAt),
+	}
+}
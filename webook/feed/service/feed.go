@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"golang.org/x/sync/errgroup"
+
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
+	"geektime-basic-go/webook/feed/domain"
+	"geektime-basic-go/webook/feed/repository"
+)
+
+const (
+	// fanoutBatchSize 推模型每次查询并写入的粉丝数
+	fanoutBatchSize = 500
+	// followeeBatchSize 拉模型每次查询的关注数
+	followeeBatchSize = 1000
+)
+
+//go:generate mockgen -source=feed.go -package=svcmocks -destination=mocks/feed_mock_gen.go FeedService
+type FeedService interface {
+	// Publish 粉丝数小于阈值的作者，推到每个粉丝的收件箱；否则写到作者的发件箱，粉丝读的时候拉
+	Publish(ctx context.Context, item domain.FeedItem) error
+	// FindFeed 合并收件箱和关注的人的发件箱，按照 (CreateAt, ID) 倒序
+	// cursor 为零值代表第一页
+	FindFeed(ctx context.Context, uid int64, cursor domain.FeedCursor, limit int) ([]domain.FeedItem, error)
+	// Delete 资源被撤回之后，从所有人的 feed 流里面删掉
+	Delete(ctx context.Context, biz string, bizID int64) error
+}
+
+type feedService struct {
+	repo      repository.FeedRepository
+	followRPC followv1.FollowServiceClient
+	// threshold 粉丝数达到这个值就使用拉模型
+	threshold int64
+}
+
+func NewFeedService(repo repository.FeedRepository, followRPC followv1.FollowServiceClient, threshold int64) FeedService {
+	return &feedService{repo: repo, followRPC: followRPC, threshold: threshold}
+}
+
+func (svc *feedService) Publish(ctx context.Context, item domain.FeedItem) error {
+	resp, err := svc.followRPC.GetFollowStatics(ctx, &followv1.GetFollowStaticsRequest{Uid: item.Author})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatics().GetFollowers() >= svc.threshold {
+		return svc.repo.CreatePullFeed(ctx, item)
+	}
+	return svc.fanout(ctx, item)
+}
+
+func (svc *feedService) fanout(ctx context.Context, item domain.FeedItem) error {
+	var minID int64
+	for {
+		resp, err := svc.followRPC.ListFollowers(ctx, &followv1.ListFollowersRequest{
+			Followee: item.Author,
+			MinId:    minID,
+			Limit:    fanoutBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+		relations := resp.GetFollowRelations()
+		if len(relations) == 0 {
+			return nil
+		}
+		uids := slice.Map(relations, func(idx int, src *followv1.FollowRelation) int64 {
+			return src.GetFollower()
+		})
+		if err = svc.repo.CreatePushFeed(ctx, item, uids); err != nil {
+			return err
+		}
+		if len(relations) < fanoutBatchSize {
+			return nil
+		}
+		minID = relations[len(relations)-1].GetId()
+	}
+}
+
+func (svc *feedService) FindFeed(ctx context.Context, uid int64, cursor domain.FeedCursor, limit int) ([]domain.FeedItem, error) {
+	if cursor.CreateAt.IsZero() {
+		cursor = domain.FeedCursor{CreateAt: time.Now(), ID: math.MaxInt64}
+	}
+	// 收件箱里面可能还有取消关注之前推过来的，所以两边都只查当前关注的人
+	followees, err := svc.followees(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		eg        errgroup.Group
+		pushItems []domain.FeedItem
+		pullItems []domain.FeedItem
+	)
+	eg.Go(func() (err error) {
+		pushItems, err = svc.repo.FindPushFeed(ctx, uid, followees, cursor, limit)
+		return
+	})
+	eg.Go(func() (err error) {
+		// 发件箱里面只有大 V 发表的内容，所以不需要区分关注的人是不是大 V
+		pullItems, err = svc.repo.FindPullFeed(ctx, followees, cursor, limit)
+		return
+	})
+	if err = eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	res := append(pushItems, pullItems...)
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreateAt.Equal(res[j].CreateAt) {
+			return res[i].ID > res[j].ID
+		}
+		return res[i].CreateAt.After(res[j].CreateAt)
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (svc *feedService) Delete(ctx context.Context, biz string, bizID int64) error {
+	return svc.repo.DeleteFeed(ctx, biz, bizID)
+}
+
+func (svc *feedService) followees(ctx context.Context, uid int64) ([]int64, error) {
+	var (
+		res   []int64
+		minID int64
+	)
+	for {
+		resp, err := svc.followRPC.ListFollowees(ctx, &followv1.ListFolloweesRequest{
+			Follower: uid,
+			MinId:    minID,
+			Limit:    followeeBatchSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		relations := resp.GetFollowRelations()
+		for _, fr := range relations {
+			res = append(res, fr.GetFollowee())
+		}
+		if len(relations) < followeeBatchSize {
+			return res, nil
+		}
+		minID = relations[len(relations)-1].GetId()
+	}
+}
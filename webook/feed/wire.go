@@ -0,0 +1,43 @@
+//go:build wireinject
+
+package main
+
+import (
+	"github.com/google/wire"
+
+	"geektime-basic-go/webook/feed/events"
+	"geektime-basic-go/webook/feed/grpc"
+	"geektime-basic-go/webook/feed/ioc"
+	"geektime-basic-go/webook/feed/repository"
+	"geektime-basic-go/webook/feed/repository/dao"
+)
+
+var feedSvcProvider = wire.NewSet(
+	ioc.InitFeedService,
+	repository.NewFeedRepository,
+	dao.NewFeedDAO,
+)
+
+var thirdProvider = wire.NewSet(
+	ioc.InitDB,
+	ioc.InitZapLogger,
+	ioc.InitKafka,
+	ioc.InitEtcd,
+	ioc.InitFollowGRPC,
+)
+
+func Init() *App {
+	wire.Build(
+		thirdProvider,
+		feedSvcProvider,
+
+		events.NewArticlePublishEventConsumer,
+		events.NewArticleWithdrawEventConsumer,
+		ioc.NewConsumers,
+
+		grpc.NewFeedServiceServer,
+		ioc.InitGRPCxServer,
+		wire.Struct(new(App), "*"),
+	)
+	return new(App)
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// FollowRelation Follower 关注了 Followee
+type FollowRelation struct {
+	ID       int64
+	Follower int64
+	Followee int64
+	CreateAt time.Time
+}
+
+// FollowStatics 某个用户的关注数据
+type FollowStatics struct {
+	// Followers 粉丝数
+	Followers int64
+	// Followees 关注数
+	Followees int64
+}
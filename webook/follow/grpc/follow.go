@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecodeclub/ekit/slice"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
+	"geektime-basic-go/webook/follow/domain"
+	"geektime-basic-go/webook/follow/service"
+)
+
+type FollowServiceServer struct {
+	followv1.UnimplementedFollowServiceServer
+	svc service.FollowService
+}
+
+func NewFollowServiceServer(svc service.FollowService) *FollowServiceServer {
+	return &FollowServiceServer{svc: svc}
+}
+
+func (f *FollowServiceServer) Register(server grpc.ServiceRegistrar) {
+	followv1.RegisterFollowServiceServer(server, f)
+}
+
+func (f *FollowServiceServer) Follow(ctx context.Context, request *followv1.FollowRequest) (*followv1.FollowResponse, error) {
+	err := f.svc.Follow(ctx, request.GetFollower(), request.GetFollowee())
+	if errors.Is(err, service.ErrFollowSelf) {
+		return &followv1.FollowResponse{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &followv1.FollowResponse{}, err
+}
+
+func (f *FollowServiceServer) Unfollow(ctx context.Context, request *followv1.UnfollowRequest) (*followv1.UnfollowResponse, error) {
+	err := f.svc.Unfollow(ctx, request.GetFollower(), request.GetFollowee())
+	return &followv1.UnfollowResponse{}, err
+}
+
+func (f *FollowServiceServer) ListFollowers(ctx context.Context, request *followv1.ListFollowersRequest) (*followv1.ListFollowersResponse, error) {
+	res, err := f.svc.ListFollowers(ctx, request.GetFollowee(), request.GetMinId(), int(request.GetLimit()))
+	if err != nil {
+		return &followv1.ListFollowersResponse{}, err
+	}
+	return &followv1.ListFollowersResponse{FollowRelations: f.toDTOs(res)}, nil
+}
+
+func (f *FollowServiceServer) ListFollowees(ctx context.Context, request *followv1.ListFolloweesRequest) (*followv1.ListFolloweesResponse, error) {
+	res, err := f.svc.ListFollowees(ctx, request.GetFollower(), request.GetMinId(), int(request.GetLimit()))
+	if err != nil {
+		return &followv1.ListFolloweesResponse{}, err
+	}
+	return &followv1.ListFolloweesResponse{FollowRelations: f.toDTOs(res)}, nil
+}
+
+func (f *FollowServiceServer) GetFollowStatics(ctx context.Context, request *followv1.GetFollowStaticsRequest) (*followv1.GetFollowStaticsResponse, error) {
+	res, err := f.svc.GetStatics(ctx, request.GetUid())
+	if err != nil {
+		return &followv1.GetFollowStaticsResponse{}, err
+	}
+	return &followv1.GetFollowStaticsResponse{Statics: &followv1.FollowStatics{
+		Followers: res.Followers,
+		Followees: res.Followees,
+	}}, nil
+}
+
+func (f *FollowServiceServer) toDTOs(relations []domain.FollowRelation) []*followv1.FollowRelation {
+	return slice.Map(relations, func(idx int, src domain.FollowRelation) *followv1.FollowRelation {
+		return &followv1.FollowRelation{
+			Id:       src.ID,
+			Follower: src.Follower,
+			Followee: src.Followee,
+			CreateAt: src.CreateAt.UnixMilli(),
+		}
+	})
+}
@@ -0,0 +1,255 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+
+	"geektime-basic-go/webook/follow/domain"
+	"geektime-basic-go/webook/follow/integration/startup"
+	"geektime-basic-go/webook/follow/repository/dao"
+	"geektime-basic-go/webook/follow/service"
+)
+
+type FollowTestSuite struct {
+	suite.Suite
+	db  *gorm.DB
+	rdb redis.Cmdable
+	svc service.FollowService
+}
+
+func TestFollowService(t *testing.T) {
+	suite.Run(t, &FollowTestSuite{})
+}
+
+func (s *FollowTestSuite) SetupSuite() {
+	startup.InitViper()
+	s.db = startup.InitDB()
+	s.rdb = startup.InitRedis()
+	s.svc = startup.InitFollowService()
+}
+
+func (s *FollowTestSuite) TearDownTest() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := s.db.Exec("TRUNCATE TABLE `follow_relations`").Error
+	assert.NoError(s.T(), err)
+	err = s.db.Exec("TRUNCATE TABLE `follow_statics`").Error
+	assert.NoError(s.T(), err)
+	err = s.rdb.FlushDB(ctx).Err()
+	assert.NoError(s.T(), err)
+}
+
+func (s *FollowTestSuite) TestFollow() {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T)
+		after  func(t *testing.T)
+
+		follower int64
+		followee int64
+
+		wantErr error
+	}{
+		{
+			name:   "第一次关注",
+			before: func(t *testing.T) {},
+			after: func(t *testing.T) {
+				s.assertRelation(t, 1, 2, dao.FollowRelationStatusActive)
+				s.assertStatics(t, 1, domain.FollowStatics{Followees: 1})
+				s.assertStatics(t, 2, domain.FollowStatics{Followers: 1})
+			},
+			follower: 1,
+			followee: 2,
+		},
+		{
+			name: "重复关注，计数不变",
+			before: func(t *testing.T) {
+				s.insertRelation(t, 1, 2, dao.FollowRelationStatusActive)
+				s.insertStatics(t, dao.FollowStatics{Uid: 1, Followees: 1})
+				s.insertStatics(t, dao.FollowStatics{Uid: 2, Followers: 1})
+			},
+			after: func(t *testing.T) {
+				s.assertRelation(t, 1, 2, dao.FollowRelationStatusActive)
+				s.assertStatics(t, 1, domain.FollowStatics{Followees: 1})
+				s.assertStatics(t, 2, domain.FollowStatics{Followers: 1})
+			},
+			follower: 1,
+			followee: 2,
+		},
+		{
+			name: "取消之后重新关注，缓存也更新",
+			before: func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+				defer cancel()
+				s.insertRelation(t, 1, 2, dao.FollowRelationStatusInactive)
+				s.insertStatics(t, dao.FollowStatics{Uid: 1})
+				s.insertStatics(t, dao.FollowStatics{Uid: 2, Followers: 5})
+				err := s.rdb.HSet(ctx, "follow:statics:2", "followers", 5, "followees", 0).Err()
+				require.NoError(t, err)
+			},
+			after: func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+				defer cancel()
+				s.assertRelation(t, 1, 2, dao.FollowRelationStatusActive)
+				s.assertStatics(t, 1, domain.FollowStatics{Followees: 1})
+				s.assertStatics(t, 2, domain.FollowStatics{Followers: 6})
+				cnt, err := s.rdb.HGet(ctx, "follow:statics:2", "followers").Int()
+				require.NoError(t, err)
+				assert.Equal(t, 6, cnt)
+			},
+			follower: 1,
+			followee: 2,
+		},
+		{
+			name:     "关注自己",
+			before:   func(t *testing.T) {},
+			after:    func(t *testing.T) {},
+			follower: 1,
+			followee: 1,
+			wantErr:  service.ErrFollowSelf,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			defer s.TearDownTest()
+			tc.before(t)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			defer cancel()
+			err := s.svc.Follow(ctx, tc.follower, tc.followee)
+			assert.Equal(t, tc.wantErr, err)
+			tc.after(t)
+		})
+	}
+}
+
+func (s *FollowTestSuite) TestUnfollow() {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T)
+		after  func(t *testing.T)
+
+		follower int64
+		followee int64
+	}{
+		{
+			name: "取消关注",
+			before: func(t *testing.T) {
+				s.insertRelation(t, 1, 2, dao.FollowRelationStatusActive)
+				s.insertStatics(t, dao.FollowStatics{Uid: 1, Followees: 1})
+				s.insertStatics(t, dao.FollowStatics{Uid: 2, Followers: 1})
+			},
+			after: func(t *testing.T) {
+				s.assertRelation(t, 1, 2, dao.FollowRelationStatusInactive)
+				s.assertStatics(t, 1, domain.FollowStatics{})
+				s.assertStatics(t, 2, domain.FollowStatics{})
+			},
+			follower: 1,
+			followee: 2,
+		},
+		{
+			name:   "没有关注过",
+			before: func(t *testing.T) {},
+			after: func(t *testing.T) {
+				var cnt int64
+				err := s.db.Model(&dao.FollowStatics{}).Count(&cnt).Error
+				require.NoError(t, err)
+				assert.Equal(t, int64(0), cnt)
+			},
+			follower: 1,
+			followee: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			defer s.TearDownTest()
+			tc.before(t)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			defer cancel()
+			err := s.svc.Unfollow(ctx, tc.follower, tc.followee)
+			assert.NoError(t, err)
+			tc.after(t)
+		})
+	}
+}
+
+func (s *FollowTestSuite) TestList() {
+	t := s.T()
+	// 2、3、4 关注了 1，1 关注了 2，5 取消了对 1 的关注
+	for _, follower := range []int64{2, 3, 4} {
+		s.insertRelation(t, follower, 1, dao.FollowRelationStatusActive)
+	}
+	s.insertRelation(t, 1, 2, dao.FollowRelationStatusActive)
+	s.insertRelation(t, 5, 1, dao.FollowRelationStatusInactive)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	followers, err := s.svc.ListFollowers(ctx, 1, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 3}, s.followers(followers))
+
+	followers, err = s.svc.ListFollowers(ctx, 1, followers[1].ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, s.followers(followers))
+
+	followees, err := s.svc.ListFollowees(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, followees, 1)
+	assert.Equal(t, int64(2), followees[0].Followee)
+}
+
+func (s *FollowTestSuite) insertRelation(t *testing.T, follower, followee int64, status uint8) {
+	now := time.Now().UnixMilli()
+	err := s.db.Create(&dao.FollowRelation{
+		Follower: follower,
+		Followee: followee,
+		Status:   status,
+		CreateAt: now,
+		UpdateAt: now,
+	}).Error
+	require.NoError(t, err)
+}
+
+func (s *FollowTestSuite) insertStatics(t *testing.T, statics dao.FollowStatics) {
+	now := time.Now().UnixMilli()
+	statics.CreateAt, statics.UpdateAt = now, now
+	err := s.db.Create(&statics).Error
+	require.NoError(t, err)
+}
+
+func (s *FollowTestSuite) assertRelation(t *testing.T, follower, followee int64, status uint8) {
+	var fr dao.FollowRelation
+	err := s.db.Where("follower = ? AND followee = ?", follower, followee).First(&fr).Error
+	require.NoError(t, err)
+	assert.Equal(t, status, fr.Status)
+}
+
+func (s *FollowTestSuite) assertStatics(t *testing.T, uid int64, want domain.FollowStatics) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	var statics dao.FollowStatics
+	err := s.db.Where("uid = ?", uid).First(&statics).Error
+	require.NoError(t, err)
+	assert.Equal(t, want, domain.FollowStatics{Followers: statics.Followers, Followees: statics.Followees})
+
+	res, err := s.svc.GetStatics(ctx, uid)
+	require.NoError(t, err)
+	assert.Equal(t, want, res)
+}
+
+func (s *FollowTestSuite) followers(relations []domain.FollowRelation) []int64 {
+	res := make([]int64, 0, len(relations))
+	for _, fr := range relations {
+		res = append(res, fr.Follower)
+	}
+	return res
+}
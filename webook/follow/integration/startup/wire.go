@@ -0,0 +1,33 @@
+//go:build wireinject
+
+package startup
+
+import (
+	"github.com/google/wire"
+
+	"geektime-basic-go/webook/follow/repository"
+	"geektime-basic-go/webook/follow/repository/cache"
+	"geektime-basic-go/webook/follow/repository/dao"
+	"geektime-basic-go/webook/follow/service"
+)
+
+var thirdProvider = wire.NewSet(
+	InitDB,
+	InitLog,
+	InitRedis,
+)
+
+var followSvcProvider = wire.NewSet(
+	service.NewFollowService,
+	repository.NewCachedFollowRepository,
+	dao.NewFollowDAO,
+	cache.NewRedisFollowCache,
+)
+
+func InitFollowService() service.FollowService {
+	wire.Build(
+		thirdProvider,
+		followSvcProvider,
+	)
+	return service.NewFollowService(nil)
+}
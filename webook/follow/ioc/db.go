@@ -0,0 +1,50 @@
+package ioc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
+
+	"geektime-basic-go/webook/follow/repository/dao"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func InitDB(l logger.Logger) *gorm.DB {
+	cfg := struct {
+		DSN string `yaml:"dsn"`
+	}{}
+	if err := viper.UnmarshalKey("db.mysql", &cfg); err != nil {
+		panic(err)
+	}
+	db, err := gorm.Open(mysql.Open(cfg.DSN), &gorm.Config{
+		//慢查询日志
+		Logger: glogger.New(gormLoggerFunc(l.Warn), glogger.Config{
+			SlowThreshold:        50 * time.Millisecond,
+			LogLevel:             glogger.Warn,
+			ParameterizedQueries: true,
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if err = db.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+		panic(err)
+	}
+
+	if err = dao.InitTables(db); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+type gormLoggerFunc func(msg string, fields ...any)
+
+func (g gormLoggerFunc) Printf(msg string, args ...any) {
+	g("GORM LOG", logger.String("args", fmt.Sprintf(msg, args...)))
+}
@@ -0,0 +1,32 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+
+	fgrpc "geektime-basic-go/webook/follow/grpc"
+	"geektime-basic-go/webook/pkg/grpcx"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func InitGRPCxServer(follow *fgrpc.FollowServiceServer, l logger.Logger) *grpcx.Server {
+	type Config struct {
+		Port    int   `yaml:"port"`
+		EtcdTTL int64 `yaml:"etcdTTL"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("grpc.server", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer()
+	follow.Register(server)
+	return &grpcx.Server{
+		Server:   server,
+		Port:     cfg.Port,
+		Name:     "follow",
+		L:        l,
+		EtcdTTL:  cfg.EtcdTTL,
+		EtcdAddr: viper.GetString("etcd.addr"),
+	}
+}
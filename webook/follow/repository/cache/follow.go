@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"geektime-basic-go/webook/follow/domain"
+)
+
+type FollowCache interface {
+	GetStatics(ctx context.Context, uid int64) (domain.FollowStatics, error)
+	SetStatics(ctx context.Context, uid int64, statics domain.FollowStatics) error
+	// Follow follower 的关注数和 followee 的粉丝数加一，缓存不存在就不处理
+	Follow(ctx context.Context, follower, followee int64) error
+	// Unfollow 和 Follow 相反
+	Unfollow(ctx context.Context, follower, followee int64) error
+}
+
+const (
+	fieldFollowers = "followers"
+	fieldFollowees = "followees"
+)
+
+//go:embed lua/incr_cnt.lua
+var luaIncrCnt string
+
+// ErrKeyNotExist 因为我们目前还是只有一个实现，所以可以保持用别名
+var ErrKeyNotExist = redis.Nil
+
+type redisFollowCache struct {
+	client     redis.Cmdable
+	expiration time.Duration
+}
+
+func NewRedisFollowCache(client redis.Cmdable) FollowCache {
+	return &redisFollowCache{client: client, expiration: 15 * time.Minute}
+}
+
+func (cache *redisFollowCache) GetStatics(ctx context.Context, uid int64) (domain.FollowStatics, error) {
+	data, err := cache.client.HGetAll(ctx, cache.staticsKey(uid)).Result()
+	if err != nil {
+		return domain.FollowStatics{}, err
+	}
+	if len(data) == 0 {
+		return domain.FollowStatics{}, ErrKeyNotExist
+	}
+
+	followers, _ := strconv.ParseInt(data[fieldFollowers], 10, 64)
+	followees, _ := strconv.ParseInt(data[fieldFollowees], 10, 64)
+	return domain.FollowStatics{Followers: followers, Followees: followees}, nil
+}
+
+func (cache *redisFollowCache) SetStatics(ctx context.Context, uid int64, statics domain.FollowStatics) error {
+	key := cache.staticsKey(uid)
+	err := cache.client.HSet(ctx, key,
+		fieldFollowers, statics.Followers,
+		fieldFollowees, statics.Followees,
+	).Err()
+	if err != nil {
+		return err
+	}
+	return cache.client.Expire(ctx, key, cache.expiration).Err()
+}
+
+func (cache *redisFollowCache) Follow(ctx context.Context, follower, followee int64) error {
+	return cache.incr(ctx, follower, followee, 1)
+}
+
+func (cache *redisFollowCache) Unfollow(ctx context.Context, follower, followee int64) error {
+	return cache.incr(ctx, follower, followee, -1)
+}
+
+func (cache *redisFollowCache) incr(ctx context.Context, follower, followee int64, delta int64) error {
+	pipe := cache.client.Pipeline()
+	pipe.Eval(ctx, luaIncrCnt, []string{cache.staticsKey(follower)}, fieldFollowees, delta)
+	pipe.Eval(ctx, luaIncrCnt, []string{cache.staticsKey(followee)}, fieldFollowers, delta)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (cache *redisFollowCache) staticsKey(uid int64) string {
+	return fmt.Sprintf("follow:statics:%d", uid)
+}
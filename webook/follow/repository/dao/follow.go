@@ -0,0 +1,146 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	FollowRelationStatusUnknown uint8 = iota
+	FollowRelationStatusActive
+	FollowRelationStatusInactive
+)
+
+type FollowDAO interface {
+	// Follow 返回关注关系是否发生了变化，已经关注过返回 false
+	Follow(ctx context.Context, follower, followee int64) (bool, error)
+	// Unfollow 返回关注关系是否发生了变化，本来就没有关注返回 false
+	Unfollow(ctx context.Context, follower, followee int64) (bool, error)
+	FollowerList(ctx context.Context, followee int64, minID int64, limit int) ([]FollowRelation, error)
+	FolloweeList(ctx context.Context, follower int64, minID int64, limit int) ([]FollowRelation, error)
+	GetStatics(ctx context.Context, uid int64) (FollowStatics, error)
+}
+
+// FollowRelation 取消关注只是把状态改为 FollowRelationStatusInactive
+type FollowRelation struct {
+	ID       int64 `gorm:"primaryKey,autoIncrement"`
+	Follower int64 `gorm:"uniqueIndex:follower_followee"`
+	Followee int64 `gorm:"uniqueIndex:follower_followee;index"`
+	Status   uint8
+	CreateAt int64
+	UpdateAt int64
+}
+
+// FollowStatics 冗余的计数，和关注关系在同一个事务里面更新
+type FollowStatics struct {
+	ID        int64 `gorm:"primaryKey,autoIncrement"`
+	Uid       int64 `gorm:"unique"`
+	Followers int64
+	Followees int64
+	CreateAt  int64
+	UpdateAt  int64
+}
+
+type gormFollowDAO struct {
+	db *gorm.DB
+}
+
+func NewFollowDAO(db *gorm.DB) FollowDAO {
+	return &gormFollowDAO{db: db}
+}
+
+func (dao *gormFollowDAO) Follow(ctx context.Context, follower, followee int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		// 先尝试恢复之前取消的关注
+		res := tx.Model(&FollowRelation{}).
+			Where("follower = ? AND followee = ? AND status = ?", follower, followee, FollowRelationStatusInactive).
+			Updates(map[string]any{"status": FollowRelationStatusActive, "update_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&FollowRelation{
+				Follower: follower,
+				Followee: followee,
+				Status:   FollowRelationStatusActive,
+				CreateAt: now,
+				UpdateAt: now,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		// 已经关注过了
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		changed = true
+		return dao.incrStatics(tx, follower, followee, 1, now)
+	})
+	return changed, err
+}
+
+func (dao *gormFollowDAO) Unfollow(ctx context.Context, follower, followee int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		res := tx.Model(&FollowRelation{}).
+			Where("follower = ? AND followee = ? AND status = ?", follower, followee, FollowRelationStatusActive).
+			Updates(map[string]any{"status": FollowRelationStatusInactive, "update_at": now})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		changed = true
+		return dao.incrStatics(tx, follower, followee, -1, now)
+	})
+	return changed, err
+}
+
+// incrStatics follower 的关注数和 followee 的粉丝数同时加上 delta
+func (dao *gormFollowDAO) incrStatics(tx *gorm.DB, follower, followee int64, delta int64, now int64) error {
+	err := tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]any{
+			"followees": gorm.Expr("`followees` + ?", delta),
+			"update_at": now,
+		}),
+	}).Create(&FollowStatics{Uid: follower, Followees: max(delta, 0), CreateAt: now, UpdateAt: now}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]any{
+			"followers": gorm.Expr("`followers` + ?", delta),
+			"update_at": now,
+		}),
+	}).Create(&FollowStatics{Uid: followee, Followers: max(delta, 0), CreateAt: now, UpdateAt: now}).Error
+}
+
+func (dao *gormFollowDAO) FollowerList(ctx context.Context, followee int64, minID int64, limit int) ([]FollowRelation, error) {
+	query := dao.db.WithContext(ctx).Where("followee = ? AND status = ?", followee, FollowRelationStatusActive)
+	return dao.list(query, minID, limit)
+}
+
+func (dao *gormFollowDAO) FolloweeList(ctx context.Context, follower int64, minID int64, limit int) ([]FollowRelation, error) {
+	query := dao.db.WithContext(ctx).Where("follower = ? AND status = ?", follower, FollowRelationStatusActive)
+	return dao.list(query, minID, limit)
+}
+
+func (dao *gormFollowDAO) list(query *gorm.DB, minID int64, limit int) ([]FollowRelation, error) {
+	if minID > 0 {
+		query = query.Where("id < ?", minID)
+	}
+	var res []FollowRelation
+	err := query.Order("id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *gormFollowDAO) GetStatics(ctx context.Context, uid int64) (FollowStatics, error) {
+	var res FollowStatics
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).First(&res).Error
+	return res, err
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+
+	"geektime-basic-go/webook/follow/domain"
+	"geektime-basic-go/webook/follow/repository/cache"
+	"geektime-basic-go/webook/follow/repository/dao"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+type FollowRepository interface {
+	Follow(ctx context.Context, follower, followee int64) error
+	Unfollow(ctx context.Context, follower, followee int64) error
+	FollowerList(ctx context.Context, followee int64, minID int64, limit int) ([]domain.FollowRelation, error)
+	FolloweeList(ctx context.Context, follower int64, minID int64, limit int) ([]domain.FollowRelation, error)
+	GetStatics(ctx context.Context, uid int64) (domain.FollowStatics, error)
+}
+
+type cachedFollowRepository struct {
+	dao   dao.FollowDAO
+	cache cache.FollowCache
+	l     logger.Logger
+}
+
+func NewCachedFollowRepository(dao dao.FollowDAO, cache cache.FollowCache, l logger.Logger) FollowRepository {
+	return &cachedFollowRepository{dao: dao, cache: cache, l: l}
+}
+
+// Follow 数据库已经提交了，更新缓存失败只记录日志，返回错误会让调用方以为关注失败了
+func (repo *cachedFollowRepository) Follow(ctx context.Context, follower, followee int64) error {
+	changed, err := repo.dao.Follow(ctx, follower, followee)
+	if err != nil || !changed {
+		return err
+	}
+	if err = repo.cache.Follow(ctx, follower, followee); err != nil {
+		repo.l.Error("更新关注数据缓存失败", logger.Int("follower", follower),
+			logger.Int("followee", followee), logger.Error(err))
+	}
+	return nil
+}
+
+// Unfollow 和 Follow 一样，缓存失败只记录日志
+func (repo *cachedFollowRepository) Unfollow(ctx context.Context, follower, followee int64) error {
+	changed, err := repo.dao.Unfollow(ctx, follower, followee)
+	if err != nil || !changed {
+		return err
+	}
+	if err = repo.cache.Unfollow(ctx, follower, followee); err != nil {
+		repo.l.Error("更新关注数据缓存失败", logger.Int("follower", follower),
+			logger.Int("followee", followee), logger.Error(err))
+	}
+	return nil
+}
+
+func (repo *cachedFollowRepository) FollowerList(ctx context.Context, followee int64, minID int64, limit int) ([]domain.FollowRelation, error) {
+	res, err := repo.dao.FollowerList(ctx, followee, minID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(res, func(idx int, src dao.FollowRelation) domain.FollowRelation {
+		return repo.toDomain(src)
+	}), nil
+}
+
+func (repo *cachedFollowRepository) FolloweeList(ctx context.Context, follower int64, minID int64, limit int) ([]domain.FollowRelation, error) {
+	res, err := repo.dao.FolloweeList(ctx, follower, minID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(res, func(idx int, src dao.FollowRelation) domain.FollowRelation {
+		return repo.toDomain(src)
+	}), nil
+}
+
+func (repo *cachedFollowRepository) GetStatics(ctx context.Context, uid int64) (domain.FollowStatics, error) {
+	res, err := repo.cache.GetStatics(ctx, uid)
+	if err == nil {
+		return res, nil
+	}
+
+	statics, err := repo.dao.GetStatics(ctx, uid)
+	switch {
+	case errors.Is(err, dao.ErrDataNotFound):
+		// 没有关注过别人，也没有被别人关注过
+	case err != nil:
+		return domain.FollowStatics{}, err
+	default:
+		res = domain.FollowStatics{Followers: statics.Followers, Followees: statics.Followees}
+	}
+
+	if err = repo.cache.SetStatics(ctx, uid, res); err != nil {
+		repo.l.Error("回写关注数据缓存失败", logger.Int("uid", uid), logger.Error(err))
+	}
+	return res, nil
+}
+
+func (repo *cachedFollowRepository) toDomain(fr dao.FollowRelation) domain.FollowRelation {
+	return domain.FollowRelation{
+		ID:       fr.ID,
+		Follower: fr.Follower,
+		Followee: fr.Followee,
+		CreateAt: time.UnixMilli(fr.CreateAt),
+	}
+}
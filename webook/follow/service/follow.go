@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"geektime-basic-go/webook/follow/domain"
+	"geektime-basic-go/webook/follow/repository"
+)
+
+var ErrFollowSelf = errors.New("不能关注自己")
+
+//go:generate mockgen -source=follow.go -package=svcmocks -destination=mocks/follow_mock_gen.go FollowService
+type FollowService interface {
+	Follow(ctx context.Context, follower, followee int64) error
+	Unfollow(ctx context.Context, follower, followee int64) error
+	ListFollowers(ctx context.Context, followee int64, minID int64, limit int) ([]domain.FollowRelation, error)
+	ListFollowees(ctx context.Context, follower int64, minID int64, limit int) ([]domain.FollowRelation, error)
+	GetStatics(ctx context.Context, uid int64) (domain.FollowStatics, error)
+}
+
+type followService struct {
+	repo repository.FollowRepository
+}
+
+func NewFollowService(repo repository.FollowRepository) FollowService {
+	return &followService{repo: repo}
+}
+
+func (svc *followService) Follow(ctx context.Context, follower, followee int64) error {
+	if follower == followee {
+		return ErrFollowSelf
+	}
+	return svc.repo.Follow(ctx, follower, followee)
+}
+
+func (svc *followService) Unfollow(ctx context.Context, follower, followee int64) error {
+	return svc.repo.Unfollow(ctx, follower, followee)
+}
+
+func (svc *followService) ListFollowers(ctx context.Context, followee int64, minID int64, limit int) ([]domain.FollowRelation, error) {
+	return svc.repo.FollowerList(ctx, followee, minID, limit)
+}
+
+func (svc *followService) ListFollowees(ctx context.Context, follower int64, minID int64, limit int) ([]domain.FollowRelation, error) {
+	return svc.repo.FolloweeList(ctx, follower, minID, limit)
+}
+
+func (svc *followService) GetStatics(ctx context.Context, uid int64) (domain.FollowStatics, error) {
+	return svc.repo.GetStatics(ctx, uid)
+}
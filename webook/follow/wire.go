@@ -0,0 +1,39 @@
+//go:build wireinject
+
+package main
+
+import (
+	"github.com/google/wire"
+
+	"geektime-basic-go/webook/follow/grpc"
+	"geektime-basic-go/webook/follow/ioc"
+	"geektime-basic-go/webook/follow/repository"
+	"geektime-basic-go/webook/follow/repository/cache"
+	"geektime-basic-go/webook/follow/repository/dao"
+	"geektime-basic-go/webook/follow/service"
+)
+
+var followSvcProvider = wire.NewSet(
+	service.NewFollowService,
+	repository.NewCachedFollowRepository,
+	dao.NewFollowDAO,
+	cache.NewRedisFollowCache,
+)
+
+var thirdProvider = wire.NewSet(
+	ioc.InitDB,
+	ioc.InitRedis,
+	ioc.InitZapLogger,
+)
+
+func Init() *App {
+	wire.Build(
+		thirdProvider,
+		followSvcProvider,
+
+		grpc.NewFollowServiceServer,
+		ioc.InitGRPCxServer,
+		wire.Struct(new(App), "*"),
+	)
+	return new(App)
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Collection 收藏夹
+type Collection struct {
+	ID   int64
+	Uid  int64
+	Name string
+	// Public 公开的收藏夹别人也可以浏览，默认收藏夹总是私有的
+	Public   bool
+	CreateAt time.Time
+	UpdateAt time.Time
+}
+
+// CollectionItem 收藏夹里的一条收藏，Cid 为 0 代表默认收藏夹
+type CollectionItem struct {
+	ID       int64
+	Cid      int64
+	Biz      string
+	BizID    int64
+	Uid      int64
+	CreateAt time.Time
+}
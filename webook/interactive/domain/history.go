@@ -1,8 +1,13 @@
 package domain
 
+import "time"
+
 type HistoryRecord struct {
+	ID int64
 	// 考虑到历史记录可以支持不同的类型，例如视频之类的，这里也沿用 biz 和 bizId 的设计
 	Biz   string
 	BizID int64
 	Uid   int64
+	// ReadAt 最近一次阅读的时间，同一个用户重复阅读同一个资源只保留一条记录
+	ReadAt time.Time
 }
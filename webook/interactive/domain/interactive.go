@@ -6,6 +6,25 @@ type Interactive struct {
 	ReadCnt    int64  `json:"read_cnt"`
 	LikeCnt    int64  `json:"like_cnt"`
 	CollectCnt int64  `json:"collect_cnt"`
+	CommentCnt int64  `json:"comment_cnt"`
 	Liked      bool   `json:"liked"`
 	Collected  bool   `json:"collected"`
 }
+
+// LikeChange 一次点赞或者取消点赞
+type LikeChange struct {
+	// Key 事件的唯一标识，同一个 Key 只会处理一次，为空的不去重
+	Key   string
+	BizID int64
+	Uid   int64
+	Liked bool
+}
+
+// CommentCntChange 评论数的一次变化，删除评论的时候 Delta 是负数
+type CommentCntChange struct {
+	// Key 和 LikeChange 的一样，为空的不去重
+	Key   string
+	Biz   string
+	BizID int64
+	Delta int64
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/IBM/sarama"
 
+	"geektime-basic-go/webook/interactive/domain"
 	"geektime-basic-go/webook/interactive/events"
 	"geektime-basic-go/webook/interactive/repository"
 	"geektime-basic-go/webook/pkg/logger"
@@ -22,58 +23,47 @@ type ReadEvent struct {
 var _ events.Consumer = (*InteractiveReadEventConsumer)(nil)
 
 type InteractiveReadEventConsumer struct {
-	client sarama.Client
-	repo   repository.InteractiveRepository
-	l      logger.Logger
+	*saramax.Consumer[ReadEvent]
+	repo    repository.InteractiveRepository
+	history repository.HistoryRecordRepository
 }
 
-func NewInteractiveReadEventConsumer(client sarama.Client, repo repository.InteractiveRepository, l logger.Logger) *InteractiveReadEventConsumer {
-	return &InteractiveReadEventConsumer{client: client, repo: repo, l: l}
+func NewInteractiveReadEventConsumer(client sarama.Client, repo repository.InteractiveRepository,
+	history repository.HistoryRecordRepository, l logger.Logger, dl *saramax.DeadLetter) *InteractiveReadEventConsumer {
+	c := &InteractiveReadEventConsumer{repo: repo, history: history}
+	c.Consumer = saramax.NewConsumer[ReadEvent](client, l, c.BatchConsume,
+		saramax.WithGroupID("interactive"),
+		saramax.WithTopics(topicReadEvent),
+		saramax.WithBatchSize(20),
+		saramax.WithDeadLetter(dl))
+	return c
 }
 
-func (c *InteractiveReadEventConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("interactive", c.client)
-	if err != nil {
-		c.l.Error("获取消费者组失败", logger.Error(err))
-		return err
-	}
-	go func() {
-		err = cg.Consume(context.Background(), []string{topicReadEvent}, saramax.NewHandler[ReadEvent](c.l, c.Consume))
-		if err != nil {
-			c.l.Error("退出消费者循环异常", logger.Error(err))
-		}
-	}()
-	return err
-}
-
-func (c *InteractiveReadEventConsumer) StartBatch() error {
-	cg, err := sarama.NewConsumerGroupFromClient("interactive", c.client)
-	if err != nil {
-		c.l.Error("获取消费者组失败", logger.Error(err))
-	}
-	go func() {
-		err = cg.Consume(context.Background(), []string{topicReadEvent}, saramax.NewBatchHandler[ReadEvent](c.l, c.BatchConsume))
-		if err != nil {
-			c.l.Error("退出消费者循环异常", logger.Error(err))
-		}
-	}()
-	return err
-}
-
-func (c *InteractiveReadEventConsumer) Consume(msg *sarama.ConsumerMessage, evt ReadEvent) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	return c.repo.IncrReadCnt(ctx, "article", evt.Aid)
-}
-
-func (c *InteractiveReadEventConsumer) BatchConsume(msgs []*sarama.ConsumerMessage, evts []ReadEvent) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+// BatchConsume 用事件 ID 去重，整批重试或者 outbox 重新发送的消息不会重复计数，阅读记录本身就是幂等的
+func (c *InteractiveReadEventConsumer) BatchConsume(ctx context.Context, msgs []*sarama.ConsumerMessage, evts []ReadEvent) error {
+	keys := make([]string, len(msgs))
 	bizs := make([]string, len(msgs))
 	ids := make([]int64, len(msgs))
+	records := make([]domain.HistoryRecord, 0, len(msgs))
 	for i := range evts {
+		keys[i] = saramax.EventKey(msgs[i])
 		bizs[i] = "article"
-		ids[i] = evts[i].Uid
+		ids[i] = evts[i].Aid
+		if evts[i].Uid > 0 {
+			records = append(records, c.toHistoryRecord(msgs[i], evts[i]))
+		}
+	}
+	if err := c.repo.BatchIncrReadCnt(ctx, keys, bizs, ids); err != nil {
+		return err
+	}
+	return c.history.BatchAddRecord(ctx, records)
+}
+
+// toHistoryRecord 阅读时间使用消息的时间戳，而不是消费的时间
+func (c *InteractiveReadEventConsumer) toHistoryRecord(msg *sarama.ConsumerMessage, evt ReadEvent) domain.HistoryRecord {
+	readAt := msg.Timestamp
+	if readAt.IsZero() {
+		readAt = time.Now()
 	}
-	return c.repo.BatchIncrReadCnt(ctx, bizs, ids)
+	return domain.HistoryRecord{Biz: "article", BizID: evt.Aid, Uid: evt.Uid, ReadAt: readAt}
 }
@@ -0,0 +1,65 @@
+package article
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"geektime-basic-go/webook/pkg/outbox"
+)
+
+const topicChangeCollect = "article_change_collect_event"
+
+// ChangeCollectEvent 收藏或者取消收藏之后发出，Collected 是变更之后的状态
+type ChangeCollectEvent struct {
+	Biz       string
+	BizID     int64
+	Uid       int64
+	Cid       int64
+	Collected bool
+}
+
+type ChangeCollectProducer interface {
+	ProduceChangeCollectEvent(ctx context.Context, evt ChangeCollectEvent) error
+}
+
+type changeCollectSaramaSyncProducer struct {
+	producer sarama.SyncProducer
+}
+
+func NewChangeCollectSaramaSyncProducer(producer sarama.SyncProducer) ChangeCollectProducer {
+	return &changeCollectSaramaSyncProducer{producer: producer}
+}
+
+func (p *changeCollectSaramaSyncProducer) ProduceChangeCollectEvent(ctx context.Context, evt ChangeCollectEvent) error {
+	tracer := otel.GetTracerProvider().Tracer("webook/interactive/events/article/change_collect")
+	_, span := tracer.Start(ctx, "produceChangeCollectEvent", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: topicChangeCollect,
+		Value: sarama.ByteEncoder(val),
+	})
+	return err
+}
+
+type changeCollectOutboxProducer struct {
+	store *outbox.Store
+}
+
+// NewChangeCollectOutboxProducer 先写到 outbox 表，由 outbox.Relay 发送
+func NewChangeCollectOutboxProducer(store *outbox.Store) ChangeCollectProducer {
+	return &changeCollectOutboxProducer{store: store}
+}
+
+func (p *changeCollectOutboxProducer) ProduceChangeCollectEvent(ctx context.Context, evt ChangeCollectEvent) error {
+	return p.store.Save(ctx, topicChangeCollect, fmt.Sprintf("%s:%d:%d", evt.Biz, evt.BizID, evt.Uid), evt)
+}
@@ -3,20 +3,26 @@ package article
 import (
 	"context"
 	"encoding/json"
-	"time"
+	"fmt"
 
 	"github.com/IBM/sarama"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/sync/errgroup"
 
+	"geektime-basic-go/webook/interactive/domain"
 	"geektime-basic-go/webook/interactive/events"
 	"geektime-basic-go/webook/interactive/repository"
+	"geektime-basic-go/webook/pkg/gormx"
 	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/outbox"
 	"geektime-basic-go/webook/pkg/saramax"
 )
 
-const topicChangeLike = "article_change_like_event"
+const (
+	topicChangeLike = "article_change_like_event"
+	// topicLikeChanged 点赞状态真正发生变化之后发出，重复点赞、没有点赞过的取消点赞都不会发
+	topicLikeChanged = "article_like_changed_event"
+)
 
 type ChangeLikeEvent struct {
 	BizID int64
@@ -24,6 +30,11 @@ type ChangeLikeEvent struct {
 	Liked bool
 }
 
+// key 同一个用户对同一篇文章的点赞和取消点赞发到同一个分区，保证顺序
+func (evt ChangeLikeEvent) key() string {
+	return fmt.Sprintf("%d:%d", evt.BizID, evt.Uid)
+}
+
 type ChangeLikeProducer interface {
 	ProduceChangeLikeEvent(ctx context.Context, evt ChangeLikeEvent) error
 }
@@ -46,89 +57,103 @@ func (p *changeLikeSaramaSyncProducer) ProduceChangeLikeEvent(ctx context.Contex
 	}
 
 	_, _, err = p.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: topicChangeLike,
-		Value: sarama.ByteEncoder(val),
+		Topic:   topicChangeLike,
+		Key:     sarama.StringEncoder(evt.key()),
+		Value:   sarama.ByteEncoder(val),
+		Headers: []sarama.RecordHeader{saramax.EventIDHeader("")},
 	})
 	return err
 }
 
-var _ events.Consumer = (*ChangeLikeEventConsumer)(nil)
+type changeLikeOutboxProducer struct {
+	store *outbox.Store
+}
 
-type ChangeLikeEventConsumer struct {
-	client sarama.Client
-	repo   repository.InteractiveRepository
-	l      logger.Logger
+// NewChangeLikeOutboxProducer 先写到 outbox 表，由 outbox.Relay 发送
+func NewChangeLikeOutboxProducer(store *outbox.Store) ChangeLikeProducer {
+	return &changeLikeOutboxProducer{store: store}
 }
 
-func NewInteractiveLikeEventConsumer(client sarama.Client, repo repository.InteractiveRepository, l logger.Logger) *ChangeLikeEventConsumer {
-	return &ChangeLikeEventConsumer{client: client, repo: repo, l: l}
+func (p *changeLikeOutboxProducer) ProduceChangeLikeEvent(ctx context.Context, evt ChangeLikeEvent) error {
+	return p.store.Save(ctx, topicChangeLike, evt.key(), evt)
 }
 
-func (c *ChangeLikeEventConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("change_like", c.client)
-	if err != nil {
-		c.l.Error("获取消费者组失败", logger.Error(err))
-	}
-	go func() {
-		err = cg.Consume(context.Background(), []string{topicChangeLike}, saramax.NewHandler[ChangeLikeEvent](c.l, c.Consume))
-		if err != nil {
-			c.l.Error("退出消费者循环异常", logger.Error(err))
-		}
-	}()
-	return err
+// LikeChangedEvent 点赞状态变化之后发出，Liked 是变更之后的状态
+type LikeChangedEvent struct {
+	BizID int64
+	Uid   int64
+	Liked bool
 }
 
-func (c *ChangeLikeEventConsumer) StartBatch() error {
-	cg, err := sarama.NewConsumerGroupFromClient("change_like", c.client)
-	if err != nil {
-		c.l.Error("获取消费者组失败", logger.Error(err))
-	}
-	go func() {
-		err = cg.Consume(context.Background(), []string{topicChangeLike}, saramax.NewBatchHandler[ChangeLikeEvent](c.l, c.BatchConsume).SetConsumerOffsetGauge())
-		if err != nil {
-			c.l.Error("退出消费者循环异常", logger.Error(err))
-		}
-	}()
-	return err
+type LikeChangedProducer interface {
+	ProduceLikeChangedEvent(ctx context.Context, evt LikeChangedEvent) error
 }
 
-func (c *ChangeLikeEventConsumer) Consume(msg *sarama.ConsumerMessage, evt ChangeLikeEvent) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+type likeChangedOutboxProducer struct {
+	store *outbox.Store
+}
 
-	if evt.Liked {
-		return c.repo.IncrLike(ctx, "article", evt.BizID, evt.Uid)
-	}
-	return c.repo.DecrLike(ctx, "article", evt.BizID, evt.Uid)
-}
-
-func (c *ChangeLikeEventConsumer) BatchConsume(msgs []*sarama.ConsumerMessage, evts []ChangeLikeEvent) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	likeAids := make([]int64, 0, len(msgs))
-	likeUids := make([]int64, 0, len(msgs))
-	unlikeAids := make([]int64, 0, len(msgs))
-	unlikeUids := make([]int64, 0, len(msgs))
-
-	for _, evt := range evts {
-		if evt.Liked {
-			likeAids = append(likeAids, evt.BizID)
-			likeUids = append(likeUids, evt.Uid)
-			continue
-		}
+// NewLikeChangedOutboxProducer 和点赞状态的变更在同一个事务里写到 outbox 表，由 outbox.Relay 发送
+func NewLikeChangedOutboxProducer(store *outbox.Store) LikeChangedProducer {
+	return &likeChangedOutboxProducer{store: store}
+}
 
-		unlikeAids = append(unlikeAids, evt.BizID)
-		unlikeUids = append(unlikeUids, evt.Uid)
-	}
+func (p *likeChangedOutboxProducer) ProduceLikeChangedEvent(ctx context.Context, evt LikeChangedEvent) error {
+	return p.store.Save(ctx, topicLikeChanged, fmt.Sprintf("%d:%d", evt.BizID, evt.Uid), evt)
+}
 
-	var eg errgroup.Group
-	eg.Go(func() error {
-		return c.repo.BatchIncrLike(ctx, "article", likeAids, likeUids)
-	})
+var _ events.Consumer = (*ChangeLikeEventConsumer)(nil)
 
-	eg.Go(func() error {
-		return c.repo.BatchDecrLike(ctx, "article", unlikeAids, unlikeUids)
+type ChangeLikeEventConsumer struct {
+	*saramax.Consumer[ChangeLikeEvent]
+	repo     repository.InteractiveRepository
+	tx       gormx.Transactor
+	producer LikeChangedProducer
+}
+
+// NewInteractiveLikeEventConsumer 同一个用户对同一篇文章的消息 key 相同，
+// 按照 key 拆分并发处理，点赞和取消点赞的顺序不会乱
+func NewInteractiveLikeEventConsumer(client sarama.Client, repo repository.InteractiveRepository, tx gormx.Transactor,
+	producer LikeChangedProducer, l logger.Logger, dl *saramax.DeadLetter) *ChangeLikeEventConsumer {
+	c := &ChangeLikeEventConsumer{repo: repo, tx: tx, producer: producer}
+	c.Consumer = saramax.NewConsumer[ChangeLikeEvent](client, l, c.BatchConsume,
+		saramax.WithGroupID("change_like"),
+		saramax.WithTopics(topicChangeLike),
+		saramax.WithBatchSize(20),
+		saramax.WithConcurrency(4),
+		saramax.WithOrderedByKey(),
+		saramax.WithDeadLetter(dl))
+	return c
+}
+
+func (c *ChangeLikeEventConsumer) BatchConsume(ctx context.Context, msgs []*sarama.ConsumerMessage, evts []ChangeLikeEvent) error {
+	// 点赞和取消点赞要按照消息的顺序处理，不能拆开并发执行
+	changes := make([]domain.LikeChange, 0, len(evts))
+	for idx, evt := range evts {
+		changes = append(changes, c.toLikeChange(msgs[idx], evt))
+	}
+	// 点赞状态的变更和 LikeChangedEvent 在同一个事务里，热榜之类的下游只看真正的状态变化
+	return c.tx.Transaction(ctx, func(ctx context.Context) error {
+		applied, err := c.repo.ChangeLikes(ctx, "article", changes)
+		if err != nil {
+			return err
+		}
+		for _, ch := range applied {
+			err = c.producer.ProduceLikeChangedEvent(ctx, LikeChangedEvent{BizID: ch.BizID, Uid: ch.Uid, Liked: ch.Liked})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+}
 
-	return eg.Wait()
+// toLikeChange 用事件 ID 作为去重的 Key，outbox 重新发送的消息位置变了，事件 ID 不变
+func (c *ChangeLikeEventConsumer) toLikeChange(msg *sarama.ConsumerMessage, evt ChangeLikeEvent) domain.LikeChange {
+	return domain.LikeChange{
+		Key:   saramax.EventKey(msg),
+		BizID: evt.BizID,
+		Uid:   evt.Uid,
+		Liked: evt.Liked,
+	}
 }
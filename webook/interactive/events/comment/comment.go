@@ -0,0 +1,53 @@
+package comment
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+
+	"geektime-basic-go/webook/interactive/domain"
+	"geektime-basic-go/webook/interactive/events"
+	"geektime-basic-go/webook/interactive/repository"
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/saramax"
+)
+
+const topicCommentCnt = "comment_cnt_event"
+
+// CommentCntEvent 由 comment 服务发出
+type CommentCntEvent struct {
+	Biz   string
+	BizID int64
+	Delta int64
+}
+
+var _ events.Consumer = (*CommentCntEventConsumer)(nil)
+
+type CommentCntEventConsumer struct {
+	*saramax.Consumer[CommentCntEvent]
+	repo repository.InteractiveRepository
+}
+
+func NewCommentCntEventConsumer(client sarama.Client, repo repository.InteractiveRepository, l logger.Logger, dl *saramax.DeadLetter) *CommentCntEventConsumer {
+	c := &CommentCntEventConsumer{repo: repo}
+	c.Consumer = saramax.NewConsumer[CommentCntEvent](client, l, c.BatchConsume,
+		saramax.WithGroupID("comment_cnt"),
+		saramax.WithTopics(topicCommentCnt),
+		saramax.WithBatchSize(20),
+		saramax.WithDeadLetter(dl))
+	return c
+}
+
+// BatchConsume 用事件 ID 去重，整批重试或者重新发送的消息不会重复计数
+func (c *CommentCntEventConsumer) BatchConsume(ctx context.Context, msgs []*sarama.ConsumerMessage, evts []CommentCntEvent) error {
+	changes := make([]domain.CommentCntChange, 0, len(evts))
+	for idx, evt := range evts {
+		changes = append(changes, domain.CommentCntChange{
+			Key:   saramax.EventKey(msgs[idx]),
+			Biz:   evt.Biz,
+			BizID: evt.BizID,
+			Delta: evt.Delta,
+		})
+	}
+	return c.repo.IncrCommentCnts(ctx, changes)
+}
@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecodeclub/ekit/slice"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	intr "geektime-basic-go/webook/api/proto/gen/interactive"
+	"geektime-basic-go/webook/interactive/domain"
+	"geektime-basic-go/webook/interactive/service"
+)
+
+func (i *InteractiveServiceServer) Uncollect(ctx context.Context, request *intr.UncollectRequest) (*intr.UncollectResponse, error) {
+	err := i.svc.Uncollect(ctx, request.GetBiz(), request.GetBizId(), request.GetUid())
+	return &intr.UncollectResponse{}, err
+}
+
+func (i *InteractiveServiceServer) CreateCollection(ctx context.Context, request *intr.CreateCollectionRequest) (*intr.CreateCollectionResponse, error) {
+	cid, err := i.svc.CreateCollection(ctx, domain.Collection{
+		Uid:    request.GetUid(),
+		Name:   request.GetName(),
+		Public: request.GetPublic(),
+	})
+	return &intr.CreateCollectionResponse{Cid: cid}, err
+}
+
+func (i *InteractiveServiceServer) RenameCollection(ctx context.Context, request *intr.RenameCollectionRequest) (*intr.RenameCollectionResponse, error) {
+	err := i.svc.RenameCollection(ctx, request.GetUid(), request.GetCid(), request.GetName())
+	return &intr.RenameCollectionResponse{}, ToStatus(err)
+}
+
+func (i *InteractiveServiceServer) SetCollectionPublic(ctx context.Context, request *intr.SetCollectionPublicRequest) (*intr.SetCollectionPublicResponse, error) {
+	err := i.svc.SetCollectionPublic(ctx, request.GetUid(), request.GetCid(), request.GetPublic())
+	return &intr.SetCollectionPublicResponse{}, ToStatus(err)
+}
+
+func (i *InteractiveServiceServer) DeleteCollection(ctx context.Context, request *intr.DeleteCollectionRequest) (*intr.DeleteCollectionResponse, error) {
+	err := i.svc.DeleteCollection(ctx, request.GetUid(), request.GetCid())
+	return &intr.DeleteCollectionResponse{}, ToStatus(err)
+}
+
+func (i *InteractiveServiceServer) ListCollections(ctx context.Context, request *intr.ListCollectionsRequest) (*intr.ListCollectionsResponse, error) {
+	cs, err := i.svc.ListCollections(ctx, request.GetUid())
+	if err != nil {
+		return &intr.ListCollectionsResponse{}, err
+	}
+	return &intr.ListCollectionsResponse{Collections: i.toCollectionDTOs(cs)}, nil
+}
+
+func (i *InteractiveServiceServer) ListPublicCollections(ctx context.Context, request *intr.ListPublicCollectionsRequest) (*intr.ListPublicCollectionsResponse, error) {
+	cs, err := i.svc.ListPublicCollections(ctx, request.GetUid())
+	if err != nil {
+		return &intr.ListPublicCollectionsResponse{}, err
+	}
+	return &intr.ListPublicCollectionsResponse{Collections: i.toCollectionDTOs(cs)}, nil
+}
+
+func (i *InteractiveServiceServer) ListCollectionItems(ctx context.Context, request *intr.ListCollectionItemsRequest) (*intr.ListCollectionItemsResponse, error) {
+	items, err := i.svc.ListCollectionItems(ctx, request.GetUid(), request.GetCid(), request.GetMaxId(), int(request.GetLimit()))
+	if err != nil {
+		return &intr.ListCollectionItemsResponse{}, ToStatus(err)
+	}
+	return &intr.ListCollectionItemsResponse{Items: slice.Map(items, func(idx int, src domain.CollectionItem) *intr.CollectionItem {
+		return &intr.CollectionItem{
+			Id:       src.ID,
+			Cid:      src.Cid,
+			Biz:      src.Biz,
+			BizId:    src.BizID,
+			Uid:      src.Uid,
+			CreateAt: src.CreateAt.UnixMilli(),
+		}
+	})}, nil
+}
+
+func (i *InteractiveServiceServer) MoveCollectionItem(ctx context.Context, request *intr.MoveCollectionItemRequest) (*intr.MoveCollectionItemResponse, error) {
+	err := i.svc.MoveCollectionItem(ctx, request.GetBiz(), request.GetBizId(), request.GetCid(), request.GetUid())
+	return &intr.MoveCollectionItemResponse{}, ToStatus(err)
+}
+
+func (i *InteractiveServiceServer) toCollectionDTOs(cs []domain.Collection) []*intr.Collection {
+	return slice.Map(cs, func(idx int, src domain.Collection) *intr.Collection {
+		return &intr.Collection{
+			Id:       src.ID,
+			Uid:      src.Uid,
+			Name:     src.Name,
+			Public:   src.Public,
+			CreateAt: src.CreateAt.UnixMilli(),
+			UpdateAt: src.UpdateAt.UnixMilli(),
+		}
+	})
+}
+
+// ToStatus 把业务错误转为 gRPC 的错误码，调用方据此区分。
+// 进程内调用的 LocalRPCAdapter 也用它，保证两条路径返回一样的错误码
+func ToStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrCollectionNotFound), errors.Is(err, service.ErrNotCollected):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrCollectedElsewhere):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,56 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"google.golang.org/grpc"
+
+	historyv1 "geektime-basic-go/webook/api/proto/gen/history"
+	"geektime-basic-go/webook/interactive/domain"
+	"geektime-basic-go/webook/interactive/service"
+)
+
+type HistoryServiceServer struct {
+	historyv1.UnimplementedHistoryServiceServer
+	svc service.HistoryService
+}
+
+func NewHistoryServiceServer(svc service.HistoryService) *HistoryServiceServer {
+	return &HistoryServiceServer{svc: svc}
+}
+
+func (h *HistoryServiceServer) Register(server grpc.ServiceRegistrar) {
+	historyv1.RegisterHistoryServiceServer(server, h)
+}
+
+func (h *HistoryServiceServer) ListHistory(ctx context.Context, request *historyv1.ListHistoryRequest) (*historyv1.ListHistoryResponse, error) {
+	var cursor domain.HistoryRecord
+	if request.GetMaxReadAt() > 0 {
+		cursor = domain.HistoryRecord{ID: request.GetMaxId(), ReadAt: time.UnixMilli(request.GetMaxReadAt())}
+	}
+	records, err := h.svc.List(ctx, request.GetUid(), cursor, int(request.GetLimit()))
+	if err != nil {
+		return &historyv1.ListHistoryResponse{}, err
+	}
+	return &historyv1.ListHistoryResponse{Records: slice.Map(records, func(idx int, src domain.HistoryRecord) *historyv1.HistoryRecord {
+		return &historyv1.HistoryRecord{
+			Id:     src.ID,
+			Biz:    src.Biz,
+			BizId:  src.BizID,
+			Uid:    src.Uid,
+			ReadAt: src.ReadAt.UnixMilli(),
+		}
+	})}, nil
+}
+
+func (h *HistoryServiceServer) DeleteHistory(ctx context.Context, request *historyv1.DeleteHistoryRequest) (*historyv1.DeleteHistoryResponse, error) {
+	err := h.svc.Delete(ctx, request.GetUid(), request.GetBiz(), request.GetBizId())
+	return &historyv1.DeleteHistoryResponse{}, err
+}
+
+func (h *HistoryServiceServer) ClearHistory(ctx context.Context, request *historyv1.ClearHistoryRequest) (*historyv1.ClearHistoryResponse, error) {
+	err := h.svc.Clear(ctx, request.GetUid())
+	return &historyv1.ClearHistoryResponse{}, err
+}
@@ -43,7 +43,7 @@ func (i *InteractiveServiceServer) Like(ctx context.Context, request *intr.LikeR
 
 func (i *InteractiveServiceServer) Collect(ctx context.Context, request *intr.CollectRequest) (*intr.CollectResponse, error) {
 	err := i.svc.Collect(ctx, request.Biz, request.BizId, request.Cid, request.Uid)
-	return &intr.CollectResponse{}, err
+	return &intr.CollectResponse{}, ToStatus(err)
 }
 
 func (i *InteractiveServiceServer) GetByIDs(ctx context.Context, request *intr.GetByIDsRequest) (*intr.GetByIDsResponse, error) {
@@ -61,6 +61,21 @@ func (i *InteractiveServiceServer) GetByIDs(ctx context.Context, request *intr.G
 	return &intr.GetByIDsResponse{Intrs: res}, err
 }
 
+func (i *InteractiveServiceServer) GetByIDsForUser(ctx context.Context, request *intr.GetByIDsForUserRequest) (*intr.GetByIDsForUserResponse, error) {
+	if len(request.GetIds()) == 0 {
+		return &intr.GetByIDsForUserResponse{}, nil
+	}
+	data, err := i.svc.GetByIDsForUser(ctx, request.GetBiz(), request.GetIds(), request.GetUid())
+	if err != nil {
+		return &intr.GetByIDsForUserResponse{}, err
+	}
+	res := make(map[int64]*intr.Interactive, len(data))
+	for k, v := range data {
+		res[k] = i.toDTO(v)
+	}
+	return &intr.GetByIDsForUserResponse{Intrs: res}, nil
+}
+
 func (i *InteractiveServiceServer) toDTO(res domain.Interactive) *intr.Interactive {
 	return &intr.Interactive{
 		Biz:        res.Biz,
@@ -68,6 +83,7 @@ func (i *InteractiveServiceServer) toDTO(res domain.Interactive) *intr.Interacti
 		ReadCnt:    res.ReadCnt,
 		LikeCnt:    res.LikeCnt,
 		CollectCnt: res.CollectCnt,
+		CommentCnt: res.CommentCnt,
 		Liked:      res.Liked,
 		Collected:  res.Collected,
 	}
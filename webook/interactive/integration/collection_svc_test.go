@@ -0,0 +1,169 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"geektime-basic-go/webook/interactive/domain"
+	"geektime-basic-go/webook/interactive/integration/startup"
+	"geektime-basic-go/webook/interactive/repository/dao"
+	"geektime-basic-go/webook/interactive/service"
+)
+
+func (s *InteractiveTestSuite) TestCollectionCRUD() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	svc := startup.InitInteractiveService()
+
+	cid, err := svc.CreateCollection(ctx, domain.Collection{Uid: 1, Name: "技术"})
+	require.NoError(t, err)
+	assert.True(t, cid > 0)
+
+	err = svc.RenameCollection(ctx, 1, cid, "后端")
+	require.NoError(t, err)
+	// 不能修改别人的收藏夹
+	err = svc.RenameCollection(ctx, 2, cid, "前端")
+	assert.Equal(t, service.ErrCollectionNotFound, err)
+
+	cs, err := svc.ListCollections(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, cs, 1)
+	assert.Equal(t, "后端", cs[0].Name)
+
+	err = svc.DeleteCollection(ctx, 2, cid)
+	assert.Equal(t, service.ErrCollectionNotFound, err)
+	err = svc.DeleteCollection(ctx, 1, cid)
+	require.NoError(t, err)
+	cs, err = svc.ListCollections(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, cs)
+}
+
+func (s *InteractiveTestSuite) TestCollectionItems() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	svc := startup.InitInteractiveService()
+
+	cid, err := svc.CreateCollection(ctx, domain.Collection{Uid: 1, Name: "技术"})
+	require.NoError(t, err)
+	for _, bizID := range []int64{1, 2, 3} {
+		err = svc.Collect(ctx, "test", bizID, 0, 1)
+		require.NoError(t, err)
+	}
+	err = s.rdb.HSet(ctx, "interactive:test:1", "collect_cnt", 1).Err()
+	require.NoError(t, err)
+
+	// 移动到新建的收藏夹
+	err = svc.MoveCollectionItem(ctx, "test", 1, cid, 1)
+	require.NoError(t, err)
+	err = svc.MoveCollectionItem(ctx, "test", 2, cid, 1)
+	require.NoError(t, err)
+	err = svc.MoveCollectionItem(ctx, "test", 4, cid, 1)
+	assert.Equal(t, service.ErrNotCollected, err)
+	// 已经收藏到了别的收藏夹，不能再收藏一次
+	err = svc.Collect(ctx, "test", 3, cid, 1)
+	assert.Equal(t, service.ErrCollectedElsewhere, err)
+
+	items, err := svc.ListCollectionItems(ctx, 1, cid, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, itemBizIDs(items))
+	items, err = svc.ListCollectionItems(ctx, 1, cid, items[0].ID, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, itemBizIDs(items))
+	items, err = svc.ListCollectionItems(ctx, 1, 0, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, itemBizIDs(items))
+	_, err = svc.ListCollectionItems(ctx, 2, cid, 0, 10)
+	assert.Equal(t, service.ErrCollectionNotFound, err)
+
+	// 取消收藏，重复取消不会重复扣减
+	err = svc.Uncollect(ctx, "test", 3, 1)
+	require.NoError(t, err)
+	err = svc.Uncollect(ctx, "test", 3, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), s.collectCnt(t, 3))
+	collected, err := svc.Get(ctx, "test", 3, 1)
+	require.NoError(t, err)
+	assert.False(t, collected.Collected)
+	// 取消之后可以重新收藏，重复收藏不会重复计数
+	err = svc.Collect(ctx, "test", 3, 0, 1)
+	require.NoError(t, err)
+	err = svc.Collect(ctx, "test", 3, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), s.collectCnt(t, 3))
+
+	// 删除收藏夹，里面的收藏一起取消
+	err = svc.DeleteCollection(ctx, 1, cid)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), s.collectCnt(t, 1))
+	assert.Equal(t, int64(0), s.collectCnt(t, 2))
+	cnt, err := s.rdb.HGet(ctx, "interactive:test:1", "collect_cnt").Int()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+	var left int64
+	err = s.db.Model(&dao.UserCollectionBiz{}).
+		Where("uid = ? AND status = ?", 1, dao.UserCollectionBizStatusValid).
+		Count(&left).Error
+	require.NoError(t, err)
+	// 只剩下默认收藏夹里的 3
+	assert.Equal(t, int64(1), left)
+}
+
+func (s *InteractiveTestSuite) TestCollectionVisibility() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	svc := startup.InitInteractiveService()
+
+	pub, err := svc.CreateCollection(ctx, domain.Collection{Uid: 1, Name: "公开", Public: true})
+	require.NoError(t, err)
+	priv, err := svc.CreateCollection(ctx, domain.Collection{Uid: 1, Name: "私有"})
+	require.NoError(t, err)
+	err = svc.Collect(ctx, "test", 1, pub, 1)
+	require.NoError(t, err)
+	err = svc.Collect(ctx, "test", 2, priv, 1)
+	require.NoError(t, err)
+
+	cs, err := svc.ListPublicCollections(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, cs, 1)
+	assert.Equal(t, pub, cs[0].ID)
+
+	items, err := svc.ListCollectionItems(ctx, 2, pub, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, itemBizIDs(items))
+	_, err = svc.ListCollectionItems(ctx, 2, priv, 0, 10)
+	assert.Equal(t, service.ErrCollectionNotFound, err)
+
+	// 改成私有之后别人就看不到了
+	err = svc.SetCollectionPublic(ctx, 2, pub, false)
+	assert.Equal(t, service.ErrCollectionNotFound, err)
+	err = svc.SetCollectionPublic(ctx, 1, pub, false)
+	require.NoError(t, err)
+	_, err = svc.ListCollectionItems(ctx, 2, pub, 0, 10)
+	assert.Equal(t, service.ErrCollectionNotFound, err)
+	items, err = svc.ListCollectionItems(ctx, 1, pub, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, itemBizIDs(items))
+}
+
+func (s *InteractiveTestSuite) collectCnt(t *testing.T, bizID int64) int64 {
+	var intr dao.Interactive
+	err := s.db.Where("biz = ? AND biz_id = ?", "test", bizID).First(&intr).Error
+	require.NoError(t, err)
+	return intr.CollectCnt
+}
+
+func itemBizIDs(items []domain.CollectionItem) []int64 {
+	res := make([]int64, 0, len(items))
+	for _, item := range items {
+		res = append(res, item.BizID)
+	}
+	return res
+}
@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+
+	"geektime-basic-go/webook/interactive/domain"
+	"geektime-basic-go/webook/interactive/integration/startup"
+	"geektime-basic-go/webook/interactive/repository"
+	"geektime-basic-go/webook/interactive/repository/dao"
+	"geektime-basic-go/webook/interactive/service"
+)
+
+type HistoryTestSuite struct {
+	suite.Suite
+	db   *gorm.DB
+	repo repository.HistoryRecordRepository
+	svc  service.HistoryService
+}
+
+func TestHistoryService(t *testing.T) {
+	suite.Run(t, &HistoryTestSuite{})
+}
+
+func (s *HistoryTestSuite) SetupSuite() {
+	startup.InitViper()
+	s.db = startup.InitDB()
+	s.repo = startup.InitHistoryRecordRepository()
+	s.svc = startup.InitHistoryService()
+}
+
+func (s *HistoryTestSuite) TearDownTest() {
+	err := s.db.Exec("TRUNCATE TABLE `history_records`").Error
+	assert.NoError(s.T(), err)
+}
+
+func (s *HistoryTestSuite) TestAddRecord() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	err := s.repo.AddRecord(ctx, domain.HistoryRecord{Biz: "article", BizID: 1, Uid: 123, ReadAt: now})
+	require.NoError(t, err)
+	// 重复阅读只更新阅读时间
+	err = s.repo.BatchAddRecord(ctx, []domain.HistoryRecord{
+		{Biz: "article", BizID: 1, Uid: 123, ReadAt: now.Add(time.Minute)},
+		{Biz: "article", BizID: 2, Uid: 123, ReadAt: now},
+	})
+	require.NoError(t, err)
+	// 乱序到达的旧消息不会覆盖新的阅读时间
+	err = s.repo.AddRecord(ctx, domain.HistoryRecord{Biz: "article", BizID: 1, Uid: 123, ReadAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+
+	var records []dao.HistoryRecord
+	err = s.db.Order("biz_id ASC").Find(&records).Error
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, now.Add(time.Minute).UnixMilli(), records[0].ReadAt)
+	assert.Equal(t, now.UnixMilli(), records[1].ReadAt)
+}
+
+func (s *HistoryTestSuite) TestList() {
+	t := s.T()
+	base := time.Now().UnixMilli()
+	err := s.db.Create(&[]dao.HistoryRecord{
+		{ID: 1, Uid: 123, Biz: "article", BizID: 1, ReadAt: base + 1},
+		{ID: 2, Uid: 123, Biz: "article", BizID: 2, ReadAt: base + 3},
+		{ID: 3, Uid: 123, Biz: "article", BizID: 3, ReadAt: base + 3},
+		{ID: 4, Uid: 123, Biz: "article", BizID: 4, ReadAt: base + 2},
+		{ID: 5, Uid: 456, Biz: "article", BizID: 1, ReadAt: base + 4},
+	}).Error
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	records, err := s.svc.List(ctx, 123, domain.HistoryRecord{}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 2}, bizIDs(records))
+
+	records, err = s.svc.List(ctx, 123, records[len(records)-1], 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 1}, bizIDs(records))
+
+	records, err = s.svc.List(ctx, 123, records[len(records)-1], 2)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func (s *HistoryTestSuite) TestDelete() {
+	t := s.T()
+	err := s.db.Create(&[]dao.HistoryRecord{
+		{Uid: 123, Biz: "article", BizID: 1, ReadAt: 1},
+		{Uid: 123, Biz: "article", BizID: 2, ReadAt: 2},
+		{Uid: 456, Biz: "article", BizID: 1, ReadAt: 3},
+		{Uid: 456, Biz: "article", BizID: 2, ReadAt: 4},
+	}).Error
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err = s.svc.Delete(ctx, 123, "article", 1)
+	require.NoError(t, err)
+	err = s.svc.Clear(ctx, 456)
+	require.NoError(t, err)
+
+	var records []dao.HistoryRecord
+	err = s.db.Find(&records).Error
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, int64(123), records[0].Uid)
+	assert.Equal(t, int64(2), records[0].BizID)
+}
+
+func bizIDs(records []domain.HistoryRecord) []int64 {
+	res := make([]int64, 0, len(records))
+	for _, r := range records {
+		res = append(res, r.BizID)
+	}
+	return res
+}
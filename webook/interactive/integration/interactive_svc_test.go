@@ -6,6 +6,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/net/context"
 	"gorm.io/gorm"
@@ -13,6 +14,7 @@ import (
 	"geektime-basic-go/webook/interactive/domain"
 	"geektime-basic-go/webook/interactive/integration/startup"
 	"geektime-basic-go/webook/interactive/repository/dao"
+	"geektime-basic-go/webook/interactive/service"
 )
 
 type InteractiveTestSuite struct {
@@ -38,8 +40,12 @@ func (s *InteractiveTestSuite) TearDownTest() {
 	assert.NoError(s.T(), err)
 	err = s.db.Exec("TRUNCATE TABLE `user_like_bizs`").Error
 	assert.NoError(s.T(), err)
+	err = s.db.Exec("TRUNCATE TABLE `processed_events`").Error
+	assert.NoError(s.T(), err)
 	err = s.db.Exec("TRUNCATE TABLE `user_collection_bizs`").Error
 	assert.NoError(s.T(), err)
+	err = s.db.Exec("TRUNCATE TABLE `collections`").Error
+	assert.NoError(s.T(), err)
 	// 清空 Redis
 	err = s.rdb.FlushDB(ctx).Err()
 	assert.NoError(s.T(), err)
@@ -239,10 +245,11 @@ func (s *InteractiveTestSuite) TestCollect() {
 				assert.True(t, cbiz.ID > 0)
 				cbiz.ID = 0
 				assert.Equal(t, dao.UserCollectionBiz{
-					Biz:   "test",
-					BizID: 1,
-					CID:   1,
-					UID:   1,
+					Biz:    "test",
+					BizID:  1,
+					CID:    1,
+					UID:    1,
+					Status: dao.UserCollectionBizStatusValid,
 				}, cbiz)
 			},
 			bizId: 1,
@@ -298,10 +305,11 @@ func (s *InteractiveTestSuite) TestCollect() {
 				assert.True(t, cbiz.ID > 0)
 				cbiz.ID = 0
 				assert.Equal(t, dao.UserCollectionBiz{
-					Biz:   "test",
-					BizID: 2,
-					CID:   1,
-					UID:   1,
+					Biz:    "test",
+					BizID:  2,
+					CID:    1,
+					UID:    1,
+					Status: dao.UserCollectionBizStatusValid,
 				}, cbiz)
 			},
 			bizId: 2,
@@ -359,10 +367,11 @@ func (s *InteractiveTestSuite) TestCollect() {
 				assert.True(t, cbiz.ID > 0)
 				cbiz.ID = 0
 				assert.Equal(t, dao.UserCollectionBiz{
-					Biz:   "test",
-					BizID: 3,
-					CID:   1,
-					UID:   1,
+					Biz:    "test",
+					BizID:  3,
+					CID:    1,
+					UID:    1,
+					Status: dao.UserCollectionBizStatusValid,
 				}, cbiz)
 			},
 			bizId: 3,
@@ -370,9 +379,31 @@ func (s *InteractiveTestSuite) TestCollect() {
 			cid:   1,
 			uid:   1,
 		},
+		{
+			name:   "收藏夹不属于当前用户",
+			before: func(t *testing.T) {},
+			after: func(t *testing.T) {
+				var cnt int64
+				err := s.db.Model(&dao.UserCollectionBiz{}).
+					Where("uid = ? AND biz = ? AND biz_id = ?", 1, "test", 4).
+					Count(&cnt).Error
+				assert.NoError(t, err)
+				assert.Equal(t, int64(0), cnt)
+			},
+			bizId:   4,
+			biz:     "test",
+			cid:     2,
+			uid:     1,
+			wantErr: service.ErrCollectionNotFound,
+		},
 	}
 
 	svc := startup.InitInteractiveService()
+	err := s.db.Create(&[]dao.Collection{
+		{ID: 1, Uid: 1, Name: "我的收藏"},
+		{ID: 2, Uid: 2, Name: "别人的收藏"},
+	}).Error
+	require.NoError(s.T(), err)
 
 	for _, tc := range testCases {
 		s.T().Run(tc.name, func(t *testing.T) {
@@ -540,3 +571,232 @@ func (s *InteractiveTestSuite) TestGetByIds() {
 		})
 	}
 }
+
+func (s *InteractiveTestSuite) TestGetByIDsForUser() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	// 故意让 ID 和 BizID 不一样
+	for i := int64(1); i < 4; i++ {
+		err := s.db.WithContext(ctx).Create(&dao.Interactive{
+			ID:      i + 10,
+			Biz:     "test",
+			BizID:   i,
+			LikeCnt: i,
+		}).Error
+		require.NoError(t, err)
+	}
+	err := s.db.WithContext(ctx).Create([]dao.UserLikeBiz{
+		{Biz: "test", BizID: 1, UID: 1, Status: 1},
+		{Biz: "test", BizID: 2, UID: 1, Status: 0},
+		{Biz: "test", BizID: 3, UID: 2, Status: 1},
+	}).Error
+	require.NoError(t, err)
+	err = s.db.WithContext(ctx).Create(&dao.UserCollectionBiz{Biz: "test", BizID: 2, UID: 1, Status: dao.UserCollectionBizStatusValid}).Error
+	require.NoError(t, err)
+
+	svc := startup.InitInteractiveService()
+	want := map[int64]domain.Interactive{
+		1: {Biz: "test", BizID: 1, LikeCnt: 1, Liked: true},
+		2: {Biz: "test", BizID: 2, LikeCnt: 2, Collected: true},
+		3: {Biz: "test", BizID: 3, LikeCnt: 3},
+		4: {Biz: "test", BizID: 4},
+	}
+	res, err := svc.GetByIDsForUser(ctx, "test", []int64{1, 2, 3, 4}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, want, res)
+
+	// 查询过的状态都回写了缓存，包括没有点赞的
+	liked, err := s.rdb.HGetAll(ctx, "interactive:liked:test:1").Result()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"1": "1", "2": "0", "3": "0", "4": "0"}, liked)
+
+	// 取消收藏会删除缓存，下次查询的时候从数据库加载
+	err = svc.Uncollect(ctx, "test", 2, 1)
+	require.NoError(t, err)
+	cached, err := s.rdb.HExists(ctx, "interactive:collected:test:1", "2").Result()
+	require.NoError(t, err)
+	assert.False(t, cached)
+	res, err = svc.GetByIDsForUser(ctx, "test", []int64{2}, 1)
+	require.NoError(t, err)
+	assert.False(t, res[2].Collected)
+
+	// 没有登录的只有计数
+	res, err = svc.GetByIDsForUser(ctx, "test", []int64{1}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]domain.Interactive{1: {Biz: "test", BizID: 1, LikeCnt: 1}}, res)
+}
+
+func (s *InteractiveTestSuite) TestChangeLikes() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	repo := startup.InitInteractiveRepository()
+
+	applied, err := repo.ChangeLikes(ctx, "test", []domain.LikeChange{
+		{Key: "k1", BizID: 1, Uid: 1, Liked: true},
+		// 重复点赞不计数
+		{Key: "k2", BizID: 1, Uid: 1, Liked: true},
+		{Key: "k3", BizID: 1, Uid: 2, Liked: true},
+		// 没有点赞过的取消点赞不计数
+		{Key: "k4", BizID: 1, Uid: 3, Liked: false},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k1", "k3"}, likeChangeKeys(applied))
+	assert.Equal(t, int64(2), s.likeCnt(t, 1))
+
+	// 重复投递的消息会被跳过
+	applied, err = repo.ChangeLikes(ctx, "test", []domain.LikeChange{
+		{Key: "k1", BizID: 1, Uid: 1, Liked: true},
+		{Key: "k3", BizID: 1, Uid: 2, Liked: true},
+		{Key: "k5", BizID: 1, Uid: 2, Liked: false},
+		{Key: "k5", BizID: 1, Uid: 2, Liked: false},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k5"}, likeChangeKeys(applied))
+	assert.Equal(t, int64(1), s.likeCnt(t, 1))
+
+	// 快速地点赞再取消，按顺序处理
+	applied, err = repo.ChangeLikes(ctx, "test", []domain.LikeChange{
+		{Key: "k6", BizID: 1, Uid: 3, Liked: true},
+		{Key: "k7", BizID: 1, Uid: 3, Liked: false},
+		{Key: "k8", BizID: 1, Uid: 3, Liked: false},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k6", "k7"}, likeChangeKeys(applied))
+	assert.Equal(t, int64(1), s.likeCnt(t, 1))
+	liked, err := repo.Liked(ctx, "test", 1, 3)
+	require.NoError(t, err)
+	assert.False(t, liked)
+}
+
+func likeChangeKeys(changes []domain.LikeChange) []string {
+	res := make([]string, 0, len(changes))
+	for _, c := range changes {
+		res = append(res, c.Key)
+	}
+	return res
+}
+
+func (s *InteractiveTestSuite) TestIncrCommentCnts() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	repo := startup.InitInteractiveRepository()
+
+	err := repo.IncrCommentCnts(ctx, []domain.CommentCntChange{
+		{Key: "c1", Biz: "test", BizID: 1, Delta: 1},
+		{Key: "c2", Biz: "test", BizID: 1, Delta: 1},
+		{Key: "c3", Biz: "test", BizID: 2, Delta: 1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), s.commentCnt(t, 1))
+	assert.Equal(t, int64(1), s.commentCnt(t, 2))
+
+	// 整批重试的时候，已经计数的消息会被跳过
+	err = repo.IncrCommentCnts(ctx, []domain.CommentCntChange{
+		{Key: "c1", Biz: "test", BizID: 1, Delta: 1},
+		{Key: "c2", Biz: "test", BizID: 1, Delta: 1},
+		{Key: "c3", Biz: "test", BizID: 2, Delta: 1},
+		{Key: "c4", Biz: "test", BizID: 2, Delta: -1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), s.commentCnt(t, 1))
+	assert.Equal(t, int64(0), s.commentCnt(t, 2))
+
+	// 评论数最小为 0
+	err = repo.IncrCommentCnts(ctx, []domain.CommentCntChange{
+		{Key: "c5", Biz: "test", BizID: 2, Delta: -1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), s.commentCnt(t, 2))
+}
+
+func (s *InteractiveTestSuite) TestBatchIncrReadCnt() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	repo := startup.InitInteractiveRepository()
+
+	err := repo.BatchIncrReadCnt(ctx, []string{"r1", "r2", "r3"}, []string{"test", "test", "test"}, []int64{1, 1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), s.readCnt(t, 1))
+	assert.Equal(t, int64(1), s.readCnt(t, 2))
+
+	// 整批重试的时候，已经计数的消息会被跳过
+	err = repo.BatchIncrReadCnt(ctx, []string{"r1", "r2", "r3", "r4"}, []string{"test", "test", "test", "test"}, []int64{1, 1, 2, 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), s.readCnt(t, 1))
+	assert.Equal(t, int64(2), s.readCnt(t, 2))
+}
+
+func (s *InteractiveTestSuite) TestReconcileLikeCnt() {
+	t := s.T()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	// 1 的点赞数偏大，2 的是对的，3 的偏小
+	err := s.db.WithContext(ctx).Create([]dao.Interactive{
+		{ID: 1, Biz: "test", BizID: 1, LikeCnt: 5},
+		{ID: 2, Biz: "test", BizID: 2, LikeCnt: 1},
+		{ID: 3, Biz: "test", BizID: 3, LikeCnt: 0},
+	}).Error
+	require.NoError(t, err)
+	err = s.db.WithContext(ctx).Create([]dao.UserLikeBiz{
+		{Biz: "test", BizID: 1, UID: 1, Status: 1},
+		{Biz: "test", BizID: 1, UID: 2, Status: 0},
+		{Biz: "test", BizID: 2, UID: 1, Status: 1},
+		{Biz: "test", BizID: 3, UID: 1, Status: 1},
+		{Biz: "test", BizID: 3, UID: 2, Status: 1},
+	}).Error
+	require.NoError(t, err)
+	err = s.db.WithContext(ctx).Create([]dao.ProcessedEvent{
+		{Key: "old", CreateAt: time.Now().Add(-time.Hour).UnixMilli()},
+		{Key: "new", CreateAt: time.Now().UnixMilli()},
+	}).Error
+	require.NoError(t, err)
+
+	repo := startup.InitInteractiveRepository()
+	next, fixed, err := repo.ReconcileLikeCnt(ctx, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), next)
+	assert.Equal(t, 1, fixed)
+	next, fixed, err = repo.ReconcileLikeCnt(ctx, next, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), next)
+	assert.Equal(t, 1, fixed)
+	next, fixed, err = repo.ReconcileLikeCnt(ctx, next, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), next)
+	assert.Equal(t, 0, fixed)
+
+	assert.Equal(t, int64(1), s.likeCnt(t, 1))
+	assert.Equal(t, int64(1), s.likeCnt(t, 2))
+	assert.Equal(t, int64(2), s.likeCnt(t, 3))
+
+	deleted, err := repo.DeleteProcessedEventsBefore(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func (s *InteractiveTestSuite) commentCnt(t *testing.T, bizID int64) int64 {
+	var intr dao.Interactive
+	err := s.db.Where("biz = ? AND biz_id = ?", "test", bizID).First(&intr).Error
+	require.NoError(t, err)
+	return intr.CommentCnt
+}
+
+func (s *InteractiveTestSuite) readCnt(t *testing.T, bizID int64) int64 {
+	var intr dao.Interactive
+	err := s.db.Where("biz = ? AND biz_id = ?", "test", bizID).First(&intr).Error
+	require.NoError(t, err)
+	return intr.ReadCnt
+}
+
+func (s *InteractiveTestSuite) likeCnt(t *testing.T, bizID int64) int64 {
+	var intr dao.Interactive
+	err := s.db.Where("biz = ? AND biz_id = ?", "test", bizID).First(&intr).Error
+	require.NoError(t, err)
+	return intr.LikeCnt
+}
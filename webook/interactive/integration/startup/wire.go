@@ -10,6 +10,7 @@ import (
 	intrcache "geektime-basic-go/webook/interactive/repository/cache"
 	intrdao "geektime-basic-go/webook/interactive/repository/dao"
 	intrscv "geektime-basic-go/webook/interactive/service"
+	"geektime-basic-go/webook/pkg/gormx"
 )
 
 var thirdProvider = wire.NewSet(
@@ -21,10 +22,13 @@ var thirdProvider = wire.NewSet(
 )
 
 var interactiveSvcProvider = wire.NewSet(
+	gormx.NewTransactor,
 	intrscv.NewInteractiveService,
 	intrrepo.NewInteractiveRepository,
 	intrdao.NewInteractiveDAO,
 	intrcache.NewInteractiveCache,
+	intrrepo.NewCollectionRepository,
+	intrdao.NewGormCollectionDAO,
 )
 
 func InitInteractiveService() intrscv.InteractiveService {
@@ -32,6 +36,38 @@ func InitInteractiveService() intrscv.InteractiveService {
 		thirdProvider,
 		interactiveSvcProvider,
 		events.NewChangeLikeSaramaSyncProducer,
+		events.NewChangeCollectSaramaSyncProducer,
 	)
-	return intrscv.NewInteractiveService(nil, nil, nil)
+	return intrscv.NewInteractiveService(nil, nil, nil, nil, nil, nil)
+}
+
+func InitHistoryService() intrscv.HistoryService {
+	wire.Build(
+		InitDB,
+		intrscv.NewHistoryService,
+		intrrepo.NewHistoryRecordRepository,
+		intrdao.NewGormHistoryRecordDAO,
+	)
+	return intrscv.NewHistoryService(nil)
+}
+
+func InitHistoryRecordRepository() intrrepo.HistoryRecordRepository {
+	wire.Build(
+		InitDB,
+		intrrepo.NewHistoryRecordRepository,
+		intrdao.NewGormHistoryRecordDAO,
+	)
+	return intrrepo.NewHistoryRecordRepository(nil)
+}
+
+func InitInteractiveRepository() intrrepo.InteractiveRepository {
+	wire.Build(
+		InitDB,
+		InitZapLogger,
+		InitRedis,
+		intrrepo.NewInteractiveRepository,
+		intrdao.NewInteractiveDAO,
+		intrcache.NewInteractiveCache,
+	)
+	return intrrepo.NewInteractiveRepository(nil, nil, nil)
 }
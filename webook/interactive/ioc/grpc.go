@@ -10,7 +10,7 @@ import (
 	"geektime-basic-go/webook/pkg/grpcx"
 )
 
-func InitGRPCxServer(intr *intr.InteractiveServiceServer, l logger.Logger) *grpcx.Server {
+func InitGRPCxServer(intr *intr.InteractiveServiceServer, history *intr.HistoryServiceServer, l logger.Logger) *grpcx.Server {
 	type Config struct {
 		Port    int   `yaml:"port"`
 		EtcdTTL int64 `yaml:"etcdTTL"`
@@ -22,6 +22,7 @@ func InitGRPCxServer(intr *intr.InteractiveServiceServer, l logger.Logger) *grpc
 	}
 	server := grpc.NewServer()
 	intr.Register(server)
+	history.Register(server)
 	return &grpcx.Server{
 		Server:   server,
 		Port:     cfg.Port,
@@ -0,0 +1,30 @@
+package ioc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"geektime-basic-go/webook/interactive/job"
+	"geektime-basic-go/webook/interactive/repository"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func InitLikeCntReconcileJob(repo repository.InteractiveRepository, l logger.Logger) *job.LikeCntReconcileJob {
+	return job.NewLikeCntReconcileJob(repo, l, 500, 30*time.Minute, 7*24*time.Hour)
+}
+
+func InitJobs(l logger.Logger, reconcileJob *job.LikeCntReconcileJob) *cron.Cron {
+	expr := cron.New(cron.WithSeconds())
+	// 每天凌晨四点对账
+	_, err := expr.AddFunc("0 0 4 * * *", func() {
+		if er := reconcileJob.Run(); er != nil {
+			l.Error("执行定时任务失败", logger.String("name", reconcileJob.Name()), logger.Error(er))
+		}
+	})
+	if err != nil {
+		panic(fmt.Sprintf("初始化定时任务失败: %s", err))
+	}
+	return expr
+}
@@ -2,19 +2,25 @@ package ioc
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/spf13/viper"
 
 	"geektime-basic-go/webook/interactive/events"
 	"geektime-basic-go/webook/interactive/events/article"
+	"geektime-basic-go/webook/interactive/events/comment"
 	"geektime-basic-go/webook/interactive/repository/dao"
+	"geektime-basic-go/webook/pkg/logger"
 	"geektime-basic-go/webook/pkg/migrator/events/fixer"
+	"geektime-basic-go/webook/pkg/saramax"
 )
 
 func InitKafka() sarama.Client {
 	type config struct {
 		Addrs []string `yaml:"addrs"`
+		// Metrics 消费者监控的配置，主要是 instanceID
+		Metrics saramax.MetricsConfig `yaml:"metrics"`
 	}
 	saramaCfg := sarama.NewConfig()
 	saramaCfg.Producer.Return.Successes = true
@@ -23,6 +29,7 @@ func InitKafka() sarama.Client {
 	if err := viper.UnmarshalKey("kafka", &cfg); err != nil {
 		panic(fmt.Sprintf("初始化 kafka 失败, 反序列化配置失败: %s", err))
 	}
+	saramax.InitMetrics(cfg.Metrics)
 	client, err := sarama.NewClient(cfg.Addrs, saramaCfg)
 	if err != nil {
 		panic(fmt.Sprintf("初始化 kafka 失败: %s", err))
@@ -38,7 +45,37 @@ func NewSyncProducer(client sarama.Client) sarama.SyncProducer {
 	return res
 }
 
+// InitDeadLetter 没有开启的时候返回 nil，消费者处理失败只记录日志
+func InitDeadLetter(producer sarama.SyncProducer, l logger.Logger) *saramax.DeadLetter {
+	type config struct {
+		Enabled    bool          `yaml:"enabled"`
+		MaxRetries int           `yaml:"maxRetries"`
+		Backoff    time.Duration `yaml:"backoff"`
+		MaxBackoff time.Duration `yaml:"maxBackoff"`
+	}
+	var cfg config
+	if err := viper.UnmarshalKey("kafka.deadLetter", &cfg); err != nil {
+		panic(fmt.Sprintf("初始化死信队列失败, 反序列化配置失败: %s", err))
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+	res := saramax.NewDeadLetter(producer, l)
+	if cfg.MaxRetries > 0 {
+		res.MaxRetries = cfg.MaxRetries
+	}
+	if cfg.Backoff > 0 && cfg.MaxBackoff >= cfg.Backoff {
+		res.Backoff = saramax.ExponentialBackoff(cfg.Backoff, cfg.MaxBackoff)
+	}
+	return res
+}
+
+func InitDeadLetterAdmin(client sarama.Client, producer sarama.SyncProducer, l logger.Logger) *saramax.DeadLetterAdmin {
+	return saramax.NewDeadLetterAdmin(client, producer, l)
+}
+
 // NewConsumers 面临的问题依旧是所有的 Consumer 在这里注册一下
-func NewConsumers(c1 *article.InteractiveReadEventConsumer, c2 *article.ChangeLikeEventConsumer, c3 *fixer.Consumer[dao.Interactive]) []events.Consumer {
-	return []events.Consumer{c1, c2, c3}
+func NewConsumers(c1 *article.InteractiveReadEventConsumer, c2 *article.ChangeLikeEventConsumer,
+	c3 *fixer.Consumer[dao.Interactive], c4 *comment.CommentCntEventConsumer) []events.Consumer {
+	return []events.Consumer{c1, c2, c3, c4}
 }
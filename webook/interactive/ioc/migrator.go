@@ -14,6 +14,7 @@ import (
 	"geektime-basic-go/webook/pkg/migrator/events"
 	"geektime-basic-go/webook/pkg/migrator/events/fixer"
 	"geektime-basic-go/webook/pkg/migrator/scheduler"
+	"geektime-basic-go/webook/pkg/saramax"
 )
 
 const topic = "migrator_interactives"
@@ -30,7 +31,7 @@ func InitMigratorProducer(p sarama.SyncProducer) events.Producer {
 	return events.NewSaramaProducer(p, topic)
 }
 
-func InitMigratorWeb(l logger.Logger, src SrcDB, dst DstDB, pool *connpool.DoubleWritePool, producer events.Producer) *ginx.Server {
+func InitMigratorWeb(l logger.Logger, src SrcDB, dst DstDB, pool *connpool.DoubleWritePool, producer events.Producer, dlq *saramax.DeadLetterAdmin) *ginx.Server {
 	gin.SetMode(gin.ReleaseMode)
 	web := gin.Default()
 	handlefunc.InitCounter(prometheus.CounterOpts{
@@ -42,6 +43,7 @@ func InitMigratorWeb(l logger.Logger, src SrcDB, dst DstDB, pool *connpool.Doubl
 	})
 	intrs := scheduler.NewScheduler[dao.Interactive](l, src, dst, pool, producer)
 	intrs.RegisterRoutes(web.Group("/intr"))
+	dlq.RegisterRoutes(web.Group("/dlq"))
 	return &ginx.Server{
 		Engine: web,
 		Addr:   viper.GetString("migrator.http.addr"),
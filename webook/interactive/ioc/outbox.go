@@ -0,0 +1,13 @@
+package ioc
+
+import (
+	"github.com/IBM/sarama"
+	"gorm.io/gorm"
+
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/outbox"
+)
+
+func InitOutboxRelay(db *gorm.DB, producer sarama.SyncProducer, l logger.Logger) *outbox.Relay {
+	return outbox.NewRelay(db, producer, l)
+}
@@ -0,0 +1,59 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"geektime-basic-go/webook/interactive/repository"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// LikeCntReconcileJob 用 UserLikeBiz 重新计算 like_cnt，修正各种原因产生的偏差，
+// 顺便清理过期的事件去重记录。
+// 重新计数本身是幂等的，所以多个实例同时执行也没有问题
+type LikeCntReconcileJob struct {
+	repo      repository.InteractiveRepository
+	l         logger.Logger
+	batchSize int
+	timeout   time.Duration
+	// eventRetention 去重记录保留多久，要比消息可能被重复投递的时间长
+	eventRetention time.Duration
+}
+
+func NewLikeCntReconcileJob(repo repository.InteractiveRepository, l logger.Logger,
+	batchSize int, timeout time.Duration, eventRetention time.Duration) *LikeCntReconcileJob {
+	return &LikeCntReconcileJob{repo: repo, l: l, batchSize: batchSize, timeout: timeout, eventRetention: eventRetention}
+}
+
+func (j *LikeCntReconcileJob) Name() string {
+	return "like_cnt_reconcile"
+}
+
+func (j *LikeCntReconcileJob) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), j.timeout)
+	defer cancel()
+
+	var (
+		startID int64
+		total   int
+	)
+	for {
+		next, fixed, err := j.repo.ReconcileLikeCnt(ctx, startID, j.batchSize)
+		total += fixed
+		if err != nil {
+			return err
+		}
+		if next == 0 {
+			break
+		}
+		startID = next
+	}
+	j.l.Info("点赞数对账完成", logger.Int("fixed", total))
+
+	deleted, err := j.repo.DeleteProcessedEventsBefore(ctx, time.Now().Add(-j.eventRetention))
+	if err != nil {
+		return err
+	}
+	j.l.Info("清理事件去重记录", logger.Int("deleted", deleted))
+	return nil
+}
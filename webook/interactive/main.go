@@ -1,38 +1,71 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/robfig/cron/v3"
 
 	"geektime-basic-go/webook/interactive/events"
 	"geektime-basic-go/webook/interactive/ioc"
 	"geektime-basic-go/webook/pkg/ginx"
 	"geektime-basic-go/webook/pkg/grpcx"
+	"geektime-basic-go/webook/pkg/lifecycle"
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/outbox"
 )
 
+// shutdownTimeout 收到退出信号之后，所有组件关闭的总时间
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	ioc.InitViperWithWatchConfig()
 	initPrometheus()
 	app := Init()
+	// 后注册的先关闭：先从 etcd 注销并停止 gRPC 服务，再停止消费者和后台任务
+	m := lifecycle.NewManager(app.l, shutdownTimeout)
+
+	app.relay.Start()
+	m.OnShutdown("outbox relay", func(ctx context.Context) error {
+		return lifecycle.Wait(ctx, app.relay.Close)
+	})
+
+	app.cron.Start()
+	m.OnShutdown("cron", func(ctx context.Context) error {
+		return lifecycle.Wait(ctx, func() {
+			<-app.cron.Stop().Done()
+		})
+	})
+
 	for _, c := range app.consumers {
 		if err := c.Start(); err != nil {
 			panic(err)
 		}
+		m.OnShutdown(fmt.Sprintf("consumer %T", c), c.Close)
 	}
 
-	go func() {
-		panic(app.migratorServer.Start())
-	}()
+	m.Go("migrator web", app.migratorServer.Start)
+	m.OnShutdown("migrator web", app.migratorServer.Shutdown)
 
-	panic(app.server.Serve())
+	m.Go("grpc", app.server.Serve)
+	m.OnShutdown("grpc", app.server.Shutdown)
+
+	if err := m.Wait(); err != nil {
+		log.Println("退出异常", err)
+	}
 }
 
 type App struct {
 	server         *grpcx.Server
 	migratorServer *ginx.Server
 	consumers      []events.Consumer
+	cron           *cron.Cron
+	relay          *outbox.Relay
+	l              logger.Logger
 }
 
 func initPrometheus() {
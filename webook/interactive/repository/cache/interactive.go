@@ -16,29 +16,53 @@ import (
 type InteractiveCache interface {
 	Get(ctx context.Context, biz string, bizID int64) (domain.Interactive, error)
 	Set(ctx context.Context, biz string, bizID int64, intr domain.Interactive) error
+	Del(ctx context.Context, biz string, bizID int64) error
 	IncrReadCntIfPresent(ctx context.Context, biz string, bizID int64) error
 	DecrLikeCntIfPresent(ctx context.Context, biz string, bizID int64) error
 	IncrLikeCntIfPresent(ctx context.Context, biz string, bizID int64) error
 	IncrCollectCntIfPresent(ctx context.Context, biz string, bizID int64) error
+	DecrCollectCntIfPresent(ctx context.Context, biz string, bizID int64) error
+	IncrCommentCntIfPresent(ctx context.Context, biz string, bizID int64, delta int64) error
 	BatchIncrLikeCntIfPresent(ctx context.Context, biz string, bizIDs []int64) error
 	BatchDecrLikeCntIfPresent(ctx context.Context, biz string, bizIDs []int64) error
 	BatchSetLikeCnt(ctx context.Context, biz string, bizIDs []int64, cnts []int64) ([]string, error)
+	// GetLiked 批量查询 uid 是否点赞过，没有缓存的资源不会出现在结果里
+	GetLiked(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error)
+	// FillLiked 查询数据库之后回写缓存，已经有缓存的不会被覆盖
+	FillLiked(ctx context.Context, biz string, uid int64, liked map[int64]bool) error
+	// DelLiked 点赞状态变更之后删除缓存，下次查询的时候再从数据库加载
+	DelLiked(ctx context.Context, biz string, uid int64, bizID int64) error
+	// GetCollected 批量查询 uid 是否收藏过，没有缓存的资源不会出现在结果里
+	GetCollected(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error)
+	FillCollected(ctx context.Context, biz string, uid int64, collected map[int64]bool) error
+	DelCollected(ctx context.Context, biz string, uid int64, bizID int64) error
 }
 
 const (
 	fieldReadCnt    = "read_cnt"
 	fieldCollectCnt = "collect_cnt"
 	fieldLikeCnt    = "like_cnt"
+	fieldCommentCnt = "comment_cnt"
 )
 
 const topLimit = 5000
 
+const (
+	// userStateExpiration 用户点赞、收藏状态的过期时间，每次回写都会续期
+	userStateExpiration = 15 * time.Minute
+	// userStateMaxFields 每个用户的点赞、收藏状态最多缓存这么多个，超过了就清空重来
+	userStateMaxFields = 1000
+)
+
 var (
 	//go:embed lua/interactive_incr_cnt.lua
 	luaIncrCnt string
 
 	//go:embed lua/interactive_remove_cnt.lua
 	luaRemCnt string
+
+	//go:embed lua/interactive_fill_user_states.lua
+	luaFillUserStates string
 )
 
 // ErrKeyNotExist 因为我们目前还是只有一个实现，所以可以保持用别名
@@ -73,7 +97,8 @@ func (cache *interactiveCache) Get(ctx context.Context, biz string, bizID int64)
 	collectCnt, _ := strconv.ParseInt(data[fieldCollectCnt], 10, 64)
 	lickCnt, _ := strconv.ParseInt(data[fieldLikeCnt], 10, 64)
 	readCnt, _ := strconv.ParseInt(data[fieldReadCnt], 10, 64)
-	return domain.Interactive{BizID: bizID, ReadCnt: readCnt, LikeCnt: lickCnt, CollectCnt: collectCnt}, err
+	commentCnt, _ := strconv.ParseInt(data[fieldCommentCnt], 10, 64)
+	return domain.Interactive{BizID: bizID, ReadCnt: readCnt, LikeCnt: lickCnt, CollectCnt: collectCnt, CommentCnt: commentCnt}, err
 }
 
 func (cache *interactiveCache) Set(ctx context.Context, biz string, bizID int64, intr domain.Interactive) error {
@@ -82,6 +107,7 @@ func (cache *interactiveCache) Set(ctx context.Context, biz string, bizID int64,
 		fieldLikeCnt, intr.LikeCnt,
 		fieldCollectCnt, intr.CollectCnt,
 		fieldReadCnt, intr.ReadCnt,
+		fieldCommentCnt, intr.CommentCnt,
 	).Err()
 	if err != nil {
 		return err
@@ -89,6 +115,10 @@ func (cache *interactiveCache) Set(ctx context.Context, biz string, bizID int64,
 	return cache.client.Expire(ctx, key, 15*time.Minute).Err()
 }
 
+func (cache *interactiveCache) Del(ctx context.Context, biz string, bizID int64) error {
+	return cache.client.Del(ctx, cache.key(biz, bizID)).Err()
+}
+
 func (cache *interactiveCache) DecrLikeCntIfPresent(ctx context.Context, biz string, bizID int64) error {
 	return cache.client.Eval(ctx, luaIncrCnt, []string{cache.key(biz, bizID)}, fieldLikeCnt, -1).Err()
 }
@@ -101,6 +131,16 @@ func (cache *interactiveCache) IncrCollectCntIfPresent(ctx context.Context, biz
 	return cache.client.Eval(ctx, luaIncrCnt, []string{cache.key(biz, bizID)}, fieldCollectCnt, 1).Err()
 }
 
+func (cache *interactiveCache) DecrCollectCntIfPresent(ctx context.Context, biz string, bizID int64) error {
+	return cache.client.Eval(ctx, luaIncrCnt, []string{cache.key(biz, bizID)}, fieldCollectCnt, -1).Err()
+}
+
+// IncrCommentCntIfPresent 删除评论的时候 delta 是负数
+// 缓存里的值可能会短暂地小于 0，缓存过期之后以数据库为准
+func (cache *interactiveCache) IncrCommentCntIfPresent(ctx context.Context, biz string, bizID int64, delta int64) error {
+	return cache.client.Eval(ctx, luaIncrCnt, []string{cache.key(biz, bizID)}, fieldCommentCnt, delta).Err()
+}
+
 func (cache *interactiveCache) BatchIncrLikeCntIfPresent(ctx context.Context, biz string, bizIDs []int64) error {
 	pipeClient := cache.client.Pipeline()
 	for _, bizID := range bizIDs {
@@ -135,3 +175,73 @@ func (cache *interactiveCache) BatchSetLikeCnt(ctx context.Context, biz string,
 	})
 	return popIDs, err
 }
+
+func (cache *interactiveCache) GetLiked(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error) {
+	return cache.getUserStates(ctx, cache.likedKey(biz, uid), bizIDs)
+}
+
+func (cache *interactiveCache) FillLiked(ctx context.Context, biz string, uid int64, liked map[int64]bool) error {
+	return cache.fillUserStates(ctx, cache.likedKey(biz, uid), liked)
+}
+
+func (cache *interactiveCache) DelLiked(ctx context.Context, biz string, uid int64, bizID int64) error {
+	return cache.client.HDel(ctx, cache.likedKey(biz, uid), strconv.FormatInt(bizID, 10)).Err()
+}
+
+func (cache *interactiveCache) GetCollected(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error) {
+	return cache.getUserStates(ctx, cache.collectedKey(biz, uid), bizIDs)
+}
+
+func (cache *interactiveCache) FillCollected(ctx context.Context, biz string, uid int64, collected map[int64]bool) error {
+	return cache.fillUserStates(ctx, cache.collectedKey(biz, uid), collected)
+}
+
+func (cache *interactiveCache) DelCollected(ctx context.Context, biz string, uid int64, bizID int64) error {
+	return cache.client.HDel(ctx, cache.collectedKey(biz, uid), strconv.FormatInt(bizID, 10)).Err()
+}
+
+// 每个用户一个 hash，field 是 bizID，value 是 1 或者 0。
+// 用 hash 而不是 set，是因为要区分"没有点赞"和"没有缓存"，这样没点赞的也能命中缓存
+func (cache *interactiveCache) likedKey(biz string, uid int64) string {
+	return fmt.Sprintf("interactive:liked:%s:%d", biz, uid)
+}
+
+func (cache *interactiveCache) collectedKey(biz string, uid int64) string {
+	return fmt.Sprintf("interactive:collected:%s:%d", biz, uid)
+}
+
+func (cache *interactiveCache) getUserStates(ctx context.Context, key string, bizIDs []int64) (map[int64]bool, error) {
+	res := make(map[int64]bool, len(bizIDs))
+	if len(bizIDs) == 0 {
+		return res, nil
+	}
+	fields := slice.Map(bizIDs, func(idx int, src int64) string {
+		return strconv.FormatInt(src, 10)
+	})
+	vals, err := cache.client.HMGet(ctx, key, fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for idx, val := range vals {
+		if str, ok := val.(string); ok {
+			res[bizIDs[idx]] = str == "1"
+		}
+	}
+	return res, nil
+}
+
+func (cache *interactiveCache) fillUserStates(ctx context.Context, key string, states map[int64]bool) error {
+	if len(states) == 0 {
+		return nil
+	}
+	args := make([]any, 0, 2+2*len(states))
+	args = append(args, userStateMaxFields, int64(userStateExpiration.Seconds()))
+	for bizID, state := range states {
+		val := 0
+		if state {
+			val = 1
+		}
+		args = append(args, strconv.FormatInt(bizID, 10), val)
+	}
+	return cache.client.Eval(ctx, luaFillUserStates, []string{key}, args...).Err()
+}
@@ -0,0 +1,13 @@
+local key = KEYS[1]
+local maxFields = tonumber(ARGV[1])
+local expiration = tonumber(ARGV[2])
+-- 字段太多了就整个删掉重新开始，避免活跃用户的 hash 无限增长
+if redis.call("HLEN", key) + (#ARGV - 2) / 2 > maxFields then
+    redis.call("DEL", key)
+end
+-- 只补充没有的字段，查询数据库期间被更新过的以更新之后的为准
+for i = 3, #ARGV, 2 do
+    redis.call("HSETNX", key, ARGV[i], ARGV[i + 1])
+end
+redis.call("EXPIRE", key, expiration)
+return 1
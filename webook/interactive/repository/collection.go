@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+
+	"geektime-basic-go/webook/interactive/domain"
+	"geektime-basic-go/webook/interactive/repository/cache"
+	"geektime-basic-go/webook/interactive/repository/dao"
+	"geektime-basic-go/webook/pkg/gormx"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+var (
+	// ErrCollectionNotFound 收藏夹或者收藏不存在
+	ErrCollectionNotFound = dao.ErrDataNotFound
+	// ErrCollectedElsewhere 已经收藏到了别的收藏夹
+	ErrCollectedElsewhere = dao.ErrCollectedElsewhere
+)
+
+type CollectionRepository interface {
+	AddCollection(ctx context.Context, c domain.Collection) (int64, error)
+	RenameCollection(ctx context.Context, uid int64, cid int64, name string) error
+	SetCollectionPublic(ctx context.Context, uid int64, cid int64, public bool) error
+	// DeleteCollection 收藏夹里面的收藏会一起删除
+	DeleteCollection(ctx context.Context, uid int64, cid int64) error
+	GetCollection(ctx context.Context, cid int64) (domain.Collection, error)
+	ListCollections(ctx context.Context, uid int64) ([]domain.Collection, error)
+	ListPublicCollections(ctx context.Context, uid int64) ([]domain.Collection, error)
+	ListItems(ctx context.Context, uid int64, cid int64, maxID int64, limit int) ([]domain.CollectionItem, error)
+	MoveItem(ctx context.Context, uid int64, biz string, bizID int64, cid int64) error
+	// RemoveItem 返回收藏状态是否发生了变化，没有收藏过的返回 false
+	RemoveItem(ctx context.Context, uid int64, biz string, bizID int64) (bool, error)
+}
+
+type cacheCollectionRepository struct {
+	dao   dao.CollectionDAO
+	cache cache.InteractiveCache
+	l     logger.Logger
+}
+
+func NewCollectionRepository(dao dao.CollectionDAO, cache cache.InteractiveCache, l logger.Logger) CollectionRepository {
+	return &cacheCollectionRepository{dao: dao, cache: cache, l: l}
+}
+
+func (repo *cacheCollectionRepository) AddCollection(ctx context.Context, c domain.Collection) (int64, error) {
+	return repo.dao.Insert(ctx, dao.Collection{Uid: c.Uid, Name: c.Name, Public: c.Public})
+}
+
+func (repo *cacheCollectionRepository) RenameCollection(ctx context.Context, uid int64, cid int64, name string) error {
+	return repo.dao.UpdateName(ctx, uid, cid, name)
+}
+
+func (repo *cacheCollectionRepository) SetCollectionPublic(ctx context.Context, uid int64, cid int64, public bool) error {
+	return repo.dao.UpdatePublic(ctx, uid, cid, public)
+}
+
+func (repo *cacheCollectionRepository) DeleteCollection(ctx context.Context, uid int64, cid int64) error {
+	items, err := repo.dao.Delete(ctx, uid, cid)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		// 缓存更新失败不影响结果，缓存过期之后以数据库为准
+		if er := repo.cache.DecrCollectCntIfPresent(ctx, item.Biz, item.BizID); er != nil {
+			repo.l.Error("扣减缓存中的收藏数失败",
+				logger.String("biz", item.Biz),
+				logger.Int("bizID", item.BizID),
+				logger.Error(er))
+		}
+		repo.delCollected(ctx, uid, item.Biz, item.BizID)
+	}
+	return nil
+}
+
+func (repo *cacheCollectionRepository) GetCollection(ctx context.Context, cid int64) (domain.Collection, error) {
+	c, err := repo.dao.FindByID(ctx, cid)
+	if err != nil {
+		return domain.Collection{}, err
+	}
+	return repo.toDomain(c), nil
+}
+
+func (repo *cacheCollectionRepository) ListCollections(ctx context.Context, uid int64) ([]domain.Collection, error) {
+	cs, err := repo.dao.FindByUid(ctx, uid)
+	return repo.toDomains(cs), err
+}
+
+func (repo *cacheCollectionRepository) ListPublicCollections(ctx context.Context, uid int64) ([]domain.Collection, error) {
+	cs, err := repo.dao.FindPublicByUid(ctx, uid)
+	return repo.toDomains(cs), err
+}
+
+func (repo *cacheCollectionRepository) ListItems(ctx context.Context, uid int64, cid int64, maxID int64, limit int) ([]domain.CollectionItem, error) {
+	items, err := repo.dao.FindItems(ctx, uid, cid, maxID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(items, func(idx int, src dao.UserCollectionBiz) domain.CollectionItem {
+		return domain.CollectionItem{
+			ID:       src.ID,
+			Cid:      src.CID,
+			Biz:      src.Biz,
+			BizID:    src.BizID,
+			Uid:      src.UID,
+			CreateAt: time.UnixMilli(src.CreateAt),
+		}
+	}), nil
+}
+
+func (repo *cacheCollectionRepository) MoveItem(ctx context.Context, uid int64, biz string, bizID int64, cid int64) error {
+	return repo.dao.MoveItem(ctx, uid, biz, bizID, cid)
+}
+
+func (repo *cacheCollectionRepository) RemoveItem(ctx context.Context, uid int64, biz string, bizID int64) (bool, error) {
+	deleted, err := repo.dao.DeleteItem(ctx, uid, biz, bizID)
+	if err != nil {
+		return false, err
+	}
+	// 外面可能还有事务，提交之后再更新缓存，缓存失败只记录日志
+	gormx.AfterCommit(ctx, func(ctx context.Context) {
+		repo.delCollected(ctx, uid, biz, bizID)
+		if !deleted {
+			return
+		}
+		if er := repo.cache.DecrCollectCntIfPresent(ctx, biz, bizID); er != nil {
+			repo.l.Error("扣减缓存中的收藏数失败", logger.String("biz", biz), logger.Int("bizID", bizID), logger.Error(er))
+		}
+	})
+	return deleted, nil
+}
+
+func (repo *cacheCollectionRepository) delCollected(ctx context.Context, uid int64, biz string, bizID int64) {
+	if err := repo.cache.DelCollected(ctx, biz, uid, bizID); err != nil {
+		repo.l.Error("删除缓存中的收藏状态失败",
+			logger.String("biz", biz),
+			logger.Int("bizID", bizID),
+			logger.Int("uid", uid),
+			logger.Error(err))
+	}
+}
+
+func (repo *cacheCollectionRepository) toDomains(cs []dao.Collection) []domain.Collection {
+	return slice.Map(cs, func(idx int, src dao.Collection) domain.Collection {
+		return repo.toDomain(src)
+	})
+}
+
+func (repo *cacheCollectionRepository) toDomain(c dao.Collection) domain.Collection {
+	return domain.Collection{
+		ID:       c.ID,
+		Uid:      c.Uid,
+		Name:     c.Name,
+		Public:   c.Public,
+		CreateAt: time.UnixMilli(c.CreateAt),
+		UpdateAt: time.UnixMilli(c.UpdateAt),
+	}
+}
@@ -0,0 +1,193 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"gorm.io/gorm"
+
+	"geektime-basic-go/webook/pkg/gormx"
+)
+
+type CollectionDAO interface {
+	Insert(ctx context.Context, c Collection) (int64, error)
+	// UpdateName 只能修改自己的收藏夹，不存在或者不属于 uid 的返回 ErrDataNotFound
+	UpdateName(ctx context.Context, uid int64, cid int64, name string) error
+	UpdatePublic(ctx context.Context, uid int64, cid int64, public bool) error
+	// Delete 删除收藏夹以及里面的收藏，同时扣减对应资源的收藏数，返回被删除的收藏
+	Delete(ctx context.Context, uid int64, cid int64) ([]UserCollectionBiz, error)
+	FindByID(ctx context.Context, cid int64) (Collection, error)
+	FindByUid(ctx context.Context, uid int64) ([]Collection, error)
+	FindPublicByUid(ctx context.Context, uid int64) ([]Collection, error)
+	// FindItems 按照 ID 倒序翻页，maxID 为 0 代表第一页
+	FindItems(ctx context.Context, uid int64, cid int64, maxID int64, limit int) ([]UserCollectionBiz, error)
+	// MoveItem 把收藏移动到 cid 收藏夹，没有收藏过返回 ErrDataNotFound
+	MoveItem(ctx context.Context, uid int64, biz string, bizID int64, cid int64) error
+	// DeleteItem 取消收藏（软删除），同时扣减收藏数。没有收藏过的返回 false
+	DeleteItem(ctx context.Context, uid int64, biz string, bizID int64) (bool, error)
+}
+
+// Collection 收藏夹
+type Collection struct {
+	ID   int64  `gorm:"primaryKey,autoIncrement"`
+	Name string `gorm:"type:varchar(256)"`
+	Uid  int64  `gorm:"index"`
+	// Public 公开的收藏夹别人也可以浏览
+	Public   bool
+	CreateAt int64
+	UpdateAt int64
+}
+
+type gormCollectionDAO struct {
+	db *gorm.DB
+}
+
+func NewGormCollectionDAO(db *gorm.DB) CollectionDAO {
+	return &gormCollectionDAO{db: db}
+}
+
+func (dao *gormCollectionDAO) Insert(ctx context.Context, c Collection) (int64, error) {
+	now := time.Now().UnixMilli()
+	c.CreateAt, c.UpdateAt = now, now
+	err := dao.db.WithContext(ctx).Create(&c).Error
+	return c.ID, err
+}
+
+func (dao *gormCollectionDAO) UpdateName(ctx context.Context, uid int64, cid int64, name string) error {
+	return dao.update(ctx, uid, cid, map[string]any{"name": name})
+}
+
+func (dao *gormCollectionDAO) UpdatePublic(ctx context.Context, uid int64, cid int64, public bool) error {
+	return dao.update(ctx, uid, cid, map[string]any{"public": public})
+}
+
+func (dao *gormCollectionDAO) update(ctx context.Context, uid int64, cid int64, values map[string]any) error {
+	values["update_at"] = time.Now().UnixMilli()
+	res := dao.db.WithContext(ctx).Model(&Collection{}).
+		Where("id = ? AND uid = ?", cid, uid).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// 值没变的时候 update_at 依旧会变，所以 0 行只可能是没找到
+		return ErrDataNotFound
+	}
+	return nil
+}
+
+func (dao *gormCollectionDAO) Delete(ctx context.Context, uid int64, cid int64) ([]UserCollectionBiz, error) {
+	var items []UserCollectionBiz
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND uid = ?", cid, uid).Delete(&Collection{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrDataNotFound
+		}
+		err := tx.Where("cid = ? AND uid = ? AND status = ?", cid, uid, UserCollectionBizStatusValid).
+			Find(&items).Error
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		now := time.Now().UnixMilli()
+		ids := slice.Map(items, func(idx int, src UserCollectionBiz) int64 {
+			return src.ID
+		})
+		err = tx.Model(&UserCollectionBiz{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":    UserCollectionBizStatusInvalid,
+			"update_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := dao.decrCollectCnt(tx, item.Biz, item.BizID, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return items, err
+}
+
+func (dao *gormCollectionDAO) FindByID(ctx context.Context, cid int64) (Collection, error) {
+	var res Collection
+	err := dao.db.WithContext(ctx).Where("id = ?", cid).First(&res).Error
+	return res, err
+}
+
+func (dao *gormCollectionDAO) FindByUid(ctx context.Context, uid int64) ([]Collection, error) {
+	var res []Collection
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).Order("id ASC").Find(&res).Error
+	return res, err
+}
+
+func (dao *gormCollectionDAO) FindPublicByUid(ctx context.Context, uid int64) ([]Collection, error) {
+	var res []Collection
+	err := dao.db.WithContext(ctx).Where("uid = ? AND public = ?", uid, true).Order("id ASC").Find(&res).Error
+	return res, err
+}
+
+func (dao *gormCollectionDAO) FindItems(ctx context.Context, uid int64, cid int64, maxID int64, limit int) ([]UserCollectionBiz, error) {
+	query := dao.db.WithContext(ctx).Where("uid = ? AND cid = ? AND status = ?", uid, cid, UserCollectionBizStatusValid)
+	if maxID > 0 {
+		query = query.Where("id < ?", maxID)
+	}
+	var res []UserCollectionBiz
+	err := query.Order("id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *gormCollectionDAO) MoveItem(ctx context.Context, uid int64, biz string, bizID int64, cid int64) error {
+	res := dao.db.WithContext(ctx).Model(&UserCollectionBiz{}).
+		Where("uid = ? AND biz = ? AND biz_id = ? AND status = ?", uid, biz, bizID, UserCollectionBizStatusValid).
+		Updates(map[string]any{
+			"cid":       cid,
+			"update_at": time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDataNotFound
+	}
+	return nil
+}
+
+func (dao *gormCollectionDAO) DeleteItem(ctx context.Context, uid int64, biz string, bizID int64) (bool, error) {
+	var deleted bool
+	// ctx 里面有事务就在这个事务里写，和收藏事件一起提交
+	err := gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UserCollectionBiz{}).
+			Where("uid = ? AND biz = ? AND biz_id = ? AND status = ?", uid, biz, bizID, UserCollectionBizStatusValid).
+			Updates(map[string]any{
+				"status":    UserCollectionBizStatusInvalid,
+				"update_at": time.Now().UnixMilli(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		// 重复取消收藏的时候不能重复扣减
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		deleted = true
+		return dao.decrCollectCnt(tx, biz, bizID, time.Now().UnixMilli())
+	})
+	return deleted, err
+}
+
+func (dao *gormCollectionDAO) decrCollectCnt(tx *gorm.DB, biz string, bizID int64, now int64) error {
+	return tx.Model(&Interactive{}).
+		Where("biz = ? AND biz_id = ?", biz, bizID).
+		Updates(map[string]any{
+			"collect_cnt": gorm.Expr("GREATEST(`collect_cnt`-1, 0)"),
+			"update_at":   now,
+		}).Error
+}
@@ -1,6 +1,13 @@
 package dao
 
-import "gorm.io/gorm"
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
 
 // ErrDataNotFound 通用的数据没找到
 var ErrDataNotFound = gorm.ErrRecordNotFound
+
+// ErrCollectedElsewhere 已经收藏到了别的收藏夹，需要移动而不是再收藏一次
+var ErrCollectedElsewhere = errors.New("已经收藏到了别的收藏夹")
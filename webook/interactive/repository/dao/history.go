@@ -0,0 +1,66 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HistoryRecordDAO interface {
+	// Upsert 同一个用户同一个资源只保留一条记录，阅读时间取最新的
+	Upsert(ctx context.Context, records []HistoryRecord) error
+	// List 按照 (read_at, id) 倒序翻页，maxReadAt 为 0 代表第一页
+	List(ctx context.Context, uid int64, maxReadAt int64, maxID int64, limit int) ([]HistoryRecord, error)
+	Delete(ctx context.Context, uid int64, biz string, bizID int64) error
+	DeleteAll(ctx context.Context, uid int64) error
+}
+
+// HistoryRecord 阅读历史
+type HistoryRecord struct {
+	ID     int64  `gorm:"primaryKey,autoIncrement"`
+	Uid    int64  `gorm:"uniqueIndex:uid_biz_type_id;index:uid_read_at"`
+	Biz    string `gorm:"type:varchar(128);uniqueIndex:uid_biz_type_id"`
+	BizID  int64  `gorm:"uniqueIndex:uid_biz_type_id"`
+	ReadAt int64  `gorm:"index:uid_read_at"`
+}
+
+type gormHistoryRecordDAO struct {
+	db *gorm.DB
+}
+
+func NewGormHistoryRecordDAO(db *gorm.DB) HistoryRecordDAO {
+	return &gormHistoryRecordDAO{db: db}
+}
+
+func (dao *gormHistoryRecordDAO) Upsert(ctx context.Context, records []HistoryRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]any{
+			// 消息可能乱序，不能让旧的阅读时间覆盖新的
+			"read_at": gorm.Expr("GREATEST(`read_at`, VALUES(`read_at`))"),
+		}),
+	}).Create(&records).Error
+}
+
+func (dao *gormHistoryRecordDAO) List(ctx context.Context, uid int64, maxReadAt int64, maxID int64, limit int) ([]HistoryRecord, error) {
+	query := dao.db.WithContext(ctx).Where("uid = ?", uid)
+	if maxReadAt > 0 {
+		query = query.Where("read_at < ? OR (read_at = ? AND id < ?)", maxReadAt, maxReadAt, maxID)
+	}
+	var res []HistoryRecord
+	err := query.Order("read_at DESC, id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *gormHistoryRecordDAO) Delete(ctx context.Context, uid int64, biz string, bizID int64) error {
+	return dao.db.WithContext(ctx).
+		Where("uid = ? AND biz = ? AND biz_id = ?", uid, biz, bizID).
+		Delete(&HistoryRecord{}).Error
+}
+
+func (dao *gormHistoryRecordDAO) DeleteAll(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Where("uid = ?", uid).Delete(&HistoryRecord{}).Error
+}
@@ -1,12 +1,19 @@
 package dao
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+
+	"geektime-basic-go/webook/pkg/outbox"
+)
 
 func InitTables(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&Interactive{},
 		&UserLikeBiz{},
+		&ProcessedEvent{},
 		&Collection{},
 		&UserCollectionBiz{},
+		&HistoryRecord{},
+		&outbox.Message{},
 	)
 }
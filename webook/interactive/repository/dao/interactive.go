@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"geektime-basic-go/webook/pkg/gormx"
 	"geektime-basic-go/webook/pkg/migrator"
 
 	"gorm.io/gorm"
@@ -14,15 +15,35 @@ type InteractiveDAO interface {
 	Get(ctx context.Context, biz string, bizID int64) (Interactive, error)
 	GetLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) (UserLikeBiz, error)
 	GetCollectionInfo(ctx context.Context, biz string, bizID int64, uid int64) (UserCollectionBiz, error)
-	InsertCollectionBiz(ctx context.Context, biz UserCollectionBiz) error
+	// InsertCollectionBiz 已经收藏到同一个收藏夹的什么也不做，返回 false
+	// 已经收藏到别的收藏夹的返回 ErrCollectedElsewhere
+	InsertCollectionBiz(ctx context.Context, biz UserCollectionBiz) (bool, error)
 	IncrReadCnt(ctx context.Context, biz string, bizID int64) error
-	BatchIncrReadCnt(ctx context.Context, bizs []string, bizIDs []int64) error
-	InsertLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) error
-	BatchInsertLikeInfo(ctx context.Context, biz string, bizIDs []int64, uids []int64) error
-	DeleteLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) error
-	BatchDeleteLikeInfo(ctx context.Context, biz string, bizIDs []int64, uids []int64) error
+	// IncrCommentCnts 在一个事务里处理一批评论数事件，Key 已经处理过的事件会被跳过，
+	// 返回真正计数了的事件。delta 可以是负数，评论数最小为 0
+	IncrCommentCnts(ctx context.Context, changes []CommentCntChange) ([]CommentCntChange, error)
+	// BatchIncrReadCnt 在一个事务里处理一批阅读事件，keys 和 ChangeLikes 的 Key 一样，已经处理过的事件会被跳过
+	BatchIncrReadCnt(ctx context.Context, keys []string, bizs []string, bizIDs []int64) error
+	// InsertLikeInfo 只有从没点赞变成点赞才会增加点赞数，返回点赞状态是否发生了变化
+	InsertLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) (bool, error)
+	// DeleteLikeInfo 只有从点赞变成没点赞才会减少点赞数，返回点赞状态是否发生了变化
+	DeleteLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) (bool, error)
+	// ChangeLikes 在一个事务里按顺序处理点赞事件，Key 已经处理过的事件会被跳过，
+	// 返回真正改变了点赞状态的事件。ctx 里面有事务的时候使用外面的事务
+	ChangeLikes(ctx context.Context, biz string, changes []LikeChange) ([]LikeChange, error)
+	// DeleteProcessedEventsBefore 清理 createAt 之前的去重记录
+	DeleteProcessedEventsBefore(ctx context.Context, createAt int64) (int64, error)
+	// FindLikeCntDrift 按照 ID 从 startID 之后开始找 limit 条互动数据
+	// 返回其中 like_cnt 和实际点赞数不一致的，以及这一批最后一条的 ID
+	FindLikeCntDrift(ctx context.Context, startID int64, limit int) ([]Interactive, int64, error)
+	// FixLikeCnt 用 UserLikeBiz 重新计算 like_cnt
+	FixLikeCnt(ctx context.Context, id int64) error
 	GetMultipleLikeCnt(ctx context.Context, biz string, bizIDs []int64) ([]Interactive, error)
 	GetByIDs(ctx context.Context, biz string, bizIDs []int64) ([]Interactive, error)
+	// FindLikedBizIDs 找出 bizIDs 中 uid 点赞过的
+	FindLikedBizIDs(ctx context.Context, biz string, uid int64, bizIDs []int64) ([]int64, error)
+	// FindCollectedBizIDs 找出 bizIDs 中 uid 收藏过的
+	FindCollectedBizIDs(ctx context.Context, biz string, uid int64, bizIDs []int64) ([]int64, error)
 }
 
 type gormDAO struct {
@@ -40,6 +61,7 @@ type Interactive struct {
 	ReadCnt    int64
 	CollectCnt int64
 	LikeCnt    int64
+	CommentCnt int64
 	CreateAt   int64
 	UpdateAt   int64
 }
@@ -66,22 +88,44 @@ type UserLikeBiz struct {
 	UpdateAt int64
 }
 
-// UserCollectionBiz 收藏的东西
-type UserCollectionBiz struct {
-	ID       int64  `gorm:"primaryKey,autoIncrement"`
-	CID      int64  `gorm:"index"`
-	BizID    int64  `gorm:"uniqueIndex:biz_type_id_uid"`
-	Biz      string `gorm:"type:varchar(128);uniqueIndex:biz_type_id_uid"`
-	UID      int64  `gorm:"uniqueIndex:biz_type_id_uid"`
-	CreateAt int64
-	UpdateAt int64
+// ProcessedEvent 处理过的事件，点赞、阅读、评论数这些计数的消费者都用它保证重复投递的消息只会处理一次
+type ProcessedEvent struct {
+	ID int64 `gorm:"primaryKey,autoIncrement"`
+	// Key 事件的唯一标识，见 saramax.EventKey
+	Key      string `gorm:"type:varchar(128);uniqueIndex"`
+	CreateAt int64  `gorm:"index"`
 }
 
-// Collection 收藏夹
-type Collection struct {
-	Id       int64  `gorm:"primaryKey,autoIncrement"`
-	Name     string `gorm:"type=varchar(1024)"`
-	Uid      int64  `gorm:""`
+// LikeChange 一次点赞或者取消点赞，Key 为空的不去重
+type LikeChange struct {
+	Key   string
+	BizID int64
+	Uid   int64
+	Liked bool
+}
+
+// CommentCntChange 一次评论数变化，Key 为空的不去重
+type CommentCntChange struct {
+	Key   string
+	Biz   string
+	BizID int64
+	Delta int64
+}
+
+const (
+	UserCollectionBizStatusInvalid uint8 = 0
+	UserCollectionBizStatusValid   uint8 = 1
+)
+
+// UserCollectionBiz 收藏的东西
+type UserCollectionBiz struct {
+	ID    int64  `gorm:"primaryKey,autoIncrement"`
+	CID   int64  `gorm:"index"`
+	BizID int64  `gorm:"uniqueIndex:biz_type_id_uid"`
+	Biz   string `gorm:"type:varchar(128);uniqueIndex:biz_type_id_uid"`
+	UID   int64  `gorm:"uniqueIndex:biz_type_id_uid"`
+	// Status 取消收藏是软删除。默认值是 1，这样加字段之前的收藏依旧有效
+	Status   uint8 `gorm:"default:1"`
 	CreateAt int64
 	UpdateAt int64
 }
@@ -90,10 +134,18 @@ func (dao *gormDAO) IncrReadCnt(ctx context.Context, biz string, bizId int64) er
 	return dao.incrReadCnt(dao.db.WithContext(ctx), biz, bizId)
 }
 
-func (dao *gormDAO) BatchIncrReadCnt(ctx context.Context, bizs []string, bizIDs []int64) error {
+func (dao *gormDAO) BatchIncrReadCnt(ctx context.Context, keys []string, bizs []string, bizIDs []int64) error {
 	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
 		for i := 0; i < len(bizs); i++ {
-			if err := dao.incrReadCnt(tx, bizs[i], bizIDs[i]); err != nil {
+			ok, err := dao.markEvent(tx, keys[i], now)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if err = dao.incrReadCnt(tx, bizs[i], bizIDs[i]); err != nil {
 				return err
 			}
 		}
@@ -117,6 +169,71 @@ func (dao *gormDAO) incrReadCnt(db *gorm.DB, biz string, bizID int64) error {
 	}).Error
 }
 
+func (dao *gormDAO) IncrCommentCnts(ctx context.Context, changes []CommentCntChange) ([]CommentCntChange, error) {
+	type key struct {
+		biz   string
+		bizID int64
+	}
+	var applied []CommentCntChange
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		applied = make([]CommentCntChange, 0, len(changes))
+		now := time.Now().UnixMilli()
+		// 同一个资源的变化先合并，减少写数据库的次数
+		deltas := make(map[key]int64, len(changes))
+		for _, c := range changes {
+			ok, err := dao.markEvent(tx, c.Key, now)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			deltas[key{biz: c.Biz, bizID: c.BizID}] += c.Delta
+			applied = append(applied, c)
+		}
+		for k, delta := range deltas {
+			if delta == 0 {
+				continue
+			}
+			if err := dao.incrCommentCnt(tx, k.biz, k.bizID, delta, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (dao *gormDAO) incrCommentCnt(tx *gorm.DB, biz string, bizID int64, delta int64, now int64) error {
+	return tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"comment_cnt": gorm.Expr("GREATEST(`comment_cnt`+?, 0)", delta),
+			"update_at":   now,
+		}),
+	}).Create(&Interactive{
+		CommentCnt: max(delta, 0),
+		CreateAt:   now,
+		UpdateAt:   now,
+		Biz:        biz,
+		BizID:      bizID,
+	}).Error
+}
+
+// markEvent 和业务数据在同一个事务里记下处理过的事件，返回 false 说明已经处理过了。key 为空的不去重
+func (dao *gormDAO) markEvent(tx *gorm.DB, key string, now int64) (bool, error) {
+	if key == "" {
+		return true, nil
+	}
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ProcessedEvent{Key: key, CreateAt: now})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
 func (dao *gormDAO) Get(ctx context.Context, biz string, bizID int64) (Interactive, error) {
 	var res Interactive
 	err := dao.db.WithContext(ctx).Where("biz = ? AND biz_id = ?", biz, bizID).Find(&res).Error
@@ -131,47 +248,96 @@ func (dao *gormDAO) GetLikeInfo(ctx context.Context, biz string, bizID int64, ui
 
 func (dao *gormDAO) GetCollectionInfo(ctx context.Context, biz string, bizID int64, uid int64) (UserCollectionBiz, error) {
 	var res UserCollectionBiz
-	err := dao.db.WithContext(ctx).Where("biz = ? AND biz_id = ? AND uid = ?", biz, bizID, uid).First(&res).Error
+	err := dao.db.WithContext(ctx).
+		Where("biz = ? AND biz_id = ? AND uid = ? AND status = ?", biz, bizID, uid, UserCollectionBizStatusValid).
+		First(&res).Error
 	return res, err
 }
 
-func (dao *gormDAO) InsertLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) error {
-	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		return dao.insertLikeInfo(tx, biz, bizID, uid)
+func (dao *gormDAO) InsertLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		changed, err = dao.insertLikeInfo(tx, biz, bizID, uid)
+		return
 	})
+	return changed, err
 }
 
-func (dao *gormDAO) BatchInsertLikeInfo(ctx context.Context, biz string, bizIDs []int64, uids []int64) error {
-	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		for i := 0; i < len(bizIDs); i++ {
-			if err := dao.insertLikeInfo(tx, biz, bizIDs[i], uids[i]); err != nil {
+func (dao *gormDAO) DeleteLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		changed, err = dao.deleteLikeInfo(tx, biz, bizID, uid)
+		return
+	})
+	return changed, err
+}
+
+func (dao *gormDAO) ChangeLikes(ctx context.Context, biz string, changes []LikeChange) ([]LikeChange, error) {
+	var applied []LikeChange
+	err := gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		applied = make([]LikeChange, 0, len(changes))
+		now := time.Now().UnixMilli()
+		for _, c := range changes {
+			// 去重记录和点赞数在同一个事务里，要么都成功要么都失败
+			changed, err := dao.markEvent(tx, c.Key, now)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				continue
+			}
+
+			if c.Liked {
+				changed, err = dao.insertLikeInfo(tx, biz, c.BizID, c.Uid)
+			} else {
+				changed, err = dao.deleteLikeInfo(tx, biz, c.BizID, c.Uid)
+			}
+			if err != nil {
 				return err
 			}
+			if changed {
+				applied = append(applied, c)
+			}
 		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
 }
 
-func (dao *gormDAO) insertLikeInfo(db *gorm.DB, biz string, bizID int64, uid int64) error {
+func (dao *gormDAO) insertLikeInfo(tx *gorm.DB, biz string, bizID int64, uid int64) (bool, error) {
 	now := time.Now().UnixMilli()
-	err := db.Clauses(clause.OnConflict{
-		DoUpdates: clause.Assignments(map[string]interface{}{
+	// 先尝试恢复之前取消的点赞
+	res := tx.Model(&UserLikeBiz{}).
+		Where("biz = ? AND biz_id = ? AND uid = ? AND status = ?", biz, bizID, uid, 0).
+		Updates(map[string]any{
 			"status":    1,
 			"update_at": now,
-		}),
-	}).Create(&UserLikeBiz{
-		BizID:    bizID,
-		Biz:      biz,
-		UID:      uid,
-		Status:   1,
-		CreateAt: now,
-		UpdateAt: now,
-	}).Error
-	if err != nil {
-		return err
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UserLikeBiz{
+			BizID:    bizID,
+			Biz:      biz,
+			UID:      uid,
+			Status:   1,
+			CreateAt: now,
+			UpdateAt: now,
+		})
+		if res.Error != nil {
+			return false, res.Error
+		}
+		// 已经点赞过了，不能重复计数
+		if res.RowsAffected == 0 {
+			return false, nil
+		}
 	}
 
-	return db.Clauses(clause.OnConflict{
+	return true, tx.Clauses(clause.OnConflict{
 		DoUpdates: clause.Assignments(map[string]interface{}{
 			"like_cnt":  gorm.Expr("`like_cnt`+1"),
 			"update_at": now,
@@ -185,55 +351,94 @@ func (dao *gormDAO) insertLikeInfo(db *gorm.DB, biz string, bizID int64, uid int
 	}).Error
 }
 
-func (dao *gormDAO) DeleteLikeInfo(ctx context.Context, biz string, bizID int64, uid int64) error {
-	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		return dao.deleteLikeInfo(tx, biz, bizID, uid)
-	})
+func (dao *gormDAO) deleteLikeInfo(tx *gorm.DB, biz string, bizID int64, uid int64) (bool, error) {
+	now := time.Now().UnixMilli()
+	res := tx.Model(&UserLikeBiz{}).
+		Where("biz = ? AND biz_id = ? AND uid = ? AND status = ?", biz, bizID, uid, 1).
+		Updates(map[string]any{
+			"status":    0,
+			"update_at": now,
+		})
+	// 没有点赞过，或者已经取消了
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	return true, tx.Model(&Interactive{}).
+		Where("biz = ? AND biz_id = ?", biz, bizID).
+		Updates(map[string]any{
+			"like_cnt":  gorm.Expr("GREATEST(`like_cnt`-1, 0)"),
+			"update_at": now,
+		}).Error
 }
 
-func (dao *gormDAO) BatchDeleteLikeInfo(ctx context.Context, biz string, bizIDs []int64, uids []int64) error {
-	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		for i := 0; i < len(bizIDs); i++ {
-			if err := dao.deleteLikeInfo(tx, biz, bizIDs[i], uids[i]); err != nil {
-				return err
-			}
+func (dao *gormDAO) DeleteProcessedEventsBefore(ctx context.Context, createAt int64) (int64, error) {
+	res := dao.db.WithContext(ctx).Where("create_at < ?", createAt).Delete(&ProcessedEvent{})
+	return res.RowsAffected, res.Error
+}
+
+func (dao *gormDAO) FindLikeCntDrift(ctx context.Context, startID int64, limit int) ([]Interactive, int64, error) {
+	var rows []struct {
+		Interactive   `gorm:"embedded"`
+		ActualLikeCnt int64
+	}
+	err := dao.db.WithContext(ctx).Model(&Interactive{}).
+		Select("interactives.*, (SELECT COUNT(*) FROM `user_like_bizs` u "+
+			"WHERE u.biz = interactives.biz AND u.biz_id = interactives.biz_id AND u.status = 1) AS actual_like_cnt").
+		Where("id > ?", startID).
+		Order("id ASC").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, 0, err
+	}
+	var res []Interactive
+	for _, row := range rows {
+		if row.LikeCnt != row.ActualLikeCnt {
+			res = append(res, row.Interactive)
 		}
-		return nil
-	})
+	}
+	return res, rows[len(rows)-1].ID, nil
 }
 
-func (dao *gormDAO) deleteLikeInfo(db *gorm.DB, biz string, bizID int64, uid int64) error {
-	now := time.Now().UnixMilli()
-	err := db.Model(&UserLikeBiz{}).
-		Where("biz = ? AND biz_id = ? AND uid = ?", biz, bizID, uid).
+func (dao *gormDAO) FixLikeCnt(ctx context.Context, id int64) error {
+	// 在一条语句里面重新计数，避免和正在处理的点赞事件相互覆盖
+	return dao.db.WithContext(ctx).Model(&Interactive{}).
+		Where("id = ?", id).
 		Updates(map[string]any{
-			"status":    0,
-			"update_at": now,
+			"like_cnt": gorm.Expr("(SELECT COUNT(*) FROM `user_like_bizs` u " +
+				"WHERE u.biz = interactives.biz AND u.biz_id = interactives.biz_id AND u.status = 1)"),
+			"update_at": time.Now().UnixMilli(),
 		}).Error
-	if err != nil {
-		return err
-	}
-	return db.Clauses(clause.OnConflict{
-		DoUpdates: clause.Assignments(map[string]any{
-			"like_cnt":  gorm.Expr("`like_cnt`-1"),
-			"update_at": now,
-		}),
-	}).Create(&Interactive{
-		LikeCnt:  1,
-		CreateAt: now,
-		UpdateAt: now,
-		Biz:      biz,
-		BizID:    bizID,
-	}).Error
 }
 
-func (dao *gormDAO) InsertCollectionBiz(ctx context.Context, cb UserCollectionBiz) error {
+func (dao *gormDAO) InsertCollectionBiz(ctx context.Context, cb UserCollectionBiz) (bool, error) {
 	now := time.Now().UnixMilli()
-	cb.CreateAt, cb.UpdateAt = now, now
-	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if err := dao.db.WithContext(ctx).Create(&cb).Error; err != nil {
-			return err
+	cb.Status, cb.CreateAt, cb.UpdateAt = UserCollectionBizStatusValid, now, now
+	var inserted bool
+	// ctx 里面有事务就在这个事务里写，和收藏事件一起提交
+	err := gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先尝试恢复之前取消的收藏
+		res := tx.Model(&UserCollectionBiz{}).
+			Where("uid = ? AND biz = ? AND biz_id = ? AND status = ?", cb.UID, cb.Biz, cb.BizID, UserCollectionBizStatusInvalid).
+			Updates(map[string]any{
+				"cid":       cb.CID,
+				"status":    UserCollectionBizStatusValid,
+				"update_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
 		}
+		if res.RowsAffected == 0 {
+			res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&cb)
+			if res.Error != nil {
+				return res.Error
+			}
+			// 已经收藏过了，不能重复计数
+			if res.RowsAffected == 0 {
+				return dao.checkCollectedCid(tx, cb)
+			}
+		}
+		inserted = true
 		return tx.Clauses(clause.OnConflict{
 			DoUpdates: clause.Assignments(map[string]any{
 				"collect_cnt": gorm.Expr("`collect_cnt`+1"),
@@ -247,6 +452,22 @@ func (dao *gormDAO) InsertCollectionBiz(ctx context.Context, cb UserCollectionBi
 			BizID:      cb.BizID,
 		}).Error
 	})
+	return inserted, err
+}
+
+// checkCollectedCid 已经收藏过了，收藏夹不一样的时候不能悄悄忽略
+func (dao *gormDAO) checkCollectedCid(tx *gorm.DB, cb UserCollectionBiz) error {
+	var cid int64
+	err := tx.Model(&UserCollectionBiz{}).
+		Where("uid = ? AND biz = ? AND biz_id = ?", cb.UID, cb.Biz, cb.BizID).
+		Select("cid").Scan(&cid).Error
+	if err != nil {
+		return err
+	}
+	if cid != cb.CID {
+		return ErrCollectedElsewhere
+	}
+	return nil
 }
 
 func (dao *gormDAO) GetMultipleLikeCnt(ctx context.Context, biz string, bizIDs []int64) ([]Interactive, error) {
@@ -260,6 +481,22 @@ func (dao *gormDAO) GetMultipleLikeCnt(ctx context.Context, biz string, bizIDs [
 
 func (dao *gormDAO) GetByIDs(ctx context.Context, biz string, bizIDs []int64) ([]Interactive, error) {
 	var res []Interactive
-	err := dao.db.WithContext(ctx).Where("biz = ? AND id IN ?", biz, bizIDs).Find(&res).Error
+	err := dao.db.WithContext(ctx).Where("biz = ? AND biz_id IN ?", biz, bizIDs).Find(&res).Error
+	return res, err
+}
+
+func (dao *gormDAO) FindLikedBizIDs(ctx context.Context, biz string, uid int64, bizIDs []int64) ([]int64, error) {
+	var res []int64
+	err := dao.db.WithContext(ctx).Model(&UserLikeBiz{}).
+		Where("biz_id IN ? AND biz = ? AND uid = ? AND status = ?", bizIDs, biz, uid, 1).
+		Pluck("biz_id", &res).Error
+	return res, err
+}
+
+func (dao *gormDAO) FindCollectedBizIDs(ctx context.Context, biz string, uid int64, bizIDs []int64) ([]int64, error) {
+	var res []int64
+	err := dao.db.WithContext(ctx).Model(&UserCollectionBiz{}).
+		Where("biz_id IN ? AND biz = ? AND uid = ? AND status = ?", bizIDs, biz, uid, UserCollectionBizStatusValid).
+		Pluck("biz_id", &res).Error
 	return res, err
 }
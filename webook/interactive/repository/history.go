@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+
+	"geektime-basic-go/webook/interactive/domain"
+	"geektime-basic-go/webook/interactive/repository/dao"
+)
+
+type HistoryRecordRepository interface {
+	AddRecord(ctx context.Context, r domain.HistoryRecord) error
+	BatchAddRecord(ctx context.Context, rs []domain.HistoryRecord) error
+	// List 返回 cursor 之后的一页，cursor 是上一页最后一条记录，第一页传零值
+	List(ctx context.Context, uid int64, cursor domain.HistoryRecord, limit int) ([]domain.HistoryRecord, error)
+	Delete(ctx context.Context, uid int64, biz string, bizID int64) error
+	Clear(ctx context.Context, uid int64) error
+}
+
+type historyRecordRepository struct {
+	dao dao.HistoryRecordDAO
+}
+
+func NewHistoryRecordRepository(dao dao.HistoryRecordDAO) HistoryRecordRepository {
+	return &historyRecordRepository{dao: dao}
+}
+
+func (repo *historyRecordRepository) AddRecord(ctx context.Context, r domain.HistoryRecord) error {
+	return repo.BatchAddRecord(ctx, []domain.HistoryRecord{r})
+}
+
+func (repo *historyRecordRepository) BatchAddRecord(ctx context.Context, rs []domain.HistoryRecord) error {
+	return repo.dao.Upsert(ctx, slice.Map(rs, func(idx int, src domain.HistoryRecord) dao.HistoryRecord {
+		return dao.HistoryRecord{
+			Uid:    src.Uid,
+			Biz:    src.Biz,
+			BizID:  src.BizID,
+			ReadAt: src.ReadAt.UnixMilli(),
+		}
+	}))
+}
+
+func (repo *historyRecordRepository) List(ctx context.Context, uid int64, cursor domain.HistoryRecord, limit int) ([]domain.HistoryRecord, error) {
+	var maxReadAt int64
+	if !cursor.ReadAt.IsZero() {
+		maxReadAt = cursor.ReadAt.UnixMilli()
+	}
+	records, err := repo.dao.List(ctx, uid, maxReadAt, cursor.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(records, func(idx int, src dao.HistoryRecord) domain.HistoryRecord {
+		return domain.HistoryRecord{
+			ID:     src.ID,
+			Biz:    src.Biz,
+			BizID:  src.BizID,
+			Uid:    src.Uid,
+			ReadAt: time.UnixMilli(src.ReadAt),
+		}
+	}), nil
+}
+
+func (repo *historyRecordRepository) Delete(ctx context.Context, uid int64, biz string, bizID int64) error {
+	return repo.dao.Delete(ctx, uid, biz, bizID)
+}
+
+func (repo *historyRecordRepository) Clear(ctx context.Context, uid int64) error {
+	return repo.dao.DeleteAll(ctx, uid)
+}
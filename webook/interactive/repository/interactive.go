@@ -5,27 +5,43 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/ecodeclub/ekit/slice"
 
 	"geektime-basic-go/webook/interactive/domain"
 	"geektime-basic-go/webook/interactive/repository/cache"
 	"geektime-basic-go/webook/interactive/repository/dao"
+	"geektime-basic-go/webook/pkg/gormx"
 	"geektime-basic-go/webook/pkg/logger"
 )
 
 type InteractiveRepository interface {
 	IncrReadCnt(ctx context.Context, biz string, bizID int64) error
+	// IncrCommentCnts 处理一批评论数事件，重复的事件不会计数
+	IncrCommentCnts(ctx context.Context, changes []domain.CommentCntChange) error
 	IncrLike(ctx context.Context, biz string, bizID int64, uid int64) error
 	DecrLike(ctx context.Context, biz string, bizID int64, uid int64) error
-	AddCollectionItem(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error
+	// AddCollectionItem 返回收藏状态是否发生了变化，已经收藏到别的收藏夹的返回 ErrCollectedElsewhere
+	AddCollectionItem(ctx context.Context, biz string, bizID int64, cid int64, uid int64) (bool, error)
 	Get(ctx context.Context, biz string, bizID int64) (domain.Interactive, error)
 	Liked(ctx context.Context, biz string, bizID int64, uid int64) (bool, error)
 	Collected(ctx context.Context, biz string, bizID int64, uid int64) (bool, error)
-	BatchIncrReadCnt(ctx context.Context, bizs []string, bizIDs []int64) error
-	BatchIncrLike(ctx context.Context, biz string, bizIDs []int64, uids []int64) error
-	BatchDecrLike(ctx context.Context, biz string, bizIDs []int64, uids []int64) error
+	// BatchIncrReadCnt keys 用来去重，重复的事件不会计数
+	BatchIncrReadCnt(ctx context.Context, keys []string, bizs []string, bizIDs []int64) error
+	// ChangeLikes 按顺序处理一批点赞事件，重复的事件和没有改变点赞状态的事件不会计数。
+	// 返回真正改变了点赞状态的事件，ctx 里有事务的时候在同一个事务里执行
+	ChangeLikes(ctx context.Context, biz string, changes []domain.LikeChange) ([]domain.LikeChange, error)
+	// ReconcileLikeCnt 从 startID 之后开始检查 limit 条互动数据，修正点赞数
+	// 返回下一批的 startID，为 0 代表已经检查完了，以及修正的数量
+	ReconcileLikeCnt(ctx context.Context, startID int64, limit int) (int64, int, error)
+	// DeleteProcessedEventsBefore 清理 t 之前的事件去重记录
+	DeleteProcessedEventsBefore(ctx context.Context, t time.Time) (int64, error)
 	GetByIDs(ctx context.Context, biz string, bizIDs []int64) ([]domain.Interactive, error)
+	// LikedByIDs 批量查询 uid 是否点赞过，结果包含所有的 bizIDs
+	LikedByIDs(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error)
+	// CollectedByIDs 批量查询 uid 是否收藏过，结果包含所有的 bizIDs
+	CollectedByIDs(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error)
 }
 
 type cacheInteractiveRepository struct {
@@ -47,6 +63,22 @@ func (repo *cacheInteractiveRepository) IncrReadCnt(ctx context.Context, biz str
 	return repo.cache.IncrReadCntIfPresent(ctx, biz, bizID)
 }
 
+func (repo *cacheInteractiveRepository) IncrCommentCnts(ctx context.Context, changes []domain.CommentCntChange) error {
+	applied, err := repo.dao.IncrCommentCnts(ctx, slice.Map(changes, func(idx int, src domain.CommentCntChange) dao.CommentCntChange {
+		return dao.CommentCntChange{Key: src.Key, Biz: src.Biz, BizID: src.BizID, Delta: src.Delta}
+	}))
+	if err != nil {
+		return err
+	}
+	// 和 ChangeLikes 一样，数据库已经提交了，缓存失败只记录日志
+	for _, c := range applied {
+		if er := repo.cache.IncrCommentCntIfPresent(ctx, c.Biz, c.BizID, c.Delta); er != nil {
+			repo.l.Error("更新缓存中的评论数失败", logger.String("biz", c.Biz), logger.Int("bizID", c.BizID), logger.Error(er))
+		}
+	}
+	return nil
+}
+
 func (repo *cacheInteractiveRepository) Get(ctx context.Context, biz string, bizID int64) (domain.Interactive, error) {
 	intr, err := repo.cache.Get(ctx, biz, bizID)
 	if err == nil {
@@ -91,24 +123,102 @@ func (repo *cacheInteractiveRepository) Collected(ctx context.Context, biz strin
 }
 
 func (repo *cacheInteractiveRepository) DecrLike(ctx context.Context, biz string, bizID int64, uid int64) error {
-	if err := repo.dao.DeleteLikeInfo(ctx, biz, bizID, uid); err != nil {
+	changed, err := repo.dao.DeleteLikeInfo(ctx, biz, bizID, uid)
+	if err != nil {
 		return err
 	}
+	repo.delLiked(ctx, biz, uid, bizID)
+	if !changed {
+		return nil
+	}
 	return repo.cache.DecrLikeCntIfPresent(ctx, biz, bizID)
 }
 
 func (repo *cacheInteractiveRepository) IncrLike(ctx context.Context, biz string, bizID int64, uid int64) error {
-	if err := repo.dao.InsertLikeInfo(ctx, biz, bizID, uid); err != nil {
+	changed, err := repo.dao.InsertLikeInfo(ctx, biz, bizID, uid)
+	if err != nil {
 		return err
 	}
+	repo.delLiked(ctx, biz, uid, bizID)
+	if !changed {
+		return nil
+	}
 	return repo.cache.IncrLikeCntIfPresent(ctx, biz, bizID)
 }
 
-func (repo *cacheInteractiveRepository) AddCollectionItem(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error {
-	if err := repo.dao.InsertCollectionBiz(ctx, dao.UserCollectionBiz{CID: cid, BizID: bizID, Biz: biz, UID: uid}); err != nil {
-		return err
+func (repo *cacheInteractiveRepository) AddCollectionItem(ctx context.Context, biz string, bizID int64, cid int64, uid int64) (bool, error) {
+	inserted, err := repo.dao.InsertCollectionBiz(ctx, dao.UserCollectionBiz{CID: cid, BizID: bizID, Biz: biz, UID: uid})
+	if err != nil {
+		return false, err
+	}
+	// 外面可能还有事务，提交之后再更新缓存，缓存失败只记录日志
+	gormx.AfterCommit(ctx, func(ctx context.Context) {
+		if er := repo.cache.DelCollected(ctx, biz, uid, bizID); er != nil {
+			repo.l.Error("删除缓存中的收藏状态失败", logger.String("biz", biz), logger.Int("bizID", bizID), logger.Int("uid", uid), logger.Error(er))
+		}
+		if !inserted {
+			return
+		}
+		if er := repo.cache.IncrCollectCntIfPresent(ctx, biz, bizID); er != nil {
+			repo.l.Error("增加缓存中的收藏数失败", logger.String("biz", biz), logger.Int("bizID", bizID), logger.Error(er))
+		}
+	})
+	return inserted, nil
+}
+
+// delLiked 数据库已经更新成功了，缓存失败只记录日志，缓存过期之后以数据库为准
+func (repo *cacheInteractiveRepository) delLiked(ctx context.Context, biz string, uid int64, bizID int64) {
+	if err := repo.cache.DelLiked(ctx, biz, uid, bizID); err != nil {
+		repo.l.Error("删除缓存中的点赞状态失败", logger.String("biz", biz), logger.Int("bizID", bizID), logger.Int("uid", uid), logger.Error(err))
+	}
+}
+
+func (repo *cacheInteractiveRepository) LikedByIDs(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error) {
+	return repo.userStates(ctx, biz, uid, bizIDs, repo.cache.GetLiked, repo.cache.FillLiked, repo.dao.FindLikedBizIDs)
+}
+
+func (repo *cacheInteractiveRepository) CollectedByIDs(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error) {
+	return repo.userStates(ctx, biz, uid, bizIDs, repo.cache.GetCollected, repo.cache.FillCollected, repo.dao.FindCollectedBizIDs)
+}
+
+// userStates 先查缓存，缓存里没有的再一次性查数据库，然后回写缓存。
+// 回写只补充缓存里没有的，避免用查询时的旧数据覆盖掉期间发生的变更
+func (repo *cacheInteractiveRepository) userStates(ctx context.Context, biz string, uid int64, bizIDs []int64,
+	get func(ctx context.Context, biz string, uid int64, bizIDs []int64) (map[int64]bool, error),
+	fill func(ctx context.Context, biz string, uid int64, states map[int64]bool) error,
+	find func(ctx context.Context, biz string, uid int64, bizIDs []int64) ([]int64, error)) (map[int64]bool, error) {
+	res, err := get(ctx, biz, uid, bizIDs)
+	if err != nil {
+		// 缓存出问题了就直接查数据库
+		repo.l.Error("查询缓存中的用户状态失败", logger.String("biz", biz), logger.Int("uid", uid), logger.Error(err))
+		res = make(map[int64]bool, len(bizIDs))
+	}
+	missed := slice.FilterMap(bizIDs, func(idx int, src int64) (int64, bool) {
+		_, ok := res[src]
+		return src, !ok
+	})
+	if len(missed) == 0 {
+		return res, nil
+	}
+
+	found, err := find(ctx, biz, uid, missed)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[int64]bool, len(missed))
+	for _, bizID := range missed {
+		states[bizID] = false
+	}
+	for _, bizID := range found {
+		states[bizID] = true
 	}
-	return repo.cache.IncrCollectCntIfPresent(ctx, biz, bizID)
+	if er := fill(ctx, biz, uid, states); er != nil {
+		repo.l.Error("回写缓存中的用户状态失败", logger.String("biz", biz), logger.Int("uid", uid), logger.Error(er))
+	}
+	for bizID, state := range states {
+		res[bizID] = state
+	}
+	return res, nil
 }
 
 func (repo *cacheInteractiveRepository) toDomain(intr dao.Interactive) domain.Interactive {
@@ -116,38 +226,93 @@ func (repo *cacheInteractiveRepository) toDomain(intr dao.Interactive) domain.In
 		BizID:      intr.BizID,
 		LikeCnt:    intr.LikeCnt,
 		CollectCnt: intr.CollectCnt,
+		CommentCnt: intr.CommentCnt,
 		ReadCnt:    intr.ReadCnt,
 	}
 }
 
-func (repo *cacheInteractiveRepository) BatchIncrReadCnt(ctx context.Context, bizs []string, bizIDs []int64) error {
-	return repo.dao.BatchIncrReadCnt(ctx, bizs, bizIDs)
+func (repo *cacheInteractiveRepository) BatchIncrReadCnt(ctx context.Context, keys []string, bizs []string, bizIDs []int64) error {
+	return repo.dao.BatchIncrReadCnt(ctx, keys, bizs, bizIDs)
 }
 
-func (repo *cacheInteractiveRepository) BatchIncrLike(ctx context.Context, biz string, bizIDs []int64, uids []int64) error {
-	if err := repo.dao.BatchInsertLikeInfo(ctx, biz, bizIDs, uids); err != nil {
-		return err
+func (repo *cacheInteractiveRepository) ChangeLikes(ctx context.Context, biz string, changes []domain.LikeChange) ([]domain.LikeChange, error) {
+	applied, err := repo.dao.ChangeLikes(ctx, biz, slice.Map(changes, func(idx int, src domain.LikeChange) dao.LikeChange {
+		return dao.LikeChange{Key: src.Key, BizID: src.BizID, Uid: src.Uid, Liked: src.Liked}
+	}))
+	if err != nil {
+		return nil, err
 	}
 
-	existBizIDs, doesNotExistBizIDs := repo.checkExists(biz, bizIDs)
-	if err := repo.cache.BatchIncrLikeCntIfPresent(ctx, biz, existBizIDs); err != nil {
-		return err
-	}
-	return repo.setLikeCntIfDoesNotExist(ctx, biz, doesNotExistBizIDs)
+	// 外面可能还有事务，提交之后再更新缓存。重试的时候事件会被去重，所以缓存失败只记录日志
+	gormx.AfterCommit(ctx, func(ctx context.Context) {
+		if er := repo.changeLikeCnts(ctx, biz, applied); er != nil {
+			repo.l.Error("更新缓存中的点赞数失败", logger.String("biz", biz), logger.Error(er))
+		}
+	})
+	return slice.Map(applied, func(idx int, src dao.LikeChange) domain.LikeChange {
+		return domain.LikeChange{Key: src.Key, BizID: src.BizID, Uid: src.Uid, Liked: src.Liked}
+	}), nil
 }
 
-func (repo *cacheInteractiveRepository) BatchDecrLike(ctx context.Context, biz string, bizIDs []int64, uids []int64) error {
-	if err := repo.dao.BatchDeleteLikeInfo(ctx, biz, bizIDs, uids); err != nil {
-		return err
+// changeLikeCnts 点赞状态变化之后更新缓存里的点赞数
+func (repo *cacheInteractiveRepository) changeLikeCnts(ctx context.Context, biz string, applied []dao.LikeChange) error {
+	var err error
+	likeBizIDs := make([]int64, 0, len(applied))
+	unlikeBizIDs := make([]int64, 0, len(applied))
+	for _, c := range applied {
+		repo.delLiked(ctx, biz, c.Uid, c.BizID)
+		if c.Liked {
+			likeBizIDs = append(likeBizIDs, c.BizID)
+			err = repo.cache.IncrLikeCntIfPresent(ctx, biz, c.BizID)
+		} else {
+			unlikeBizIDs = append(unlikeBizIDs, c.BizID)
+			err = repo.cache.DecrLikeCntIfPresent(ctx, biz, c.BizID)
+		}
+		if err != nil {
+			repo.l.Error("更新缓存中的点赞数失败", logger.String("biz", biz), logger.Int("bizID", c.BizID), logger.Error(err))
+		}
 	}
 
-	existBizIDs, doesNotExistBizIDs := repo.checkExists(biz, bizIDs)
-	if err := repo.cache.BatchDecrLikeCntIfPresent(ctx, biz, existBizIDs); err != nil {
+	existBizIDs, doesNotExistBizIDs := repo.checkExists(biz, likeBizIDs)
+	if err = repo.cache.BatchIncrLikeCntIfPresent(ctx, biz, existBizIDs); err != nil {
+		return err
+	}
+	if err = repo.setLikeCntIfDoesNotExist(ctx, biz, doesNotExistBizIDs); err != nil {
+		return err
+	}
+	existBizIDs, doesNotExistBizIDs = repo.checkExists(biz, unlikeBizIDs)
+	if err = repo.cache.BatchDecrLikeCntIfPresent(ctx, biz, existBizIDs); err != nil {
 		return err
 	}
 	return repo.setLikeCntIfDoesNotExist(ctx, biz, doesNotExistBizIDs)
 }
 
+func (repo *cacheInteractiveRepository) ReconcileLikeCnt(ctx context.Context, startID int64, limit int) (int64, int, error) {
+	drifts, lastID, err := repo.dao.FindLikeCntDrift(ctx, startID, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	for idx, intr := range drifts {
+		if err = repo.dao.FixLikeCnt(ctx, intr.ID); err != nil {
+			return 0, idx, err
+		}
+		repo.l.Warn("修正点赞数",
+			logger.String("biz", intr.Biz),
+			logger.Int("bizID", intr.BizID),
+			logger.Int("likeCnt", intr.LikeCnt))
+		// 删掉缓存，下一次查询的时候以数据库为准
+		if er := repo.cache.Del(ctx, intr.Biz, intr.BizID); er != nil {
+			repo.l.Error("删除互动缓存失败", logger.String("biz", intr.Biz), logger.Int("bizID", intr.BizID), logger.Error(er))
+		}
+	}
+	// 没有数据的时候 lastID 是 0
+	return lastID, len(drifts), nil
+}
+
+func (repo *cacheInteractiveRepository) DeleteProcessedEventsBefore(ctx context.Context, t time.Time) (int64, error) {
+	return repo.dao.DeleteProcessedEventsBefore(ctx, t.UnixMilli())
+}
+
 func (repo *cacheInteractiveRepository) setLikeCntIfDoesNotExist(ctx context.Context, biz string, bizIDs []int64) error {
 	res, err := repo.dao.GetMultipleLikeCnt(ctx, biz, bizIDs)
 	if err != nil {
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"geektime-basic-go/webook/interactive/domain"
+	"geektime-basic-go/webook/interactive/repository"
+)
+
+//go:generate mockgen -source=history.go -package=svcmocks -destination=mocks/history_mock_gen.go HistoryService
+type HistoryService interface {
+	// List 最近阅读的在前面，cursor 是上一页最后一条记录，第一页传零值
+	List(ctx context.Context, uid int64, cursor domain.HistoryRecord, limit int) ([]domain.HistoryRecord, error)
+	Delete(ctx context.Context, uid int64, biz string, bizID int64) error
+	Clear(ctx context.Context, uid int64) error
+}
+
+type historyService struct {
+	repo repository.HistoryRecordRepository
+}
+
+func NewHistoryService(repo repository.HistoryRecordRepository) HistoryService {
+	return &historyService{repo: repo}
+}
+
+func (svc *historyService) List(ctx context.Context, uid int64, cursor domain.HistoryRecord, limit int) ([]domain.HistoryRecord, error) {
+	return svc.repo.List(ctx, uid, cursor, limit)
+}
+
+func (svc *historyService) Delete(ctx context.Context, uid int64, biz string, bizID int64) error {
+	return svc.repo.Delete(ctx, uid, biz, bizID)
+}
+
+func (svc *historyService) Clear(ctx context.Context, uid int64) error {
+	return svc.repo.Clear(ctx, uid)
+}
@@ -2,32 +2,75 @@ package service
 
 import (
 	"context"
+	"errors"
 
 	"golang.org/x/sync/errgroup"
 
 	"geektime-basic-go/webook/interactive/domain"
 	events "geektime-basic-go/webook/interactive/events/article"
 	"geektime-basic-go/webook/interactive/repository"
+	"geektime-basic-go/webook/pkg/gormx"
 	"geektime-basic-go/webook/pkg/logger"
 )
 
+var (
+	// ErrCollectionNotFound 收藏夹不存在，或者不属于当前用户
+	ErrCollectionNotFound = errors.New("收藏夹不存在")
+	// ErrNotCollected 用户没有收藏过该资源
+	ErrNotCollected = errors.New("没有收藏过该资源")
+	// ErrCollectedElsewhere 已经收藏到了别的收藏夹，要换收藏夹用 MoveCollectionItem
+	ErrCollectedElsewhere = errors.New("已经收藏到了别的收藏夹")
+)
+
 //go:generate mockgen -source=interactive.go -package=svcmocks -destination=mocks/interactive_mock_gen.go InteractiveService
 type InteractiveService interface {
 	IncrReadCnt(ctx context.Context, biz string, bizID int64) error
 	Get(ctx context.Context, biz string, bizID int64, uid int64) (domain.Interactive, error)
 	Like(ctx context.Context, biz string, bizID int64, uid int64, like bool) error
+	// Collect 已经收藏到 cid 的什么也不做，收藏到别的收藏夹的返回 ErrCollectedElsewhere
 	Collect(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error
 	GetByIDs(ctx context.Context, biz string, bizIDs []int64) (map[int64]domain.Interactive, error)
+	// GetByIDsForUser 批量查询，同时查出 uid 是否点赞、收藏
+	// 结果包含所有的 bizIDs，uid 小于等于 0 的时候只查计数
+	GetByIDsForUser(ctx context.Context, biz string, bizIDs []int64, uid int64) (map[int64]domain.Interactive, error)
+
+	// Uncollect 取消收藏，没有收藏过的什么也不做
+	Uncollect(ctx context.Context, biz string, bizID int64, uid int64) error
+	CreateCollection(ctx context.Context, c domain.Collection) (int64, error)
+	RenameCollection(ctx context.Context, uid int64, cid int64, name string) error
+	SetCollectionPublic(ctx context.Context, uid int64, cid int64, public bool) error
+	// DeleteCollection 收藏夹里面的收藏会被一起取消
+	DeleteCollection(ctx context.Context, uid int64, cid int64) error
+	ListCollections(ctx context.Context, uid int64) ([]domain.Collection, error)
+	// ListPublicCollections 别人浏览 uid 的收藏夹，只能看到公开的
+	ListPublicCollections(ctx context.Context, uid int64) ([]domain.Collection, error)
+	// ListCollectionItems viewer 可以看自己的收藏夹和别人公开的收藏夹
+	// cid 为 0 代表 viewer 自己的默认收藏夹，maxID 为 0 代表第一页
+	ListCollectionItems(ctx context.Context, viewer int64, cid int64, maxID int64, limit int) ([]domain.CollectionItem, error)
+	// MoveCollectionItem 把已经收藏的资源移动到 cid 收藏夹
+	MoveCollectionItem(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error
 }
 
 type interactiveService struct {
-	repo     repository.InteractiveRepository
-	producer events.ChangeLikeProducer
-	l        logger.Logger
+	repo            repository.InteractiveRepository
+	collectionRepo  repository.CollectionRepository
+	producer        events.ChangeLikeProducer
+	collectProducer events.ChangeCollectProducer
+	// tx 收藏状态和收藏事件在同一个事务里写，事件由 outbox 保证最终会发出去
+	tx gormx.Transactor
+	l  logger.Logger
 }
 
-func NewInteractiveService(repo repository.InteractiveRepository, producer events.ChangeLikeProducer, l logger.Logger) InteractiveService {
-	return &interactiveService{repo: repo, producer: producer, l: l}
+func NewInteractiveService(repo repository.InteractiveRepository, collectionRepo repository.CollectionRepository,
+	producer events.ChangeLikeProducer, collectProducer events.ChangeCollectProducer, tx gormx.Transactor, l logger.Logger) InteractiveService {
+	return &interactiveService{
+		repo:            repo,
+		collectionRepo:  collectionRepo,
+		producer:        producer,
+		collectProducer: collectProducer,
+		tx:              tx,
+		l:               l,
+	}
 }
 
 func (svc *interactiveService) IncrReadCnt(ctx context.Context, biz string, bizID int64) error {
@@ -65,7 +108,22 @@ func (svc *interactiveService) Like(ctx context.Context, biz string, bizID int64
 }
 
 func (svc *interactiveService) Collect(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error {
-	return svc.repo.AddCollectionItem(ctx, biz, bizID, cid, uid)
+	if err := svc.checkCollectionOwner(ctx, cid, uid); err != nil {
+		return err
+	}
+	err := svc.tx.Transaction(ctx, func(ctx context.Context) error {
+		inserted, err := svc.repo.AddCollectionItem(ctx, biz, bizID, cid, uid)
+		if err != nil || !inserted {
+			// 收藏状态没有变化，不需要通知
+			return err
+		}
+		return svc.collectProducer.ProduceChangeCollectEvent(ctx,
+			events.ChangeCollectEvent{Biz: biz, BizID: bizID, Uid: uid, Cid: cid, Collected: true})
+	})
+	if errors.Is(err, repository.ErrCollectedElsewhere) {
+		return ErrCollectedElsewhere
+	}
+	return err
 }
 
 func (svc *interactiveService) GetByIDs(ctx context.Context, biz string, bizIDs []int64) (map[int64]domain.Interactive, error) {
@@ -79,3 +137,135 @@ func (svc *interactiveService) GetByIDs(ctx context.Context, biz string, bizIDs
 	}
 	return res, nil
 }
+
+func (svc *interactiveService) GetByIDsForUser(ctx context.Context, biz string, bizIDs []int64, uid int64) (map[int64]domain.Interactive, error) {
+	var (
+		eg               errgroup.Group
+		intrs            []domain.Interactive
+		liked, collected map[int64]bool
+	)
+	eg.Go(func() (err error) {
+		intrs, err = svc.repo.GetByIDs(ctx, biz, bizIDs)
+		return
+	})
+	if uid > 0 {
+		eg.Go(func() (err error) {
+			liked, err = svc.repo.LikedByIDs(ctx, biz, uid, bizIDs)
+			return
+		})
+		eg.Go(func() (err error) {
+			collected, err = svc.repo.CollectedByIDs(ctx, biz, uid, bizIDs)
+			return
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	res := make(map[int64]domain.Interactive, len(bizIDs))
+	for _, bizID := range bizIDs {
+		res[bizID] = domain.Interactive{Biz: biz, BizID: bizID}
+	}
+	for _, intr := range intrs {
+		intr.Biz = biz
+		res[intr.BizID] = intr
+	}
+	for bizID, intr := range res {
+		intr.Liked, intr.Collected = liked[bizID], collected[bizID]
+		res[bizID] = intr
+	}
+	return res, nil
+}
+
+func (svc *interactiveService) Uncollect(ctx context.Context, biz string, bizID int64, uid int64) error {
+	return svc.tx.Transaction(ctx, func(ctx context.Context) error {
+		deleted, err := svc.collectionRepo.RemoveItem(ctx, uid, biz, bizID)
+		if err != nil || !deleted {
+			return err
+		}
+		return svc.collectProducer.ProduceChangeCollectEvent(ctx,
+			events.ChangeCollectEvent{Biz: biz, BizID: bizID, Uid: uid, Collected: false})
+	})
+}
+
+func (svc *interactiveService) CreateCollection(ctx context.Context, c domain.Collection) (int64, error) {
+	return svc.collectionRepo.AddCollection(ctx, c)
+}
+
+func (svc *interactiveService) RenameCollection(ctx context.Context, uid int64, cid int64, name string) error {
+	err := svc.collectionRepo.RenameCollection(ctx, uid, cid, name)
+	if errors.Is(err, repository.ErrCollectionNotFound) {
+		return ErrCollectionNotFound
+	}
+	return err
+}
+
+func (svc *interactiveService) SetCollectionPublic(ctx context.Context, uid int64, cid int64, public bool) error {
+	err := svc.collectionRepo.SetCollectionPublic(ctx, uid, cid, public)
+	if errors.Is(err, repository.ErrCollectionNotFound) {
+		return ErrCollectionNotFound
+	}
+	return err
+}
+
+func (svc *interactiveService) DeleteCollection(ctx context.Context, uid int64, cid int64) error {
+	err := svc.collectionRepo.DeleteCollection(ctx, uid, cid)
+	if errors.Is(err, repository.ErrCollectionNotFound) {
+		return ErrCollectionNotFound
+	}
+	return err
+}
+
+func (svc *interactiveService) ListCollections(ctx context.Context, uid int64) ([]domain.Collection, error) {
+	return svc.collectionRepo.ListCollections(ctx, uid)
+}
+
+func (svc *interactiveService) ListPublicCollections(ctx context.Context, uid int64) ([]domain.Collection, error) {
+	return svc.collectionRepo.ListPublicCollections(ctx, uid)
+}
+
+func (svc *interactiveService) ListCollectionItems(ctx context.Context, viewer int64, cid int64, maxID int64, limit int) ([]domain.CollectionItem, error) {
+	if cid == 0 {
+		return svc.collectionRepo.ListItems(ctx, viewer, cid, maxID, limit)
+	}
+	c, err := svc.collectionRepo.GetCollection(ctx, cid)
+	switch {
+	case errors.Is(err, repository.ErrCollectionNotFound):
+		return nil, ErrCollectionNotFound
+	case err != nil:
+		return nil, err
+	case c.Uid != viewer && !c.Public:
+		// 私有的收藏夹对别人来说就是不存在
+		return nil, ErrCollectionNotFound
+	}
+	return svc.collectionRepo.ListItems(ctx, c.Uid, cid, maxID, limit)
+}
+
+func (svc *interactiveService) MoveCollectionItem(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error {
+	if err := svc.checkCollectionOwner(ctx, cid, uid); err != nil {
+		return err
+	}
+	err := svc.collectionRepo.MoveItem(ctx, uid, biz, bizID, cid)
+	if errors.Is(err, repository.ErrCollectionNotFound) {
+		return ErrNotCollected
+	}
+	return err
+}
+
+// checkCollectionOwner cid 为 0 是默认收藏夹，每个用户都有
+func (svc *interactiveService) checkCollectionOwner(ctx context.Context, cid int64, uid int64) error {
+	if cid == 0 {
+		return nil
+	}
+	c, err := svc.collectionRepo.GetCollection(ctx, cid)
+	switch {
+	case errors.Is(err, repository.ErrCollectionNotFound):
+		return ErrCollectionNotFound
+	case err != nil:
+		return err
+	case c.Uid != uid:
+		return ErrCollectionNotFound
+	default:
+		return nil
+	}
+}
@@ -6,26 +6,45 @@ import (
 	"github.com/google/wire"
 
 	events "geektime-basic-go/webook/interactive/events/article"
+	"geektime-basic-go/webook/interactive/events/comment"
 	"geektime-basic-go/webook/interactive/grpc"
 	"geektime-basic-go/webook/interactive/ioc"
 	intrrepo "geektime-basic-go/webook/interactive/repository"
 	intrcache "geektime-basic-go/webook/interactive/repository/cache"
 	intrdao "geektime-basic-go/webook/interactive/repository/dao"
 	intrscv "geektime-basic-go/webook/interactive/service"
+	"geektime-basic-go/webook/pkg/gormx"
+	"geektime-basic-go/webook/pkg/outbox"
 )
 
 var interactiveSvcProvider = wire.NewSet(
+	gormx.NewTransactor,
 	intrscv.NewInteractiveService,
 	intrrepo.NewInteractiveRepository,
 	intrdao.NewInteractiveDAO,
 	intrcache.NewInteractiveCache,
+	intrrepo.NewCollectionRepository,
+	intrdao.NewGormCollectionDAO,
+)
+
+var historySvcProvider = wire.NewSet(
+	intrscv.NewHistoryService,
+	intrrepo.NewHistoryRecordRepository,
+	intrdao.NewGormHistoryRecordDAO,
 )
 
 var eventsProvider = wire.NewSet(
 	ioc.NewSyncProducer,
-	events.NewChangeLikeSaramaSyncProducer,
+	outbox.NewStore,
+	ioc.InitOutboxRelay,
+	events.NewChangeLikeOutboxProducer,
+	events.NewChangeCollectOutboxProducer,
+	events.NewLikeChangedOutboxProducer,
+	ioc.InitDeadLetter,
+	ioc.InitDeadLetterAdmin,
 	events.NewInteractiveReadEventConsumer,
 	events.NewInteractiveLikeEventConsumer,
+	comment.NewCommentCntEventConsumer,
 )
 
 var thirdProvider = wire.NewSet(
@@ -49,11 +68,15 @@ func Init() *App {
 		thirdProvider,
 		eventsProvider,
 		interactiveSvcProvider,
+		historySvcProvider,
 		migratorProvider,
 
 		grpc.NewInteractiveServiceServer,
+		grpc.NewHistoryServiceServer,
 		ioc.InitGRPCxServer,
 		ioc.NewConsumers,
+		ioc.InitLikeCntReconcileJob,
+		ioc.InitJobs,
 		wire.Struct(new(App), "*"),
 	)
 	return new(App)
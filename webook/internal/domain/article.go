@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"time"
+
+	"geektime-basic-go/webook/pkg/diffx"
+)
 
 type Article struct {
 	ID      int64
@@ -11,6 +15,10 @@ type Article struct {
 	Author   Author
 	CreateAt time.Time
 	UpdateAt time.Time
+	// PublishAt 定时发表的时间，只有 ArticleStatusScheduled 状态下才有意义
+	PublishAt time.Time
+	// Tags 文章的标签，为 nil 的时候保存文章不会修改原有的标签
+	Tags []string
 }
 
 func (a *Article) Abstract() string {
@@ -37,8 +45,42 @@ const (
 	ArticleStatusPublished
 	// ArticleStatusPrivate 仅自己可见
 	ArticleStatusPrivate
+	// ArticleStatusScheduled 等待定时发表
+	ArticleStatusScheduled
 )
 
+// ArticleRevision 文章的某一个历史版本
+type ArticleRevision struct {
+	ArticleID int64
+	Version   int64
+	Title     string
+	Content   string
+	Status    ArticleStatus
+	Author    Author
+	CreateAt  time.Time
+}
+
+func (r *ArticleRevision) Abstract() string {
+	cs := []rune(r.Content)
+	if len(cs) < 100 {
+		return r.Content
+	}
+	return string(cs[:100])
+}
+
+// ArticleRevisionDiff 两个历史版本按行比较的结果
+type ArticleRevisionDiff struct {
+	From  ArticleRevision
+	To    ArticleRevision
+	Lines []diffx.Line
+}
+
+// Tag 标签，ArticleCnt 是使用了这个标签的文章数量
+type Tag struct {
+	Name       string
+	ArticleCnt int64
+}
+
 type Author struct {
 	ID   int64
 	Name string
@@ -50,16 +92,18 @@ type Vo struct {
 	// 摘要
 	Abstract string `json:"abstract"`
 	// 内容
-	Content  string `json:"content"`
-	Status   uint8  `json:"status"`
-	Author   string `json:"author"`
-	CreateAt string `json:"create_at"`
-	UpdateAt string `json:"update_at"`
+	Content  string   `json:"content"`
+	Status   uint8    `json:"status"`
+	Author   string   `json:"author"`
+	Tags     []string `json:"tags"`
+	CreateAt string   `json:"create_at"`
+	UpdateAt string   `json:"update_at"`
 
 	// 点赞之类的信息
 	LikeCnt    int64 `json:"likeCnt"`
 	CollectCnt int64 `json:"collectCnt"`
 	ReadCnt    int64 `json:"readCnt"`
+	CommentCnt int64 `json:"commentCnt"`
 
 	// 个人是否点赞的信息
 	Liked     bool `json:"liked"`
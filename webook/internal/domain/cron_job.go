@@ -15,6 +15,29 @@ type CronJob struct {
 	Cfg        string
 	Expression string
 	NextTime   time.Time
+	Status     CronJobStatus
+	CreateAt   time.Time
+	UpdateAt   time.Time
+
+	// MaxRetries 一次调度失败之后最多重试几次，用完了就等下一次调度
+	MaxRetries int
+	// RetryBackoff 第一次重试的间隔，之后每次翻倍
+	RetryBackoff time.Duration
+	// Timeout 一次执行的超时时间，0 代表不限制
+	Timeout time.Duration
+	Misfire MisfirePolicy
+	// AlertThreshold 连续失败多少次之后告警，0 代表使用调度器的默认值
+	AlertThreshold int
+	// Retries 这一次调度已经重试的次数
+	Retries int
+	// FailCount 连续失败的次数，成功之后清零
+	FailCount int
+
+	// Upstreams 上游任务的名字。有上游的任务不按照表达式调度，
+	// 而是等所有上游在同一个窗口里面都执行成功之后触发
+	Upstreams []string
+	// Window 这一次执行属于哪个窗口，同一个窗口里面的执行组成一次工作流
+	Window time.Time
 
 	// 放弃抢占状态
 	CancelFunc func()
@@ -23,6 +46,143 @@ type CronJob struct {
 var expr = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
 
 func (j CronJob) Next(t time.Time) time.Time {
-	s, _ := expr.Parse(j.Expression)
+	s, err := expr.Parse(j.Expression)
+	if err != nil {
+		return time.Time{}
+	}
 	return s.Next(t)
 }
+
+// triggeredNextTime 下游任务没有被上游触发的时候的下次执行时间，也就是不会按照时间调度
+var triggeredNextTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// Dependent 有上游的任务
+func (j CronJob) Dependent() bool {
+	return len(j.Upstreams) > 0
+}
+
+// WaitingUpstream 下游任务正在等上游触发
+func (j CronJob) WaitingUpstream() bool {
+	return j.Dependent() && !j.NextTime.Before(triggeredNextTime)
+}
+
+// Schedule 创建、修改或者恢复任务的时候，下一次调度的时间
+func (j CronJob) Schedule(now time.Time) time.Time {
+	if j.Dependent() {
+		return triggeredNextTime
+	}
+	return j.Next(now)
+}
+
+// CurrentWindow 这一次执行属于哪个窗口。
+// 根任务第一次执行的时候是调度的时间，重试的时候沿用之前的窗口；下游任务是上游触发的时候带过来的。
+// 所以同一个工作流里面的根任务要用同一个表达式，不然永远凑不齐同一个窗口
+func (j CronJob) CurrentWindow() time.Time {
+	if !j.Dependent() && j.Retries == 0 {
+		return j.NextTime
+	}
+	return j.Window
+}
+
+// NextFire 执行完之后下一次调度的时间
+func (j CronJob) NextFire(now time.Time) time.Time {
+	if j.Dependent() {
+		return triggeredNextTime
+	}
+	// 重试过的任务 NextTime 是最后一次重试的时间，所以要从这一次的窗口开始补
+	if j.Misfire == MisfireCatchUp && !j.Window.IsZero() {
+		return j.Next(j.Window)
+	}
+	return j.Next(now)
+}
+
+const (
+	// MaxJobRetries 一次调度最多重试的次数
+	MaxJobRetries = 10
+	// maxRetryBackoff 重试间隔翻倍到这个值之后就不再增长
+	maxRetryBackoff = time.Hour
+)
+
+// RetryAt 第 retries 次重试的时间
+func (j CronJob) RetryAt(now time.Time, retries int) time.Time {
+	backoff := j.RetryBackoff
+	if backoff <= 0 {
+		backoff = 10 * time.Second
+	}
+	// 一次一次翻倍，到了上限就停下来，避免移位溢出
+	for i := 1; i < retries && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return now.Add(min(backoff, maxRetryBackoff))
+}
+
+// ValidExpression 校验 cron 表达式，支持秒
+func (j CronJob) ValidExpression() error {
+	_, err := expr.Parse(j.Expression)
+	return err
+}
+
+// MisfirePolicy 节点宕机或者任务执行时间太长，错过了调度时间之后怎么办
+type MisfirePolicy uint8
+
+func (p MisfirePolicy) ToUint8() uint8 {
+	return uint8(p)
+}
+
+const (
+	// MisfireSkip 错过的就不管了，从当前时间开始计算下一次
+	MisfireSkip MisfirePolicy = iota
+	// MisfireCatchUp 错过的每一次都要补上，从上一次调度的时间开始计算下一次，
+	// 所以会马上连续执行，直到追上当前时间
+	MisfireCatchUp
+)
+
+type CronJobStatus uint8
+
+func (s CronJobStatus) ToUint8() uint8 {
+	return uint8(s)
+}
+
+const (
+	CronJobStatusUnknown CronJobStatus = iota
+	// CronJobStatusWaiting 等待被调度
+	CronJobStatusWaiting
+	// CronJobStatusRunning 已经被某个节点抢占了，正在执行
+	CronJobStatusRunning
+	// CronJobStatusEnd 被删除了，不再调度
+	CronJobStatusEnd
+	// CronJobStatusPaused 暂停调度，恢复之后重新计算下次执行时间
+	CronJobStatusPaused
+)
+
+// JobExecution 任务的一次执行
+type JobExecution struct {
+	ID      int64
+	JobID   int64
+	JobName string
+	// Node 执行任务的节点
+	Node    string
+	StartAt time.Time
+	EndAt   time.Time
+	Status  JobExecutionStatus
+	// Err 失败的原因
+	Err    string
+	Window time.Time
+}
+
+type JobExecutionStatus uint8
+
+func (s JobExecutionStatus) ToUint8() uint8 {
+	return uint8(s)
+}
+
+const (
+	JobExecutionStatusUnknown JobExecutionStatus = iota
+	JobExecutionStatusRunning
+	JobExecutionStatusSuccess
+	JobExecutionStatusFailed
+	// JobExecutionStatusSkipped 上游失败了，这个窗口不执行
+	JobExecutionStatusSkipped
+	// JobExecutionStatusInterrupted 调度器退出的时候被中断了，这个窗口会被重新执行
+	JobExecutionStatusInterrupted
+)
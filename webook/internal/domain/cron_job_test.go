@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronJob_NextFire(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 30, 20, 0, time.Local)
+	testCases := []struct {
+		name string
+		job  CronJob
+
+		wantNext time.Time
+	}{
+		{
+			name:     "跳过错过的，从现在开始算",
+			job:      CronJob{Expression: "0 * * * * ?", Window: now.Add(-time.Hour)},
+			wantNext: time.Date(2024, 1, 1, 10, 31, 0, 0, time.Local),
+		},
+		{
+			name: "补上错过的，从这一次的窗口开始算",
+			job: CronJob{Expression: "0 * * * * ?", Misfire: MisfireCatchUp,
+				Window: time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local)},
+			wantNext: time.Date(2024, 1, 1, 9, 1, 0, 0, time.Local),
+		},
+		{
+			name: "补上错过的，重试之后也是从窗口开始算",
+			job: CronJob{Expression: "0 * * * * ?", Misfire: MisfireCatchUp,
+				Window:   time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local),
+				NextTime: time.Date(2024, 1, 1, 9, 30, 0, 0, time.Local)},
+			wantNext: time.Date(2024, 1, 1, 9, 1, 0, 0, time.Local),
+		},
+		{
+			name:     "补上错过的，没有窗口就从现在开始算",
+			job:      CronJob{Expression: "0 * * * * ?", Misfire: MisfireCatchUp},
+			wantNext: time.Date(2024, 1, 1, 10, 31, 0, 0, time.Local),
+		},
+		{
+			name:     "下游任务等上游触发",
+			job:      CronJob{Expression: "0 * * * * ?", Upstreams: []string{"up"}},
+			wantNext: triggeredNextTime,
+		},
+		{
+			name: "表达式不合法",
+			job:  CronJob{Expression: "abc"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantNext, tc.job.NextFire(now))
+		})
+	}
+}
+
+func TestCronJob_RetryAt(t *testing.T) {
+	now := time.UnixMilli(1000)
+	testCases := []struct {
+		name    string
+		backoff time.Duration
+		retries int
+
+		wantAt time.Time
+	}{
+		{
+			name:    "第一次重试",
+			backoff: time.Second,
+			retries: 1,
+			wantAt:  now.Add(time.Second),
+		},
+		{
+			name:    "每次翻倍",
+			backoff: time.Second,
+			retries: 3,
+			wantAt:  now.Add(4 * time.Second),
+		},
+		{
+			name:    "没有配置间隔用默认值",
+			retries: 2,
+			wantAt:  now.Add(20 * time.Second),
+		},
+		{
+			name:    "翻倍到上限",
+			backoff: time.Minute,
+			retries: 10,
+			wantAt:  now.Add(time.Hour),
+		},
+		{
+			name:    "重试次数很大也不会溢出",
+			backoff: time.Second,
+			retries: 100,
+			wantAt:  now.Add(time.Hour),
+		},
+		{
+			name:    "配置的间隔超过上限",
+			backoff: 48 * time.Hour,
+			retries: 1,
+			wantAt:  now.Add(time.Hour),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			j := CronJob{RetryBackoff: tc.backoff}
+			assert.Equal(t, tc.wantAt, j.RetryAt(now, tc.retries))
+		})
+	}
+}
+
+func TestCronJob_CurrentWindow(t *testing.T) {
+	nextTime := time.UnixMilli(2000)
+	window := time.UnixMilli(1000)
+	testCases := []struct {
+		name string
+		job  CronJob
+
+		wantWindow time.Time
+	}{
+		{
+			name:       "第一次执行，窗口是调度的时间",
+			job:        CronJob{NextTime: nextTime, Window: window},
+			wantWindow: nextTime,
+		},
+		{
+			name:       "重试沿用之前的窗口",
+			job:        CronJob{NextTime: nextTime, Window: window, Retries: 1},
+			wantWindow: window,
+		},
+		{
+			name:       "下游任务用上游带过来的窗口",
+			job:        CronJob{NextTime: nextTime, Window: window, Upstreams: []string{"up"}},
+			wantWindow: window,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantWindow, tc.job.CurrentWindow())
+		})
+	}
+}
+
+func TestCronJob_Schedule(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 30, 20, 0, time.Local)
+	testCases := []struct {
+		name string
+		job  CronJob
+
+		wantNext      time.Time
+		wantDependent bool
+		// wantWaiting 调度之后下游任务在等上游触发
+		wantWaiting bool
+	}{
+		{
+			name:     "按照表达式调度",
+			job:      CronJob{Expression: "0 * * * * ?"},
+			wantNext: time.Date(2024, 1, 1, 10, 31, 0, 0, time.Local),
+		},
+		{
+			name:          "有上游的任务不按照表达式调度",
+			job:           CronJob{Expression: "0 * * * * ?", Upstreams: []string{"up"}},
+			wantNext:      triggeredNextTime,
+			wantDependent: true,
+			wantWaiting:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := tc.job.Schedule(now)
+			assert.Equal(t, tc.wantNext, next)
+			assert.Equal(t, tc.wantDependent, tc.job.Dependent())
+			tc.job.NextTime = next
+			assert.Equal(t, tc.wantWaiting, tc.job.WaitingUpstream())
+			// 被上游触发之后就不是在等了
+			tc.job.NextTime = now
+			assert.False(t, tc.job.WaitingUpstream())
+		})
+	}
+}
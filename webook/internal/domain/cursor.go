@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("非法的分页游标")
+
+// ArticleCursor 按 (update_at, id) 倒序翻页的游标
+// 零值代表从第一页开始
+type ArticleCursor struct {
+	UpdateAt time.Time
+	ID       int64
+}
+
+func (c ArticleCursor) IsZero() bool {
+	return c.UpdateAt.IsZero() && c.ID == 0
+}
+
+// Encode 编码成对前端不透明的字符串，零值编码为空字符串
+func (c ArticleCursor) Encode() string {
+	if c.IsZero() {
+		return ""
+	}
+	raw := strconv.FormatInt(c.UpdateAt.UnixMilli(), 10) + "_" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseArticleCursor 解析 Encode 的结果，空字符串代表第一页
+func ParseArticleCursor(s string) (ArticleCursor, error) {
+	if s == "" {
+		return ArticleCursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ArticleCursor{}, ErrInvalidCursor
+	}
+	updateAt, id, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return ArticleCursor{}, ErrInvalidCursor
+	}
+	ms, err := strconv.ParseInt(updateAt, 10, 64)
+	if err != nil {
+		return ArticleCursor{}, ErrInvalidCursor
+	}
+	aid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ArticleCursor{}, ErrInvalidCursor
+	}
+	return ArticleCursor{UpdateAt: time.UnixMilli(ms), ID: aid}, nil
+}
+
+// NextArticleCursor 根据这一页的结果计算下一页的游标
+// 不满一页说明已经没有数据了，返回零值
+func NextArticleCursor(arts []Article, limit int) ArticleCursor {
+	if len(arts) == 0 || len(arts) < limit {
+		return ArticleCursor{}
+	}
+	last := arts[len(arts)-1]
+	return ArticleCursor{UpdateAt: last.UpdateAt, ID: last.ID}
+}
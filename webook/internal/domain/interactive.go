@@ -0,0 +1,19 @@
+package domain
+
+// Interactive 资源的点赞、收藏等计数，以及当前用户是否点赞、收藏
+type Interactive struct {
+	BizID      int64
+	ReadCnt    int64
+	LikeCnt    int64
+	CollectCnt int64
+	CommentCnt int64
+	Liked      bool
+	Collected  bool
+}
+
+// RankingEvent 一次互动，Delta 是计数的变化，取消点赞就是 LikeCnt 为 -1
+type RankingEvent struct {
+	Biz   string
+	BizID int64
+	Delta Interactive
+}
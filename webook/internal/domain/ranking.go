@@ -0,0 +1,72 @@
+package domain
+
+import "time"
+
+// RankingSnapshot 某一次计算出来的榜单，ID 就是版本号
+type RankingSnapshot struct {
+	ID       int64
+	Board    string
+	CreateAt time.Time
+	// Items 按照排名从高到低
+	Items []RankingItem
+}
+
+// RankingItem Rank 从 1 开始，Title 是计算的时候的标题，后面文章修改了也不变
+type RankingItem struct {
+	BizID int64
+	Rank  int
+	Score float64
+	Title string
+}
+
+// RankingChange PrevRank 为 0 代表新上榜
+type RankingChange struct {
+	RankingItem
+	PrevRank int
+}
+
+func (c RankingChange) IsNew() bool {
+	return c.PrevRank == 0
+}
+
+// Move 排名的变化，正数是上升，负数是下降
+func (c RankingChange) Move() int {
+	if c.IsNew() {
+		return 0
+	}
+	return c.PrevRank - c.Rank
+}
+
+// RankingDiff 两个快照之间的变化
+type RankingDiff struct {
+	From RankingSnapshot
+	To   RankingSnapshot
+	// Changes 按照 To 的排名
+	Changes []RankingChange
+	// Dropped 在 From 里面，但是掉出了 To
+	Dropped []RankingItem
+}
+
+// DiffRankingSnapshots 计算 from 到 to 的排名变化，返回的 From 和 To 不带 Items
+func DiffRankingSnapshots(from, to RankingSnapshot) RankingDiff {
+	prev := make(map[int64]int, len(from.Items))
+	for _, item := range from.Items {
+		prev[item.BizID] = item.Rank
+	}
+	res := RankingDiff{
+		Changes: make([]RankingChange, 0, len(to.Items)),
+	}
+	cur := make(map[int64]struct{}, len(to.Items))
+	for _, item := range to.Items {
+		cur[item.BizID] = struct{}{}
+		res.Changes = append(res.Changes, RankingChange{RankingItem: item, PrevRank: prev[item.BizID]})
+	}
+	for _, item := range from.Items {
+		if _, ok := cur[item.BizID]; !ok {
+			res.Dropped = append(res.Dropped, item)
+		}
+	}
+	from.Items, to.Items = nil, nil
+	res.From, res.To = from, to
+	return res
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRankingSnapshots(t *testing.T) {
+	fromAt := time.UnixMilli(1000)
+	toAt := time.UnixMilli(2000)
+	testCases := []struct {
+		name string
+		from []RankingItem
+		to   []RankingItem
+
+		wantChanges []RankingChange
+		wantDropped []RankingItem
+	}{
+		{
+			name:        "两个快照都是空的",
+			wantChanges: []RankingChange{},
+		},
+		{
+			name: "之前没有快照，全部是新上榜",
+			to: []RankingItem{
+				{BizID: 1, Rank: 1, Score: 10},
+				{BizID: 2, Rank: 2, Score: 5},
+			},
+			wantChanges: []RankingChange{
+				{RankingItem: RankingItem{BizID: 1, Rank: 1, Score: 10}},
+				{RankingItem: RankingItem{BizID: 2, Rank: 2, Score: 5}},
+			},
+		},
+		{
+			name: "排名上升、下降、不变",
+			from: []RankingItem{
+				{BizID: 1, Rank: 1},
+				{BizID: 2, Rank: 2},
+				{BizID: 3, Rank: 3},
+			},
+			to: []RankingItem{
+				{BizID: 2, Rank: 1},
+				{BizID: 1, Rank: 2},
+				{BizID: 3, Rank: 3},
+			},
+			wantChanges: []RankingChange{
+				{RankingItem: RankingItem{BizID: 2, Rank: 1}, PrevRank: 2},
+				{RankingItem: RankingItem{BizID: 1, Rank: 2}, PrevRank: 1},
+				{RankingItem: RankingItem{BizID: 3, Rank: 3}, PrevRank: 3},
+			},
+		},
+		{
+			name: "有新上榜的，也有掉榜的",
+			from: []RankingItem{
+				{BizID: 1, Rank: 1, Title: "旧标题"},
+				{BizID: 2, Rank: 2},
+				{BizID: 3, Rank: 3},
+			},
+			to: []RankingItem{
+				{BizID: 4, Rank: 1},
+				{BizID: 2, Rank: 2},
+			},
+			wantChanges: []RankingChange{
+				{RankingItem: RankingItem{BizID: 4, Rank: 1}},
+				{RankingItem: RankingItem{BizID: 2, Rank: 2}, PrevRank: 2},
+			},
+			wantDropped: []RankingItem{
+				{BizID: 1, Rank: 1, Title: "旧标题"},
+				{BizID: 3, Rank: 3},
+			},
+		},
+		{
+			name: "全部掉榜",
+			from: []RankingItem{
+				{BizID: 1, Rank: 1},
+			},
+			wantChanges: []RankingChange{},
+			wantDropped: []RankingItem{
+				{BizID: 1, Rank: 1},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			from := RankingSnapshot{ID: 1, Board: "test", CreateAt: fromAt, Items: tc.from}
+			to := RankingSnapshot{ID: 2, Board: "test", CreateAt: toAt, Items: tc.to}
+			res := DiffRankingSnapshots(from, to)
+			assert.Equal(t, tc.wantChanges, res.Changes)
+			assert.Equal(t, tc.wantDropped, res.Dropped)
+			// 返回的快照不带 Items，也不能改掉传入的快照
+			assert.Equal(t, RankingSnapshot{ID: 1, Board: "test", CreateAt: fromAt}, res.From)
+			assert.Equal(t, RankingSnapshot{ID: 2, Board: "test", CreateAt: toAt}, res.To)
+			assert.Equal(t, tc.from, from.Items)
+			assert.Equal(t, tc.to, to.Items)
+		})
+	}
+}
+
+func TestRankingChange_Move(t *testing.T) {
+	testCases := []struct {
+		name   string
+		change RankingChange
+
+		wantNew  bool
+		wantMove int
+	}{
+		{
+			name:    "新上榜",
+			change:  RankingChange{RankingItem: RankingItem{Rank: 3}},
+			wantNew: true,
+		},
+		{
+			name:     "上升",
+			change:   RankingChange{RankingItem: RankingItem{Rank: 1}, PrevRank: 4},
+			wantMove: 3,
+		},
+		{
+			name:     "下降",
+			change:   RankingChange{RankingItem: RankingItem{Rank: 5}, PrevRank: 2},
+			wantMove: -3,
+		},
+		{
+			name:   "不变",
+			change: RankingChange{RankingItem: RankingItem{Rank: 2}, PrevRank: 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantNew, tc.change.IsNew())
+			assert.Equal(t, tc.wantMove, tc.change.Move())
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package domain
+
+// ArticleSearchResult 文章搜索的一页结果
+type ArticleSearchResult struct {
+	// Total 命中的总数
+	Total int
+	Hits  []ArticleSearchHit
+}
+
+// ArticleSearchHit 命中的文章，Title 和 Abstract 中命中的部分已经高亮
+type ArticleSearchHit struct {
+	Article  Article
+	Title    string
+	Abstract string
+}
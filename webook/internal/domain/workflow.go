@@ -0,0 +1,99 @@
+package domain
+
+import "time"
+
+// WorkflowRun 一个 DAG 在某个窗口里面的执行情况
+type WorkflowRun struct {
+	Window time.Time
+	// Nodes 按照拓扑排序，上游在前面
+	Nodes []WorkflowNode
+}
+
+type WorkflowNode struct {
+	Job CronJob
+	// Execution 这个窗口里面最近的一次执行，ID 为 0 代表还没有执行
+	Execution JobExecution
+}
+
+type WorkflowStatus uint8
+
+func (s WorkflowStatus) ToUint8() uint8 {
+	return uint8(s)
+}
+
+const (
+	WorkflowStatusUnknown WorkflowStatus = iota
+	// WorkflowStatusPending 一个任务都还没有执行
+	WorkflowStatusPending
+	WorkflowStatusRunning
+	WorkflowStatusSuccess
+	// WorkflowStatusFailed 有任务失败或者被跳过
+	WorkflowStatusFailed
+)
+
+func (r WorkflowRun) Status() WorkflowStatus {
+	started, success := false, true
+	for _, n := range r.Nodes {
+		switch n.Execution.Status {
+		case JobExecutionStatusFailed, JobExecutionStatusSkipped:
+			return WorkflowStatusFailed
+		case JobExecutionStatusSuccess:
+			started = true
+		// 被中断的任务会被重新调度，还是当成正在执行
+		case JobExecutionStatusRunning, JobExecutionStatusInterrupted:
+			started, success = true, false
+		default:
+			success = false
+		}
+	}
+	switch {
+	case success && len(r.Nodes) > 0:
+		return WorkflowStatusSuccess
+	case started:
+		return WorkflowStatusRunning
+	default:
+		return WorkflowStatusPending
+	}
+}
+
+// NewWorkflowRun execs 里面同一个任务有多条执行记录的时候，取 ID 最大的那条
+func NewWorkflowRun(window time.Time, jobs []CronJob, execs []JobExecution) WorkflowRun {
+	latest := make(map[int64]JobExecution, len(execs))
+	for _, e := range execs {
+		if e.ID > latest[e.JobID].ID {
+			latest[e.JobID] = e
+		}
+	}
+	run := WorkflowRun{Window: window, Nodes: make([]WorkflowNode, 0, len(jobs))}
+	for _, j := range sortJobs(jobs) {
+		run.Nodes = append(run.Nodes, WorkflowNode{Job: j, Execution: latest[j.ID]})
+	}
+	return run
+}
+
+// sortJobs 拓扑排序，不在 jobs 里面的上游忽略
+func sortJobs(jobs []CronJob) []CronJob {
+	byName := make(map[string]CronJob, len(jobs))
+	for _, j := range jobs {
+		byName[j.Name] = j
+	}
+	res := make([]CronJob, 0, len(jobs))
+	visited := make(map[string]bool, len(jobs))
+	var visit func(j CronJob)
+	visit = func(j CronJob) {
+		if visited[j.Name] {
+			return
+		}
+		visited[j.Name] = true
+		for _, up := range j.Upstreams {
+			if u, ok := byName[up]; ok {
+				visit(u)
+			}
+		}
+		res = append(res, j)
+	}
+	for _, j := range jobs {
+		visit(j)
+	}
+	return res
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortJobs(t *testing.T) {
+	testCases := []struct {
+		name string
+		jobs []CronJob
+
+		wantNames []string
+	}{
+		{
+			name:      "没有任务",
+			wantNames: []string{},
+		},
+		{
+			name: "上游在前面",
+			jobs: []CronJob{
+				{Name: "c", Upstreams: []string{"b"}},
+				{Name: "b", Upstreams: []string{"a"}},
+				{Name: "a"},
+			},
+			wantNames: []string{"a", "b", "c"},
+		},
+		{
+			name: "菱形依赖",
+			jobs: []CronJob{
+				{Name: "d", Upstreams: []string{"b", "c"}},
+				{Name: "c", Upstreams: []string{"a"}},
+				{Name: "b", Upstreams: []string{"a"}},
+				{Name: "a"},
+			},
+			wantNames: []string{"a", "b", "c", "d"},
+		},
+		{
+			name: "不在 jobs 里面的上游忽略",
+			jobs: []CronJob{
+				{Name: "b", Upstreams: []string{"x", "a"}},
+				{Name: "a", Upstreams: []string{"y"}},
+			},
+			wantNames: []string{"a", "b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := sortJobs(tc.jobs)
+			assert.Equal(t, tc.wantNames, slice.Map(res, func(idx int, src CronJob) string {
+				return src.Name
+			}))
+		})
+	}
+}
+
+func TestWorkflowRun_Status(t *testing.T) {
+	testCases := []struct {
+		name     string
+		statuses []JobExecutionStatus
+
+		wantStatus WorkflowStatus
+	}{
+		{
+			name:       "没有任务",
+			wantStatus: WorkflowStatusPending,
+		},
+		{
+			name:       "都还没有执行",
+			statuses:   []JobExecutionStatus{JobExecutionStatusUnknown, JobExecutionStatusUnknown},
+			wantStatus: WorkflowStatusPending,
+		},
+		{
+			name:       "上游成功了，下游还没有执行",
+			statuses:   []JobExecutionStatus{JobExecutionStatusSuccess, JobExecutionStatusUnknown},
+			wantStatus: WorkflowStatusRunning,
+		},
+		{
+			name:       "正在执行",
+			statuses:   []JobExecutionStatus{JobExecutionStatusRunning, JobExecutionStatusUnknown},
+			wantStatus: WorkflowStatusRunning,
+		},
+		{
+			name:       "被中断了，等重新执行",
+			statuses:   []JobExecutionStatus{JobExecutionStatusSuccess, JobExecutionStatusInterrupted},
+			wantStatus: WorkflowStatusRunning,
+		},
+		{
+			name:       "全部成功",
+			statuses:   []JobExecutionStatus{JobExecutionStatusSuccess, JobExecutionStatusSuccess},
+			wantStatus: WorkflowStatusSuccess,
+		},
+		{
+			name:       "有任务失败",
+			statuses:   []JobExecutionStatus{JobExecutionStatusSuccess, JobExecutionStatusFailed, JobExecutionStatusRunning},
+			wantStatus: WorkflowStatusFailed,
+		},
+		{
+			name:       "有任务被跳过",
+			statuses:   []JobExecutionStatus{JobExecutionStatusFailed, JobExecutionStatusSkipped},
+			wantStatus: WorkflowStatusFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := WorkflowRun{Nodes: slice.Map(tc.statuses, func(idx int, src JobExecutionStatus) WorkflowNode {
+				return WorkflowNode{Execution: JobExecution{Status: src}}
+			})}
+			assert.Equal(t, tc.wantStatus, run.Status())
+		})
+	}
+}
+
+func TestNewWorkflowRun(t *testing.T) {
+	window := time.UnixMilli(1000)
+	jobs := []CronJob{
+		{ID: 2, Name: "b", Upstreams: []string{"a"}},
+		{ID: 1, Name: "a"},
+	}
+	execs := []JobExecution{
+		{ID: 1, JobID: 1, Status: JobExecutionStatusFailed},
+		{ID: 3, JobID: 1, Status: JobExecutionStatusSuccess},
+		{ID: 2, JobID: 1, Status: JobExecutionStatusFailed},
+	}
+	run := NewWorkflowRun(window, jobs, execs)
+	// 同一个任务取 ID 最大的执行记录，没有执行的任务执行记录是零值
+	assert.Equal(t, WorkflowRun{
+		Window: window,
+		Nodes: []WorkflowNode{
+			{Job: jobs[1], Execution: execs[1]},
+			{Job: jobs[0]},
+		},
+	}, run)
+}
@@ -17,6 +17,52 @@ const (
 // Article 部分，模块代码使用 02
 const (
 	// ArticleInvalidInput 含糊的输入错误
-	ArticleInvalidInput        = 402001
+	ArticleInvalidInput = 402001
+	// ArticleRevisionNotFound 历史版本不存在
+	ArticleRevisionNotFound = 402002
+	// ArticleNotScheduled 文章不存在、不是自己的，或者没有处于定时发表状态
+	ArticleNotScheduled        = 402003
 	ArticleInternalServerError = 502001
 )
+
+// Comment 部分，模块代码使用 03
+const (
+	CommentInvalidInput        = 403001
+	CommentInternalServerError = 503001
+)
+
+// Follow 部分，模块代码使用 04
+const (
+	FollowInvalidInput        = 404001
+	FollowInternalServerError = 504001
+)
+
+// Feed 部分，模块代码使用 05
+const (
+	FeedInvalidInput        = 405001
+	FeedInternalServerError = 505001
+)
+
+// History 部分，模块代码使用 06
+const (
+	HistoryInvalidInput        = 406001
+	HistoryInternalServerError = 506001
+)
+
+// Collection 部分，模块代码使用 07
+const (
+	CollectionInvalidInput = 407001
+	CollectionNotFound     = 407002
+	// CollectionItemExists 已经收藏到了别的收藏夹
+	CollectionItemExists          = 407003
+	CollectionInternalServerError = 507001
+)
+
+// CronJob 部分，模块代码使用 08
+const (
+	CronJobInvalidInput        = 408001
+	CronJobNotFound            = 408002
+	CronJobStatusConflict      = 408003
+	CronJobNameConflict        = 408004
+	CronJobInternalServerError = 508001
+)
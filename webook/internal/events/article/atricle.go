@@ -1,22 +1,50 @@
 package article
 
 import (
+	"context"
 	"encoding/json"
+	"strconv"
 
 	"github.com/IBM/sarama"
+
+	"geektime-basic-go/webook/pkg/outbox"
+	"geektime-basic-go/webook/pkg/saramax"
 )
 
-const topicReadEvent = "article_read_event"
+const (
+	topicReadEvent     = "article_read_event"
+	topicPublishEvent  = "article_publish_event"
+	topicWithdrawEvent = "article_withdraw_event"
+)
 
 type ReadEvent struct {
 	Aid int64
 	Uid int64
 }
 
+// PublishEvent 文章发表之后发出，feed 据此推送给粉丝
+type PublishEvent struct {
+	Aid      int64
+	Uid      int64
+	Title    string
+	Abstract string
+	// PublishAt 发表时间，毫秒数
+	PublishAt int64
+}
+
+// WithdrawEvent 文章撤回之后发出，搜索据此删除索引
+type WithdrawEvent struct {
+	Aid int64
+	Uid int64
+}
+
 type Producer interface {
-	ProduceReadEvent(evt ReadEvent) error
+	ProduceReadEvent(ctx context.Context, evt ReadEvent) error
+	ProducePublishEvent(ctx context.Context, evt PublishEvent) error
+	ProduceWithdrawEvent(ctx context.Context, evt WithdrawEvent) error
 }
 
+// saramaSyncProducer 每条消息都带上新的事件 ID，sarama 内部重试发送的还是同一条消息
 type saramaSyncProducer struct {
 	producer sarama.SyncProducer
 }
@@ -25,15 +53,66 @@ func NewSaramaSyncProducer(producer sarama.SyncProducer) Producer {
 	return &saramaSyncProducer{producer: producer}
 }
 
-func (p *saramaSyncProducer) ProduceReadEvent(evt ReadEvent) error {
+func (p *saramaSyncProducer) ProduceReadEvent(ctx context.Context, evt ReadEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   topicReadEvent,
+		Value:   sarama.ByteEncoder(val),
+		Headers: []sarama.RecordHeader{saramax.EventIDHeader("")},
+	})
+	return err
+}
+
+func (p *saramaSyncProducer) ProducePublishEvent(ctx context.Context, evt PublishEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   topicPublishEvent,
+		Value:   sarama.ByteEncoder(val),
+		Headers: []sarama.RecordHeader{saramax.EventIDHeader("")},
+	})
+	return err
+}
+
+func (p *saramaSyncProducer) ProduceWithdrawEvent(ctx context.Context, evt WithdrawEvent) error {
 	val, err := json.Marshal(evt)
 	if err != nil {
 		return err
 	}
 
 	_, _, err = p.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: topicReadEvent,
-		Value: sarama.ByteEncoder(val),
+		Topic:   topicWithdrawEvent,
+		Value:   sarama.ByteEncoder(val),
+		Headers: []sarama.RecordHeader{saramax.EventIDHeader("")},
 	})
 	return err
 }
+
+// outboxProducer 先把消息写到 outbox 表，由 outbox.Relay 发送
+// ctx 里面有事务的话，消息和业务数据在同一个事务里提交
+type outboxProducer struct {
+	store *outbox.Store
+}
+
+func NewOutboxProducer(store *outbox.Store) Producer {
+	return &outboxProducer{store: store}
+}
+
+func (p *outboxProducer) ProduceReadEvent(ctx context.Context, evt ReadEvent) error {
+	return p.store.Save(ctx, topicReadEvent, strconv.FormatInt(evt.Aid, 10), evt)
+}
+
+func (p *outboxProducer) ProducePublishEvent(ctx context.Context, evt PublishEvent) error {
+	return p.store.Save(ctx, topicPublishEvent, strconv.FormatInt(evt.Aid, 10), evt)
+}
+
+func (p *outboxProducer) ProduceWithdrawEvent(ctx context.Context, evt WithdrawEvent) error {
+	return p.store.Save(ctx, topicWithdrawEvent, strconv.FormatInt(evt.Aid, 10), evt)
+}
@@ -0,0 +1,84 @@
+package ranking
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/events"
+	"geektime-basic-go/webook/internal/service"
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/saramax"
+)
+
+// 阅读事件是 webook 发出来的，点赞、收藏事件是 interactive 发出来的，topic 要和发送方保持一致。
+// 点赞用的是点赞状态真正变化之后的事件，不是用户点击的意图，重复点赞不会重复计分。
+// 收藏事件本身就只在收藏状态变化之后才会发出
+const (
+	topicReadEvent     = "article_read_event"
+	topicLikeChanged   = "article_like_changed_event"
+	topicChangeCollect = "article_change_collect_event"
+)
+
+// rankingEvent 阅读、点赞、收藏三种事件的字段合在一起，按照 topic 区分
+type rankingEvent struct {
+	// Aid 阅读事件
+	Aid int64
+	// Biz 收藏事件，为空的时候就是 article
+	Biz   string
+	BizID int64
+	// Liked 和 Collected 都是变更之后的状态
+	Liked     bool
+	Collected bool
+}
+
+var _ events.Consumer = (*RankingEventConsumer)(nil)
+
+// RankingEventConsumer 根据互动事件实时更新热榜。
+// 整批重试的时候会重复累加，热榜只要求大致准确，所以不做去重
+type RankingEventConsumer struct {
+	*saramax.Consumer[rankingEvent]
+	svc service.RankingService
+}
+
+func NewRankingEventConsumer(client sarama.Client, svc service.RankingService, l logger.Logger) *RankingEventConsumer {
+	c := &RankingEventConsumer{svc: svc}
+	c.Consumer = saramax.NewConsumer[rankingEvent](client, l, c.BatchConsume,
+		saramax.WithGroupID("ranking"),
+		saramax.WithTopics(topicReadEvent, topicLikeChanged, topicChangeCollect),
+		saramax.WithBatchSize(50))
+	return c
+}
+
+func (c *RankingEventConsumer) BatchConsume(ctx context.Context, msgs []*sarama.ConsumerMessage, evts []rankingEvent) error {
+	res := make([]domain.RankingEvent, 0, len(evts))
+	for idx, evt := range evts {
+		re := domain.RankingEvent{Biz: "article"}
+		switch msgs[idx].Topic {
+		case topicReadEvent:
+			re.BizID = evt.Aid
+			re.Delta = domain.Interactive{ReadCnt: 1}
+		case topicLikeChanged:
+			re.BizID = evt.BizID
+			re.Delta = domain.Interactive{LikeCnt: c.delta(evt.Liked)}
+		case topicChangeCollect:
+			if evt.Biz != "" {
+				re.Biz = evt.Biz
+			}
+			re.BizID = evt.BizID
+			re.Delta = domain.Interactive{CollectCnt: c.delta(evt.Collected)}
+		default:
+			continue
+		}
+		res = append(res, re)
+	}
+	return c.svc.Incr(ctx, res)
+}
+
+func (c *RankingEventConsumer) delta(on bool) int64 {
+	if on {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,66 @@
+package search
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+
+	"geektime-basic-go/webook/internal/events"
+	"geektime-basic-go/webook/internal/service"
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/saramax"
+)
+
+// topic 要和 internal/events/article 里面发送方保持一致
+const (
+	topicPublishEvent  = "article_publish_event"
+	topicWithdrawEvent = "article_withdraw_event"
+)
+
+// articleEvent 发表和撤回事件只需要文章 ID，文章的最新状态从线上库查
+type articleEvent struct {
+	Aid int64
+}
+
+var _ events.Consumer = (*ArticleEventConsumer)(nil)
+
+// ArticleEventConsumer 根据发表、撤回事件更新搜索索引
+type ArticleEventConsumer struct {
+	*saramax.Consumer[articleEvent]
+	svc service.SearchService
+}
+
+// NewArticleEventConsumer 索引在每个实例自己的内存里，所以每个实例用自己的消费者组，都能收到全部的事件。
+// 拿不到主机名就用随机的 ID，不能退化成所有实例共用一个消费者组
+func NewArticleEventConsumer(client sarama.Client, svc service.SearchService, l logger.Logger) *ArticleEventConsumer {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = uuid.NewString()
+		l.Error("获取主机名失败，使用随机的消费者组", logger.String("group", "search_"+host), logger.Error(err))
+	}
+	c := &ArticleEventConsumer{svc: svc}
+	c.Consumer = saramax.NewConsumer[articleEvent](client, l, c.BatchConsume,
+		saramax.WithGroupID("search_"+host),
+		saramax.WithTopics(topicPublishEvent, topicWithdrawEvent),
+		saramax.WithBatchSize(50),
+		saramax.WithTimeout(5*time.Second))
+	return c
+}
+
+// BatchConsume 同一批里面同一篇文章只需要同步一次
+func (c *ArticleEventConsumer) BatchConsume(ctx context.Context, msgs []*sarama.ConsumerMessage, evts []articleEvent) error {
+	seen := make(map[int64]struct{}, len(evts))
+	for _, evt := range evts {
+		if _, ok := seen[evt.Aid]; ok {
+			continue
+		}
+		seen[evt.Aid] = struct{}{}
+		if err := c.svc.SyncArticle(ctx, evt.Aid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package startup
+
+import (
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/pkg/searchx"
+)
+
+func InitArticleSearchIndex() *searchx.Index[domain.Article] {
+	return searchx.NewIndex[domain.Article](map[string]float64{"title": 3, "content": 1})
+}
@@ -12,6 +12,11 @@ import (
 
 	"geektime-basic-go/webook/internal/web"
 	"geektime-basic-go/webook/internal/web/article"
+	"geektime-basic-go/webook/internal/web/collection"
+	"geektime-basic-go/webook/internal/web/comment"
+	"geektime-basic-go/webook/internal/web/feed"
+	"geektime-basic-go/webook/internal/web/follow"
+	"geektime-basic-go/webook/internal/web/history"
 	myjwt "geektime-basic-go/webook/internal/web/jwt"
 	"geektime-basic-go/webook/internal/web/middleware/login"
 	"geektime-basic-go/webook/pkg/ginx/accesslog"
@@ -23,6 +28,11 @@ import (
 func InitWeb(fn []gin.HandlerFunc,
 	uh *web.UserHandler,
 	ah *article.Handler,
+	ch *comment.Handler,
+	fh *follow.Handler,
+	feh *feed.Handler,
+	hh *history.Handler,
+	colh *collection.Handler,
 	oh *web.OAuth2WechatHandler,
 	l logger.Logger,
 ) *gin.Engine {
@@ -31,6 +41,11 @@ func InitWeb(fn []gin.HandlerFunc,
 	server.Use(fn...)
 	uh.RegisterRoutes(server)
 	ah.RegisterRoutes(server)
+	ch.RegisterRoutes(server)
+	fh.RegisterRoutes(server)
+	feh.RegisterRoutes(server)
+	hh.RegisterRoutes(server)
+	colh.RegisterRoutes(server)
 	oh.RegisterRoutes(server)
 	return server
 }
@@ -8,13 +8,20 @@ import (
 
 	events "geektime-basic-go/webook/internal/events/article"
 	"geektime-basic-go/webook/internal/repository"
+	"geektime-basic-go/webook/internal/repository/cache/memory"
 	redisCache "geektime-basic-go/webook/internal/repository/cache/redis"
 	"geektime-basic-go/webook/internal/repository/dao"
 	"geektime-basic-go/webook/internal/repository/dao/article"
 	"geektime-basic-go/webook/internal/service"
 	"geektime-basic-go/webook/internal/web"
 	webarticle "geektime-basic-go/webook/internal/web/article"
+	webcollection "geektime-basic-go/webook/internal/web/collection"
+	webcomment "geektime-basic-go/webook/internal/web/comment"
+	webfeed "geektime-basic-go/webook/internal/web/feed"
+	webfollow "geektime-basic-go/webook/internal/web/follow"
+	webhistory "geektime-basic-go/webook/internal/web/history"
 	myjwt "geektime-basic-go/webook/internal/web/jwt"
+	"geektime-basic-go/webook/pkg/gormx"
 )
 
 var thirdProvider = wire.NewSet(
@@ -33,12 +40,30 @@ var userSvcProvider = wire.NewSet(
 )
 
 var articleSvcProvider = wire.NewSet(
+	gormx.NewTransactor,
 	service.NewArticleService,
 	repository.NewCacheArticleRepository,
 	article.NewGormArticleDAO,
+	article.NewGormRevisionDAO,
+	article.NewGormTagDAO,
 	redisCache.NewArticleCache,
 )
 
+var searchSvcProvider = wire.NewSet(
+	service.NewSearchService,
+	repository.NewIndexSearchRepository,
+	InitArticleSearchIndex,
+)
+
+var rankSvcProvider = wire.NewSet(
+	service.NewBatchRankingService,
+	service.DefaultRankingConfig,
+	repository.NewCacheRankingRepository,
+	dao.NewGormRankingSnapshotDAO,
+	redisCache.NewRankingCache,
+	memory.NewRankingCache,
+)
+
 var codeSvcProvider = wire.NewSet(
 	InitSmsSvc,
 	service.NewSMSCodeService,
@@ -55,6 +80,11 @@ var HandlerProvider = wire.NewSet(
 	web.NewUserHandler,
 	web.NewOAuth2WechatHandler,
 	webarticle.NewArticleHandler,
+	webcomment.NewCommentHandler,
+	webfollow.NewFollowHandler,
+	webfeed.NewFeedHandler,
+	webhistory.NewHistoryHandler,
+	webcollection.NewCollectionHandler,
 )
 
 func InitWebServer() *gin.Engine {
@@ -68,6 +98,8 @@ func InitWebServer() *gin.Engine {
 		userSvcProvider,
 		codeSvcProvider,
 		articleSvcProvider,
+		searchSvcProvider,
+		rankSvcProvider,
 		InitLocalWechatService,
 
 		// handler 部分
@@ -88,9 +120,14 @@ func InitArticleHandlerWithDAO(dao article.DAO) *webarticle.Handler {
 		thirdProvider,
 		userSvcProvider,
 		events.NewSaramaSyncProducer,
+		gormx.NewTransactor,
 		service.NewArticleService,
 		repository.NewCacheArticleRepository,
+		article.NewGormRevisionDAO,
+		article.NewGormTagDAO,
 		redisCache.NewArticleCache,
+		searchSvcProvider,
+		rankSvcProvider,
 		webarticle.NewArticleHandler,
 	)
 	return new(webarticle.Handler)
@@ -101,6 +138,8 @@ func InitArticleHandlerWithKafka() *webarticle.Handler {
 		thirdProvider,
 		userSvcProvider,
 		articleSvcProvider,
+		searchSvcProvider,
+		rankSvcProvider,
 		events.NewSaramaSyncProducer,
 		webarticle.NewArticleHandler,
 	)
@@ -112,9 +151,14 @@ func InitArticleHandlerWithDAO(dao article.DAO) *webarticle.Handler {
 		thirdProvider,
 		userSvcProvider,
 		events.NewSaramaSyncProducer,
+		gormx.NewTransactor,
 		service.NewArticleService,
 		repository.NewCacheArticleRepository,
+		article.NewGormRevisionDAO,
+		article.NewGormTagDAO,
 		redisCache.NewArticleCache,
+		searchSvcProvider,
+		rankSvcProvider,
 		webarticle.NewArticleHandler,
 	)
 	return new(webarticle.Handler)
@@ -0,0 +1,37 @@
+package job
+
+import (
+	"context"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// AlertHook 任务连续失败到一定次数之后调用，
+// 可以接入短信、邮件或者 IM 机器人之类的告警渠道
+type AlertHook interface {
+	// Alert j.FailCount 是连续失败的次数，err 是最后一次失败的原因
+	Alert(ctx context.Context, j CronJob, err error)
+}
+
+type AlertFunc func(ctx context.Context, j CronJob, err error)
+
+func (f AlertFunc) Alert(ctx context.Context, j CronJob, err error) {
+	f(ctx, j, err)
+}
+
+// LogAlertHook 默认的告警，只打日志
+type LogAlertHook struct {
+	l logger.Logger
+}
+
+func NewLogAlertHook(l logger.Logger) *LogAlertHook {
+	return &LogAlertHook{l: l}
+}
+
+func (h *LogAlertHook) Alert(ctx context.Context, j CronJob, err error) {
+	h.l.Error("任务连续失败",
+		logger.Int("id", j.ID),
+		logger.String("name", j.Name),
+		logger.Int("failCount", j.FailCount),
+		logger.Error(err))
+}
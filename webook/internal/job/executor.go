@@ -0,0 +1,61 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryConfig 远程执行器的超时和重试配置
+type RetryConfig struct {
+	// Timeout 每一次调用的超时时间
+	Timeout time.Duration `yaml:"timeout"`
+	// Retries 失败之后最多重试几次，0 就是不重试
+	Retries int `yaml:"retries"`
+	// Backoff 重试的间隔，每次重试翻倍
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+func (c RetryConfig) withDefault() RetryConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = time.Second
+	}
+	return c
+}
+
+// permanentError 不需要重试的错误，比如说参数不对
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// retry 每一次调用都带上超时，ctx 被取消了就不再重试
+func retry(ctx context.Context, cfg RetryConfig, fn func(ctx context.Context) error) error {
+	var err error
+	backoff := cfg.Backoff
+	for i := 0; ; i++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		err = fn(attemptCtx)
+		cancel()
+		var pe permanentError
+		if err == nil || errors.As(err, &pe) || i >= cfg.Retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,117 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/resolver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcresolver "google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+
+	jobv1 "geektime-basic-go/webook/api/proto/gen/job"
+)
+
+// GRPCTarget 一个任务对应的服务，服务要实现 JobService 并且注册到 etcd 上
+type GRPCTarget struct {
+	// Service 在 etcd 上注册的服务名
+	Service string `yaml:"service"`
+	RetryConfig
+}
+
+// GRPCExecutor 通过 etcd 找到服务，调用 JobService.Execute 来执行任务。
+// 同一个服务的连接是复用的
+type GRPCExecutor struct {
+	builder grpcresolver.Builder
+	lock    sync.Mutex
+	targets map[string]GRPCTarget
+	conns   map[string]*grpc.ClientConn
+}
+
+func NewGRPCExecutor(client *clientv3.Client) (*GRPCExecutor, error) {
+	bd, err := resolver.NewBuilder(client)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCExecutor{
+		builder: bd,
+		targets: make(map[string]GRPCTarget),
+		conns:   make(map[string]*grpc.ClientConn),
+	}, nil
+}
+
+func (g *GRPCExecutor) AddTarget(name string, target GRPCTarget) {
+	target.RetryConfig = target.RetryConfig.withDefault()
+	g.lock.Lock()
+	g.targets[name] = target
+	g.lock.Unlock()
+}
+
+func (g *GRPCExecutor) HasJob(name string) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	_, ok := g.targets[name]
+	return ok
+}
+
+func (g *GRPCExecutor) Name() string {
+	return "grpc"
+}
+
+func (g *GRPCExecutor) Exec(ctx context.Context, j CronJob) error {
+	target, client, err := g.client(j.Name)
+	if err != nil {
+		return err
+	}
+	req := &jobv1.ExecuteRequest{Id: j.ID, Name: j.Name, Cfg: j.Cfg}
+	return retry(ctx, target.RetryConfig, func(ctx context.Context) error {
+		_, err := client.Execute(ctx, req)
+		switch status.Code(err) {
+		case codes.OK:
+			return nil
+		// 这些错误重试可能会成功
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+			return err
+		default:
+			return permanentError{err: err}
+		}
+	})
+}
+
+func (g *GRPCExecutor) client(name string) (GRPCTarget, jobv1.JobServiceClient, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	target, ok := g.targets[name]
+	if !ok {
+		return GRPCTarget{}, nil, fmt.Errorf("任务 %s 没有配置 gRPC 服务", name)
+	}
+	cc, ok := g.conns[target.Service]
+	if !ok {
+		var err error
+		cc, err = grpc.Dial("etcd:///service/"+target.Service,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithResolvers(g.builder))
+		if err != nil {
+			return GRPCTarget{}, nil, err
+		}
+		g.conns[target.Service] = cc
+	}
+	return target, jobv1.NewJobServiceClient(cc), nil
+}
+
+// Close 关闭所有的连接
+func (g *GRPCExecutor) Close() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	var err error
+	for svc, cc := range g.conns {
+		err = errors.Join(err, cc.Close())
+		delete(g.conns, svc)
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package job
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcresolver "google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+
+	jobv1 "geektime-basic-go/webook/api/proto/gen/job"
+)
+
+func TestGRPCExecutor_Exec(t *testing.T) {
+	testCases := []struct {
+		name string
+		// codes 每一次调用返回的错误码，超出之后都返回最后一个
+		codes   []codes.Code
+		retries int
+		// jobName 没有配置服务的任务
+		jobName string
+
+		wantCalls int32
+		wantCode  codes.Code
+		wantErr   bool
+	}{
+		{
+			name:      "执行成功",
+			codes:     []codes.Code{codes.OK},
+			wantCalls: 1,
+		},
+		{
+			name:      "服务不可用重试之后成功",
+			codes:     []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.OK},
+			retries:   2,
+			wantCalls: 3,
+		},
+		{
+			name:      "重试次数用完了",
+			codes:     []codes.Code{codes.Aborted},
+			retries:   2,
+			wantCalls: 3,
+			wantCode:  codes.Aborted,
+			wantErr:   true,
+		},
+		{
+			name:      "参数错误不重试",
+			codes:     []codes.Code{codes.InvalidArgument},
+			retries:   2,
+			wantCalls: 1,
+			wantCode:  codes.InvalidArgument,
+			wantErr:   true,
+		},
+		{
+			name:    "任务没有配置服务",
+			jobName: "unknown_job",
+			// 不是 gRPC 的错误
+			wantCode: codes.Unknown,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &fakeJobService{codes: tc.codes}
+			g := newTestGRPCExecutor(t, svc)
+			g.AddTarget("test_job", GRPCTarget{
+				Service:     "test",
+				RetryConfig: RetryConfig{Retries: tc.retries, Backoff: time.Millisecond},
+			})
+			defer g.Close()
+
+			name := "test_job"
+			if tc.jobName != "" {
+				name = tc.jobName
+			}
+			err := g.Exec(context.Background(), CronJob{ID: 1, Name: name, Cfg: "hello"})
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.wantCode, status.Code(err))
+			assert.Equal(t, tc.wantCalls, svc.calls.Load())
+		})
+	}
+}
+
+// TestGRPCExecutor_Conn 同一个服务的连接是复用的，Close 之后全部关闭
+func TestGRPCExecutor_Conn(t *testing.T) {
+	svc := &fakeJobService{codes: []codes.Code{codes.OK}}
+	g := newTestGRPCExecutor(t, svc)
+	g.AddTarget("job1", GRPCTarget{Service: "test"})
+	g.AddTarget("job2", GRPCTarget{Service: "test"})
+
+	for _, name := range []string{"job1", "job2"} {
+		require.NoError(t, g.Exec(context.Background(), CronJob{Name: name}))
+	}
+	assert.Len(t, g.conns, 1)
+	assert.Equal(t, int32(2), svc.calls.Load())
+
+	require.NoError(t, g.Close())
+	assert.Empty(t, g.conns)
+}
+
+// newTestGRPCExecutor 不依赖 etcd，用 manual 的 resolver 解析到本地启动的服务
+func newTestGRPCExecutor(t *testing.T, svc jobv1.JobServiceServer) *GRPCExecutor {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	jobv1.RegisterJobServiceServer(server, svc)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+
+	r := manual.NewBuilderWithScheme("etcd")
+	r.InitialState(grpcresolver.State{Addresses: []grpcresolver.Address{{Addr: lis.Addr().String()}}})
+	return &GRPCExecutor{
+		builder: r,
+		targets: make(map[string]GRPCTarget),
+		conns:   make(map[string]*grpc.ClientConn),
+	}
+}
+
+type fakeJobService struct {
+	jobv1.UnimplementedJobServiceServer
+	codes []codes.Code
+	calls atomic.Int32
+}
+
+func (f *fakeJobService) Execute(ctx context.Context, req *jobv1.ExecuteRequest) (*jobv1.ExecuteResponse, error) {
+	idx := int(f.calls.Add(1)) - 1
+	if idx >= len(f.codes) {
+		idx = len(f.codes) - 1
+	}
+	if f.codes[idx] == codes.OK {
+		return &jobv1.ExecuteResponse{}, nil
+	}
+	return nil, status.Error(f.codes[idx], "模拟失败")
+}
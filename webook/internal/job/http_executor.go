@@ -0,0 +1,98 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HTTPEndpoint 一个任务对应的 HTTP 接口，任务的 Cfg 作为请求体
+type HTTPEndpoint struct {
+	URL string `yaml:"url"`
+	// Method 默认是 POST
+	Method string            `yaml:"method"`
+	Header map[string]string `yaml:"header"`
+	RetryConfig
+}
+
+// HTTPExecutor 通过 HTTP 调用别的服务来执行任务，按照任务的名字找到对应的接口。
+// 2xx 代表成功，5xx、408、429 和网络错误会重试，其它的 4xx 不会重试
+type HTTPExecutor struct {
+	client    *http.Client
+	lock      sync.RWMutex
+	endpoints map[string]HTTPEndpoint
+}
+
+func NewHTTPExecutor(client *http.Client) *HTTPExecutor {
+	return &HTTPExecutor{client: client, endpoints: make(map[string]HTTPEndpoint)}
+}
+
+func (h *HTTPExecutor) AddEndpoint(name string, ep HTTPEndpoint) {
+	if ep.Method == "" {
+		ep.Method = http.MethodPost
+	}
+	ep.RetryConfig = ep.RetryConfig.withDefault()
+	h.lock.Lock()
+	h.endpoints[name] = ep
+	h.lock.Unlock()
+}
+
+func (h *HTTPExecutor) HasJob(name string) bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	_, ok := h.endpoints[name]
+	return ok
+}
+
+func (h *HTTPExecutor) Name() string {
+	return "http"
+}
+
+func (h *HTTPExecutor) Exec(ctx context.Context, j CronJob) error {
+	h.lock.RLock()
+	ep, ok := h.endpoints[j.Name]
+	h.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("任务 %s 没有配置 HTTP 接口", j.Name)
+	}
+	return retry(ctx, ep.RetryConfig, func(ctx context.Context) error {
+		return h.call(ctx, ep, j)
+	})
+}
+
+func (h *HTTPExecutor) call(ctx context.Context, ep HTTPEndpoint, j CronJob) error {
+	req, err := http.NewRequestWithContext(ctx, ep.Method, ep.URL, strings.NewReader(j.Cfg))
+	if err != nil {
+		return permanentError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ep.Header {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("X-Job-ID", strconv.FormatInt(j.ID, 10))
+	req.Header.Set("X-Job-Name", j.Name)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	// 带上一部分响应，方便在执行记录里面排查问题
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("HTTP 状态码 %d: %s", resp.StatusCode, body)
+	switch {
+	// 服务端处理不过来，稍后再试可能会成功
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return err
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return permanentError{err: err}
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package job
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPExecutor_Exec(t *testing.T) {
+	testCases := []struct {
+		name string
+		// codes 每一次请求返回的状态码，超出之后都返回最后一个
+		codes   []int
+		retries int
+
+		wantCalls int32
+		wantErr   bool
+	}{
+		{
+			name:      "执行成功",
+			codes:     []int{http.StatusOK},
+			wantCalls: 1,
+		},
+		{
+			name:      "服务端错误重试之后成功",
+			codes:     []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent},
+			retries:   2,
+			wantCalls: 3,
+		},
+		{
+			name:      "重试次数用完了",
+			codes:     []int{http.StatusServiceUnavailable},
+			retries:   2,
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "超时和限流重试之后成功",
+			codes:     []int{http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusOK},
+			retries:   2,
+			wantCalls: 3,
+		},
+		{
+			name:      "参数错误不重试",
+			codes:     []int{http.StatusBadRequest},
+			retries:   2,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				idx := int(calls.Add(1)) - 1
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, `{"hello":"world"}`, string(body))
+				assert.Equal(t, "test_job", r.Header.Get("X-Job-Name"))
+				assert.Equal(t, "1", r.Header.Get("X-Job-ID"))
+				assert.Equal(t, "abc", r.Header.Get("X-Token"))
+				w.WriteHeader(tc.codes[min(idx, len(tc.codes)-1)])
+			}))
+			defer server.Close()
+
+			executor := NewHTTPExecutor(server.Client())
+			executor.AddEndpoint("test_job", HTTPEndpoint{
+				URL:    server.URL,
+				Header: map[string]string{"X-Token": "abc"},
+				RetryConfig: RetryConfig{
+					Timeout: time.Second, Retries: tc.retries, Backoff: time.Millisecond,
+				},
+			})
+			err := executor.Exec(context.Background(), CronJob{ID: 1, Name: "test_job", Cfg: `{"hello":"world"}`})
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestHTTPExecutor_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	executor := NewHTTPExecutor(server.Client())
+	executor.AddEndpoint("test_job", HTTPEndpoint{URL: server.URL, RetryConfig: RetryConfig{
+		Timeout: 50 * time.Millisecond, Retries: 5, Backoff: time.Second,
+	}})
+	// 调度器的 ctx 取消之后不再重试
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := executor.Exec(ctx, CronJob{Name: "test_job"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	err = executor.Exec(context.Background(), CronJob{Name: "unknown"})
+	assert.Error(t, err)
+}
@@ -3,6 +3,10 @@ package job
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
 	"time"
 
 	"golang.org/x/sync/semaphore"
@@ -16,12 +20,17 @@ type ExecFunc func(ctx context.Context, j domain.CronJob) error
 
 type Executor interface {
 	Name() string
-	// Exec ctx 是整个任务调度的上下文
+	// Exec 任务超时，或者调度器关闭超时的时候 ctx 会被取消
 	// 当从 ctx.Done 有信号的时候，就需要考虑结束执行
 	// 具体实现来控制
 	Exec(ctx context.Context, j domain.CronJob) error
 }
 
+// jobChecker 按照任务名字找执行逻辑的执行器，只能执行注册或者配置过的任务
+type jobChecker interface {
+	HasJob(name string) bool
+}
+
 type LocalFuncExecutor struct {
 	funcs map[string]ExecFunc
 }
@@ -34,6 +43,11 @@ func (l *LocalFuncExecutor) AddLocalFunc(name string, fn ExecFunc) {
 	l.funcs[name] = fn
 }
 
+func (l *LocalFuncExecutor) HasJob(name string) bool {
+	_, ok := l.funcs[name]
+	return ok
+}
+
 func (l *LocalFuncExecutor) Name() string {
 	return "local"
 }
@@ -54,29 +68,75 @@ type Scheduler struct {
 	dbTimeout time.Duration
 	interval  time.Duration
 	limiter   *semaphore.Weighted
+	// node 记录在执行记录里面，默认是主机名
+	node string
+	// alert 连续失败 alertThreshold 次就告警一次，任务自己配置了阈值的以任务的为准
+	alert          AlertHook
+	alertThreshold int
+
+	// execCtx 所有任务执行的上下文，和 Start 的 ctx 分开，Close 超时之后才取消
+	execCtx    context.Context
+	execCancel context.CancelFunc
+	// wg 正在执行的任务
+	wg sync.WaitGroup
+	// stopped Start 退出之后关闭，之后就不会再抢占任务了
+	stopped chan struct{}
+	lock    sync.Mutex
+	running map[int64]CronJob
 }
 
 func NewScheduler(svc service.CronJobService, l logger.Logger) *Scheduler {
+	node, _ := os.Hostname()
+	execCtx, execCancel := context.WithCancel(context.Background())
 	return &Scheduler{
-		svc:       svc,
-		execs:     make(map[string]Executor, 8),
-		l:         l,
-		dbTimeout: 3 * time.Second,
-		interval:  time.Second,
+		execCtx:        execCtx,
+		execCancel:     execCancel,
+		node:           node,
+		alert:          NewLogAlertHook(l),
+		alertThreshold: 3,
+		svc:            svc,
+		execs:          make(map[string]Executor, 8),
+		l:              l,
+		dbTimeout:      3 * time.Second,
+		interval:       time.Second,
 		// 假如说最多只有 100 个在运行
 		limiter: semaphore.NewWeighted(100),
+		stopped: make(chan struct{}),
+		running: make(map[int64]CronJob, 8),
 	}
 }
 
+// RegisterJob 启动的时候注册任务，见 service.CronJobService 的 RegisterJob
 func (s *Scheduler) RegisterJob(ctx context.Context, j CronJob) error {
-	return s.svc.AddJob(ctx, j)
+	return s.svc.RegisterJob(ctx, j)
+}
+
+// SetAlertHook 替换默认的只打日志的告警
+func (s *Scheduler) SetAlertHook(hook AlertHook) {
+	s.alert = hook
 }
 
 func (s *Scheduler) RegisterExecutor(exec Executor) {
 	s.execs[exec.Name()] = exec
 }
 
+// CanExecute 管理后台创建、修改任务的时候用来校验执行方式。
+// 执行器要存在，并且能找到名字为 name 的任务的执行逻辑
+func (s *Scheduler) CanExecute(executor, name string) bool {
+	exec, ok := s.execs[executor]
+	if !ok {
+		return false
+	}
+	if c, ok := exec.(jobChecker); ok {
+		return c.HasJob(name)
+	}
+	return true
+}
+
+// Start 阻塞直到 ctx 被取消，只能调用一次。
+// ctx 被取消之后不再抢占新的任务，正在执行的任务继续执行，由 Close 来等待它们结束
 func (s *Scheduler) Start(ctx context.Context) error {
+	defer close(s.stopped)
 	for {
 		if ctx.Err() != nil {
 			// 已经超时了，或者被取消运行，大多数时候，都是被取消了，或者说关闭了
@@ -90,39 +150,177 @@ func (s *Scheduler) Start(ctx context.Context) error {
 		j, err := s.svc.Preempt(dbCtx)
 		cancel()
 		if err != nil {
+			s.limiter.Release(1)
 			// 你也可以进一步细分不同的错误，如果是可以容忍的错误，
 			// 没有抢占到，进入下一个循环
 			// 这里可以考虑睡眠一段时间
 			// 不然就直接 return
-			time.Sleep(s.interval)
+			select {
+			case <-ctx.Done():
+			case <-time.After(s.interval):
+			}
 			continue
 		}
 
 		// 执行job
 		exec, ok := s.execs[j.Executor]
 		if !ok {
-			s.l.Error("支持的 Executor 方式")
+			// 直接释放的话马上又会被抢占，所以先暂停，等人修改执行方式之后再恢复
+			s.l.Error("不支持的 Executor 方式，暂停任务", logger.Int("id", j.ID), logger.String("executor", j.Executor))
+			s.pause(j)
+			s.limiter.Release(1)
 			j.CancelFunc()
 			continue
 		}
+		j.Window = j.CurrentWindow()
+		s.track(j)
 		// 要单独开一个 goroutine 来执行，这样我们就可以进入下一个循环了
 		go func() {
 			defer func() {
+				s.untrack(j)
 				s.limiter.Release(1)
 				j.CancelFunc()
 			}()
 
-			if e := exec.Exec(ctx, j); e != nil {
-				s.l.Error("调度任务失败", logger.Int("id", j.ID), logger.Error(e))
+			execID := s.startExecution(j)
+			e := s.exec(s.execCtx, exec, j)
+			if e != nil && s.execCtx.Err() != nil {
+				// 调度器退出打断的，不算失败，不更新下次执行的时间和重试次数，
+				// 释放之后别的节点重新执行这个窗口
+				s.l.Warn("任务执行被中断", logger.Int("id", j.ID), logger.Error(e))
+				s.finishExecution(j, execID, fmt.Errorf("%w: %w", service.ErrJobInterrupted, e))
 				return
 			}
-			if e := s.svc.ResetNextTime(ctx, j); e != nil {
-				s.l.Error("更新下次执行失败", logger.Int("id", j.ID), logger.Error(e))
+			s.finishExecution(j, execID, e)
+			if e != nil {
+				s.l.Error("调度任务失败", logger.Int("id", j.ID), logger.Error(e))
 			}
+			s.complete(j, e)
 		}()
 	}
 }
 
+// Close 要在取消 Start 的 ctx 之后调用，等待正在执行的任务结束。
+// ctx 过期了任务还没有结束，就通知这些任务退出，并且释放它们，让别的节点可以重新抢占
+func (s *Scheduler) Close(ctx context.Context) error {
+	select {
+	case <-s.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return s.closeExecutors()
+	case <-ctx.Done():
+		s.execCancel()
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		for _, j := range s.running {
+			s.l.Warn("任务没有按时结束，释放任务", logger.Int("id", j.ID))
+			j.CancelFunc()
+		}
+		return ctx.Err()
+	}
+}
+
+// exec 任务配置了超时时间的，超时之后通过 ctx 通知 Executor 退出
+func (s *Scheduler) exec(ctx context.Context, exec Executor, j CronJob) error {
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	return exec.Exec(ctx, j)
+}
+
+// complete 不管成功失败都要更新下次执行的时间，不然任务会被马上再次调度或者卡住
+func (s *Scheduler) complete(j CronJob, execErr error) {
+	// ctx 可能已经被取消了，更新下次执行时间不能因此失败
+	ctx, cancel := context.WithTimeout(context.Background(), s.dbTimeout)
+	defer cancel()
+	j, err := s.svc.Complete(ctx, j, execErr)
+	if err != nil {
+		s.l.Error("更新下次执行失败", logger.Int("id", j.ID), logger.Error(err))
+	}
+	// 成功了，或者失败之后不会再重试了，这个窗口就结束了，结果要传递给下游
+	if execErr == nil || j.Retries == 0 {
+		if err = s.svc.TriggerDownstream(ctx, j, execErr == nil); err != nil {
+			s.l.Error("触发下游任务失败", logger.Int("id", j.ID), logger.Error(err))
+		}
+	}
+	if execErr == nil {
+		return
+	}
+	threshold := j.AlertThreshold
+	if threshold <= 0 {
+		threshold = s.alertThreshold
+	}
+	// 持续失败的时候，每失败 threshold 次告警一次，避免刷屏
+	if j.FailCount > 0 && j.FailCount%threshold == 0 {
+		s.alert.Alert(ctx, j, execErr)
+	}
+}
+
+func (s *Scheduler) pause(j CronJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.dbTimeout)
+	defer cancel()
+	if err := s.svc.Pause(ctx, j.ID); err != nil {
+		s.l.Error("暂停任务失败", logger.Int("id", j.ID), logger.Error(err))
+	}
+}
+
+// startExecution 执行记录写失败了不影响任务执行，返回 0
+func (s *Scheduler) startExecution(j CronJob) int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), s.dbTimeout)
+	defer cancel()
+	id, err := s.svc.StartExecution(ctx, j, s.node)
+	if err != nil {
+		s.l.Error("记录任务开始执行失败", logger.Int("id", j.ID), logger.Error(err))
+	}
+	return id
+}
+
+func (s *Scheduler) finishExecution(j CronJob, execID int64, execErr error) {
+	if execID == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.dbTimeout)
+	defer cancel()
+	if err := s.svc.FinishExecution(ctx, execID, execErr); err != nil {
+		s.l.Error("记录任务执行结果失败", logger.Int("id", j.ID), logger.Int("execution", execID), logger.Error(err))
+	}
+}
+
+// closeExecutors 关闭持有连接的 Executor，比如说 GRPCExecutor
+func (s *Scheduler) closeExecutors() error {
+	var err error
+	for _, exec := range s.execs {
+		if c, ok := exec.(io.Closer); ok {
+			err = errors.Join(err, c.Close())
+		}
+	}
+	return err
+}
+
+func (s *Scheduler) track(j CronJob) {
+	s.wg.Add(1)
+	s.lock.Lock()
+	s.running[j.ID] = j
+	s.lock.Unlock()
+}
+
+func (s *Scheduler) untrack(j CronJob) {
+	s.lock.Lock()
+	delete(s.running, j.ID)
+	s.lock.Unlock()
+	s.wg.Done()
+}
+
 // CronJob 使用别名来做一个解耦
 // 后续万一我们要加字段，就很方便扩展
 type CronJob = domain.CronJob
@@ -3,6 +3,8 @@ package job
 import (
 	"context"
 	"errors"
+	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"geektime-basic-go/webook/internal/integration/startup"
 	"geektime-basic-go/webook/internal/service"
 	svcmocks "geektime-basic-go/webook/internal/service/mocks"
+	"geektime-basic-go/webook/pkg/logger"
 )
 
 func TestScheduler_Start(t *testing.T) {
@@ -41,12 +44,32 @@ func TestScheduler_Start(t *testing.T) {
 					},
 				}, nil)
 				svc.EXPECT().Preempt(gomock.Any()).AnyTimes().Return(domain.CronJob{}, errors.New("db 错误"))
-				svc.EXPECT().ResetNextTime(gomock.Any(), gomock.Any()).Return(nil)
+				svc.EXPECT().StartExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(1), nil)
+				svc.EXPECT().FinishExecution(gomock.Any(), int64(1), nil).Return(nil)
+				svc.EXPECT().Complete(gomock.Any(), gomock.Any(), nil).Return(domain.CronJob{}, nil)
+				svc.EXPECT().TriggerDownstream(gomock.Any(), gomock.Any(), true).Return(nil)
 				return svc
 			},
 			wantErr: context.DeadlineExceeded,
 			wantJob: &testJob{cnt: 1},
 		},
+		{
+			name: "不支持的执行方式，暂停任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().Preempt(gomock.Any()).Return(domain.CronJob{
+					ID:         1,
+					Name:       "test_job",
+					Executor:   "unknown",
+					CancelFunc: func() {},
+				}, nil)
+				svc.EXPECT().Preempt(gomock.Any()).AnyTimes().Return(domain.CronJob{}, errors.New("db 错误"))
+				svc.EXPECT().Pause(gomock.Any(), int64(1)).Return(nil)
+				return svc
+			},
+			wantErr: context.DeadlineExceeded,
+			wantJob: &testJob{},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -69,6 +92,161 @@ func TestScheduler_Start(t *testing.T) {
 	}
 }
 
+func TestScheduler_Close(t *testing.T) {
+	testCases := []struct {
+		name string
+		// exec 任务执行的时间，ctx 被取消的时候提前返回
+		exec    time.Duration
+		timeout time.Duration
+
+		wantErr error
+		// wantInterrupted 关闭超时，任务被中断，不更新下次执行的时间
+		wantInterrupted bool
+	}{
+		{
+			name:    "等任务执行完",
+			exec:    50 * time.Millisecond,
+			timeout: time.Second,
+		},
+		{
+			name:            "关闭超时，中断任务",
+			exec:            time.Minute,
+			timeout:         50 * time.Millisecond,
+			wantErr:         context.DeadlineExceeded,
+			wantInterrupted: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			// 关闭超时的时候会提前释放，和 Preempt 返回的一样只有第一次生效
+			released := make(chan struct{})
+			var once sync.Once
+			svc := svcmocks.NewMockCronJobService(ctrl)
+			svc.EXPECT().Preempt(gomock.Any()).Return(domain.CronJob{
+				ID:         1,
+				Name:       "test_job",
+				Executor:   "local",
+				CancelFunc: func() { once.Do(func() { close(released) }) },
+			}, nil)
+			svc.EXPECT().Preempt(gomock.Any()).AnyTimes().Return(domain.CronJob{}, errors.New("db 错误"))
+			svc.EXPECT().StartExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(1), nil)
+			if tc.wantInterrupted {
+				svc.EXPECT().FinishExecution(gomock.Any(), int64(1), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, id int64, err error) error {
+						assert.ErrorIs(t, err, service.ErrJobInterrupted)
+						assert.ErrorIs(t, err, context.Canceled)
+						return nil
+					})
+				// 被中断不算失败，不更新下次执行的时间，也不让下游跳过这个窗口
+				svc.EXPECT().Complete(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				svc.EXPECT().TriggerDownstream(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			} else {
+				svc.EXPECT().FinishExecution(gomock.Any(), int64(1), nil).Return(nil)
+				svc.EXPECT().Complete(gomock.Any(), gomock.Any(), nil).Return(domain.CronJob{ID: 1}, nil)
+				svc.EXPECT().TriggerDownstream(gomock.Any(), gomock.Any(), true).Return(nil)
+			}
+
+			scheduler := NewScheduler(svc, logger.NewNoOpLogger())
+			started := make(chan struct{})
+			executor := NewLocalFuncExecutor()
+			executor.AddLocalFunc("test_job", func(ctx context.Context, j domain.CronJob) error {
+				close(started)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(tc.exec):
+					return nil
+				}
+			})
+			scheduler.RegisterExecutor(executor)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				_ = scheduler.Start(ctx)
+			}()
+			<-started
+			// 取消 Start 的 ctx 不会打断正在执行的任务
+			cancel()
+			closeCtx, closeCancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer closeCancel()
+			err := scheduler.Close(closeCtx)
+			assert.Equal(t, tc.wantErr, err)
+			select {
+			case <-released:
+			case <-time.After(time.Second):
+				t.Fatal("任务没有被释放")
+			}
+			// 等执行任务的 goroutine 记录完执行结果
+			scheduler.wg.Wait()
+		})
+	}
+}
+
+func TestScheduler_RegisterJob(t *testing.T) {
+	testCases := []struct {
+		name   string
+		regErr error
+
+		wantErr error
+	}{
+		{
+			name: "注册成功",
+		},
+		{
+			name:    "数据库错误",
+			regErr:  errors.New("db 错误"),
+			wantErr: errors.New("db 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := svcmocks.NewMockCronJobService(ctrl)
+			svc.EXPECT().RegisterJob(gomock.Any(), CronJob{Name: "test_job"}).Return(tc.regErr)
+			scheduler := NewScheduler(svc, logger.NewNoOpLogger())
+			err := scheduler.RegisterJob(context.Background(), CronJob{Name: "test_job"})
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestScheduler_CanExecute(t *testing.T) {
+	local := NewLocalFuncExecutor()
+	local.AddLocalFunc("local_job", func(ctx context.Context, j domain.CronJob) error {
+		return nil
+	})
+	remote := NewHTTPExecutor(http.DefaultClient)
+	remote.AddEndpoint("remote_job", HTTPEndpoint{URL: "http://localhost:8080/job"})
+	scheduler := NewScheduler(nil, logger.NewNoOpLogger())
+	scheduler.RegisterExecutor(local)
+	scheduler.RegisterExecutor(remote)
+
+	testCases := []struct {
+		name     string
+		executor string
+		job      string
+
+		want bool
+	}{
+		{name: "注册过的本地方法", executor: "local", job: "local_job", want: true},
+		{name: "没有注册的本地方法", executor: "local", job: "remote_job"},
+		{name: "配置了接口的 HTTP 任务", executor: "http", job: "remote_job", want: true},
+		{name: "没有配置接口的 HTTP 任务", executor: "http", job: "local_job"},
+		{name: "不支持的执行方式", executor: "grpc", job: "remote_job"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, scheduler.CanExecute(tc.executor, tc.job))
+		})
+	}
+}
+
 type testJob struct {
 	cnt int
 }
@@ -77,3 +255,73 @@ func (tj *testJob) Do(ctx context.Context, j domain.CronJob) error {
 	tj.cnt++
 	return nil
 }
+
+func TestScheduler_complete(t *testing.T) {
+	testCases := []struct {
+		name    string
+		job     domain.CronJob
+		execErr error
+
+		// wantTrigger 这个窗口结束了，要通知下游
+		wantTrigger bool
+		wantAlert   bool
+	}{
+		{
+			name:        "执行成功",
+			job:         domain.CronJob{ID: 1},
+			wantTrigger: true,
+		},
+		{
+			name:    "失败了还要重试",
+			job:     domain.CronJob{ID: 1, MaxRetries: 2, Retries: 1, FailCount: 1},
+			execErr: errors.New("执行失败"),
+		},
+		{
+			name:        "失败次数没有到默认阈值",
+			job:         domain.CronJob{ID: 1, FailCount: 2},
+			execErr:     errors.New("执行失败"),
+			wantTrigger: true,
+		},
+		{
+			name:        "失败次数到了默认阈值",
+			job:         domain.CronJob{ID: 1, FailCount: 3},
+			execErr:     errors.New("执行失败"),
+			wantTrigger: true,
+			wantAlert:   true,
+		},
+		{
+			name:        "任务自己配置的阈值",
+			job:         domain.CronJob{ID: 1, FailCount: 2, AlertThreshold: 2},
+			execErr:     errors.New("执行失败"),
+			wantTrigger: true,
+			wantAlert:   true,
+		},
+		{
+			name:        "告警之后继续失败不重复告警",
+			job:         domain.CronJob{ID: 1, FailCount: 4},
+			execErr:     errors.New("执行失败"),
+			wantTrigger: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := svcmocks.NewMockCronJobService(ctrl)
+			svc.EXPECT().Complete(gomock.Any(), gomock.Any(), tc.execErr).Return(tc.job, nil)
+			if tc.wantTrigger {
+				svc.EXPECT().TriggerDownstream(gomock.Any(), tc.job, tc.execErr == nil).Return(nil)
+			}
+			scheduler := NewScheduler(svc, logger.NewNoOpLogger())
+			alerted := false
+			scheduler.SetAlertHook(AlertFunc(func(ctx context.Context, j CronJob, err error) {
+				alerted = true
+				assert.Equal(t, tc.job.FailCount, j.FailCount)
+				assert.Equal(t, tc.execErr, err)
+			}))
+			scheduler.complete(tc.job, tc.execErr)
+			assert.Equal(t, tc.wantAlert, alerted)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/service"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// ScheduledPublishJob 定时发表文章
+// 通过 LocalFuncExecutor 注册到 Scheduler 上，每次调度都把到期的文章发表出去
+type ScheduledPublishJob struct {
+	svc       service.ArticleService
+	l         logger.Logger
+	batchSize int
+}
+
+func NewScheduledPublishJob(svc service.ArticleService, l logger.Logger) *ScheduledPublishJob {
+	return &ScheduledPublishJob{svc: svc, l: l, batchSize: 100}
+}
+
+func (s *ScheduledPublishJob) Name() string {
+	return "article_scheduled_publish"
+}
+
+// CronJob 注册到 Scheduler 的任务，每十秒检查一次
+func (s *ScheduledPublishJob) CronJob() CronJob {
+	return CronJob{
+		Name:       s.Name(),
+		Executor:   "local",
+		Expression: "@every 10s",
+	}
+}
+
+func (s *ScheduledPublishJob) Exec(ctx context.Context, j domain.CronJob) error {
+	cnt, err := s.svc.PublishScheduled(ctx, time.Now(), s.batchSize)
+	if cnt > 0 {
+		s.l.Info("定时发表文章", logger.Int("cnt", cnt))
+	}
+	return err
+}
@@ -10,10 +10,20 @@ import (
 	intr "geektime-basic-go/webook/api/proto/gen/interactive"
 	"geektime-basic-go/webook/internal/domain"
 	"geektime-basic-go/webook/internal/repository/cache"
+	"geektime-basic-go/webook/internal/repository/dao"
 	"geektime-basic-go/webook/internal/repository/dao/article"
+	"geektime-basic-go/webook/pkg/gormx"
 	"geektime-basic-go/webook/pkg/logger"
 )
 
+var (
+	// ErrRevisionNotFound 历史版本不存在，或者不是这个作者的
+	ErrRevisionNotFound = dao.ErrDataNotFound
+	// ErrPossibleIncorrectAuthor 文章不存在，或者不是这个作者的
+	ErrPossibleIncorrectAuthor = article.ErrPossibleIncorrectAuthor
+	ErrScheduleChanged         = article.ErrScheduleChanged
+)
+
 //go:generate mockgen -source=article.go -package=svcmocks -destination=mocks/article_mock_gen.go ArticleRepository
 type ArticleRepository interface {
 	Create(ctx context.Context, art domain.Article) (int64, error)
@@ -22,27 +32,57 @@ type ArticleRepository interface {
 	SyncStatus(ctx context.Context, uid, id int64, status domain.ArticleStatus) error
 	GetPublishedByID(ctx context.Context, id int64) (domain.Article, error)
 	GetById(ctx context.Context, id int64) (domain.Article, error)
-	List(ctx context.Context, author int64, offset int, limit int) ([]domain.Article, error)
-	ListPub(ctx context.Context, updateAt time.Time, offset int, limit int) ([]domain.Article, error)
+	List(ctx context.Context, author int64, cursor domain.ArticleCursor, limit int) ([]domain.Article, error)
+	ListPub(ctx context.Context, cursor domain.ArticleCursor, limit int) ([]domain.Article, error)
+	ListPubByTag(ctx context.Context, tag string, cursor domain.ArticleCursor, limit int) ([]domain.Article, error)
+	// ListPubByIDs 按照 ids 的顺序返回，没有发表的文章会被跳过
+	ListPubByIDs(ctx context.Context, ids []int64) ([]domain.Article, error)
+	SuggestTags(ctx context.Context, prefix string, limit int) ([]domain.Tag, error)
 	PubDetail(ctx context.Context, bizID int64, uid int64) (domain.Vo, error)
+	// GetInteractives 批量查询文章的互动数据，以及 uid 是否点赞、收藏
+	GetInteractives(ctx context.Context, ids []int64, uid int64) (map[int64]domain.Interactive, error)
 	Collect(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error
+	Uncollect(ctx context.Context, biz string, bizID int64, uid int64) error
 	Like(ctx context.Context, biz string, bizID int64, uid int64, like bool) error
+	ListRevisions(ctx context.Context, author, id int64, offset int, limit int) ([]domain.ArticleRevision, error)
+	GetRevision(ctx context.Context, author, id, version int64) (domain.ArticleRevision, error)
+	ListScheduled(ctx context.Context, publishAt time.Time, limit int) ([]domain.Article, error)
+	// PublishScheduled 发表 ListScheduled 查出来的文章，查出来之后文章被修改或者取消定时了就返回 ErrScheduleChanged
+	PublishScheduled(ctx context.Context, art domain.Article) error
+	CancelSchedule(ctx context.Context, uid, id int64) error
 }
 
 type cacheArticleRepository struct {
 	dao      article.DAO
+	revDAO   article.RevisionDAO
+	tagDAO   article.TagDAO
 	userRepo UserRepository
 	cache    cache.ArticleCache
 	rpc      intr.InteractiveServiceClient
+	tx       gormx.Transactor
 	l        logger.Logger
 }
 
-func NewCacheArticleRepository(dao article.DAO, userRepo UserRepository, cache cache.ArticleCache, rpc intr.InteractiveServiceClient, l logger.Logger) ArticleRepository {
-	return &cacheArticleRepository{dao: dao, userRepo: userRepo, cache: cache, rpc: rpc, l: l}
+func NewCacheArticleRepository(dao article.DAO, revDAO article.RevisionDAO, tagDAO article.TagDAO, userRepo UserRepository,
+	cache cache.ArticleCache, rpc intr.InteractiveServiceClient, tx gormx.Transactor, l logger.Logger) ArticleRepository {
+	return &cacheArticleRepository{dao: dao, revDAO: revDAO, tagDAO: tagDAO, userRepo: userRepo,
+		cache: cache, rpc: rpc, tx: tx, l: l}
 }
 
+// Create 文章、标签和历史版本在同一个事务里写入
 func (repo *cacheArticleRepository) Create(ctx context.Context, art domain.Article) (int64, error) {
-	id, err := repo.dao.Insert(ctx, repo.toEntity(art))
+	var id int64
+	err := repo.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if id, err = repo.dao.Insert(ctx, repo.toEntity(art)); err != nil {
+			return err
+		}
+		if err = repo.setTags(ctx, id, art.Tags); err != nil {
+			return err
+		}
+		art.ID = id
+		return repo.addRevision(ctx, art)
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -54,7 +94,16 @@ func (repo *cacheArticleRepository) Create(ctx context.Context, art domain.Artic
 }
 
 func (repo *cacheArticleRepository) Update(ctx context.Context, art domain.Article) error {
-	if err := repo.dao.UpdateById(ctx, repo.toEntity(art)); err != nil {
+	err := repo.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := repo.dao.UpdateById(ctx, repo.toEntity(art)); err != nil {
+			return err
+		}
+		if err := repo.setTags(ctx, art.ID, art.Tags); err != nil {
+			return err
+		}
+		return repo.addRevision(ctx, art)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -65,52 +114,208 @@ func (repo *cacheArticleRepository) Update(ctx context.Context, art domain.Artic
 }
 
 func (repo *cacheArticleRepository) Sync(ctx context.Context, art domain.Article) (int64, error) {
-	id, err := repo.dao.Sync(ctx, repo.toEntity(art))
+	return repo.sync(ctx, art, repo.dao.Sync)
+}
+
+func (repo *cacheArticleRepository) PublishScheduled(ctx context.Context, art domain.Article) error {
+	_, err := repo.sync(ctx, art, func(ctx context.Context, entity article.Article) (int64, error) {
+		// ListScheduled 查出来的 update_at 作为版本号
+		entity.UpdateAt = art.UpdateAt.UnixMilli()
+		return entity.ID, repo.dao.SyncScheduled(ctx, entity)
+	})
+	return err
+}
+
+// sync 线上库、标签和历史版本在同一个事务里写入，fn 负责写线上库
+func (repo *cacheArticleRepository) sync(ctx context.Context, art domain.Article,
+	fn func(ctx context.Context, entity article.Article) (int64, error)) (int64, error) {
+	var id int64
+	err := repo.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if id, err = fn(ctx, repo.toEntity(art)); err != nil {
+			return err
+		}
+		if err = repo.setTags(ctx, id, art.Tags); err != nil {
+			return err
+		}
+		// 线上的标签只在发表的时候从制作库复制过去，没有带上标签就发表制作库里原有的标签
+		if err = repo.tagDAO.SyncPubTags(ctx, id); err != nil {
+			return err
+		}
+		art.ID = id
+		return repo.addRevision(ctx, art)
+	})
 	if err != nil {
 		return 0, err
 	}
-	art.ID = id
-	go func() {
-		authorID := art.Author.ID
-		if e := repo.cache.DelFirstPage(ctx, authorID); e != nil {
-			repo.l.Error("删除缓存失败", logger.Int("author", art.Author.ID), logger.Error(e))
+	// 外面可能还有事务，例如和发表事件一起写 outbox，所以要等提交之后再更新缓存
+	gormx.AfterCommit(ctx, func(ctx context.Context) {
+		if art.Tags == nil {
+			// 定时发表之类的场景没有带上标签，缓存需要原有的标签
+			art.Tags = repo.getTags(ctx, id, repo.tagDAO.GetPubByArticles)
 		}
+		go func() {
+			authorID := art.Author.ID
+			if e := repo.cache.DelFirstPage(ctx, authorID); e != nil {
+				repo.l.Error("删除缓存失败", logger.Int("author", art.Author.ID), logger.Error(e))
+			}
 
-		user, e := repo.userRepo.FindByID(ctx, authorID)
-		if e != nil {
-			repo.l.Error("提前设置缓存准备用户信息失败", logger.Int("uid", authorID), logger.Error(e))
-		}
+			user, e := repo.userRepo.FindByID(ctx, authorID)
+			if e != nil {
+				repo.l.Error("提前设置缓存准备用户信息失败", logger.Int("uid", authorID), logger.Error(e))
+			}
 
-		art.ID = id
-		art.Author = domain.Author{ID: user.ID, Name: user.Nickname}
-		if err = repo.cache.SetPub(ctx, art); err != nil {
-			repo.l.Error("提前设置缓存失败", logger.Int("author", authorID), logger.Error(err))
-		}
-	}()
+			art.ID = id
+			art.Author = domain.Author{ID: user.ID, Name: user.Nickname}
+			if e = repo.cache.SetPub(ctx, art); e != nil {
+				repo.l.Error("提前设置缓存失败", logger.Int("author", authorID), logger.Error(e))
+			}
+		}()
+	})
 	return id, nil
 }
 
+// SyncStatus 搜索索引由撤回事件更新
 func (repo *cacheArticleRepository) SyncStatus(ctx context.Context, uid, id int64, status domain.ArticleStatus) error {
 	return repo.dao.SyncStatus(ctx, uid, id, status.ToUint8())
 }
 
+// setTags tags 为 nil 说明不修改标签，ctx 里面有事务就在事务里写
+func (repo *cacheArticleRepository) setTags(ctx context.Context, aid int64, tags []string) error {
+	if tags == nil {
+		return nil
+	}
+	return repo.tagDAO.SetArticleTags(ctx, aid, tags)
+}
+
+// getTags get 是 TagDAO 查询制作库或者线上库标签的方法
+func (repo *cacheArticleRepository) getTags(ctx context.Context, aid int64,
+	get func(ctx context.Context, aids []int64) (map[int64][]string, error)) []string {
+	tags, err := get(ctx, []int64{aid})
+	if err != nil {
+		repo.l.Error("查询文章标签失败", logger.Int("aid", aid), logger.Error(err))
+	}
+	return tags[aid]
+}
+
+// fillTags 批量查询标签，查询失败只影响展示，get 和 getTags 一样
+func (repo *cacheArticleRepository) fillTags(ctx context.Context, arts []domain.Article,
+	get func(ctx context.Context, aids []int64) (map[int64][]string, error)) {
+	if len(arts) == 0 {
+		return
+	}
+	aids := slice.Map(arts, func(idx int, src domain.Article) int64 {
+		return src.ID
+	})
+	tags, err := get(ctx, aids)
+	if err != nil {
+		repo.l.Error("批量查询文章标签失败", logger.Error(err))
+		return
+	}
+	for i := range arts {
+		arts[i].Tags = tags[arts[i].ID]
+	}
+}
+
+// addRevision 要在保存文章的事务里调用，记录历史版本失败整个保存都会回滚，保证每次保存都有对应的版本
+func (repo *cacheArticleRepository) addRevision(ctx context.Context, art domain.Article) error {
+	_, err := repo.revDAO.Insert(ctx, article.ArticleRevision{
+		ArticleID: art.ID,
+		AuthorID:  art.Author.ID,
+		Title:     art.Title,
+		Content:   art.Content,
+		Status:    art.Status.ToUint8(),
+	})
+	return err
+}
+
+func (repo *cacheArticleRepository) ListRevisions(ctx context.Context, author, id int64, offset int, limit int) ([]domain.ArticleRevision, error) {
+	revs, err := repo.revDAO.GetByArticle(ctx, author, id, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(revs, func(idx int, src article.ArticleRevision) domain.ArticleRevision {
+		return repo.revisionToDomain(src)
+	}), nil
+}
+
+func (repo *cacheArticleRepository) GetRevision(ctx context.Context, author, id, version int64) (domain.ArticleRevision, error) {
+	rev, err := repo.revDAO.GetByVersion(ctx, author, id, version)
+	if err != nil {
+		return domain.ArticleRevision{}, err
+	}
+	return repo.revisionToDomain(rev), nil
+}
+
+func (repo *cacheArticleRepository) revisionToDomain(rev article.ArticleRevision) domain.ArticleRevision {
+	return domain.ArticleRevision{
+		ArticleID: rev.ArticleID,
+		Version:   rev.Version,
+		Title:     rev.Title,
+		Content:   rev.Content,
+		Status:    domain.ArticleStatus(rev.Status),
+		Author:    domain.Author{ID: rev.AuthorID},
+		CreateAt:  time.UnixMilli(rev.CreateAt),
+	}
+}
+
+func (repo *cacheArticleRepository) ListScheduled(ctx context.Context, publishAt time.Time, limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.ListScheduled(ctx, publishAt, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(arts, func(idx int, src article.Article) domain.Article {
+		return repo.toDomain(src)
+	}), nil
+}
+
+func (repo *cacheArticleRepository) CancelSchedule(ctx context.Context, uid, id int64) error {
+	if err := repo.dao.CancelSchedule(ctx, uid, id); err != nil {
+		return err
+	}
+
+	if err := repo.cache.DelFirstPage(ctx, uid); err != nil {
+		repo.l.Error("删除缓存失败", logger.Int("author", uid), logger.Error(err))
+	}
+	return nil
+}
+
+func (repo *cacheArticleRepository) toCursorEntity(cursor domain.ArticleCursor) article.Cursor {
+	if cursor.IsZero() {
+		return article.Cursor{}
+	}
+	return article.Cursor{UpdateAt: cursor.UpdateAt.UnixMilli(), ID: cursor.ID}
+}
+
 func (repo *cacheArticleRepository) toEntity(art domain.Article) article.Article {
+	var publishAt int64
+	if !art.PublishAt.IsZero() {
+		publishAt = art.PublishAt.UnixMilli()
+	}
 	return article.Article{
-		ID:       art.ID,
-		Title:    art.Title,
-		Content:  art.Content,
-		AuthorID: art.Author.ID,
-		Status:   art.Status.ToUint8(),
+		ID:        art.ID,
+		Title:     art.Title,
+		Content:   art.Content,
+		AuthorID:  art.Author.ID,
+		Status:    art.Status.ToUint8(),
+		PublishAt: publishAt,
 	}
 }
 
 func (repo *cacheArticleRepository) toDomain(art article.Article) domain.Article {
+	var publishAt time.Time
+	if art.PublishAt > 0 {
+		publishAt = time.UnixMilli(art.PublishAt)
+	}
 	return domain.Article{
-		ID:      art.ID,
-		Title:   art.Title,
-		Status:  domain.ArticleStatus(art.Status),
-		Content: art.Content,
-		Author:  domain.Author{ID: art.AuthorID},
+		ID:        art.ID,
+		Title:     art.Title,
+		Status:    domain.ArticleStatus(art.Status),
+		Content:   art.Content,
+		Author:    domain.Author{ID: art.AuthorID},
+		CreateAt:  time.UnixMilli(art.CreateAt),
+		UpdateAt:  time.UnixMilli(art.UpdateAt),
+		PublishAt: publishAt,
 	}
 }
 
@@ -133,6 +338,7 @@ func (repo *cacheArticleRepository) GetPublishedByID(ctx context.Context, id int
 		Status:  domain.ArticleStatus(art.Status),
 		Content: art.Content,
 		Author:  domain.Author{ID: user.ID, Name: user.Nickname},
+		Tags:    repo.getTags(ctx, art.ID, repo.tagDAO.GetPubByArticles),
 	}
 
 	go func() {
@@ -152,28 +358,35 @@ func (repo *cacheArticleRepository) GetById(ctx context.Context, id int64) (doma
 	if err != nil {
 		return domain.Article{}, err
 	}
-	return repo.toDomain(art), nil
+	res := repo.toDomain(art)
+	res.Tags = repo.getTags(ctx, id, repo.tagDAO.GetByArticles)
+	return res, nil
 }
 
-func (repo *cacheArticleRepository) List(ctx context.Context, author int64, offset int, limit int) ([]domain.Article, error) {
-	if offset == 0 && limit == 100 {
+func (repo *cacheArticleRepository) List(ctx context.Context, author int64, cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+	// 只缓存第一页
+	firstPage := cursor.IsZero() && limit == 100
+	if firstPage {
 		data, err := repo.cache.GetFirstPage(ctx, author)
-		if err != nil {
-			repo.l.Error("查询缓存文章失败", logger.Int("author", author), logger.Error(err))
+		if err == nil {
+			go func() { repo.preCache(ctx, data) }()
+			return data, nil
 		}
-
-		go func() { repo.preCache(ctx, data) }()
-		return data, nil
+		repo.l.Error("查询缓存文章失败", logger.Int("author", author), logger.Error(err))
 	}
 
-	arts, err := repo.dao.GetByAuthor(ctx, author, offset, limit)
+	arts, err := repo.dao.GetByAuthor(ctx, author, repo.toCursorEntity(cursor), limit)
 	if err != nil {
 		return nil, err
 	}
 	res := slice.Map[article.Article, domain.Article](arts, func(idx int, src article.Article) domain.Article {
 		return repo.toDomain(arts[idx])
 	})
+	repo.fillTags(ctx, res, repo.tagDAO.GetByArticles)
 	go func() { repo.preCache(ctx, res) }()
+	if !firstPage {
+		return res, nil
+	}
 	if err = repo.cache.SetFirstPage(ctx, author, res); err != nil {
 		repo.l.Error("刷新第一页文章的缓存失败", logger.Int("author", author), logger.Error(err))
 	}
@@ -189,13 +402,58 @@ func (repo *cacheArticleRepository) preCache(ctx context.Context, arts []domain.
 	}
 }
 
-func (repo *cacheArticleRepository) ListPub(ctx context.Context, updateAt time.Time, offset int, limit int) ([]domain.Article, error) {
-	val, err := repo.dao.ListPubByCreateAt(ctx, updateAt, offset, limit)
+func (repo *cacheArticleRepository) ListPub(ctx context.Context, cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+	val, err := repo.dao.ListPub(ctx, repo.toCursorEntity(cursor), limit)
+	if err != nil {
+		return nil, err
+	}
+	return repo.pubToDomain(ctx, val), nil
+}
+
+func (repo *cacheArticleRepository) ListPubByTag(ctx context.Context, tag string, cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+	val, err := repo.dao.ListPubByTag(ctx, tag, repo.toCursorEntity(cursor), limit)
 	if err != nil {
 		return nil, err
 	}
-	return slice.Map(val, func(idx int, src article.PublishedArticle) domain.Article {
+	return repo.pubToDomain(ctx, val), nil
+}
+
+func (repo *cacheArticleRepository) ListPubByIDs(ctx context.Context, ids []int64) ([]domain.Article, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	val, err := repo.dao.ListPubByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	arts := make(map[int64]domain.Article, len(val))
+	for _, art := range repo.pubToDomain(ctx, val) {
+		arts[art.ID] = art
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, id := range ids {
+		if art, ok := arts[id]; ok {
+			res = append(res, art)
+		}
+	}
+	return res, nil
+}
+
+func (repo *cacheArticleRepository) pubToDomain(ctx context.Context, pubs []article.PublishedArticle) []domain.Article {
+	res := slice.Map(pubs, func(idx int, src article.PublishedArticle) domain.Article {
 		return repo.toDomain(article.Article(src))
+	})
+	repo.fillTags(ctx, res, repo.tagDAO.GetPubByArticles)
+	return res
+}
+
+func (repo *cacheArticleRepository) SuggestTags(ctx context.Context, prefix string, limit int) ([]domain.Tag, error) {
+	tags, err := repo.tagDAO.SearchByPrefix(ctx, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(tags, func(idx int, src article.Tag) domain.Tag {
+		return domain.Tag{Name: src.Name, ArticleCnt: src.ArticleCnt}
 	}), nil
 }
 
@@ -211,7 +469,7 @@ func (repo *cacheArticleRepository) PubDetail(ctx context.Context, bizID int64,
 		return
 	})
 	eg.Go(func() (err error) {
-		interResp, err = repo.rpc.Get(ctx, &intr.GetRequest{Biz: "interactive", BizId: bizID, Uid: uid})
+		interResp, err = repo.rpc.Get(ctx, &intr.GetRequest{Biz: "article", BizId: bizID, Uid: uid})
 		return
 	})
 	if err := eg.Wait(); err != nil {
@@ -224,16 +482,41 @@ func (repo *cacheArticleRepository) PubDetail(ctx context.Context, bizID int64,
 		Status:     art.Status.ToUint8(),
 		Content:    art.Content,
 		Author:     art.Author.Name,
+		Tags:       art.Tags,
 		CreateAt:   art.CreateAt.Format(time.DateTime),
 		UpdateAt:   art.UpdateAt.Format(time.DateTime),
 		ReadCnt:    interResp.Intr.ReadCnt,
 		CollectCnt: interResp.Intr.CollectCnt,
 		LikeCnt:    interResp.Intr.LikeCnt,
+		CommentCnt: interResp.Intr.CommentCnt,
 		Liked:      interResp.Intr.Liked,
 		Collected:  interResp.Intr.Collected,
 	}, nil
 }
 
+func (repo *cacheArticleRepository) GetInteractives(ctx context.Context, ids []int64, uid int64) (map[int64]domain.Interactive, error) {
+	res := make(map[int64]domain.Interactive, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	resp, err := repo.rpc.GetByIDsForUser(ctx, &intr.GetByIDsForUserRequest{Biz: "article", Ids: ids, Uid: uid})
+	if err != nil {
+		return nil, err
+	}
+	for id, val := range resp.GetIntrs() {
+		res[id] = domain.Interactive{
+			BizID:      val.GetBizId(),
+			ReadCnt:    val.GetReadCnt(),
+			LikeCnt:    val.GetLikeCnt(),
+			CollectCnt: val.GetCollectCnt(),
+			CommentCnt: val.GetCommentCnt(),
+			Liked:      val.GetLiked(),
+			Collected:  val.GetCollected(),
+		}
+	}
+	return res, nil
+}
+
 func (repo *cacheArticleRepository) Like(ctx context.Context, biz string, bizID int64, uid int64, like bool) error {
 	_, err := repo.rpc.Like(ctx, &intr.LikeRequest{Biz: biz, BizId: bizID, Uid: uid, Liked: like})
 	return err
@@ -243,3 +526,8 @@ func (repo *cacheArticleRepository) Collect(ctx context.Context, biz string, biz
 	_, err := repo.rpc.Collect(ctx, &intr.CollectRequest{Biz: biz, BizId: bizID, Cid: cid, Uid: uid})
 	return err
 }
+
+func (repo *cacheArticleRepository) Uncollect(ctx context.Context, biz string, bizID int64, uid int64) error {
+	_, err := repo.rpc.Uncollect(ctx, &intr.UncollectRequest{Biz: biz, BizId: bizID, Uid: uid})
+	return err
+}
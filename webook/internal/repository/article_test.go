@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/repository/cache"
+	"geektime-basic-go/webook/internal/repository/dao/article"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// TestCacheArticleRepository_PubTags 草稿的标签只在发表的时候复制到线上库
+func TestCacheArticleRepository_PubTags(t *testing.T) {
+	testCases := []struct {
+		name string
+		do   func(t *testing.T, repo ArticleRepository)
+
+		wantPubTags []string
+	}{
+		{
+			name: "保存草稿不发表标签",
+			do: func(t *testing.T, repo ArticleRepository) {
+				_, err := repo.Create(context.Background(), domain.Article{Title: "草稿", Tags: []string{"go"}})
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "发表的时候复制标签",
+			do: func(t *testing.T, repo ArticleRepository) {
+				id, err := repo.Create(context.Background(), domain.Article{Title: "草稿", Tags: []string{"go"}})
+				require.NoError(t, err)
+				_, err = repo.Sync(context.Background(), domain.Article{ID: id, Tags: []string{"go", "mysql"}})
+				require.NoError(t, err)
+			},
+			wantPubTags: []string{"go", "mysql"},
+		},
+		{
+			name: "发表之前修改过标签",
+			do: func(t *testing.T, repo ArticleRepository) {
+				id, err := repo.Create(context.Background(), domain.Article{Title: "草稿", Tags: []string{"go"}})
+				require.NoError(t, err)
+				require.NoError(t, repo.Update(context.Background(), domain.Article{ID: id, Tags: []string{"redis"}}))
+				// 没有带上标签，发表制作库里的标签
+				_, err = repo.Sync(context.Background(), domain.Article{ID: id})
+				require.NoError(t, err)
+			},
+			wantPubTags: []string{"redis"},
+		},
+		{
+			name: "定时发表",
+			do: func(t *testing.T, repo ArticleRepository) {
+				id, err := repo.Create(context.Background(), domain.Article{Title: "草稿", Tags: []string{"go"}})
+				require.NoError(t, err)
+				require.NoError(t, repo.PublishScheduled(context.Background(), domain.Article{ID: id}))
+			},
+			wantPubTags: []string{"go"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tagDAO := &fakeTagDAO{draft: map[int64][]string{}, pub: map[int64][]string{}}
+			repo := NewCacheArticleRepository(fakeArticleDAO{}, fakeRevisionDAO{}, tagDAO, fakeUserRepo{},
+				fakeArticleCache{}, nil, fakeTransactor{}, logger.NewNoOpLogger())
+			tc.do(t, repo)
+
+			tags, err := tagDAO.GetPubByArticles(context.Background(), []int64{1})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantPubTags, tags[1])
+		})
+	}
+}
+
+// fakeTagDAO 只记录制作库和线上库的标签
+type fakeTagDAO struct {
+	article.TagDAO
+	draft map[int64][]string
+	pub   map[int64][]string
+}
+
+func (f *fakeTagDAO) SetArticleTags(ctx context.Context, aid int64, tags []string) error {
+	f.draft[aid] = tags
+	return nil
+}
+
+func (f *fakeTagDAO) SyncPubTags(ctx context.Context, aid int64) error {
+	f.pub[aid] = f.draft[aid]
+	return nil
+}
+
+func (f *fakeTagDAO) GetPubByArticles(ctx context.Context, aids []int64) (map[int64][]string, error) {
+	res := make(map[int64][]string, len(aids))
+	for _, aid := range aids {
+		if tags, ok := f.pub[aid]; ok {
+			res[aid] = tags
+		}
+	}
+	return res, nil
+}
+
+// fakeArticleDAO 只有一篇 ID 为 1 的文章
+type fakeArticleDAO struct {
+	article.DAO
+}
+
+func (fakeArticleDAO) Insert(ctx context.Context, art article.Article) (int64, error) {
+	return 1, nil
+}
+
+func (fakeArticleDAO) UpdateById(ctx context.Context, art article.Article) error {
+	return nil
+}
+
+func (fakeArticleDAO) Sync(ctx context.Context, art article.Article) (int64, error) {
+	return art.ID, nil
+}
+
+func (fakeArticleDAO) SyncScheduled(ctx context.Context, art article.Article) error {
+	return nil
+}
+
+type fakeRevisionDAO struct {
+	article.RevisionDAO
+}
+
+func (fakeRevisionDAO) Insert(ctx context.Context, rev article.ArticleRevision) (int64, error) {
+	return 1, nil
+}
+
+type fakeUserRepo struct {
+	UserRepository
+}
+
+func (fakeUserRepo) FindByID(ctx context.Context, id int64) (domain.User, error) {
+	return domain.User{ID: id}, nil
+}
+
+type fakeArticleCache struct {
+	cache.ArticleCache
+}
+
+func (fakeArticleCache) DelFirstPage(ctx context.Context, author int64) error {
+	return nil
+}
+
+func (fakeArticleCache) SetPub(ctx context.Context, art domain.Article) error {
+	return nil
+}
+
+// fakeTransactor 不开启事务，直接执行
+type fakeTransactor struct{}
+
+func (fakeTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -3,41 +3,50 @@ package memory
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
-	"github.com/ecodeclub/ekit/syncx/atomicx"
-
 	"geektime-basic-go/webook/internal/domain"
 )
 
+// RankingCache 每个榜单一份
 type RankingCache struct {
-	topN       *atomicx.Value[[]domain.Article]
-	ddl        *atomicx.Value[time.Time]
+	lock       sync.RWMutex
+	boards     map[string]rankingEntry
 	expiration time.Duration
 }
 
+type rankingEntry struct {
+	topN []domain.Article
+	ddl  time.Time
+}
+
 func NewRankingCache() *RankingCache {
 	return &RankingCache{
-		topN:       atomicx.NewValue[[]domain.Article](),
-		ddl:        atomicx.NewValueOf(time.Now()),
+		boards:     make(map[string]rankingEntry),
 		expiration: 3 * time.Minute,
 	}
 }
 
-func (r *RankingCache) Set(ctx context.Context, arts []domain.Article) error {
-	r.ddl.Store(time.Now().Add(3 * time.Minute))
-	r.topN.Store(arts)
+func (r *RankingCache) Set(ctx context.Context, board string, arts []domain.Article) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.boards[board] = rankingEntry{topN: arts, ddl: time.Now().Add(r.expiration)}
 	return nil
 }
 
-func (r *RankingCache) Get(context.Context) ([]domain.Article, error) {
-	arts := r.topN.Load()
-	if len(arts) == 0 || r.ddl.Load().Before(time.Now()) {
+func (r *RankingCache) Get(ctx context.Context, board string) ([]domain.Article, error) {
+	r.lock.RLock()
+	entry := r.boards[board]
+	r.lock.RUnlock()
+	if len(entry.topN) == 0 || entry.ddl.Before(time.Now()) {
 		return nil, errors.New("本地缓存失效了")
 	}
-	return arts, nil
+	return entry.topN, nil
 }
 
-func (r *RankingCache) ForceGet(context.Context) ([]domain.Article, error) {
-	return r.topN.Load(), nil
+func (r *RankingCache) ForceGet(ctx context.Context, board string) ([]domain.Article, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.boards[board].topN, nil
 }
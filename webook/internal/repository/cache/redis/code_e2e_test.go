@@ -5,15 +5,15 @@ import (
 	"testing"
 	"time"
 
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"geektime-basic-go/webook/internal/repository/cache"
-	"geektime-basic-go/webook/ioc"
 )
 
 func TestCodeCache_Set_e2e(t *testing.T) {
-	rdb := ioc.InitRedis()
+	rdb := initRedis()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -106,7 +106,7 @@ func TestCodeCache_Set_e2e(t *testing.T) {
 }
 
 func TestCodeCache_Verify_e2e(t *testing.T) {
-	rdb := ioc.InitRedis()
+	rdb := initRedis()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	require.NoError(t, rdb.Ping(ctx).Err())
@@ -224,3 +224,8 @@ func TestCodeCache_Verify_e2e(t *testing.T) {
 		})
 	}
 }
+
+// initRedis 不能用 ioc.InitRedis，ioc 依赖了 repository，repository 又依赖了这个包
+func initRedis() goredis.Cmdable {
+	return goredis.NewClient(&goredis.Options{Addr: "localhost:6379"})
+}
@@ -3,6 +3,8 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,16 +13,16 @@ import (
 )
 
 type RankingCache struct {
-	client     redis.Cmdable
-	key        string
-	expiration time.Duration
+	client         redis.Cmdable
+	expiration     time.Duration
+	liveExpiration time.Duration
 }
 
 func NewRankingCache(client redis.Cmdable) *RankingCache {
-	return &RankingCache{client: client, key: "ranking:article", expiration: 3 * time.Minute}
+	return &RankingCache{client: client, expiration: 3 * time.Minute, liveExpiration: 10 * time.Second}
 }
 
-func (r *RankingCache) Set(ctx context.Context, arts []domain.Article) error {
+func (r *RankingCache) Set(ctx context.Context, board string, arts []domain.Article) error {
 	for i := 0; i < len(arts); i++ {
 		arts[i].Content = arts[i].Abstract()
 	}
@@ -28,11 +30,11 @@ func (r *RankingCache) Set(ctx context.Context, arts []domain.Article) error {
 	if err != nil {
 		return err
 	}
-	return r.client.Set(ctx, r.key, val, r.expiration).Err()
+	return r.client.Set(ctx, r.key(board), val, r.expiration).Err()
 }
 
-func (r *RankingCache) Get(ctx context.Context) ([]domain.Article, error) {
-	val, err := r.client.Get(ctx, r.key).Bytes()
+func (r *RankingCache) Get(ctx context.Context, board string) ([]domain.Article, error) {
+	val, err := r.client.Get(ctx, r.key(board)).Bytes()
 	if err != nil {
 		return nil, err
 	}
@@ -40,3 +42,88 @@ func (r *RankingCache) Get(ctx context.Context) ([]domain.Article, error) {
 	err = json.Unmarshal(val, &res)
 	return res, err
 }
+
+func (r *RankingCache) key(board string) string {
+	return fmt.Sprintf("ranking:%s", board)
+}
+
+// IncrLiveScores 增量模式下每个桶一个 ZSET，从 bucket 开始过了 lifetime 之后桶自然就不在榜单的时间窗口里了
+func (r *RankingCache) IncrLiveScores(ctx context.Context, board string, bucket time.Time,
+	scores map[int64]float64, lifetime time.Duration) error {
+	key := r.bucketKey(board, bucket)
+	pipe := r.client.TxPipeline()
+	for id, score := range scores {
+		pipe.ZIncrBy(ctx, key, score, strconv.FormatInt(id, 10))
+	}
+	pipe.ExpireAt(ctx, key, bucket.Add(lifetime))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// LiveTopN 把各个桶按照 weights 加权合并之后取前 n 个，分数小于等于 0 的不要。
+// 合并的结果缓存 liveExpiration，在这段时间内的互动不会马上体现在榜单上
+func (r *RankingCache) LiveTopN(ctx context.Context, board string, buckets []time.Time,
+	weights []float64, n int) ([]int64, error) {
+	dst := r.liveKey(board)
+	cnt, err := r.client.Exists(ctx, dst).Result()
+	if err != nil {
+		return nil, err
+	}
+	if cnt == 0 {
+		keys := make([]string, 0, len(buckets))
+		for _, b := range buckets {
+			keys = append(keys, r.bucketKey(board, b))
+		}
+		pipe := r.client.TxPipeline()
+		pipe.ZUnionStore(ctx, dst, &redis.ZStore{Keys: keys, Weights: weights, Aggregate: "SUM"})
+		pipe.Expire(ctx, dst, r.liveExpiration)
+		if _, err = pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+	members, err := r.client.ZRevRangeByScore(ctx, dst, &redis.ZRangeBy{
+		Max: "+inf", Min: "(0", Count: int64(n),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+// ResetLive 用 scores 覆盖各个桶，scores 里没有的桶直接删掉，最后删掉合并的结果
+func (r *RankingCache) ResetLive(ctx context.Context, board string, buckets []time.Time,
+	scores map[time.Time]map[int64]float64, lifetime time.Duration) error {
+	for _, b := range buckets {
+		key := r.bucketKey(board, b)
+		pipe := r.client.TxPipeline()
+		pipe.Del(ctx, key)
+		if vals := scores[b]; len(vals) > 0 {
+			members := make([]redis.Z, 0, len(vals))
+			for id, score := range vals {
+				members = append(members, redis.Z{Score: score, Member: strconv.FormatInt(id, 10)})
+			}
+			pipe.ZAdd(ctx, key, members...)
+			pipe.ExpireAt(ctx, key, b.Add(lifetime))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return r.client.Del(ctx, r.liveKey(board)).Err()
+}
+
+func (r *RankingCache) bucketKey(board string, bucket time.Time) string {
+	return fmt.Sprintf("ranking:live:%s:%d", board, bucket.Unix())
+}
+
+func (r *RankingCache) liveKey(board string) string {
+	return fmt.Sprintf("ranking:live:%s", board)
+}
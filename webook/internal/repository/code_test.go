@@ -10,7 +10,7 @@ import (
 	"go.uber.org/mock/gomock"
 
 	"geektime-basic-go/webook/internal/repository/cache"
-	"geektime-basic-go/webook/internal/repository/cache/mocks"
+	mocks "geektime-basic-go/webook/internal/repository/cache/mocks"
 )
 
 func Test_cachedCodeRepository_Store(t *testing.T) {
@@ -4,32 +4,99 @@ import (
 	"context"
 	"time"
 
+	"github.com/ecodeclub/ekit/slice"
+
 	"geektime-basic-go/webook/internal/domain"
 	"geektime-basic-go/webook/internal/repository/dao"
 )
 
+var (
+	ErrCronJobNotFound       = dao.ErrDataNotFound
+	ErrCronJobStatusConflict = dao.ErrJobStatusConflict
+	ErrCronJobNameConflict   = dao.ErrJobNameConflict
+)
+
+//go:generate mockgen -source=cron_job.go -package=svcmocks -destination=mocks/cron_job_mock_gen.go CronJobRepository
 type CronJobRepository interface {
 	Preempt(ctx context.Context) (domain.CronJob, error)
 	Release(ctx context.Context, id int64) error
 	UpdateUpdateTime(ctx context.Context, id int64) error
+	// AddJob 同名的任务已经存在的时候返回 ErrCronJobNameConflict
 	AddJob(ctx context.Context, j domain.CronJob) error
-	UpdateNextTime(ctx context.Context, id int64, nextTime time.Time) error
+	// Complete 保存 j 的 NextTime、Retries、FailCount 和 Window
+	Complete(ctx context.Context, j domain.CronJob) error
+	Trigger(ctx context.Context, id int64, window time.Time) error
+	Skip(ctx context.Context, id int64, window time.Time) error
+	// GetByNames 不存在的任务忽略
+	GetByNames(ctx context.Context, names []string) ([]domain.CronJob, error)
+	// FindDownstreams 直接依赖这些任务的下游任务
+	FindDownstreams(ctx context.Context, names []string) ([]domain.CronJob, error)
+	GetByID(ctx context.Context, id int64) (domain.CronJob, error)
+	List(ctx context.Context, offset, limit int) ([]domain.CronJob, error)
+	Update(ctx context.Context, j domain.CronJob) error
+	Pause(ctx context.Context, id int64) error
+	Resume(ctx context.Context, id int64, nextTime time.Time) error
+	Delete(ctx context.Context, id int64) error
+	// Restore 按照 j 的配置和依赖恢复被删除的任务
+	Restore(ctx context.Context, j domain.CronJob) error
+	RunNow(ctx context.Context, id int64) error
+
+	// AddExecution 记录一次执行，返回执行记录的 ID
+	AddExecution(ctx context.Context, e domain.JobExecution) (int64, error)
+	FinishExecution(ctx context.Context, id int64, status domain.JobExecutionStatus, errMsg string) error
+	ListExecutions(ctx context.Context, jobID int64, offset, limit int) ([]domain.JobExecution, error)
+	ListExecutionsByWindow(ctx context.Context, jobIDs []int64, window time.Time) ([]domain.JobExecution, error)
 }
 
 type preemptCronJobRepository struct {
-	dao dao.CronJobDAO
+	dao     dao.CronJobDAO
+	execDAO dao.JobExecutionDAO
+	depDAO  dao.JobDependencyDAO
 }
 
-func NewPreemptCronJobRepository(dao dao.CronJobDAO) CronJobRepository {
-	return &preemptCronJobRepository{dao: dao}
+func NewPreemptCronJobRepository(dao dao.CronJobDAO, execDAO dao.JobExecutionDAO, depDAO dao.JobDependencyDAO) CronJobRepository {
+	return &preemptCronJobRepository{dao: dao, execDAO: execDAO, depDAO: depDAO}
 }
 
+// AddJob 同名的任务已经存在的时候，不会动它的依赖
 func (repo *preemptCronJobRepository) AddJob(ctx context.Context, j domain.CronJob) error {
-	return repo.dao.Insert(ctx, repo.toEntity(j))
+	if err := repo.dao.Insert(ctx, repo.toEntity(j)); err != nil {
+		return err
+	}
+	return repo.depDAO.Insert(ctx, j.Name, j.Upstreams)
+}
+
+func (repo *preemptCronJobRepository) Complete(ctx context.Context, j domain.CronJob) error {
+	return repo.dao.Complete(ctx, j.ID, j.NextTime, j.Retries, j.FailCount, j.Window)
+}
+
+func (repo *preemptCronJobRepository) Trigger(ctx context.Context, id int64, window time.Time) error {
+	return repo.dao.Trigger(ctx, id, window)
 }
 
-func (repo *preemptCronJobRepository) UpdateNextTime(ctx context.Context, id int64, nextTime time.Time) error {
-	return repo.dao.UpdateNextTime(ctx, id, nextTime)
+func (repo *preemptCronJobRepository) Skip(ctx context.Context, id int64, window time.Time) error {
+	return repo.dao.Skip(ctx, id, window)
+}
+
+func (repo *preemptCronJobRepository) GetByNames(ctx context.Context, names []string) ([]domain.CronJob, error) {
+	if len(names) == 0 {
+		return []domain.CronJob{}, nil
+	}
+	jobs, err := repo.dao.GetByNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	return repo.toDomains(ctx, jobs)
+}
+
+func (repo *preemptCronJobRepository) FindDownstreams(ctx context.Context, names []string) ([]domain.CronJob, error) {
+	deps, err := repo.depDAO.FindDownstreams(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetByNames(ctx, slice.Map(deps, func(idx int, src dao.JobDependency) string {
+		return src.Job
+	}))
 }
 
 func (repo *preemptCronJobRepository) Release(ctx context.Context, id int64) error {
@@ -43,9 +110,147 @@ func (repo *preemptCronJobRepository) UpdateUpdateTime(ctx context.Context, id i
 func (repo *preemptCronJobRepository) Preempt(ctx context.Context) (domain.CronJob, error) {
 	j, err := repo.dao.Preempt(ctx)
 	if err != nil {
-		return domain.CronJob{}, nil
+		return domain.CronJob{}, err
+	}
+	return repo.withUpstreams(ctx, j)
+}
+
+func (repo *preemptCronJobRepository) GetByID(ctx context.Context, id int64) (domain.CronJob, error) {
+	j, err := repo.dao.GetByID(ctx, id)
+	if err != nil {
+		return domain.CronJob{}, err
+	}
+	return repo.withUpstreams(ctx, j)
+}
+
+func (repo *preemptCronJobRepository) withUpstreams(ctx context.Context, j dao.Job) (domain.CronJob, error) {
+	res, err := repo.toDomains(ctx, []dao.Job{j})
+	if err != nil {
+		return domain.CronJob{}, err
+	}
+	return res[0], nil
+}
+
+// toDomains 顺便查询这些任务的上游
+func (repo *preemptCronJobRepository) toDomains(ctx context.Context, jobs []dao.Job) ([]domain.CronJob, error) {
+	if len(jobs) == 0 {
+		return []domain.CronJob{}, nil
+	}
+	deps, err := repo.depDAO.FindUpstreams(ctx, slice.Map(jobs, func(idx int, src dao.Job) string {
+		return src.Name
+	}))
+	if err != nil {
+		return nil, err
+	}
+	upstreams := make(map[string][]string, len(jobs))
+	for _, d := range deps {
+		upstreams[d.Job] = append(upstreams[d.Job], d.Upstream)
+	}
+	return slice.Map(jobs, func(idx int, src dao.Job) domain.CronJob {
+		j := repo.toDomain(src)
+		j.Upstreams = upstreams[src.Name]
+		return j
+	}), nil
+}
+
+func (repo *preemptCronJobRepository) List(ctx context.Context, offset, limit int) ([]domain.CronJob, error) {
+	jobs, err := repo.dao.List(ctx, offset, limit)
+	if err != nil {
+		return nil, err
 	}
-	return repo.toDomain(j), nil
+	return repo.toDomains(ctx, jobs)
+}
+
+// Update 任务和依赖分两步更新，依赖更新失败了重新提交一次就可以
+func (repo *preemptCronJobRepository) Update(ctx context.Context, j domain.CronJob) error {
+	if err := repo.dao.Update(ctx, repo.toEntity(j)); err != nil {
+		return err
+	}
+	return repo.depDAO.Replace(ctx, j.Name, j.Upstreams)
+}
+
+func (repo *preemptCronJobRepository) Pause(ctx context.Context, id int64) error {
+	return repo.dao.Pause(ctx, id)
+}
+
+func (repo *preemptCronJobRepository) Resume(ctx context.Context, id int64, nextTime time.Time) error {
+	return repo.dao.Resume(ctx, id, nextTime)
+}
+
+func (repo *preemptCronJobRepository) Delete(ctx context.Context, id int64) error {
+	return repo.dao.Delete(ctx, id)
+}
+
+// Restore 和 Update 一样分两步更新
+func (repo *preemptCronJobRepository) Restore(ctx context.Context, j domain.CronJob) error {
+	if err := repo.dao.Restore(ctx, repo.toEntity(j)); err != nil {
+		return err
+	}
+	return repo.depDAO.Replace(ctx, j.Name, j.Upstreams)
+}
+
+func (repo *preemptCronJobRepository) RunNow(ctx context.Context, id int64) error {
+	return repo.dao.RunNow(ctx, id)
+}
+
+func (repo *preemptCronJobRepository) AddExecution(ctx context.Context, e domain.JobExecution) (int64, error) {
+	entity := dao.JobExecution{
+		JobID:      e.JobID,
+		JobName:    e.JobName,
+		Node:       e.Node,
+		StartAt:    e.StartAt.UnixMilli(),
+		Status:     e.Status.ToUint8(),
+		Err:        e.Err,
+		WindowTime: e.Window.UnixMilli(),
+	}
+	if !e.EndAt.IsZero() {
+		entity.EndAt = e.EndAt.UnixMilli()
+	}
+	return repo.execDAO.Insert(ctx, entity)
+}
+
+func (repo *preemptCronJobRepository) FinishExecution(ctx context.Context, id int64, status domain.JobExecutionStatus, errMsg string) error {
+	return repo.execDAO.Finish(ctx, id, status.ToUint8(), errMsg)
+}
+
+func (repo *preemptCronJobRepository) ListExecutions(ctx context.Context, jobID int64, offset, limit int) ([]domain.JobExecution, error) {
+	execs, err := repo.execDAO.ListByJob(ctx, jobID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(execs, func(idx int, src dao.JobExecution) domain.JobExecution {
+		return repo.toExecution(src)
+	}), nil
+}
+
+func (repo *preemptCronJobRepository) ListExecutionsByWindow(ctx context.Context, jobIDs []int64, window time.Time) ([]domain.JobExecution, error) {
+	if len(jobIDs) == 0 {
+		return []domain.JobExecution{}, nil
+	}
+	execs, err := repo.execDAO.ListByWindow(ctx, jobIDs, window.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(execs, func(idx int, src dao.JobExecution) domain.JobExecution {
+		return repo.toExecution(src)
+	}), nil
+}
+
+func (repo *preemptCronJobRepository) toExecution(src dao.JobExecution) domain.JobExecution {
+	e := domain.JobExecution{
+		ID:      src.ID,
+		JobID:   src.JobID,
+		JobName: src.JobName,
+		Node:    src.Node,
+		StartAt: time.UnixMilli(src.StartAt),
+		Status:  domain.JobExecutionStatus(src.Status),
+		Err:     src.Err,
+		Window:  time.UnixMilli(src.WindowTime),
+	}
+	if src.EndAt > 0 {
+		e.EndAt = time.UnixMilli(src.EndAt)
+	}
+	return e
 }
 
 func (repo *preemptCronJobRepository) toEntity(j domain.CronJob) dao.Job {
@@ -56,6 +261,12 @@ func (repo *preemptCronJobRepository) toEntity(j domain.CronJob) dao.Job {
 		Cfg:        j.Cfg,
 		Executor:   j.Executor,
 		NextTime:   j.NextTime.UnixMilli(),
+
+		MaxRetries:     j.MaxRetries,
+		RetryBackoff:   j.RetryBackoff.Milliseconds(),
+		Timeout:        j.Timeout.Milliseconds(),
+		Misfire:        j.Misfire.ToUint8(),
+		AlertThreshold: j.AlertThreshold,
 	}
 }
 
@@ -67,5 +278,17 @@ func (repo *preemptCronJobRepository) toDomain(j dao.Job) domain.CronJob {
 		Cfg:        j.Cfg,
 		Executor:   j.Executor,
 		NextTime:   time.UnixMilli(j.NextTime),
+		Status:     domain.CronJobStatus(j.Status),
+		CreateAt:   time.UnixMilli(j.CreateAt),
+		UpdateAt:   time.UnixMilli(j.UpdateAt),
+
+		MaxRetries:     j.MaxRetries,
+		RetryBackoff:   time.Duration(j.RetryBackoff) * time.Millisecond,
+		Timeout:        time.Duration(j.Timeout) * time.Millisecond,
+		Misfire:        domain.MisfirePolicy(j.Misfire),
+		AlertThreshold: j.AlertThreshold,
+		Retries:        j.Retries,
+		FailCount:      j.FailCount,
+		Window:         time.UnixMilli(j.WindowTime),
 	}
 }
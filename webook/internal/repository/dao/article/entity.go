@@ -4,10 +4,47 @@ type Article struct {
 	ID       int64  `gorm:"primaryKey,autoIncrement" bson:"id,omitempty"`
 	Title    string `gorm:"type=varchar(4096)" bson:"title,omitempty"`
 	Content  string `gorm:"type=BLOB" bson:"content,omitempty"`
-	AuthorID int64  `gorm:"index" bson:"author_id,omitempty"`
+	AuthorID int64  `gorm:"index:author_id_update_at" bson:"author_id,omitempty"`
 	Status   uint8  `bson:"status,omitempty"`
 	CreateAt int64  `bson:"create_at,omitempty"`
-	UpdateAt int64  `bson:"update_at,omitempty"`
+	// UpdateAt 和 ID 一起作为翻页的游标
+	UpdateAt int64 `gorm:"index:author_id_update_at;index" bson:"update_at,omitempty"`
+	// PublishAt 定时发表的时间
+	PublishAt int64 `gorm:"index" bson:"publish_at,omitempty"`
+	// Tags 只在 MongoDB 中使用，MySQL 通过 ArticleTag 和 PublishedArticleTag 关联
+	Tags []string `gorm:"-" bson:"tags,omitempty"`
 }
 
 type PublishedArticle Article
+
+// ArticleRevision 文章的历史版本，每次保存或者发表都会记录一条
+type ArticleRevision struct {
+	ID        int64  `gorm:"primaryKey,autoIncrement"`
+	ArticleID int64  `gorm:"uniqueIndex:article_id_version"`
+	Version   int64  `gorm:"uniqueIndex:article_id_version"`
+	AuthorID  int64  `gorm:"index"`
+	Title     string `gorm:"type:varchar(4096)"`
+	Content   string `gorm:"type:BLOB"`
+	Status    uint8
+	CreateAt  int64
+}
+
+// Tag 标签，ArticleCnt 是关联的已发表文章数量，用于自动补全的时候排序
+type Tag struct {
+	ID         int64  `gorm:"primaryKey,autoIncrement" bson:"id,omitempty"`
+	Name       string `gorm:"type:varchar(64);uniqueIndex" bson:"name,omitempty"`
+	ArticleCnt int64  `bson:"article_cnt"`
+	CreateAt   int64  `bson:"create_at,omitempty"`
+	UpdateAt   int64  `bson:"update_at,omitempty"`
+}
+
+// ArticleTag 制作库的文章和标签的多对多关联，保存草稿只修改它
+type ArticleTag struct {
+	ID        int64 `gorm:"primaryKey,autoIncrement"`
+	ArticleID int64 `gorm:"uniqueIndex:article_id_tag_id"`
+	TagID     int64 `gorm:"uniqueIndex:article_id_tag_id;index"`
+	CreateAt  int64
+}
+
+// PublishedArticleTag 线上库的关联，发表的时候从 ArticleTag 复制过来，按标签查询文章和 Tag.ArticleCnt 都以它为准
+type PublishedArticleTag ArticleTag
@@ -6,6 +6,8 @@ import (
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+
+	"geektime-basic-go/webook/pkg/gormx"
 )
 
 type gormDAO struct {
@@ -16,23 +18,31 @@ func NewGormArticleDAO(db *gorm.DB) DAO {
 	return &gormDAO{db: db}
 }
 
+// Insert 和 UpdateById 一样，ctx 里面有 gormx.Transactor 开启的事务，就在这个事务里执行
 func (dao *gormDAO) Insert(ctx context.Context, art Article) (int64, error) {
 	now := time.Now().UnixMilli()
 	art.CreateAt, art.UpdateAt = now, now
-	err := dao.db.WithContext(ctx).Create(&art).Error
+	err := gormx.DB(ctx, dao.db).WithContext(ctx).Create(&art).Error
 	return art.ID, err
 }
 
 func (dao *gormDAO) UpdateById(ctx context.Context, art Article) error {
 	now := time.Now().UnixMilli()
-	res := dao.db.WithContext(ctx).Model(&Article{}).
+	values := map[string]any{
+		"title":      art.Title,
+		"content":    art.Content,
+		"status":     art.Status,
+		"publish_at": art.PublishAt,
+		"update_at":  now,
+	}
+	if art.Status == statusUnpublished {
+		// 保存草稿不会取消定时发表
+		values["status"] = gorm.Expr("CASE WHEN status = ? THEN status ELSE ? END", statusScheduled, art.Status)
+		delete(values, "publish_at")
+	}
+	res := gormx.DB(ctx, dao.db).WithContext(ctx).Model(&Article{}).
 		Where("id= ? AND author_id = ? ", art.ID, art.AuthorID).
-		Updates(map[string]any{
-			"title":     art.Title,
-			"content":   art.Content,
-			"status":    art.Status,
-			"update_at": now,
-		})
+		Updates(values)
 
 	if err := res.Error; err != nil {
 		return err
@@ -43,8 +53,9 @@ func (dao *gormDAO) UpdateById(ctx context.Context, art Article) error {
 	return nil
 }
 
+// Sync ctx 里面有 gormx.Transactor 开启的事务，就在这个事务里同步
 func (dao *gormDAO) Sync(ctx context.Context, art Article) (int64, error) {
-	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var err error
 		if art.ID == 0 {
 			art.ID, err = NewGormArticleDAO(tx).Insert(ctx, art)
@@ -54,26 +65,47 @@ func (dao *gormDAO) Sync(ctx context.Context, art Article) (int64, error) {
 		if err != nil {
 			return err
 		}
+		return dao.upsertPub(tx, art)
+	})
+
+	return art.ID, err
+}
 
-		now := time.Now().UnixMilli()
-		publishArt := PublishedArticle(art)
-		publishArt.CreateAt, publishArt.UpdateAt = now, now
-		return tx.Clauses(clause.OnConflict{
-			Columns: []clause.Column{{Name: "id"}},
-			DoUpdates: clause.Assignments(map[string]interface{}{
-				"title":     art.Title,
-				"content":   art.Content,
+func (dao *gormDAO) SyncScheduled(ctx context.Context, art Article) error {
+	return gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Article{}).
+			Where("id = ? AND author_id = ? AND status = ? AND update_at = ?", art.ID, art.AuthorID, statusScheduled, art.UpdateAt).
+			Updates(map[string]any{
 				"status":    art.Status,
-				"update_at": now,
-			}),
-		}).Create(&publishArt).Error
+				"update_at": time.Now().UnixMilli(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrScheduleChanged
+		}
+		return dao.upsertPub(tx, art)
 	})
+}
 
-	return art.ID, err
+func (dao *gormDAO) upsertPub(tx *gorm.DB, art Article) error {
+	now := time.Now().UnixMilli()
+	publishArt := PublishedArticle(art)
+	publishArt.CreateAt, publishArt.UpdateAt = now, now
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"title":     art.Title,
+			"content":   art.Content,
+			"status":    art.Status,
+			"update_at": now,
+		}),
+	}).Create(&publishArt).Error
 }
 
 func (dao *gormDAO) SyncStatus(ctx context.Context, uid, id int64, status uint8) error {
-	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	return gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		res := tx.Model(&Article{}).Where("id = ? AND author_id = ?", id, uid).Update("status", status)
 		if res.Error != nil {
 			return res.Error
@@ -100,13 +132,12 @@ func (dao *gormDAO) GetPubByID(ctx context.Context, id int64) (PublishedArticle,
 	return pub, err
 }
 
-func (dao *gormDAO) GetByAuthor(ctx context.Context, author int64, offset int, limit int) ([]Article, error) {
+func (dao *gormDAO) GetByAuthor(ctx context.Context, author int64, cursor Cursor, limit int) ([]Article, error) {
 	var arts []Article
-	err := dao.db.WithContext(ctx).Model(&Article{}).
+	err := dao.afterCursor(dao.db.WithContext(ctx).Model(&Article{}), cursor).
 		Where("author_id = ?", author).
-		Offset(offset).
+		Order("update_at DESC, id DESC").
 		Limit(limit).
-		Order("update_at DESC").
 		Find(&arts).Error
 	return arts, err
 }
@@ -117,8 +148,67 @@ func (dao *gormDAO) GetByID(ctx context.Context, id int64) (Article, error) {
 	return art, err
 }
 
-func (dao *gormDAO) ListPubByCreateAt(ctx context.Context, updateAt time.Time, offset int, limit int) ([]PublishedArticle, error) {
+func (dao *gormDAO) ListPub(ctx context.Context, cursor Cursor, limit int) ([]PublishedArticle, error) {
+	var res []PublishedArticle
+	err := dao.afterCursor(dao.db.WithContext(ctx).Model(&PublishedArticle{}), cursor).
+		Order("update_at DESC, id DESC").
+		Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *gormDAO) ListPubByTag(ctx context.Context, tag string, cursor Cursor, limit int) ([]PublishedArticle, error) {
+	db := dao.db.WithContext(ctx)
+	tagID := db.Model(&Tag{}).Select("id").Where("name = ?", tag)
+	aids := db.Model(&PublishedArticleTag{}).Select("article_id").Where("tag_id = (?)", tagID)
+	var res []PublishedArticle
+	err := dao.afterCursor(db.Model(&PublishedArticle{}), cursor).
+		Where("id IN (?) AND status = ?", aids, statusPublished).
+		Order("update_at DESC, id DESC").
+		Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *gormDAO) ListPubByIDs(ctx context.Context, ids []int64) ([]PublishedArticle, error) {
 	var res []PublishedArticle
-	err := dao.db.WithContext(ctx).Where("update_at < ?", updateAt.UnixMilli()).Order("create_at DESC").Limit(limit).Offset(offset).Error
+	err := dao.db.WithContext(ctx).Model(&PublishedArticle{}).
+		Where("id IN ? AND status = ?", ids, statusPublished).
+		Find(&res).Error
 	return res, err
 }
+
+// afterCursor 只查询排在 cursor 之后的数据，不依赖 OFFSET 所以翻页再深也能走索引
+func (dao *gormDAO) afterCursor(db *gorm.DB, cursor Cursor) *gorm.DB {
+	if cursor.UpdateAt == 0 && cursor.ID == 0 {
+		return db
+	}
+	return db.Where("update_at < ? OR (update_at = ? AND id < ?)", cursor.UpdateAt, cursor.UpdateAt, cursor.ID)
+}
+
+func (dao *gormDAO) ListScheduled(ctx context.Context, publishAt time.Time, limit int) ([]Article, error) {
+	var arts []Article
+	err := dao.db.WithContext(ctx).
+		Where("status = ? AND publish_at <= ?", statusScheduled, publishAt.UnixMilli()).
+		Order("publish_at ASC").
+		Limit(limit).
+		Find(&arts).Error
+	return arts, err
+}
+
+func (dao *gormDAO) CancelSchedule(ctx context.Context, uid, id int64) error {
+	res := dao.db.WithContext(ctx).Model(&Article{}).
+		Where("id = ? AND author_id = ? AND status = ?", id, uid, statusScheduled).
+		Updates(map[string]any{
+			"status":     statusUnpublished,
+			"publish_at": 0,
+			"update_at":  time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPossibleIncorrectAuthor
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"regexp"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -290,3 +291,136 @@ func TestGormDAO_Sync(t *testing.T) {
 		})
 	}
 }
+
+func TestGormDAO_UpdateById_KeepSchedule(t *testing.T) {
+	sqlmockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	// 保存草稿的时候定时发表的文章保持原来的状态，也不会修改发表时间
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `articles` SET `content`=?,`status`=CASE WHEN status = ? THEN status ELSE ? END,`title`=?,`update_at`=? WHERE")).
+		WithArgs("文章内容", statusScheduled, statusUnpublished, "新的文章", sqlmock.AnyArg(), int64(1), int64(123)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	db, err := nweMockDB(sqlmockDB)
+	require.NoError(t, err)
+
+	err = NewGormArticleDAO(db).UpdateById(context.Background(), Article{
+		ID: 1, Title: "新的文章", Content: "文章内容", AuthorID: 123, Status: statusUnpublished,
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGormDAO_SyncScheduled(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+
+		wantErr error
+	}{
+		{
+			name: "发表成功",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE `articles` SET `status`=?,`update_at`=? WHERE id = ? AND author_id = ? AND status = ? AND update_at = ?")).
+					WithArgs(statusPublished, sqlmock.AnyArg(), int64(1), int64(123), statusScheduled, int64(1000)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO `published_articles`").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		{
+			name: "查出来之后被修改或者取消了",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE `articles`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+				return db
+			},
+			wantErr: ErrScheduleChanged,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := nweMockDB(tc.sqlmock(t))
+			require.NoError(t, err)
+			err = NewGormArticleDAO(db).SyncScheduled(context.Background(), Article{
+				ID: 1, Title: "新的文章", Content: "文章内容", AuthorID: 123, Status: statusPublished, UpdateAt: 1000,
+			})
+			require.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestGormDAO_GetByAuthor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+		cursor  Cursor
+
+		wantRes []Article
+		wantErr error
+	}{
+		{
+			name: "第一页",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				rows := sqlmock.NewRows([]string{"id", "author_id", "update_at"}).
+					AddRow(2, 123, 200).
+					AddRow(1, 123, 100)
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `articles` WHERE author_id = ? ORDER BY update_at DESC, id DESC LIMIT 2")).
+					WithArgs(123).
+					WillReturnRows(rows)
+				return db
+			},
+			wantRes: []Article{
+				{ID: 2, AuthorID: 123, UpdateAt: 200},
+				{ID: 1, AuthorID: 123, UpdateAt: 100},
+			},
+		},
+		{
+			name: "带游标",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				rows := sqlmock.NewRows([]string{"id", "author_id", "update_at"}).
+					AddRow(3, 123, 100)
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `articles` WHERE (update_at < ? OR (update_at = ? AND id < ?)) AND author_id = ? ORDER BY update_at DESC, id DESC LIMIT 2")).
+					WithArgs(100, 100, 5, 123).
+					WillReturnRows(rows)
+				return db
+			},
+			cursor: Cursor{UpdateAt: 100, ID: 5},
+			wantRes: []Article{
+				{ID: 3, AuthorID: 123, UpdateAt: 100},
+			},
+		},
+		{
+			name: "查询失败",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectQuery("SELECT").WillReturnError(errors.New("模拟失败"))
+				return db
+			},
+			wantErr: errors.New("模拟失败"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlmockDB := tc.sqlmock(t)
+			db, err := nweMockDB(sqlmockDB)
+			require.NoError(t, err)
+			dao := NewGormArticleDAO(db)
+			arts, err := dao.GetByAuthor(context.Background(), 123, tc.cursor, 2)
+			require.Equal(t, tc.wantErr, err)
+			require.Equal(t, tc.wantRes, arts)
+		})
+	}
+}
@@ -40,12 +40,41 @@ func InitCollections(db *mongo.Database) error {
 			},
 			Options: options.Index(),
 		},
+		{
+			Keys: bson.D{
+				{Key: "author_id", Value: 1},
+				{Key: "update_at", Value: -1},
+				{Key: "id", Value: -1},
+			},
+			Options: options.Index(),
+		},
+		{
+			Keys: bson.D{
+				{Key: "update_at", Value: -1},
+				{Key: "id", Value: -1},
+			},
+			Options: options.Index(),
+		},
 	}
 
 	if _, err := db.Collection("articles").Indexes().CreateMany(ctx, index); err != nil {
 		return err
 	}
-	_, err := db.Collection("published_articles").Indexes().CreateMany(ctx, index)
+	pubIndex := append(index, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "tags", Value: 1},
+			{Key: "update_at", Value: -1},
+			{Key: "id", Value: -1},
+		},
+		Options: options.Index(),
+	})
+	if _, err := db.Collection("published_articles").Indexes().CreateMany(ctx, pubIndex); err != nil {
+		return err
+	}
+	_, err := db.Collection("tags").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"name": 1},
+		Options: options.Index().SetUnique(true),
+	})
 	return err
 }
 
@@ -60,7 +89,18 @@ func (dao *mongoDBDAO) Insert(ctx context.Context, art Article) (int64, error) {
 func (dao *mongoDBDAO) UpdateById(ctx context.Context, art Article) error {
 	art.UpdateAt = time.Now().UnixMilli()
 	filter := bson.D{{Key: "id", Value: art.ID}, {Key: "author_id", Value: art.AuthorID}}
-	sets := bson.M{"$set": art}
+	var sets any = bson.M{"$set": art}
+	if art.Status == statusUnpublished {
+		// 保存草稿不会取消定时发表，内容用 $literal 避免以 $ 开头的内容被当成字段
+		sets = mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"title":     bson.M{"$literal": art.Title},
+			"content":   bson.M{"$literal": art.Content},
+			"update_at": art.UpdateAt,
+			"status": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$status", statusScheduled}}, "$status", art.Status,
+			}},
+		}}}}
+	}
 	res, err := dao.col.UpdateOne(ctx, filter, sets)
 	if err != nil {
 		return err
@@ -81,13 +121,34 @@ func (dao *mongoDBDAO) Sync(ctx context.Context, art Article) (int64, error) {
 	if err != nil {
 		return art.ID, err
 	}
+	return art.ID, dao.upsertLive(ctx, art)
+}
+
+func (dao *mongoDBDAO) SyncScheduled(ctx context.Context, art Article) error {
+	filter := bson.D{
+		{Key: "id", Value: art.ID},
+		{Key: "author_id", Value: art.AuthorID},
+		{Key: "status", Value: statusScheduled},
+		{Key: "update_at", Value: art.UpdateAt},
+	}
+	sets := bson.M{"$set": bson.M{"status": art.Status, "update_at": time.Now().UnixMilli()}}
+	res, err := dao.col.UpdateOne(ctx, filter, sets)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount != 1 {
+		return ErrScheduleChanged
+	}
+	return dao.upsertLive(ctx, art)
+}
 
+func (dao *mongoDBDAO) upsertLive(ctx context.Context, art Article) error {
 	now := time.Now().UnixMilli()
 	art.UpdateAt = now
 	filter := bson.D{{Key: "id", Value: art.ID}, {Key: "author_id", Value: art.AuthorID}}
 	sets := bson.M{"$set": art, "$setOnInsert": bson.M{"create_at": now}}
-	_, err = dao.liveCol.UpdateOne(ctx, filter, sets, options.Update().SetUpsert(true))
-	return art.ID, err
+	_, err := dao.liveCol.UpdateOne(ctx, filter, sets, options.Update().SetUpsert(true))
+	return err
 }
 
 func (dao *mongoDBDAO) SyncStatus(ctx context.Context, author, id int64, status uint8) error {
@@ -118,12 +179,89 @@ func (dao *mongoDBDAO) GetByID(ctx context.Context, id int64) (Article, error) {
 	panic("implement me")
 }
 
-func (dao *mongoDBDAO) GetByAuthor(ctx context.Context, author int64, offset, limit int) ([]Article, error) {
-	//TODO implement me
-	panic("implement me")
+func (dao *mongoDBDAO) GetByAuthor(ctx context.Context, author int64, cursor Cursor, limit int) ([]Article, error) {
+	filter := dao.afterCursor(bson.D{{Key: "author_id", Value: author}}, cursor)
+	res, err := dao.col.Find(ctx, filter, dao.cursorFindOptions(limit))
+	if err != nil {
+		return nil, err
+	}
+	var arts []Article
+	err = res.All(ctx, &arts)
+	return arts, err
 }
 
-func (dao *mongoDBDAO) ListPubByCreateAt(ctx context.Context, updateAt time.Time, offset int, limit int) ([]PublishedArticle, error) {
-	//TODO implement me
-	panic("implement me")
+func (dao *mongoDBDAO) ListPub(ctx context.Context, cursor Cursor, limit int) ([]PublishedArticle, error) {
+	res, err := dao.liveCol.Find(ctx, dao.afterCursor(bson.D{}, cursor), dao.cursorFindOptions(limit))
+	if err != nil {
+		return nil, err
+	}
+	var arts []PublishedArticle
+	err = res.All(ctx, &arts)
+	return arts, err
+}
+
+func (dao *mongoDBDAO) ListPubByTag(ctx context.Context, tag string, cursor Cursor, limit int) ([]PublishedArticle, error) {
+	filter := dao.afterCursor(bson.D{{Key: "tags", Value: tag}, {Key: "status", Value: statusPublished}}, cursor)
+	res, err := dao.liveCol.Find(ctx, filter, dao.cursorFindOptions(limit))
+	if err != nil {
+		return nil, err
+	}
+	var arts []PublishedArticle
+	err = res.All(ctx, &arts)
+	return arts, err
+}
+
+func (dao *mongoDBDAO) ListPubByIDs(ctx context.Context, ids []int64) ([]PublishedArticle, error) {
+	filter := bson.D{{Key: "id", Value: bson.M{"$in": ids}}, {Key: "status", Value: statusPublished}}
+	res, err := dao.liveCol.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var arts []PublishedArticle
+	err = res.All(ctx, &arts)
+	return arts, err
+}
+
+func (dao *mongoDBDAO) afterCursor(filter bson.D, cursor Cursor) bson.D {
+	if cursor.UpdateAt == 0 && cursor.ID == 0 {
+		return filter
+	}
+	return append(filter, bson.E{Key: "$or", Value: bson.A{
+		bson.M{"update_at": bson.M{"$lt": cursor.UpdateAt}},
+		bson.M{"update_at": cursor.UpdateAt, "id": bson.M{"$lt": cursor.ID}},
+	}})
+}
+
+func (dao *mongoDBDAO) cursorFindOptions(limit int) *options.FindOptions {
+	return options.Find().
+		SetSort(bson.D{{Key: "update_at", Value: -1}, {Key: "id", Value: -1}}).
+		SetLimit(int64(limit))
+}
+
+func (dao *mongoDBDAO) ListScheduled(ctx context.Context, publishAt time.Time, limit int) ([]Article, error) {
+	filter := bson.D{
+		{Key: "status", Value: statusScheduled},
+		{Key: "publish_at", Value: bson.M{"$lte": publishAt.UnixMilli()}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "publish_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := dao.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var arts []Article
+	err = cursor.All(ctx, &arts)
+	return arts, err
+}
+
+func (dao *mongoDBDAO) CancelSchedule(ctx context.Context, uid, id int64) error {
+	filter := bson.D{{Key: "id", Value: id}, {Key: "author_id", Value: uid}, {Key: "status", Value: statusScheduled}}
+	sets := bson.M{"$set": bson.M{"status": statusUnpublished, "publish_at": 0, "update_at": time.Now().UnixMilli()}}
+	res, err := dao.col.UpdateOne(ctx, filter, sets)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount != 1 {
+		return ErrPossibleIncorrectAuthor
+	}
+	return nil
 }
@@ -0,0 +1,80 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"geektime-basic-go/webook/pkg/gormx"
+)
+
+type RevisionDAO interface {
+	Insert(ctx context.Context, rev ArticleRevision) (int64, error)
+	GetByArticle(ctx context.Context, author, artID int64, offset, limit int) ([]ArticleRevision, error)
+	GetByVersion(ctx context.Context, author, artID, version int64) (ArticleRevision, error)
+}
+
+const (
+	uniqueIndexErrNo uint16 = 1062
+	// maxInsertRevisionRetries 版本号冲突的时候最多尝试几次
+	maxInsertRevisionRetries = 3
+)
+
+type gormRevisionDAO struct {
+	db *gorm.DB
+}
+
+func NewGormRevisionDAO(db *gorm.DB) RevisionDAO {
+	return &gormRevisionDAO{db: db}
+}
+
+// Insert 并发保存同一篇文章的时候，会算出同一个版本号，唯一索引会让其中一个失败，
+// 失败的那个重新计算版本号再插入，而不是丢掉这个版本
+// ctx 里面有 gormx.Transactor 开启的事务，就和文章在同一个事务里写，所以版本号要用当前读
+func (dao *gormRevisionDAO) Insert(ctx context.Context, rev ArticleRevision) (int64, error) {
+	rev.CreateAt = time.Now().UnixMilli()
+	var err error
+	for i := 0; i < maxInsertRevisionRetries; i++ {
+		err = gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var version int64
+			err := tx.Model(&ArticleRevision{}).
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("COALESCE(MAX(version), 0)").
+				Where("article_id = ?", rev.ArticleID).
+				Scan(&version).Error
+			if err != nil {
+				return err
+			}
+			rev.Version = version + 1
+			return tx.Create(&rev).Error
+		})
+		var me *mysql.MySQLError
+		if !errors.As(err, &me) || me.Number != uniqueIndexErrNo {
+			return rev.Version, err
+		}
+	}
+	return 0, err
+}
+
+func (dao *gormRevisionDAO) GetByArticle(ctx context.Context, author, artID int64, offset, limit int) ([]ArticleRevision, error) {
+	var revs []ArticleRevision
+	err := dao.db.WithContext(ctx).
+		Where("article_id = ? AND author_id = ?", artID, author).
+		Order("version DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&revs).Error
+	return revs, err
+}
+
+func (dao *gormRevisionDAO) GetByVersion(ctx context.Context, author, artID, version int64) (ArticleRevision, error) {
+	var rev ArticleRevision
+	err := dao.db.WithContext(ctx).
+		Where("article_id = ? AND author_id = ? AND version = ?", artID, author, version).
+		First(&rev).Error
+	return rev, err
+}
@@ -0,0 +1,158 @@
+package article
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"geektime-basic-go/webook/pkg/gormx"
+)
+
+type TagDAO interface {
+	// SetArticleTags 用 tags 覆盖制作库里文章原有的标签，不存在的标签会被创建。
+	// 不影响线上的文章，也不修改标签关联的文章数量
+	SetArticleTags(ctx context.Context, aid int64, tags []string) error
+	// SyncPubTags 发表的时候把制作库的标签复制到线上库，同时更新标签关联的文章数量
+	SyncPubTags(ctx context.Context, aid int64) error
+	// GetByArticles 批量查询制作库里文章的标签，key 是文章 ID
+	GetByArticles(ctx context.Context, aids []int64) (map[int64][]string, error)
+	// GetPubByArticles 批量查询已发表文章的标签，key 是文章 ID
+	GetPubByArticles(ctx context.Context, aids []int64) (map[int64][]string, error)
+	// SearchByPrefix 找出以 prefix 开头的标签，关联文章多的排在前面
+	SearchByPrefix(ctx context.Context, prefix string, limit int) ([]Tag, error)
+}
+
+type gormTagDAO struct {
+	db *gorm.DB
+}
+
+func NewGormTagDAO(db *gorm.DB) TagDAO {
+	return &gormTagDAO{db: db}
+}
+
+// SetArticleTags ctx 里面有 gormx.Transactor 开启的事务，就和文章在同一个事务里写
+func (dao *gormTagDAO) SetArticleTags(ctx context.Context, aid int64, tags []string) error {
+	now := time.Now().UnixMilli()
+	return gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tagIDs []int64
+		if len(tags) > 0 {
+			newTags := slice.Map(tags, func(idx int, src string) Tag {
+				return Tag{Name: src, CreateAt: now, UpdateAt: now}
+			})
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&newTags).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&Tag{}).Where("name IN ?", tags).Pluck("id", &tagIDs).Error; err != nil {
+				return err
+			}
+		}
+		_, _, err := replaceLinks[ArticleTag](tx, aid, tagIDs, now)
+		return err
+	})
+}
+
+// SyncPubTags 和 SetArticleTags 一样，ctx 里面有事务就在事务里写
+func (dao *gormTagDAO) SyncPubTags(ctx context.Context, aid int64) error {
+	now := time.Now().UnixMilli()
+	return gormx.DB(ctx, dao.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tagIDs []int64
+		if err := tx.Model(&ArticleTag{}).Where("article_id = ?", aid).Pluck("tag_id", &tagIDs).Error; err != nil {
+			return err
+		}
+		removed, added, err := replaceLinks[PublishedArticleTag](tx, aid, tagIDs, now)
+		if err != nil {
+			return err
+		}
+		if err = dao.incrArticleCnt(tx, removed, -1, now); err != nil {
+			return err
+		}
+		return dao.incrArticleCnt(tx, added, 1, now)
+	})
+}
+
+// replaceLinks 把文章关联的标签改成 tagIDs，返回删掉和新增的标签
+func replaceLinks[T ArticleTag | PublishedArticleTag](tx *gorm.DB, aid int64, tagIDs []int64, now int64) ([]int64, []int64, error) {
+	var oldIDs []int64
+	if err := tx.Model(new(T)).Where("article_id = ?", aid).Pluck("tag_id", &oldIDs).Error; err != nil {
+		return nil, nil, err
+	}
+
+	removed := slice.DiffSet(oldIDs, tagIDs)
+	if len(removed) > 0 {
+		if err := tx.Where("article_id = ? AND tag_id IN ?", aid, removed).Delete(new(T)).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	added := slice.DiffSet(tagIDs, oldIDs)
+	if len(added) > 0 {
+		rows := slice.Map(added, func(idx int, src int64) T {
+			return T(ArticleTag{ArticleID: aid, TagID: src, CreateAt: now})
+		})
+		if err := tx.Create(&rows).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return removed, added, nil
+}
+
+func (dao *gormTagDAO) incrArticleCnt(tx *gorm.DB, ids []int64, delta int, now int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Model(&Tag{}).Where("id IN ?", ids).Updates(map[string]any{
+		"article_cnt": gorm.Expr("article_cnt + ?", delta),
+		"update_at":   now,
+	}).Error
+}
+
+func (dao *gormTagDAO) GetByArticles(ctx context.Context, aids []int64) (map[int64][]string, error) {
+	return dao.getByArticles(ctx, "article_tags", aids)
+}
+
+func (dao *gormTagDAO) GetPubByArticles(ctx context.Context, aids []int64) (map[int64][]string, error) {
+	return dao.getByArticles(ctx, "published_article_tags", aids)
+}
+
+// getByArticles table 是 ArticleTag 或者 PublishedArticleTag 的表名
+func (dao *gormTagDAO) getByArticles(ctx context.Context, table string, aids []int64) (map[int64][]string, error) {
+	res := make(map[int64][]string, len(aids))
+	if len(aids) == 0 {
+		return res, nil
+	}
+
+	var rows []struct {
+		ArticleID int64
+		Name      string
+	}
+	err := dao.db.WithContext(ctx).Table(table).
+		Select(table+".article_id, tags.name").
+		Joins("JOIN tags ON tags.id = "+table+".tag_id").
+		Where(table+".article_id IN ?", aids).
+		Order(table + ".id ASC").
+		Scan(&rows).Error
+	for _, row := range rows {
+		res[row.ArticleID] = append(res[row.ArticleID], row.Name)
+	}
+	return res, err
+}
+
+func (dao *gormTagDAO) SearchByPrefix(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	var tags []Tag
+	err := dao.db.WithContext(ctx).
+		Where("name LIKE ? AND article_cnt > 0", escapeLike(prefix)+"%").
+		Order("article_cnt DESC, id ASC").
+		Limit(limit).
+		Find(&tags).Error
+	return tags, err
+}
+
+var likeReplacer = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeReplacer.Replace(s)
+}
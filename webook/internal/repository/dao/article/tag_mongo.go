@@ -0,0 +1,139 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/ecodeclub/ekit/slice"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTagDAO 文章的标签直接保存在文章的 tags 字段里，tags 集合只用于自动补全
+type mongoTagDAO struct {
+	tagCol  *mongo.Collection
+	col     *mongo.Collection
+	liveCol *mongo.Collection
+	node    *snowflake.Node
+}
+
+func NewMongoTagDAO(db *mongo.Database, node *snowflake.Node) TagDAO {
+	return &mongoTagDAO{
+		tagCol:  db.Collection("tags"),
+		col:     db.Collection("articles"),
+		liveCol: db.Collection("published_articles"),
+		node:    node,
+	}
+}
+
+func (dao *mongoTagDAO) SetArticleTags(ctx context.Context, aid int64, tags []string) error {
+	now := time.Now().UnixMilli()
+	for _, name := range tags {
+		sets := bson.M{"$setOnInsert": bson.M{"id": dao.node.Generate().Int64(), "create_at": now}}
+		_, err := dao.tagCol.UpdateOne(ctx, bson.M{"name": name}, sets, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+	_, err := dao.col.UpdateOne(ctx, bson.M{"id": aid}, bson.M{"$set": bson.M{"tags": tags}})
+	return err
+}
+
+func (dao *mongoTagDAO) SyncPubTags(ctx context.Context, aid int64) error {
+	tags, err := dao.getTags(ctx, dao.col, aid)
+	if err != nil {
+		return err
+	}
+	oldTags, err := dao.getTags(ctx, dao.liveCol, aid)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	if err = dao.incrArticleCnt(ctx, slice.DiffSet(oldTags, tags), -1, now); err != nil {
+		return err
+	}
+	if err = dao.incrArticleCnt(ctx, slice.DiffSet(tags, oldTags), 1, now); err != nil {
+		return err
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+	_, err = dao.liveCol.UpdateOne(ctx, bson.M{"id": aid}, bson.M{"$set": bson.M{"tags": tags}})
+	return err
+}
+
+func (dao *mongoTagDAO) getTags(ctx context.Context, col *mongo.Collection, aid int64) ([]string, error) {
+	var art Article
+	err := col.FindOne(ctx, bson.M{"id": aid}, options.FindOne().SetProjection(bson.M{"tags": 1})).Decode(&art)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return art.Tags, nil
+}
+
+func (dao *mongoTagDAO) incrArticleCnt(ctx context.Context, names []string, delta int, now int64) error {
+	if len(names) == 0 {
+		return nil
+	}
+	sets := bson.M{"$inc": bson.M{"article_cnt": delta}, "$set": bson.M{"update_at": now}}
+	_, err := dao.tagCol.UpdateMany(ctx, bson.M{"name": bson.M{"$in": names}}, sets)
+	return err
+}
+
+func (dao *mongoTagDAO) GetByArticles(ctx context.Context, aids []int64) (map[int64][]string, error) {
+	return dao.getByArticles(ctx, dao.col, aids)
+}
+
+func (dao *mongoTagDAO) GetPubByArticles(ctx context.Context, aids []int64) (map[int64][]string, error) {
+	return dao.getByArticles(ctx, dao.liveCol, aids)
+}
+
+func (dao *mongoTagDAO) getByArticles(ctx context.Context, col *mongo.Collection, aids []int64) (map[int64][]string, error) {
+	res := make(map[int64][]string, len(aids))
+	if len(aids) == 0 {
+		return res, nil
+	}
+
+	opts := options.Find().SetProjection(bson.M{"id": 1, "tags": 1})
+	cursor, err := col.Find(ctx, bson.M{"id": bson.M{"$in": aids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var arts []Article
+	if err = cursor.All(ctx, &arts); err != nil {
+		return nil, err
+	}
+	for _, art := range arts {
+		if len(art.Tags) > 0 {
+			res[art.ID] = art.Tags
+		}
+	}
+	return res, nil
+}
+
+func (dao *mongoTagDAO) SearchByPrefix(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	filter := bson.M{
+		"name":        bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)},
+		"article_cnt": bson.M{"$gt": 0},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "article_cnt", Value: -1}, {Key: "id", Value: 1}}).
+		SetLimit(int64(limit))
+	cursor, err := dao.tagCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var tags []Tag
+	err = cursor.All(ctx, &tags)
+	return tags, err
+}
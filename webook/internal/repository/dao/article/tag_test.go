@@ -0,0 +1,187 @@
+package article
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGormTagDAO_SetArticleTags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+		tags    []string
+
+		wantErr error
+	}{
+		{
+			// 草稿的标签不影响线上，所以不修改 article_cnt
+			name: "新增标签",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `tags`").WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectQuery("SELECT `id` FROM `tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectQuery("SELECT `tag_id` FROM `article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}))
+				mock.ExpectExec("INSERT INTO `article_tags`").WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit()
+				return db
+			},
+			tags: []string{"go", "mysql"},
+		},
+		{
+			name: "替换标签",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `tags`").WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectQuery("SELECT `id` FROM `tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectQuery("SELECT `tag_id` FROM `article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}).AddRow(1).AddRow(3))
+				mock.ExpectExec("DELETE FROM `article_tags`").
+					WithArgs(int64(123), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO `article_tags`").WillReturnResult(sqlmock.NewResult(4, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			tags: []string{"go", "redis"},
+		},
+		{
+			name: "清空标签",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT `tag_id` FROM `article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}).AddRow(1))
+				mock.ExpectExec("DELETE FROM `article_tags`").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			tags: []string{},
+		},
+		{
+			name: "创建标签失败",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `tags`").WillReturnError(errors.New("模拟失败"))
+				mock.ExpectRollback()
+				return db
+			},
+			tags:    []string{"go"},
+			wantErr: errors.New("模拟失败"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlmockDB := tc.sqlmock(t)
+			db, err := nweMockDB(sqlmockDB)
+			require.NoError(t, err)
+			dao := NewGormTagDAO(db)
+			err = dao.SetArticleTags(context.Background(), 123, tc.tags)
+			require.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestGormTagDAO_SyncPubTags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+
+		wantErr error
+	}{
+		{
+			name: "首次发表",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT `tag_id` FROM `article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}).AddRow(1).AddRow(2))
+				mock.ExpectQuery("SELECT `tag_id` FROM `published_article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}))
+				mock.ExpectExec("INSERT INTO `published_article_tags`").WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectExec("UPDATE `tags` SET `article_cnt`=article_cnt \\+ \\?").
+					WithArgs(1, sqlmock.AnyArg(), int64(1), int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		{
+			name: "重新发表，替换线上的标签",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT `tag_id` FROM `article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}).AddRow(1).AddRow(2))
+				mock.ExpectQuery("SELECT `tag_id` FROM `published_article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}).AddRow(1).AddRow(3))
+				mock.ExpectExec("DELETE FROM `published_article_tags`").
+					WithArgs(int64(123), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO `published_article_tags`").WillReturnResult(sqlmock.NewResult(4, 1))
+				mock.ExpectExec("UPDATE `tags` SET `article_cnt`=article_cnt \\+ \\?").
+					WithArgs(-1, sqlmock.AnyArg(), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE `tags` SET `article_cnt`=article_cnt \\+ \\?").
+					WithArgs(1, sqlmock.AnyArg(), int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		{
+			name: "标签没有变化",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT `tag_id` FROM `article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}).AddRow(1))
+				mock.ExpectQuery("SELECT `tag_id` FROM `published_article_tags`").
+					WillReturnRows(sqlmock.NewRows([]string{"tag_id"}).AddRow(1))
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		{
+			name: "查询草稿标签失败",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT `tag_id` FROM `article_tags`").WillReturnError(errors.New("模拟失败"))
+				mock.ExpectRollback()
+				return db
+			},
+			wantErr: errors.New("模拟失败"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlmockDB := tc.sqlmock(t)
+			db, err := nweMockDB(sqlmockDB)
+			require.NoError(t, err)
+			dao := NewGormTagDAO(db)
+			err = dao.SyncPubTags(context.Background(), 123)
+			require.Equal(t, tc.wantErr, err)
+		})
+	}
+}
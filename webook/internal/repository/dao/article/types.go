@@ -4,17 +4,49 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"geektime-basic-go/webook/internal/domain"
+)
+
+var (
+	statusUnpublished = domain.ArticleStatusUnpublished.ToUint8()
+	statusPublished   = domain.ArticleStatusPublished.ToUint8()
+	statusScheduled   = domain.ArticleStatusScheduled.ToUint8()
 )
 
-var ErrPossibleIncorrectAuthor = errors.New("用户在尝试操作非本人数据")
+// Cursor 按 (update_at, id) 倒序翻页，零值代表第一页
+type Cursor struct {
+	UpdateAt int64
+	ID       int64
+}
+
+var (
+	ErrPossibleIncorrectAuthor = errors.New("用户在尝试操作非本人数据")
+	// ErrScheduleChanged 查出来之后，定时发表的文章被作者修改或者取消了
+	ErrScheduleChanged = errors.New("定时发表的文章已经被修改")
+)
 
 type DAO interface {
 	Insert(ctx context.Context, art Article) (int64, error)
+	// UpdateById 保存草稿（status 为未发表）的时候，处于定时发表状态的文章依旧保持定时发表，
+	// 发表时间也不变，要取消只能调用 CancelSchedule
 	UpdateById(ctx context.Context, art Article) error
 	Sync(ctx context.Context, art Article) (int64, error)
 	SyncStatus(ctx context.Context, uid, id int64, status uint8) error
 	GetPubByID(ctx context.Context, id int64) (PublishedArticle, error)
 	GetByID(ctx context.Context, id int64) (Article, error)
-	GetByAuthor(ctx context.Context, author int64, offset, limit int) ([]Article, error)
-	ListPubByCreateAt(ctx context.Context, updateAt time.Time, offset int, limit int) ([]PublishedArticle, error)
+	GetByAuthor(ctx context.Context, author int64, cursor Cursor, limit int) ([]Article, error)
+	// ListPub 按照 (update_at, id) 倒序找出 cursor 之后的线上库文章
+	ListPub(ctx context.Context, cursor Cursor, limit int) ([]PublishedArticle, error)
+	// ListPubByTag 和 ListPub 一样翻页，只返回带有 tag 标签并且处于发表状态的文章
+	ListPubByTag(ctx context.Context, tag string, cursor Cursor, limit int) ([]PublishedArticle, error)
+	// ListPubByIDs 只返回处于发表状态的文章，顺序不保证和 ids 一致
+	ListPubByIDs(ctx context.Context, ids []int64) ([]PublishedArticle, error)
+	// ListScheduled 找出 publishAt 之前需要定时发表的草稿
+	ListScheduled(ctx context.Context, publishAt time.Time, limit int) ([]Article, error)
+	// SyncScheduled 发表 ListScheduled 查出来的文章，文章依旧处于定时状态并且 update_at 没有变过才会发表，
+	// 否则返回 ErrScheduleChanged，避免用旧的内容覆盖作者的修改
+	SyncScheduled(ctx context.Context, art Article) error
+	// CancelSchedule 取消定时发表，文章回到未发表状态
+	CancelSchedule(ctx context.Context, uid, id int64) error
 }
@@ -2,17 +2,50 @@ package dao
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrJobStatusConflict 任务不存在，或者任务当前的状态不允许这个操作
+	ErrJobStatusConflict = errors.New("任务状态冲突")
+	ErrJobNameConflict   = errors.New("任务名字冲突")
+)
+
 type CronJobDAO interface {
 	Preempt(ctx context.Context) (Job, error)
+	// Insert 同名的任务已经存在的时候返回 ErrJobNameConflict
 	Insert(ctx context.Context, j Job) error
-	UpdateNextTime(ctx context.Context, id int64, nextTime time.Time) error
+	// Complete 一次执行结束之后，更新下次执行的时间、重试次数、连续失败的次数和这一次执行的窗口
+	Complete(ctx context.Context, id int64, nextTime time.Time, retries, failCount int, window time.Time) error
+	// Trigger 上游都执行成功之后，让等待中的下游任务马上执行 window 这个窗口，
+	// 正在执行的下游任务等释放之后再执行。
+	// 已经触发过这个窗口或者更新的窗口，或者任务被暂停、删除的时候返回 ErrJobStatusConflict
+	Trigger(ctx context.Context, id int64, window time.Time) error
+	// Skip 上游失败之后，下游任务跳过 window 这个窗口，
+	// 已经触发或者跳过这个窗口的时候返回 ErrJobStatusConflict
+	Skip(ctx context.Context, id int64, window time.Time) error
+	GetByNames(ctx context.Context, names []string) ([]Job, error)
+	// Release 执行期间被触发了新的窗口，并且这一次不用重试了，就马上执行新的窗口
 	Release(ctx context.Context, id int64) error
 	UpdateUpdateTime(ctx context.Context, id int64) error
+	GetByID(ctx context.Context, id int64) (Job, error)
+	List(ctx context.Context, offset, limit int) ([]Job, error)
+	// Update 修改执行方式、配置和表达式，已经删除的任务不能修改
+	Update(ctx context.Context, j Job) error
+	// Pause 等待中和运行中的任务都可以暂停，运行中的任务这一次会执行完
+	Pause(ctx context.Context, id int64) error
+	// Resume 恢复暂停的任务，nextTime 是下次执行的时间
+	Resume(ctx context.Context, id int64, nextTime time.Time) error
+	// Delete 软删除，把状态改成 jobStatusEnd
+	Delete(ctx context.Context, id int64) error
+	// Restore 按照 j 的配置恢复被删除的任务，任务没有被删除的时候返回 ErrJobStatusConflict
+	Restore(ctx context.Context, j Job) error
+	// RunNow 等待中的任务马上执行一次
+	RunNow(ctx context.Context, id int64) error
 }
 
 type gormCronJobDAO struct {
@@ -26,21 +59,88 @@ func NewGormCronJobDAO(db *gorm.DB) CronJobDAO {
 func (dao *gormCronJobDAO) Insert(ctx context.Context, j Job) error {
 	now := time.Now().UnixMilli()
 	j.CreateAt, j.UpdateAt = now, now
-	return dao.db.WithContext(ctx).Create(&j).Error
+	j.Status = jobStatusWaiting
+	err := dao.db.WithContext(ctx).Create(&j).Error
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == uniqueIndexErrNo {
+		return ErrJobNameConflict
+	}
+	return err
 }
 
-func (dao *gormCronJobDAO) UpdateNextTime(ctx context.Context, id int64, nextTime time.Time) error {
+func (dao *gormCronJobDAO) Complete(ctx context.Context, id int64, nextTime time.Time, retries, failCount int, window time.Time) error {
 	return dao.db.WithContext(ctx).Model(&Job{}).Where("id = ?", id).Updates(map[string]any{
 		"next_time":   nextTime.UnixMilli(),
-		"update_time": time.Now().UnixMilli(),
+		"retries":     retries,
+		"fail_count":  failCount,
+		"window_time": window.UnixMilli(),
+		"update_at":   time.Now().UnixMilli(),
 	}).Error
 }
 
+// Trigger 用 window_time 做 CAS，多个上游同时执行成功也只会触发一次。
+// 任务还在执行上一个窗口的时候记到 pending_window 里面，释放的时候再执行，只记最新的一个窗口
+func (dao *gormCronJobDAO) Trigger(ctx context.Context, id int64, window time.Time) error {
+	now := time.Now().UnixMilli()
+	db := dao.db.WithContext(ctx)
+	res := db.Model(&Job{}).
+		Where("id = ? AND status = ? AND window_time < ?", id, jobStatusWaiting, window.UnixMilli()).
+		Updates(map[string]any{
+			"next_time":   now,
+			"window_time": window.UnixMilli(),
+			"retries":     0,
+			"update_at":   now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+	res = db.Model(&Job{}).
+		Where("id = ? AND status = ? AND window_time < ? AND pending_window < ?",
+			id, jobStatusRunning, window.UnixMilli(), window.UnixMilli()).
+		Update("pending_window", window.UnixMilli())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrJobStatusConflict
+	}
+	return nil
+}
+
+func (dao *gormCronJobDAO) Skip(ctx context.Context, id int64, window time.Time) error {
+	res := dao.db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND status <> ? AND window_time < ?", id, jobStatusEnd, window.UnixMilli()).
+		Updates(map[string]any{
+			"window_time": window.UnixMilli(),
+			"update_at":   time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrJobStatusConflict
+	}
+	return nil
+}
+
+func (dao *gormCronJobDAO) GetByNames(ctx context.Context, names []string) ([]Job, error) {
+	var res []Job
+	err := dao.db.WithContext(ctx).Where("name IN ?", names).Find(&res).Error
+	return res, err
+}
+
+// Release 只释放运行中的任务，执行期间被暂停或者删除的任务保持原来的状态。
+// MySQL 按照顺序赋值，next_time 要在 window_time 前面，读到的才是原来的 window_time
 func (dao *gormCronJobDAO) Release(ctx context.Context, id int64) error {
-	return dao.db.WithContext(ctx).Model(&Job{}).Where("id = ?", id).Updates(map[string]any{
-		"status":    jobStatusWaiting,
-		"update_at": time.Now().UnixMilli(),
-	}).Error
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Exec("UPDATE `jobs` SET "+
+		"`next_time` = IF(`pending_window` > `window_time` AND `retries` = 0, ?, `next_time`), "+
+		"`window_time` = IF(`pending_window` > `window_time` AND `retries` = 0, `pending_window`, `window_time`), "+
+		"`status` = ?, `update_at` = ? WHERE id = ? AND status = ?",
+		now, jobStatusWaiting, now, id, jobStatusRunning).Error
 }
 
 func (dao *gormCronJobDAO) UpdateUpdateTime(ctx context.Context, id int64) error {
@@ -49,6 +149,88 @@ func (dao *gormCronJobDAO) UpdateUpdateTime(ctx context.Context, id int64) error
 	}).Error
 }
 
+func (dao *gormCronJobDAO) GetByID(ctx context.Context, id int64) (Job, error) {
+	var j Job
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&j).Error
+	return j, err
+}
+
+func (dao *gormCronJobDAO) List(ctx context.Context, offset, limit int) ([]Job, error) {
+	var res []Job
+	err := dao.db.WithContext(ctx).Order("id ASC").Offset(offset).Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *gormCronJobDAO) Update(ctx context.Context, j Job) error {
+	return dao.transit(ctx, j.ID, []int{jobStatusWaiting, jobStatusRunning, jobStatusPaused}, dao.configUpdates(j))
+}
+
+// Restore 之前执行的状态都不要了，当成一个新的任务
+func (dao *gormCronJobDAO) Restore(ctx context.Context, j Job) error {
+	updates := dao.configUpdates(j)
+	updates["status"] = jobStatusWaiting
+	updates["retries"] = 0
+	updates["fail_count"] = 0
+	updates["pending_window"] = 0
+	return dao.transit(ctx, j.ID, []int{jobStatusEnd}, updates)
+}
+
+// configUpdates 后台可以修改的配置
+func (dao *gormCronJobDAO) configUpdates(j Job) map[string]any {
+	return map[string]any{
+		"executor":   j.Executor,
+		"cfg":        j.Cfg,
+		"expression": j.Expression,
+		"next_time":  j.NextTime,
+		// 重试和告警的策略
+		"max_retries":     j.MaxRetries,
+		"retry_backoff":   j.RetryBackoff,
+		"timeout":         j.Timeout,
+		"misfire":         j.Misfire,
+		"alert_threshold": j.AlertThreshold,
+	}
+}
+
+func (dao *gormCronJobDAO) Pause(ctx context.Context, id int64) error {
+	return dao.transit(ctx, id, []int{jobStatusWaiting, jobStatusRunning}, map[string]any{
+		"status": jobStatusPaused,
+	})
+}
+
+func (dao *gormCronJobDAO) Resume(ctx context.Context, id int64, nextTime time.Time) error {
+	return dao.transit(ctx, id, []int{jobStatusPaused}, map[string]any{
+		"status":    jobStatusWaiting,
+		"next_time": nextTime.UnixMilli(),
+	})
+}
+
+func (dao *gormCronJobDAO) Delete(ctx context.Context, id int64) error {
+	return dao.transit(ctx, id, []int{jobStatusWaiting, jobStatusRunning, jobStatusPaused}, map[string]any{
+		"status": jobStatusEnd,
+	})
+}
+
+func (dao *gormCronJobDAO) RunNow(ctx context.Context, id int64) error {
+	return dao.transit(ctx, id, []int{jobStatusWaiting}, map[string]any{
+		"next_time": time.Now().UnixMilli(),
+	})
+}
+
+// transit 任务处于 from 里面的某个状态的时候才更新，否则返回 ErrJobStatusConflict
+func (dao *gormCronJobDAO) transit(ctx context.Context, id int64, from []int, updates map[string]any) error {
+	updates["update_at"] = time.Now().UnixMilli()
+	res := dao.db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrJobStatusConflict
+	}
+	return nil
+}
+
 func (dao *gormCronJobDAO) Preempt(ctx context.Context) (Job, error) {
 	db := dao.db.WithContext(ctx)
 	for {
@@ -59,7 +241,7 @@ func (dao *gormCronJobDAO) Preempt(ctx context.Context) (Job, error) {
 		// 到了调度的时间
 		// 任务处于运行转态, 且 5分钟内没有更新转态, 可以认为节点崩溃了, 可以重新调度
 		if err := db.Where("next_time <= ? AND status = ?", now, jobStatusWaiting).
-			Or("status = ? AND update_at <=", jobStatusRunning, nowTime.Add(-5*time.Minute).UnixMilli()).
+			Or("status = ? AND update_at <= ?", jobStatusRunning, nowTime.Add(-5*time.Minute).UnixMilli()).
 			First(&j).Error; err != nil {
 			return Job{}, err
 		}
@@ -84,8 +266,8 @@ func (dao *gormCronJobDAO) Preempt(ctx context.Context) (Job, error) {
 }
 
 type Job struct {
-	ID         int64 `gorm:"primaryKey,autoIncrement"`
-	Name       string
+	ID         int64  `gorm:"primaryKey,autoIncrement"`
+	Name       string `gorm:"type:varchar(128);uniqueIndex"`
 	Executor   string
 	Cfg        string
 	Expression string
@@ -94,6 +276,20 @@ type Job struct {
 	Status     int
 	CreateAt   int64
 	UpdateAt   int64
+
+	MaxRetries int
+	// RetryBackoff 和 Timeout 都是毫秒数
+	RetryBackoff   int64
+	Timeout        int64
+	Misfire        uint8
+	AlertThreshold int
+	// Retries 和 FailCount 放在数据库里面，节点重启之后也不会丢
+	Retries   int
+	FailCount int
+	// WindowTime 最近一次执行的窗口，毫秒数
+	WindowTime int64
+	// PendingWindow 执行期间上游触发的窗口，比 WindowTime 大的时候才有效
+	PendingWindow int64
 }
 
 const (
@@ -103,4 +299,6 @@ const (
 	jobStatusRunning
 	// 不再需要调度了，比如说被终止了，或者被删除了。
 	jobStatusEnd
+	// 暂停调度，恢复之后回到等待状态
+	jobStatusPaused
 )
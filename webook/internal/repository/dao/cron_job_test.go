@@ -0,0 +1,273 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGormCronJobDAO_Insert(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+
+		wantErr error
+	}{
+		{
+			name: "创建成功",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectExec("INSERT INTO `jobs` .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				return db
+			},
+		},
+		{
+			name: "名字冲突",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectExec("INSERT INTO `jobs` .*").WillReturnError(&mysql.MySQLError{Number: uniqueIndexErrNo})
+				return db
+			},
+			wantErr: ErrJobNameConflict,
+		},
+		{
+			name: "数据库错误",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectExec("INSERT INTO `jobs` .*").WillReturnError(errors.New("db 错误"))
+				return db
+			},
+			wantErr: errors.New("db 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := initDB(tc.sqlmock(t))
+			require.NoError(t, err)
+			err = NewGormCronJobDAO(db).Insert(context.Background(), Job{Name: "job", Executor: "local"})
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestGormCronJobDAO_transit(t *testing.T) {
+	nextTime := time.UnixMilli(1000)
+	testCases := []struct {
+		name string
+		// sql 更新语句，参数按照列名排序
+		sql  string
+		args []driver.Value
+		// affected 更新的行数，为 0 代表当前状态不允许
+		affected int64
+		do       func(dao CronJobDAO) error
+
+		wantErr error
+	}{
+		{
+			name:     "暂停等待中或者运行中的任务",
+			sql:      "UPDATE `jobs` SET `status`=?,`update_at`=? WHERE id = ? AND status IN (?,?)",
+			args:     []driver.Value{jobStatusPaused, sqlmock.AnyArg(), int64(1), jobStatusWaiting, jobStatusRunning},
+			affected: 1,
+			do: func(dao CronJobDAO) error {
+				return dao.Pause(context.Background(), 1)
+			},
+		},
+		{
+			name: "暂停已经删除的任务",
+			sql:  "UPDATE `jobs` SET `status`=?,`update_at`=? WHERE id = ? AND status IN (?,?)",
+			args: []driver.Value{jobStatusPaused, sqlmock.AnyArg(), int64(1), jobStatusWaiting, jobStatusRunning},
+			do: func(dao CronJobDAO) error {
+				return dao.Pause(context.Background(), 1)
+			},
+			wantErr: ErrJobStatusConflict,
+		},
+		{
+			name:     "恢复暂停的任务",
+			sql:      "UPDATE `jobs` SET `next_time`=?,`status`=?,`update_at`=? WHERE id = ? AND status IN (?)",
+			args:     []driver.Value{int64(1000), jobStatusWaiting, sqlmock.AnyArg(), int64(1), jobStatusPaused},
+			affected: 1,
+			do: func(dao CronJobDAO) error {
+				return dao.Resume(context.Background(), 1, nextTime)
+			},
+		},
+		{
+			name: "恢复没有暂停的任务",
+			sql:  "UPDATE `jobs` SET `next_time`=?,`status`=?,`update_at`=? WHERE id = ? AND status IN (?)",
+			args: []driver.Value{int64(1000), jobStatusWaiting, sqlmock.AnyArg(), int64(1), jobStatusPaused},
+			do: func(dao CronJobDAO) error {
+				return dao.Resume(context.Background(), 1, nextTime)
+			},
+			wantErr: ErrJobStatusConflict,
+		},
+		{
+			name: "删除任务",
+			sql:  "UPDATE `jobs` SET `status`=?,`update_at`=? WHERE id = ? AND status IN (?,?,?)",
+			args: []driver.Value{jobStatusEnd, sqlmock.AnyArg(), int64(1),
+				jobStatusWaiting, jobStatusRunning, jobStatusPaused},
+			affected: 1,
+			do: func(dao CronJobDAO) error {
+				return dao.Delete(context.Background(), 1)
+			},
+		},
+		{
+			name: "删除已经删除的任务",
+			sql:  "UPDATE `jobs` SET `status`=?,`update_at`=? WHERE id = ? AND status IN (?,?,?)",
+			args: []driver.Value{jobStatusEnd, sqlmock.AnyArg(), int64(1),
+				jobStatusWaiting, jobStatusRunning, jobStatusPaused},
+			do: func(dao CronJobDAO) error {
+				return dao.Delete(context.Background(), 1)
+			},
+			wantErr: ErrJobStatusConflict,
+		},
+		{
+			name: "恢复删除的任务",
+			sql: "UPDATE `jobs` SET `alert_threshold`=?,`cfg`=?,`executor`=?,`expression`=?,`fail_count`=?," +
+				"`max_retries`=?,`misfire`=?,`next_time`=?,`pending_window`=?,`retries`=?,`retry_backoff`=?," +
+				"`status`=?,`timeout`=?,`update_at`=? WHERE id = ? AND status IN (?)",
+			args: []driver.Value{0, "", "local", "@every 1h", 0, 0, 0, int64(1000), 0, 0, int64(0),
+				jobStatusWaiting, int64(0), sqlmock.AnyArg(), int64(1), jobStatusEnd},
+			affected: 1,
+			do: func(dao CronJobDAO) error {
+				return dao.Restore(context.Background(), Job{ID: 1, Executor: "local", Expression: "@every 1h", NextTime: 1000})
+			},
+		},
+		{
+			name: "恢复没有删除的任务",
+			sql: "UPDATE `jobs` SET `alert_threshold`=?,`cfg`=?,`executor`=?,`expression`=?,`fail_count`=?," +
+				"`max_retries`=?,`misfire`=?,`next_time`=?,`pending_window`=?,`retries`=?,`retry_backoff`=?," +
+				"`status`=?,`timeout`=?,`update_at`=? WHERE id = ? AND status IN (?)",
+			args: []driver.Value{0, "", "local", "@every 1h", 0, 0, 0, int64(1000), 0, 0, int64(0),
+				jobStatusWaiting, int64(0), sqlmock.AnyArg(), int64(1), jobStatusEnd},
+			do: func(dao CronJobDAO) error {
+				return dao.Restore(context.Background(), Job{ID: 1, Executor: "local", Expression: "@every 1h", NextTime: 1000})
+			},
+			wantErr: ErrJobStatusConflict,
+		},
+		{
+			name:     "立即执行等待中的任务",
+			sql:      "UPDATE `jobs` SET `next_time`=?,`update_at`=? WHERE id = ? AND status IN (?)",
+			args:     []driver.Value{sqlmock.AnyArg(), sqlmock.AnyArg(), int64(1), jobStatusWaiting},
+			affected: 1,
+			do: func(dao CronJobDAO) error {
+				return dao.RunNow(context.Background(), 1)
+			},
+		},
+		{
+			name: "立即执行正在运行的任务",
+			sql:  "UPDATE `jobs` SET `next_time`=?,`update_at`=? WHERE id = ? AND status IN (?)",
+			args: []driver.Value{sqlmock.AnyArg(), sqlmock.AnyArg(), int64(1), jobStatusWaiting},
+			do: func(dao CronJobDAO) error {
+				return dao.RunNow(context.Background(), 1)
+			},
+			wantErr: ErrJobStatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			mock.ExpectExec(tc.sql).WithArgs(tc.args...).WillReturnResult(sqlmock.NewResult(0, tc.affected))
+			db, err := initDB(sqlDB)
+			require.NoError(t, err)
+			err = tc.do(NewGormCronJobDAO(db))
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGormCronJobDAO_Trigger(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+
+		wantErr error
+	}{
+		{
+			name: "等待中的任务马上执行",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectExec("UPDATE `jobs` SET .* WHERE id = \\? AND status = \\? AND window_time < \\?").
+					WithArgs(sqlmock.AnyArg(), 0, sqlmock.AnyArg(), int64(2000), int64(1), jobStatusWaiting, int64(2000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		{
+			name: "正在执行的任务记下这个窗口",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectExec("UPDATE `jobs` SET .* WHERE id = \\? AND status = \\? AND window_time < \\?").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE `jobs` SET `pending_window`=\\? "+
+					"WHERE id = \\? AND status = \\? AND window_time < \\? AND pending_window < \\?").
+					WithArgs(int64(2000), int64(1), jobStatusRunning, int64(2000), int64(2000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		{
+			name: "已经触发过，或者任务被暂停了",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectExec("UPDATE `jobs` SET .* AND window_time < \\?").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE `jobs` SET `pending_window`=\\? .*").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			wantErr: ErrJobStatusConflict,
+		},
+		{
+			name: "数据库错误",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectExec("UPDATE `jobs` SET .*").WillReturnError(errors.New("db 错误"))
+				return db
+			},
+			wantErr: errors.New("db 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := initDB(tc.sqlmock(t))
+			require.NoError(t, err)
+			err = NewGormCronJobDAO(db).Trigger(context.Background(), 1, time.UnixMilli(2000))
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestGormCronJobDAO_Release(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	// 有更新的窗口并且不用重试了，就马上执行这个窗口
+	mock.ExpectExec("UPDATE `jobs` SET "+
+		"`next_time` = IF\\(`pending_window` > `window_time` AND `retries` = 0, \\?, `next_time`\\), "+
+		"`window_time` = IF\\(`pending_window` > `window_time` AND `retries` = 0, `pending_window`, `window_time`\\), "+
+		"`status` = \\?, `update_at` = \\? WHERE id = \\? AND status = \\?").
+		WithArgs(sqlmock.AnyArg(), jobStatusWaiting, sqlmock.AnyArg(), int64(1), jobStatusRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	db, err := initDB(sqlDB)
+	require.NoError(t, err)
+	assert.NoError(t, NewGormCronJobDAO(db).Release(context.Background(), 1))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,6 +4,7 @@ import (
 	"gorm.io/gorm"
 
 	"geektime-basic-go/webook/internal/repository/dao/article"
+	"geektime-basic-go/webook/pkg/outbox"
 )
 
 func InitTables(db *gorm.DB) error {
@@ -11,5 +12,15 @@ func InitTables(db *gorm.DB) error {
 		&User{},
 		&article.Article{},
 		&article.PublishedArticle{},
+		&article.ArticleRevision{},
+		&article.Tag{},
+		&article.ArticleTag{},
+		&article.PublishedArticleTag{},
+		&Job{},
+		&JobExecution{},
+		&JobDependency{},
+		&RankingSnapshot{},
+		&RankingSnapshotItem{},
+		&outbox.Message{},
 	)
 }
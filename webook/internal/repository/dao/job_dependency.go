@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobDependencyDAO 任务之间的依赖，都用任务的名字，因为名字创建之后不能修改
+type JobDependencyDAO interface {
+	// Insert 已经存在的依赖忽略
+	Insert(ctx context.Context, job string, upstreams []string) error
+	// Replace 用 upstreams 替换 job 原来的依赖，upstreams 为空就是删除所有的依赖
+	Replace(ctx context.Context, job string, upstreams []string) error
+	FindUpstreams(ctx context.Context, jobs []string) ([]JobDependency, error)
+	FindDownstreams(ctx context.Context, upstreams []string) ([]JobDependency, error)
+}
+
+type gormJobDependencyDAO struct {
+	db *gorm.DB
+}
+
+func NewGormJobDependencyDAO(db *gorm.DB) JobDependencyDAO {
+	return &gormJobDependencyDAO{db: db}
+}
+
+func (dao *gormJobDependencyDAO) Insert(ctx context.Context, job string, upstreams []string) error {
+	return dao.insert(dao.db.WithContext(ctx), job, upstreams)
+}
+
+func (dao *gormJobDependencyDAO) Replace(ctx context.Context, job string, upstreams []string) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job = ?", job).Delete(&JobDependency{}).Error; err != nil {
+			return err
+		}
+		return dao.insert(tx, job, upstreams)
+	})
+}
+
+func (dao *gormJobDependencyDAO) insert(db *gorm.DB, job string, upstreams []string) error {
+	if len(upstreams) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	deps := slice.Map(upstreams, func(idx int, src string) JobDependency {
+		return JobDependency{Job: job, Upstream: src, CreateAt: now}
+	})
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deps).Error
+}
+
+func (dao *gormJobDependencyDAO) FindUpstreams(ctx context.Context, jobs []string) ([]JobDependency, error) {
+	var res []JobDependency
+	err := dao.db.WithContext(ctx).Where("job IN ?", jobs).Order("id ASC").Find(&res).Error
+	return res, err
+}
+
+func (dao *gormJobDependencyDAO) FindDownstreams(ctx context.Context, upstreams []string) ([]JobDependency, error) {
+	var res []JobDependency
+	err := dao.db.WithContext(ctx).Where("upstream IN ?", upstreams).Order("id ASC").Find(&res).Error
+	return res, err
+}
+
+// JobDependency Job 依赖 Upstream
+type JobDependency struct {
+	ID       int64  `gorm:"primaryKey,autoIncrement"`
+	Job      string `gorm:"type:varchar(128);uniqueIndex:uk_job_upstream"`
+	Upstream string `gorm:"type:varchar(128);uniqueIndex:uk_job_upstream;index"`
+	CreateAt int64
+}
+
+func (JobDependency) TableName() string {
+	return "job_dependency"
+}
@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type JobExecutionDAO interface {
+	// Insert 开始执行的时候插入，返回执行记录的 ID
+	Insert(ctx context.Context, e JobExecution) (int64, error)
+	// Finish 执行结束之后记录结果
+	Finish(ctx context.Context, id int64, status uint8, errMsg string) error
+	// ListByJob 按照开始时间倒序
+	ListByJob(ctx context.Context, jobID int64, offset, limit int) ([]JobExecution, error)
+	// ListByWindow 这些任务在 window 这个窗口里面的所有执行记录
+	ListByWindow(ctx context.Context, jobIDs []int64, window int64) ([]JobExecution, error)
+}
+
+type gormJobExecutionDAO struct {
+	db *gorm.DB
+}
+
+func NewGormJobExecutionDAO(db *gorm.DB) JobExecutionDAO {
+	return &gormJobExecutionDAO{db: db}
+}
+
+func (dao *gormJobExecutionDAO) Insert(ctx context.Context, e JobExecution) (int64, error) {
+	err := dao.db.WithContext(ctx).Create(&e).Error
+	return e.ID, err
+}
+
+func (dao *gormJobExecutionDAO) Finish(ctx context.Context, id int64, status uint8, errMsg string) error {
+	return dao.db.WithContext(ctx).Model(&JobExecution{}).Where("id = ?", id).Updates(map[string]any{
+		"status": status,
+		"err":    errMsg,
+		"end_at": time.Now().UnixMilli(),
+	}).Error
+}
+
+func (dao *gormJobExecutionDAO) ListByJob(ctx context.Context, jobID int64, offset, limit int) ([]JobExecution, error) {
+	var res []JobExecution
+	err := dao.db.WithContext(ctx).Where("job_id = ?", jobID).
+		Order("start_at DESC, id DESC").
+		Offset(offset).Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *gormJobExecutionDAO) ListByWindow(ctx context.Context, jobIDs []int64, window int64) ([]JobExecution, error) {
+	var res []JobExecution
+	err := dao.db.WithContext(ctx).Where("job_id IN ? AND window_time = ?", jobIDs, window).
+		Order("id ASC").
+		Find(&res).Error
+	return res, err
+}
+
+// JobExecution 任务的一次执行
+type JobExecution struct {
+	ID      int64  `gorm:"primaryKey,autoIncrement"`
+	JobID   int64  `gorm:"index:idx_job_start_at"`
+	JobName string `gorm:"type:varchar(128)"`
+	// Node 执行任务的节点
+	Node    string `gorm:"type:varchar(128)"`
+	StartAt int64  `gorm:"index:idx_job_start_at"`
+	EndAt   int64
+	Status  uint8
+	Err     string `gorm:"type:varchar(4096)"`
+	// WindowTime 属于哪个窗口，毫秒数
+	WindowTime int64 `gorm:"index"`
+}
+
+func (JobExecution) TableName() string {
+	return "job_execution"
+}
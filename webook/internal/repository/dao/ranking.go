@@ -0,0 +1,84 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type RankingSnapshotDAO interface {
+	// Insert 快照和里面的条目在同一个事务里面写入，返回快照的 ID
+	Insert(ctx context.Context, s RankingSnapshot, items []RankingSnapshotItem) (int64, error)
+	// GetBefore 找出 board 在 at 或者 at 之前最新的一个快照
+	GetBefore(ctx context.Context, board string, at int64) (RankingSnapshot, []RankingSnapshotItem, error)
+	// DeleteBefore 删除 at 之前的快照，返回删除的快照数量
+	DeleteBefore(ctx context.Context, at int64) (int64, error)
+}
+
+type gormRankingSnapshotDAO struct {
+	db *gorm.DB
+}
+
+func NewGormRankingSnapshotDAO(db *gorm.DB) RankingSnapshotDAO {
+	return &gormRankingSnapshotDAO{db: db}
+}
+
+func (dao *gormRankingSnapshotDAO) Insert(ctx context.Context, s RankingSnapshot, items []RankingSnapshotItem) (int64, error) {
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&s).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for i := range items {
+			items[i].SnapshotID = s.ID
+		}
+		return tx.Create(&items).Error
+	})
+	return s.ID, err
+}
+
+func (dao *gormRankingSnapshotDAO) GetBefore(ctx context.Context, board string, at int64) (RankingSnapshot, []RankingSnapshotItem, error) {
+	db := dao.db.WithContext(ctx)
+	var s RankingSnapshot
+	err := db.Where("board = ? AND create_at <= ?", board, at).
+		Order("create_at DESC, id DESC").
+		First(&s).Error
+	if err != nil {
+		return RankingSnapshot{}, nil, err
+	}
+	var items []RankingSnapshotItem
+	err = db.Where("snapshot_id = ?", s.ID).Order("`rank` ASC").Find(&items).Error
+	return s, items, err
+}
+
+func (dao *gormRankingSnapshotDAO) DeleteBefore(ctx context.Context, at int64) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids := tx.Model(&RankingSnapshot{}).Select("id").Where("create_at < ?", at)
+		if err := tx.Where("snapshot_id IN (?)", ids).Delete(&RankingSnapshotItem{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("create_at < ?", at).Delete(&RankingSnapshot{})
+		cnt = res.RowsAffected
+		return res.Error
+	})
+	return cnt, err
+}
+
+// RankingSnapshot 每次计算榜单都会保存一个
+type RankingSnapshot struct {
+	ID       int64  `gorm:"primaryKey,autoIncrement"`
+	Board    string `gorm:"type:varchar(64);index:idx_board_create_at"`
+	CreateAt int64  `gorm:"index:idx_board_create_at;index"`
+}
+
+type RankingSnapshotItem struct {
+	ID         int64 `gorm:"primaryKey,autoIncrement"`
+	SnapshotID int64 `gorm:"index"`
+	Rank       int
+	BizID      int64
+	Score      float64
+	Title      string `gorm:"type:varchar(4096)"`
+}
@@ -0,0 +1,191 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGormRankingSnapshotDAO_Insert(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+		items   []RankingSnapshotItem
+
+		wantID  int64
+		wantErr error
+	}{
+		{
+			name: "快照和条目一起写入",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `ranking_snapshots` .*").WillReturnResult(sqlmock.NewResult(3, 1))
+				// 条目的 snapshot_id 是刚刚插入的快照的 ID
+				mock.ExpectExec("INSERT INTO `ranking_snapshot_items` .*").
+					WithArgs(int64(3), 1, int64(11), 2.5, "a", int64(3), 2, int64(12), 1.5, "b").
+					WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectCommit()
+				return db
+			},
+			items: []RankingSnapshotItem{
+				{Rank: 1, BizID: 11, Score: 2.5, Title: "a"},
+				{Rank: 2, BizID: 12, Score: 1.5, Title: "b"},
+			},
+			wantID: 3,
+		},
+		{
+			name: "空的榜单只写快照",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `ranking_snapshots` .*").WillReturnResult(sqlmock.NewResult(4, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			wantID: 4,
+		},
+		{
+			name: "写条目失败，整个回滚",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `ranking_snapshots` .*").WillReturnResult(sqlmock.NewResult(5, 1))
+				mock.ExpectExec("INSERT INTO `ranking_snapshot_items` .*").WillReturnError(errors.New("模拟插入失败"))
+				mock.ExpectRollback()
+				return db
+			},
+			items:   []RankingSnapshotItem{{Rank: 1, BizID: 11}},
+			wantID:  5,
+			wantErr: errors.New("模拟插入失败"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := initDB(tc.sqlmock(t))
+			require.NoError(t, err)
+			dao := NewGormRankingSnapshotDAO(db)
+			id, err := dao.Insert(context.Background(), RankingSnapshot{Board: "test", CreateAt: 1000}, tc.items)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantID, id)
+		})
+	}
+}
+
+func TestGormRankingSnapshotDAO_GetBefore(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+
+		wantSnapshot RankingSnapshot
+		wantItems    []RankingSnapshotItem
+		wantErr      error
+	}{
+		{
+			name: "找到了",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectQuery("SELECT \\* FROM `ranking_snapshots` WHERE board = \\? AND create_at <= \\? "+
+					"ORDER BY create_at DESC, id DESC").
+					WithArgs("test", int64(2000)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "board", "create_at"}).AddRow(7, "test", 1500))
+				mock.ExpectQuery("SELECT \\* FROM `ranking_snapshot_items` WHERE snapshot_id = \\? ORDER BY `rank` ASC").
+					WithArgs(int64(7)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "snapshot_id", "rank", "biz_id", "score", "title"}).
+						AddRow(1, 7, 1, 11, 2.5, "a").
+						AddRow(2, 7, 2, 12, 1.5, "b"))
+				return db
+			},
+			wantSnapshot: RankingSnapshot{ID: 7, Board: "test", CreateAt: 1500},
+			wantItems: []RankingSnapshotItem{
+				{ID: 1, SnapshotID: 7, Rank: 1, BizID: 11, Score: 2.5, Title: "a"},
+				{ID: 2, SnapshotID: 7, Rank: 2, BizID: 12, Score: 1.5, Title: "b"},
+			},
+		},
+		{
+			name: "没有快照",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectQuery("SELECT \\* FROM `ranking_snapshots`").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "board", "create_at"}))
+				return db
+			},
+			wantErr: ErrDataNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := initDB(tc.sqlmock(t))
+			require.NoError(t, err)
+			dao := NewGormRankingSnapshotDAO(db)
+			s, items, err := dao.GetBefore(context.Background(), "test", 2000)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantSnapshot, s)
+			assert.Equal(t, tc.wantItems, items)
+		})
+	}
+}
+
+func TestGormRankingSnapshotDAO_DeleteBefore(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sqlmock func(t *testing.T) *sql.DB
+
+		wantCnt int64
+		wantErr error
+	}{
+		{
+			name: "先删条目再删快照",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM `ranking_snapshot_items` WHERE snapshot_id IN " +
+					"\\(SELECT `id` FROM `ranking_snapshots` WHERE create_at < \\?\\)").
+					WithArgs(int64(1000)).
+					WillReturnResult(sqlmock.NewResult(0, 10))
+				mock.ExpectExec("DELETE FROM `ranking_snapshots` WHERE create_at < \\?").
+					WithArgs(int64(1000)).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+				return db
+			},
+			wantCnt: 2,
+		},
+		{
+			name: "删除条目失败",
+			sqlmock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM `ranking_snapshot_items`").WillReturnError(errors.New("模拟删除失败"))
+				mock.ExpectRollback()
+				return db
+			},
+			wantErr: errors.New("模拟删除失败"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := initDB(tc.sqlmock(t))
+			require.NoError(t, err)
+			dao := NewGormRankingSnapshotDAO(db)
+			cnt, err := dao.DeleteBefore(context.Background(), 1000)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCnt, cnt)
+		})
+	}
+}
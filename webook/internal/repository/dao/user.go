@@ -22,6 +22,8 @@ type UserDAO interface {
 	Insert(ctx context.Context, u User) error
 	Update(ctx context.Context, u User) error
 	FindByID(ctx context.Context, id int64) (User, error)
+	// FindByIDs 批量查询，找不到的用户不会返回
+	FindByIDs(ctx context.Context, ids []int64) ([]User, error)
 	FindByEmail(ctx context.Context, email string) (User, error)
 	FindByPhone(ctx context.Context, phone string) (User, error)
 	FindByWechat(ctx context.Context, openID string) (User, error)
@@ -82,6 +84,12 @@ func (ud *gormUserDAO) FindByID(ctx context.Context, id int64) (User, error) {
 	return u, err
 }
 
+func (ud *gormUserDAO) FindByIDs(ctx context.Context, ids []int64) ([]User, error) {
+	var us []User
+	err := ud.db.WithContext(ctx).Where("id IN ?", ids).Find(&us).Error
+	return us, err
+}
+
 func (ud *gormUserDAO) FindByPhone(ctx context.Context, phone string) (User, error) {
 	var u User
 	err := ud.db.WithContext(ctx).First(&u, "phone = ?", phone).Error
@@ -2,43 +2,103 @@ package repository
 
 import (
 	"context"
+	"time"
 
-	"github.com/ecodeclub/ekit/syncx/atomicx"
+	"github.com/ecodeclub/ekit/slice"
 
 	"geektime-basic-go/webook/internal/domain"
 	"geektime-basic-go/webook/internal/repository/cache/memory"
 	"geektime-basic-go/webook/internal/repository/cache/redis"
+	"geektime-basic-go/webook/internal/repository/dao"
 )
 
+var ErrRankingSnapshotNotFound = dao.ErrDataNotFound
+
+//go:generate mockgen -source=ranking.go -package=svcmocks -destination=mocks/ranking_mock_gen.go RankingRepository
 type RankingRepository interface {
-	ReplaceTopN(ctx context.Context, arts []domain.Article) error
-	GetTopN(ctx context.Context) ([]domain.Article, error)
+	ReplaceTopN(ctx context.Context, board string, arts []domain.Article) error
+	GetTopN(ctx context.Context, board string) ([]domain.Article, error)
+	// IncrLiveScores 增量模式下把 scores 累加到 bucket 这个桶里，桶从 bucket 开始算起保留 lifetime
+	IncrLiveScores(ctx context.Context, board string, bucket time.Time, scores map[int64]float64, lifetime time.Duration) error
+	// GetLiveTopN 按照 weights 加权合并 buckets 之后的前 n 个
+	GetLiveTopN(ctx context.Context, board string, buckets []time.Time, weights []float64, n int) ([]int64, error)
+	// ResetLive 对账，用全量计算的 scores 覆盖 buckets 里的各个桶
+	ResetLive(ctx context.Context, board string, buckets []time.Time, scores map[time.Time]map[int64]float64, lifetime time.Duration) error
+	// SaveSnapshot 保存一个快照，返回快照的 ID
+	SaveSnapshot(ctx context.Context, s domain.RankingSnapshot) (int64, error)
+	// GetSnapshot board 在 at 或者 at 之前最新的快照
+	GetSnapshot(ctx context.Context, board string, at time.Time) (domain.RankingSnapshot, error)
+	// DeleteSnapshotsBefore 删除 at 之前的快照，返回删除的数量
+	DeleteSnapshotsBefore(ctx context.Context, at time.Time) (int64, error)
 }
 
 type cacheRankingRepository struct {
-	Cache      *redis.RankingCache
-	localCache *memory.RankingCache
-	topN       atomicx.Value[[]domain.Article]
+	Cache       *redis.RankingCache
+	localCache  *memory.RankingCache
+	snapshotDAO dao.RankingSnapshotDAO
 }
 
-func NewCacheRankingRepository(cache *redis.RankingCache, localCache *memory.RankingCache) RankingRepository {
-	return &cacheRankingRepository{Cache: cache, localCache: localCache}
+func NewCacheRankingRepository(cache *redis.RankingCache, localCache *memory.RankingCache,
+	snapshotDAO dao.RankingSnapshotDAO) RankingRepository {
+	return &cacheRankingRepository{Cache: cache, localCache: localCache, snapshotDAO: snapshotDAO}
 }
 
-func (c *cacheRankingRepository) ReplaceTopN(ctx context.Context, arts []domain.Article) error {
-	_ = c.localCache.Set(ctx, arts)
-	return c.Cache.Set(ctx, arts)
+func (c *cacheRankingRepository) ReplaceTopN(ctx context.Context, board string, arts []domain.Article) error {
+	_ = c.localCache.Set(ctx, board, arts)
+	return c.Cache.Set(ctx, board, arts)
 }
 
-func (c *cacheRankingRepository) GetTopN(ctx context.Context) ([]domain.Article, error) {
-	arts, err := c.localCache.Get(ctx)
-	if err != nil {
+func (c *cacheRankingRepository) GetTopN(ctx context.Context, board string) ([]domain.Article, error) {
+	arts, err := c.localCache.Get(ctx, board)
+	if err == nil {
 		return arts, nil
 	}
-	arts, err = c.Cache.Get(ctx)
+	arts, err = c.Cache.Get(ctx, board)
+	if err != nil {
+		// redis 出问题的时候用本地缓存里过期的数据兜底
+		return c.localCache.ForceGet(ctx, board)
+	}
+	_ = c.localCache.Set(ctx, board, arts)
+	return arts, nil
+}
+
+func (c *cacheRankingRepository) IncrLiveScores(ctx context.Context, board string, bucket time.Time,
+	scores map[int64]float64, lifetime time.Duration) error {
+	return c.Cache.IncrLiveScores(ctx, board, bucket, scores, lifetime)
+}
+
+func (c *cacheRankingRepository) GetLiveTopN(ctx context.Context, board string, buckets []time.Time,
+	weights []float64, n int) ([]int64, error) {
+	return c.Cache.LiveTopN(ctx, board, buckets, weights, n)
+}
+
+func (c *cacheRankingRepository) ResetLive(ctx context.Context, board string, buckets []time.Time,
+	scores map[time.Time]map[int64]float64, lifetime time.Duration) error {
+	return c.Cache.ResetLive(ctx, board, buckets, scores, lifetime)
+}
+
+func (c *cacheRankingRepository) SaveSnapshot(ctx context.Context, s domain.RankingSnapshot) (int64, error) {
+	items := slice.Map(s.Items, func(idx int, src domain.RankingItem) dao.RankingSnapshotItem {
+		return dao.RankingSnapshotItem{Rank: src.Rank, BizID: src.BizID, Score: src.Score, Title: src.Title}
+	})
+	return c.snapshotDAO.Insert(ctx, dao.RankingSnapshot{Board: s.Board, CreateAt: s.CreateAt.UnixMilli()}, items)
+}
+
+func (c *cacheRankingRepository) GetSnapshot(ctx context.Context, board string, at time.Time) (domain.RankingSnapshot, error) {
+	s, items, err := c.snapshotDAO.GetBefore(ctx, board, at.UnixMilli())
 	if err != nil {
-		return c.localCache.ForceGet(ctx)
+		return domain.RankingSnapshot{}, err
 	}
-	_ = c.localCache.Set(ctx, arts)
-	return arts, err
+	return domain.RankingSnapshot{
+		ID:       s.ID,
+		Board:    s.Board,
+		CreateAt: time.UnixMilli(s.CreateAt),
+		Items: slice.Map(items, func(idx int, src dao.RankingSnapshotItem) domain.RankingItem {
+			return domain.RankingItem{BizID: src.BizID, Rank: src.Rank, Score: src.Score, Title: src.Title}
+		}),
+	}, nil
+}
+
+func (c *cacheRankingRepository) DeleteSnapshotsBefore(ctx context.Context, at time.Time) (int64, error) {
+	return c.snapshotDAO.DeleteBefore(ctx, at.UnixMilli())
 }
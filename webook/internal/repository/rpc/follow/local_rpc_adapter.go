@@ -0,0 +1,71 @@
+package follow
+
+import (
+	"context"
+
+	"github.com/ecodeclub/ekit/slice"
+	"google.golang.org/grpc"
+
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
+	"geektime-basic-go/webook/follow/domain"
+	"geektime-basic-go/webook/follow/service"
+)
+
+//go:generate mockgen -source=../../../../api/proto/gen/follow/follow_grpc.pb.go -package=followmocks -destination=mocks/follow_grpc_mock_gen.go FollowServiceClient
+
+// LocalRPCAdapter 把本地的 FollowService 适配成 gRPC 客户端，迁移期间在进程内调用
+type LocalRPCAdapter struct {
+	svc service.FollowService
+}
+
+func NewFollowServiceAdapter(svc service.FollowService) *LocalRPCAdapter {
+	return &LocalRPCAdapter{svc: svc}
+}
+
+func (local *LocalRPCAdapter) Follow(ctx context.Context, in *followv1.FollowRequest, opts ...grpc.CallOption) (*followv1.FollowResponse, error) {
+	err := local.svc.Follow(ctx, in.GetFollower(), in.GetFollowee())
+	return &followv1.FollowResponse{}, err
+}
+
+func (local *LocalRPCAdapter) Unfollow(ctx context.Context, in *followv1.UnfollowRequest, opts ...grpc.CallOption) (*followv1.UnfollowResponse, error) {
+	err := local.svc.Unfollow(ctx, in.GetFollower(), in.GetFollowee())
+	return &followv1.UnfollowResponse{}, err
+}
+
+func (local *LocalRPCAdapter) ListFollowers(ctx context.Context, in *followv1.ListFollowersRequest, opts ...grpc.CallOption) (*followv1.ListFollowersResponse, error) {
+	res, err := local.svc.ListFollowers(ctx, in.GetFollowee(), in.GetMinId(), int(in.GetLimit()))
+	if err != nil {
+		return &followv1.ListFollowersResponse{}, err
+	}
+	return &followv1.ListFollowersResponse{FollowRelations: local.toDTOs(res)}, nil
+}
+
+func (local *LocalRPCAdapter) ListFollowees(ctx context.Context, in *followv1.ListFolloweesRequest, opts ...grpc.CallOption) (*followv1.ListFolloweesResponse, error) {
+	res, err := local.svc.ListFollowees(ctx, in.GetFollower(), in.GetMinId(), int(in.GetLimit()))
+	if err != nil {
+		return &followv1.ListFolloweesResponse{}, err
+	}
+	return &followv1.ListFolloweesResponse{FollowRelations: local.toDTOs(res)}, nil
+}
+
+func (local *LocalRPCAdapter) GetFollowStatics(ctx context.Context, in *followv1.GetFollowStaticsRequest, opts ...grpc.CallOption) (*followv1.GetFollowStaticsResponse, error) {
+	res, err := local.svc.GetStatics(ctx, in.GetUid())
+	if err != nil {
+		return &followv1.GetFollowStaticsResponse{}, err
+	}
+	return &followv1.GetFollowStaticsResponse{Statics: &followv1.FollowStatics{
+		Followers: res.Followers,
+		Followees: res.Followees,
+	}}, nil
+}
+
+func (local *LocalRPCAdapter) toDTOs(relations []domain.FollowRelation) []*followv1.FollowRelation {
+	return slice.Map(relations, func(idx int, src domain.FollowRelation) *followv1.FollowRelation {
+		return &followv1.FollowRelation{
+			Id:       src.ID,
+			Follower: src.Follower,
+			Followee: src.Followee,
+			CreateAt: src.CreateAt.UnixMilli(),
+		}
+	})
+}
@@ -35,3 +35,43 @@ func (g *grpcInteractiveRPC) Get(ctx context.Context, in *intr.GetRequest, opts
 func (g *grpcInteractiveRPC) GetByIDs(ctx context.Context, in *intr.GetByIDsRequest, opts ...grpc.CallOption) (*intr.GetByIDsResponse, error) {
 	return g.rpc.GetByIDs(ctx, in)
 }
+
+func (g *grpcInteractiveRPC) GetByIDsForUser(ctx context.Context, in *intr.GetByIDsForUserRequest, opts ...grpc.CallOption) (*intr.GetByIDsForUserResponse, error) {
+	return g.rpc.GetByIDsForUser(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) Uncollect(ctx context.Context, in *intr.UncollectRequest, opts ...grpc.CallOption) (*intr.UncollectResponse, error) {
+	return g.rpc.Uncollect(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) CreateCollection(ctx context.Context, in *intr.CreateCollectionRequest, opts ...grpc.CallOption) (*intr.CreateCollectionResponse, error) {
+	return g.rpc.CreateCollection(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) RenameCollection(ctx context.Context, in *intr.RenameCollectionRequest, opts ...grpc.CallOption) (*intr.RenameCollectionResponse, error) {
+	return g.rpc.RenameCollection(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) DeleteCollection(ctx context.Context, in *intr.DeleteCollectionRequest, opts ...grpc.CallOption) (*intr.DeleteCollectionResponse, error) {
+	return g.rpc.DeleteCollection(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) ListCollections(ctx context.Context, in *intr.ListCollectionsRequest, opts ...grpc.CallOption) (*intr.ListCollectionsResponse, error) {
+	return g.rpc.ListCollections(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) ListCollectionItems(ctx context.Context, in *intr.ListCollectionItemsRequest, opts ...grpc.CallOption) (*intr.ListCollectionItemsResponse, error) {
+	return g.rpc.ListCollectionItems(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) MoveCollectionItem(ctx context.Context, in *intr.MoveCollectionItemRequest, opts ...grpc.CallOption) (*intr.MoveCollectionItemResponse, error) {
+	return g.rpc.MoveCollectionItem(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) SetCollectionPublic(ctx context.Context, in *intr.SetCollectionPublicRequest, opts ...grpc.CallOption) (*intr.SetCollectionPublicResponse, error) {
+	return g.rpc.SetCollectionPublic(ctx, in)
+}
+
+func (g *grpcInteractiveRPC) ListPublicCollections(ctx context.Context, in *intr.ListPublicCollectionsRequest, opts ...grpc.CallOption) (*intr.ListPublicCollectionsResponse, error) {
+	return g.rpc.ListPublicCollections(ctx, in)
+}
@@ -41,6 +41,46 @@ func (rpc *LoadBalanceRPC) GetByIDs(ctx context.Context, in *intr.GetByIDsReques
 	return rpc.selectClient().GetByIDs(ctx, in)
 }
 
+func (rpc *LoadBalanceRPC) GetByIDsForUser(ctx context.Context, in *intr.GetByIDsForUserRequest, opts ...grpc.CallOption) (*intr.GetByIDsForUserResponse, error) {
+	return rpc.selectClient().GetByIDsForUser(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) Uncollect(ctx context.Context, in *intr.UncollectRequest, opts ...grpc.CallOption) (*intr.UncollectResponse, error) {
+	return rpc.selectClient().Uncollect(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) CreateCollection(ctx context.Context, in *intr.CreateCollectionRequest, opts ...grpc.CallOption) (*intr.CreateCollectionResponse, error) {
+	return rpc.selectClient().CreateCollection(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) RenameCollection(ctx context.Context, in *intr.RenameCollectionRequest, opts ...grpc.CallOption) (*intr.RenameCollectionResponse, error) {
+	return rpc.selectClient().RenameCollection(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) DeleteCollection(ctx context.Context, in *intr.DeleteCollectionRequest, opts ...grpc.CallOption) (*intr.DeleteCollectionResponse, error) {
+	return rpc.selectClient().DeleteCollection(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) ListCollections(ctx context.Context, in *intr.ListCollectionsRequest, opts ...grpc.CallOption) (*intr.ListCollectionsResponse, error) {
+	return rpc.selectClient().ListCollections(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) ListCollectionItems(ctx context.Context, in *intr.ListCollectionItemsRequest, opts ...grpc.CallOption) (*intr.ListCollectionItemsResponse, error) {
+	return rpc.selectClient().ListCollectionItems(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) MoveCollectionItem(ctx context.Context, in *intr.MoveCollectionItemRequest, opts ...grpc.CallOption) (*intr.MoveCollectionItemResponse, error) {
+	return rpc.selectClient().MoveCollectionItem(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) SetCollectionPublic(ctx context.Context, in *intr.SetCollectionPublicRequest, opts ...grpc.CallOption) (*intr.SetCollectionPublicResponse, error) {
+	return rpc.selectClient().SetCollectionPublic(ctx, in)
+}
+
+func (rpc *LoadBalanceRPC) ListPublicCollections(ctx context.Context, in *intr.ListPublicCollectionsRequest, opts ...grpc.CallOption) (*intr.ListPublicCollectionsResponse, error) {
+	return rpc.selectClient().ListPublicCollections(ctx, in)
+}
+
 func (rpc *LoadBalanceRPC) selectClient() intr.InteractiveServiceClient {
 	num := rand.Int31n(100)
 	if num < rpc.threshold.Load() {
@@ -3,13 +3,19 @@ package interactive
 import (
 	"context"
 
+	"github.com/ecodeclub/ekit/slice"
 	"google.golang.org/grpc"
 
 	intr "geektime-basic-go/webook/api/proto/gen/interactive"
 	"geektime-basic-go/webook/interactive/domain"
+	intrgrpc "geektime-basic-go/webook/interactive/grpc"
 	"geektime-basic-go/webook/interactive/service"
 )
 
+//go:generate mockgen -source=../../../../api/proto/gen/interactive/interactive_grpc.pb.go -package=intrmocks -destination=mocks/interactive_grpc_mock_gen.go InteractiveServiceClient
+
+// LocalRPCAdapter 进程内调用 InteractiveService，错误和 gRPC 服务端一样用 intrgrpc.ToStatus 转换，
+// 调用方不需要关心请求有没有走网络
 type LocalRPCAdapter struct {
 	svc service.InteractiveService
 }
@@ -38,7 +44,7 @@ func (local *LocalRPCAdapter) Like(ctx context.Context, in *intr.LikeRequest, op
 
 func (local *LocalRPCAdapter) Collect(ctx context.Context, in *intr.CollectRequest, opts ...grpc.CallOption) (*intr.CollectResponse, error) {
 	err := local.svc.Collect(ctx, in.Biz, in.BizId, in.Cid, in.Uid)
-	return &intr.CollectResponse{}, err
+	return &intr.CollectResponse{}, intrgrpc.ToStatus(err)
 }
 
 func (local *LocalRPCAdapter) GetByIDs(ctx context.Context, in *intr.GetByIDsRequest, opts ...grpc.CallOption) (*intr.GetByIDsResponse, error) {
@@ -56,6 +62,97 @@ func (local *LocalRPCAdapter) GetByIDs(ctx context.Context, in *intr.GetByIDsReq
 	return &intr.GetByIDsResponse{Intrs: res}, nil
 }
 
+func (local *LocalRPCAdapter) GetByIDsForUser(ctx context.Context, in *intr.GetByIDsForUserRequest, opts ...grpc.CallOption) (*intr.GetByIDsForUserResponse, error) {
+	if len(in.GetIds()) == 0 {
+		return &intr.GetByIDsForUserResponse{}, nil
+	}
+	data, err := local.svc.GetByIDsForUser(ctx, in.GetBiz(), in.GetIds(), in.GetUid())
+	if err != nil {
+		return &intr.GetByIDsForUserResponse{}, err
+	}
+	res := make(map[int64]*intr.Interactive, len(data))
+	for k, v := range data {
+		res[k] = local.toDTO(v)
+	}
+	return &intr.GetByIDsForUserResponse{Intrs: res}, nil
+}
+
+func (local *LocalRPCAdapter) Uncollect(ctx context.Context, in *intr.UncollectRequest, opts ...grpc.CallOption) (*intr.UncollectResponse, error) {
+	err := local.svc.Uncollect(ctx, in.GetBiz(), in.GetBizId(), in.GetUid())
+	return &intr.UncollectResponse{}, err
+}
+
+func (local *LocalRPCAdapter) CreateCollection(ctx context.Context, in *intr.CreateCollectionRequest, opts ...grpc.CallOption) (*intr.CreateCollectionResponse, error) {
+	cid, err := local.svc.CreateCollection(ctx, domain.Collection{Uid: in.GetUid(), Name: in.GetName(), Public: in.GetPublic()})
+	return &intr.CreateCollectionResponse{Cid: cid}, err
+}
+
+func (local *LocalRPCAdapter) RenameCollection(ctx context.Context, in *intr.RenameCollectionRequest, opts ...grpc.CallOption) (*intr.RenameCollectionResponse, error) {
+	err := local.svc.RenameCollection(ctx, in.GetUid(), in.GetCid(), in.GetName())
+	return &intr.RenameCollectionResponse{}, intrgrpc.ToStatus(err)
+}
+
+func (local *LocalRPCAdapter) SetCollectionPublic(ctx context.Context, in *intr.SetCollectionPublicRequest, opts ...grpc.CallOption) (*intr.SetCollectionPublicResponse, error) {
+	err := local.svc.SetCollectionPublic(ctx, in.GetUid(), in.GetCid(), in.GetPublic())
+	return &intr.SetCollectionPublicResponse{}, intrgrpc.ToStatus(err)
+}
+
+func (local *LocalRPCAdapter) DeleteCollection(ctx context.Context, in *intr.DeleteCollectionRequest, opts ...grpc.CallOption) (*intr.DeleteCollectionResponse, error) {
+	err := local.svc.DeleteCollection(ctx, in.GetUid(), in.GetCid())
+	return &intr.DeleteCollectionResponse{}, intrgrpc.ToStatus(err)
+}
+
+func (local *LocalRPCAdapter) ListCollections(ctx context.Context, in *intr.ListCollectionsRequest, opts ...grpc.CallOption) (*intr.ListCollectionsResponse, error) {
+	cs, err := local.svc.ListCollections(ctx, in.GetUid())
+	if err != nil {
+		return &intr.ListCollectionsResponse{}, err
+	}
+	return &intr.ListCollectionsResponse{Collections: local.toCollectionDTOs(cs)}, nil
+}
+
+func (local *LocalRPCAdapter) ListPublicCollections(ctx context.Context, in *intr.ListPublicCollectionsRequest, opts ...grpc.CallOption) (*intr.ListPublicCollectionsResponse, error) {
+	cs, err := local.svc.ListPublicCollections(ctx, in.GetUid())
+	if err != nil {
+		return &intr.ListPublicCollectionsResponse{}, err
+	}
+	return &intr.ListPublicCollectionsResponse{Collections: local.toCollectionDTOs(cs)}, nil
+}
+
+func (local *LocalRPCAdapter) ListCollectionItems(ctx context.Context, in *intr.ListCollectionItemsRequest, opts ...grpc.CallOption) (*intr.ListCollectionItemsResponse, error) {
+	items, err := local.svc.ListCollectionItems(ctx, in.GetUid(), in.GetCid(), in.GetMaxId(), int(in.GetLimit()))
+	if err != nil {
+		return &intr.ListCollectionItemsResponse{}, intrgrpc.ToStatus(err)
+	}
+	return &intr.ListCollectionItemsResponse{Items: slice.Map(items, func(idx int, src domain.CollectionItem) *intr.CollectionItem {
+		return &intr.CollectionItem{
+			Id:       src.ID,
+			Cid:      src.Cid,
+			Biz:      src.Biz,
+			BizId:    src.BizID,
+			Uid:      src.Uid,
+			CreateAt: src.CreateAt.UnixMilli(),
+		}
+	})}, nil
+}
+
+func (local *LocalRPCAdapter) MoveCollectionItem(ctx context.Context, in *intr.MoveCollectionItemRequest, opts ...grpc.CallOption) (*intr.MoveCollectionItemResponse, error) {
+	err := local.svc.MoveCollectionItem(ctx, in.GetBiz(), in.GetBizId(), in.GetCid(), in.GetUid())
+	return &intr.MoveCollectionItemResponse{}, intrgrpc.ToStatus(err)
+}
+
+func (local *LocalRPCAdapter) toCollectionDTOs(cs []domain.Collection) []*intr.Collection {
+	return slice.Map(cs, func(idx int, src domain.Collection) *intr.Collection {
+		return &intr.Collection{
+			Id:       src.ID,
+			Uid:      src.Uid,
+			Name:     src.Name,
+			Public:   src.Public,
+			CreateAt: src.CreateAt.UnixMilli(),
+			UpdateAt: src.UpdateAt.UnixMilli(),
+		}
+	})
+}
+
 func (local *LocalRPCAdapter) toDTO(res domain.Interactive) *intr.Interactive {
 	return &intr.Interactive{
 		Biz:        res.Biz,
@@ -63,6 +160,7 @@ func (local *LocalRPCAdapter) toDTO(res domain.Interactive) *intr.Interactive {
 		ReadCnt:    res.ReadCnt,
 		LikeCnt:    res.LikeCnt,
 		CollectCnt: res.CollectCnt,
+		CommentCnt: res.CommentCnt,
 		Liked:      res.Liked,
 		Collected:  res.Collected,
 	}
@@ -0,0 +1,120 @@
+package interactive
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	intr "geektime-basic-go/webook/api/proto/gen/interactive"
+	"geektime-basic-go/webook/interactive/service"
+	svcmocks "geektime-basic-go/webook/interactive/service/mocks"
+)
+
+// TestLocalRPCAdapter_Status 进程内调用返回的错误码要和 gRPC 服务端一致
+func TestLocalRPCAdapter_Status(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.InteractiveService
+		call func(local *LocalRPCAdapter) error
+
+		wantCode codes.Code
+	}{
+		{
+			name: "收藏成功",
+			mock: func(ctrl *gomock.Controller) service.InteractiveService {
+				svc := svcmocks.NewMockInteractiveService(ctrl)
+				svc.EXPECT().Collect(gomock.Any(), "article", int64(1), int64(2), int64(3)).Return(nil)
+				return svc
+			},
+			call: func(local *LocalRPCAdapter) error {
+				_, err := local.Collect(context.Background(), &intr.CollectRequest{Biz: "article", BizId: 1, Cid: 2, Uid: 3})
+				return err
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name: "收藏到不存在的收藏夹",
+			mock: func(ctrl *gomock.Controller) service.InteractiveService {
+				svc := svcmocks.NewMockInteractiveService(ctrl)
+				svc.EXPECT().Collect(gomock.Any(), "article", int64(1), int64(2), int64(3)).
+					Return(service.ErrCollectionNotFound)
+				return svc
+			},
+			call: func(local *LocalRPCAdapter) error {
+				_, err := local.Collect(context.Background(), &intr.CollectRequest{Biz: "article", BizId: 1, Cid: 2, Uid: 3})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "已经收藏到了别的收藏夹",
+			mock: func(ctrl *gomock.Controller) service.InteractiveService {
+				svc := svcmocks.NewMockInteractiveService(ctrl)
+				svc.EXPECT().Collect(gomock.Any(), "article", int64(1), int64(2), int64(3)).
+					Return(service.ErrCollectedElsewhere)
+				return svc
+			},
+			call: func(local *LocalRPCAdapter) error {
+				_, err := local.Collect(context.Background(), &intr.CollectRequest{Biz: "article", BizId: 1, Cid: 2, Uid: 3})
+				return err
+			},
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name: "重命名不存在的收藏夹",
+			mock: func(ctrl *gomock.Controller) service.InteractiveService {
+				svc := svcmocks.NewMockInteractiveService(ctrl)
+				svc.EXPECT().RenameCollection(gomock.Any(), int64(3), int64(2), "新名字").
+					Return(service.ErrCollectionNotFound)
+				return svc
+			},
+			call: func(local *LocalRPCAdapter) error {
+				_, err := local.RenameCollection(context.Background(), &intr.RenameCollectionRequest{Uid: 3, Cid: 2, Name: "新名字"})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "移动没有收藏的内容",
+			mock: func(ctrl *gomock.Controller) service.InteractiveService {
+				svc := svcmocks.NewMockInteractiveService(ctrl)
+				svc.EXPECT().MoveCollectionItem(gomock.Any(), "article", int64(1), int64(2), int64(3)).
+					Return(service.ErrNotCollected)
+				return svc
+			},
+			call: func(local *LocalRPCAdapter) error {
+				_, err := local.MoveCollectionItem(context.Background(),
+					&intr.MoveCollectionItemRequest{Biz: "article", BizId: 1, Cid: 2, Uid: 3})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "其它错误",
+			mock: func(ctrl *gomock.Controller) service.InteractiveService {
+				svc := svcmocks.NewMockInteractiveService(ctrl)
+				svc.EXPECT().DeleteCollection(gomock.Any(), int64(3), int64(2)).Return(errors.New("db 错误"))
+				return svc
+			},
+			call: func(local *LocalRPCAdapter) error {
+				_, err := local.DeleteCollection(context.Background(), &intr.DeleteCollectionRequest{Uid: 3, Cid: 2})
+				return err
+			},
+			wantCode: codes.Unknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			local := NewInteractiveServiceAdapter(tc.mock(ctrl))
+			assert.Equal(t, tc.wantCode, status.Code(tc.call(local)))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"html"
+
+	"github.com/ecodeclub/ekit/slice"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/pkg/searchx"
+)
+
+const (
+	searchFieldTitle   = "title"
+	searchFieldContent = "content"
+)
+
+//go:generate mockgen -source=search.go -package=svcmocks -destination=mocks/search_mock_gen.go SearchRepository
+type SearchRepository interface {
+	// IndexArticle 新增或者更新已发表文章的索引
+	IndexArticle(ctx context.Context, art domain.Article) error
+	DeleteArticle(ctx context.Context, id int64) error
+	SearchArticle(ctx context.Context, query string, offset, limit int) (domain.ArticleSearchResult, error)
+}
+
+// indexSearchRepository 基于进程内的倒排索引，重启之后需要重建。
+// 索引不在实例之间共享，每个实例各自维护一份，见 ioc.InitSearchService
+type indexSearchRepository struct {
+	idx *searchx.Index[domain.Article]
+	hl  searchx.Highlighter
+}
+
+func NewIndexSearchRepository(idx *searchx.Index[domain.Article]) SearchRepository {
+	return &indexSearchRepository{
+		idx: idx,
+		hl: searchx.Highlighter{
+			Pre:          "<em>",
+			Post:         "</em>",
+			FragmentSize: 100,
+			Escape:       html.EscapeString,
+		},
+	}
+}
+
+func (repo *indexSearchRepository) IndexArticle(ctx context.Context, art domain.Article) error {
+	repo.idx.Put(searchx.Document[domain.Article]{
+		ID: art.ID,
+		Fields: map[string]string{
+			searchFieldTitle:   art.Title,
+			searchFieldContent: art.Content,
+		},
+		Payload: art,
+	})
+	return nil
+}
+
+func (repo *indexSearchRepository) DeleteArticle(ctx context.Context, id int64) error {
+	repo.idx.Delete(id)
+	return nil
+}
+
+func (repo *indexSearchRepository) SearchArticle(ctx context.Context, query string, offset, limit int) (domain.ArticleSearchResult, error) {
+	total, hits := repo.idx.Search(query, offset, limit)
+	return domain.ArticleSearchResult{
+		Total: total,
+		Hits: slice.Map(hits, func(idx int, src searchx.Hit[domain.Article]) domain.ArticleSearchHit {
+			return domain.ArticleSearchHit{
+				Article:  src.Payload,
+				Title:    repo.hl.Highlight(src.Payload.Title, query),
+				Abstract: repo.hl.Fragment(src.Payload.Content, query),
+			}
+		}),
+	}, nil
+}
@@ -21,6 +21,8 @@ type UserRepository interface {
 	Create(ctx context.Context, u domain.User) error
 	Update(ctx context.Context, u domain.User) error
 	FindByID(ctx context.Context, id int64) (domain.User, error)
+	// FindByIDs 批量查询，先查缓存，缓存里没有的一次性查数据库。找不到的用户不会出现在结果里
+	FindByIDs(ctx context.Context, ids []int64) (map[int64]domain.User, error)
 	FindByEmail(ctx context.Context, email string) (domain.User, error)
 	FindByPhone(ctx context.Context, phone string) (domain.User, error)
 	FindByWechat(ctx context.Context, openID string) (domain.User, error)
@@ -75,6 +77,37 @@ func (ur *userRepository) FindByID(ctx context.Context, id int64) (domain.User,
 	}
 }
 
+func (ur *userRepository) FindByIDs(ctx context.Context, ids []int64) (map[int64]domain.User, error) {
+	res := make(map[int64]domain.User, len(ids))
+	seen := make(map[int64]struct{}, len(ids))
+	missed := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		u, err := ur.cache.Get(ctx, id)
+		if err != nil {
+			missed = append(missed, id)
+			continue
+		}
+		res[id] = u
+	}
+	if len(missed) == 0 {
+		return res, nil
+	}
+	ues, err := ur.dao.FindByIDs(ctx, missed)
+	if err != nil {
+		return res, err
+	}
+	for _, ue := range ues {
+		u := ur.entityToDomain(ue)
+		res[u.ID] = u
+		_ = ur.cache.Set(ctx, u)
+	}
+	return res, nil
+}
+
 func (ur *userRepository) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
 	u, err := ur.dao.FindByPhone(ctx, phone)
 	return ur.entityToDomain(u), err
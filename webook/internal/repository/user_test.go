@@ -239,6 +239,74 @@ func TestUserRepository_FindByID(t *testing.T) {
 	}
 }
 
+func TestUserRepository_FindByIDs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache)
+		ids       []int64
+		wantUsers map[int64]domain.User
+		wantErr   error
+	}{
+		{
+			name: "部分命中缓存，没命中的一次查完",
+			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+				ud := daomocks.NewMockUserDAO(ctrl)
+				uc := cachemocks.NewMockUserCache(ctrl)
+				uc.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{ID: 1, Nickname: "Tom"}, nil)
+				uc.EXPECT().Get(gomock.Any(), int64(2)).Return(domain.User{}, cache.ErrKeyNotExist)
+				uc.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, cache.ErrKeyNotExist)
+				ud.EXPECT().FindByIDs(gomock.Any(), []int64{2, 3}).Return([]dao.User{
+					{ID: 2, Nickname: sql.NullString{String: "Jerry", Valid: true}},
+				}, nil)
+				uc.EXPECT().Set(gomock.Any(), gomock.Any()).Return(nil)
+				return ud, uc
+			},
+			ids: []int64{1, 2, 1, 3},
+			wantUsers: map[int64]domain.User{
+				1: {ID: 1, Nickname: "Tom"},
+				2: {ID: 2, Nickname: "Jerry", Birthday: time.Time{}, CreateAt: time.UnixMilli(0)},
+			},
+		},
+		{
+			name: "全部命中缓存",
+			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+				uc := cachemocks.NewMockUserCache(ctrl)
+				uc.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{ID: 1, Nickname: "Tom"}, nil)
+				return nil, uc
+			},
+			ids:       []int64{1},
+			wantUsers: map[int64]domain.User{1: {ID: 1, Nickname: "Tom"}},
+		},
+		{
+			name: "查询数据库失败，返回缓存里的部分",
+			mock: func(ctrl *gomock.Controller) (dao.UserDAO, cache.UserCache) {
+				ud := daomocks.NewMockUserDAO(ctrl)
+				uc := cachemocks.NewMockUserCache(ctrl)
+				uc.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{ID: 1, Nickname: "Tom"}, nil)
+				uc.EXPECT().Get(gomock.Any(), int64(2)).Return(domain.User{}, cache.ErrKeyNotExist)
+				ud.EXPECT().FindByIDs(gomock.Any(), []int64{2}).Return(nil, errors.New("db 错误"))
+				return ud, uc
+			},
+			ids:       []int64{1, 2},
+			wantUsers: map[int64]domain.User{1: {ID: 1, Nickname: "Tom"}},
+			wantErr:   errors.New("db 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ud, uc := tc.mock(ctrl)
+			repo := NewUserRepository(ud, uc)
+			us, err := repo.FindByIDs(context.Background(), tc.ids)
+			require.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUsers, us)
+		})
+	}
+}
+
 func TestUserRepository_FindByEmail(t *testing.T) {
 	nowMs := time.Now().UnixMilli()
 	now := time.UnixMilli(nowMs)
@@ -2,11 +2,20 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/ecodeclub/ekit/slice"
+	"golang.org/x/sync/errgroup"
 
 	"geektime-basic-go/webook/internal/domain"
 	events "geektime-basic-go/webook/internal/events/article"
 	"geektime-basic-go/webook/internal/repository"
+	"geektime-basic-go/webook/pkg/diffx"
+	"geektime-basic-go/webook/pkg/gormx"
 	"geektime-basic-go/webook/pkg/logger"
 )
 
@@ -17,24 +26,62 @@ type ArticleService interface {
 	Withdraw(ctx context.Context, uid, id int64) error
 	GetPublishedByID(ctx context.Context, id, uid int64) (domain.Article, error)
 	GetByID(ctx context.Context, id int64) (domain.Article, error)
-	List(ctx context.Context, id int64, offset int, limit int) ([]domain.Article, error)
-	ListPub(ctx context.Context, updateAt time.Time, offset, limit int) ([]domain.Article, error)
+	List(ctx context.Context, id int64, cursor domain.ArticleCursor, limit int) ([]domain.Article, error)
+	// ListPub 按 (update_at, id) 倒序翻页查询已发表的文章
+	ListPub(ctx context.Context, cursor domain.ArticleCursor, limit int) ([]domain.Article, error)
+	// ListPubByTag 某个标签下已发表的文章，翻页方式和 ListPub 一样
+	ListPubByTag(ctx context.Context, tag string, cursor domain.ArticleCursor, limit int) ([]domain.Article, error)
+	// ListPubByIDs 按照 ids 的顺序返回已发表的文章
+	ListPubByIDs(ctx context.Context, ids []int64) ([]domain.Article, error)
+	// SuggestTags 标签自动补全
+	SuggestTags(ctx context.Context, prefix string, limit int) ([]domain.Tag, error)
 	PubDetail(ctx context.Context, bizID int64, uid int64) (domain.Vo, error)
+	// GetInteractives 列表页批量查询文章的互动数据，以及 uid 是否点赞、收藏
+	GetInteractives(ctx context.Context, ids []int64, uid int64) (map[int64]domain.Interactive, error)
 	Collect(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error
+	Uncollect(ctx context.Context, biz string, bizID int64, uid int64) error
 	Like(ctx context.Context, biz string, bizID int64, uid int64, like bool) error
+	ListRevisions(ctx context.Context, uid, id int64, offset, limit int) ([]domain.ArticleRevision, error)
+	DiffRevisions(ctx context.Context, uid, id, from, to int64) (domain.ArticleRevisionDiff, error)
+	// RestoreRevision 把某个历史版本恢复成当前的草稿
+	RestoreRevision(ctx context.Context, uid, id, version int64) (int64, error)
+	// SchedulePublish 在 art.PublishAt 定时发表，重复调用就是修改发表时间
+	SchedulePublish(ctx context.Context, art domain.Article) (int64, error)
+	CancelSchedule(ctx context.Context, uid, id int64) error
+	// PublishScheduled 发表所有到期的定时文章，返回成功发表的数量
+	PublishScheduled(ctx context.Context, now time.Time, batchSize int) (int, error)
 }
 
+var (
+	ErrInvalidPublishTime = errors.New("定时发表的时间必须晚于当前时间")
+	ErrInvalidTags        = fmt.Errorf("每篇文章最多 %d 个标签，每个标签最多 %d 个字符", maxTagCnt, maxTagLen)
+	ErrRevisionNotFound   = repository.ErrRevisionNotFound
+	ErrInvalidLimit       = errors.New("分页大小必须大于 0")
+	// ErrNotScheduled 文章不存在、不是这个作者的，或者没有处于定时发表状态
+	ErrNotScheduled = repository.ErrPossibleIncorrectAuthor
+)
+
+const (
+	maxTagCnt = 5
+	maxTagLen = 20
+)
+
 type articleService struct {
 	repo     repository.ArticleRepository
 	logger   logger.Logger
 	producer events.Producer
+	tx       gormx.Transactor
 }
 
-func NewArticleService(repo repository.ArticleRepository, logger logger.Logger, producer events.Producer) ArticleService {
-	return &articleService{repo: repo, logger: logger, producer: producer}
+func NewArticleService(repo repository.ArticleRepository, logger logger.Logger, producer events.Producer, tx gormx.Transactor) ArticleService {
+	return &articleService{repo: repo, logger: logger, producer: producer, tx: tx}
 }
 
 func (svc *articleService) Save(ctx context.Context, art domain.Article) (int64, error) {
+	var err error
+	if art.Tags, err = normalizeTags(art.Tags); err != nil {
+		return 0, err
+	}
 	art.Status = domain.ArticleStatusUnpublished
 	if art.ID > 0 {
 		return art.ID, svc.repo.Update(ctx, art)
@@ -42,64 +89,239 @@ func (svc *articleService) Save(ctx context.Context, art domain.Article) (int64,
 	return svc.repo.Create(ctx, art)
 }
 
+func (svc *articleService) SchedulePublish(ctx context.Context, art domain.Article) (int64, error) {
+	if !art.PublishAt.After(time.Now()) {
+		return 0, ErrInvalidPublishTime
+	}
+	var err error
+	if art.Tags, err = normalizeTags(art.Tags); err != nil {
+		return 0, err
+	}
+	art.Status = domain.ArticleStatusScheduled
+	if art.ID > 0 {
+		return art.ID, svc.repo.Update(ctx, art)
+	}
+	return svc.repo.Create(ctx, art)
+}
+
+func (svc *articleService) CancelSchedule(ctx context.Context, uid, id int64) error {
+	return svc.repo.CancelSchedule(ctx, uid, id)
+}
+
+func (svc *articleService) PublishScheduled(ctx context.Context, now time.Time, batchSize int) (int, error) {
+	cnt := 0
+	for {
+		arts, err := svc.repo.ListScheduled(ctx, now, batchSize)
+		if err != nil {
+			return cnt, err
+		}
+		published := 0
+		for _, art := range arts {
+			err = svc.publishScheduled(ctx, art)
+			if errors.Is(err, repository.ErrScheduleChanged) {
+				// 作者刚刚修改或者取消了，还是定时状态的话下一批会查出新的版本
+				continue
+			}
+			if err != nil {
+				// 发表失败的文章依旧处于定时状态，下一次调度会再次尝试
+				svc.logger.Error("定时发表文章失败", logger.Int("aid", art.ID), logger.Error(err))
+				continue
+			}
+			published++
+		}
+		cnt += published
+		// 发表成功的文章不会再被查出来，所以这里不需要 offset
+		// 但是如果整批都失败了，就要避免死循环
+		if len(arts) < batchSize || published == 0 {
+			return cnt, nil
+		}
+	}
+}
+
+// publishScheduled 和 Publish 一样发出发表事件，但是只发表 ListScheduled 查出来的那个版本
+func (svc *articleService) publishScheduled(ctx context.Context, art domain.Article) error {
+	art.Status = domain.ArticleStatusPublished
+	return svc.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := svc.repo.PublishScheduled(ctx, art); err != nil {
+			return err
+		}
+		return svc.producer.ProducePublishEvent(ctx, svc.publishEvent(art.ID, art))
+	})
+}
+
 func (svc *articleService) Publish(ctx context.Context, art domain.Article) (int64, error) {
+	var err error
+	if art.Tags, err = normalizeTags(art.Tags); err != nil {
+		return 0, err
+	}
 	art.Status = domain.ArticleStatusPublished
-	return svc.repo.Sync(ctx, art)
+
+	var id int64
+	// 发表和发表事件在同一个事务里，事件由 outbox 保证最终会发出去
+	err = svc.tx.Transaction(ctx, func(ctx context.Context) error {
+		id, err = svc.repo.Sync(ctx, art)
+		if err != nil {
+			return err
+		}
+		return svc.producer.ProducePublishEvent(ctx, svc.publishEvent(id, art))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (svc *articleService) publishEvent(id int64, art domain.Article) events.PublishEvent {
+	return events.PublishEvent{
+		Aid:       id,
+		Uid:       art.Author.ID,
+		Title:     art.Title,
+		Abstract:  art.Abstract(),
+		PublishAt: time.Now().UnixMilli(),
+	}
 }
 
+// normalizeTags 去掉首尾空白、空标签和重复的标签，英文统一转为小写
+// nil 代表不修改标签，所以原样返回
+func normalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slice.Contains(res, tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLen {
+			return nil, ErrInvalidTags
+		}
+		res = append(res, tag)
+	}
+	if len(res) > maxTagCnt {
+		return nil, ErrInvalidTags
+	}
+	return res, nil
+}
+
+// Withdraw 撤回和撤回事件在同一个事务里，搜索等下游据此删除文章
 func (svc *articleService) Withdraw(ctx context.Context, uid, id int64) error {
-	return svc.repo.SyncStatus(ctx, uid, id, domain.ArticleStatusPrivate)
+	return svc.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := svc.repo.SyncStatus(ctx, uid, id, domain.ArticleStatusPrivate); err != nil {
+			return err
+		}
+		return svc.producer.ProduceWithdrawEvent(ctx, events.WithdrawEvent{Aid: id, Uid: uid})
+	})
 }
 
 func (svc *articleService) GetPublishedByID(ctx context.Context, id, uid int64) (domain.Article, error) {
 	res, err := svc.repo.GetPublishedByID(ctx, id)
-	defer func() {
-		go func() {
-			if err != nil {
-				return
-			}
+	if err != nil {
+		return domain.Article{}, err
+	}
+	svc.produceReadEvent(ctx, id, uid)
+	return res, nil
+}
 
-			if e := svc.producer.ProduceReadEvent(events.ReadEvent{Aid: id, Uid: uid}); err != nil {
-				svc.logger.Error("发送消息失败", logger.Int("uid", uid), logger.Int("aid", id), logger.Error(e))
-			}
-		}()
-	}()
-	return res, err
+// produceReadEvent 阅读事件写到 outbox 里，不会因为 kafka 不可用而丢失
+// 写失败了也不影响阅读
+func (svc *articleService) produceReadEvent(ctx context.Context, aid, uid int64) {
+	if err := svc.producer.ProduceReadEvent(ctx, events.ReadEvent{Aid: aid, Uid: uid}); err != nil {
+		svc.logger.Error("发送阅读事件失败", logger.Int("uid", uid), logger.Int("aid", aid), logger.Error(err))
+	}
 }
 
 func (svc *articleService) GetByID(ctx context.Context, id int64) (domain.Article, error) {
 	return svc.repo.GetById(ctx, id)
 }
 
-func (svc *articleService) List(ctx context.Context, author int64, offset int, limit int) ([]domain.Article, error) {
-	return svc.repo.List(ctx, author, offset, limit)
+func (svc *articleService) List(ctx context.Context, author int64, cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	return svc.repo.List(ctx, author, cursor, limit)
 }
 
-func (svc *articleService) ListPub(ctx context.Context, updateAt time.Time, offset, limit int) ([]domain.Article, error) {
-	return svc.repo.ListPub(ctx, updateAt, offset, limit)
+func (svc *articleService) ListPub(ctx context.Context, cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	return svc.repo.ListPub(ctx, cursor, limit)
 }
 
-func (svc *articleService) PubDetail(ctx context.Context, bizID int64, uid int64) (domain.Vo, error) {
-	res, err := svc.repo.PubDetail(ctx, bizID, uid)
-	defer func() {
-		go func() {
-			if err != nil {
-				return
-			}
+func (svc *articleService) ListPubByIDs(ctx context.Context, ids []int64) ([]domain.Article, error) {
+	return svc.repo.ListPubByIDs(ctx, ids)
+}
 
-			if e := svc.producer.ProduceReadEvent(events.ReadEvent{Aid: bizID, Uid: uid}); err != nil {
-				svc.logger.Error("发送消息失败", logger.Int("uid", uid), logger.Int("aid", bizID), logger.Error(e))
-			}
-		}()
-	}()
+func (svc *articleService) ListPubByTag(ctx context.Context, tag string, cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	return svc.repo.ListPubByTag(ctx, strings.ToLower(strings.TrimSpace(tag)), cursor, limit)
+}
 
-	return res, err
+func (svc *articleService) SuggestTags(ctx context.Context, prefix string, limit int) ([]domain.Tag, error) {
+	return svc.repo.SuggestTags(ctx, strings.ToLower(strings.TrimSpace(prefix)), limit)
+}
+
+func (svc *articleService) GetInteractives(ctx context.Context, ids []int64, uid int64) (map[int64]domain.Interactive, error) {
+	return svc.repo.GetInteractives(ctx, ids, uid)
+}
+
+func (svc *articleService) PubDetail(ctx context.Context, bizID int64, uid int64) (domain.Vo, error) {
+	res, err := svc.repo.PubDetail(ctx, bizID, uid)
+	if err != nil {
+		return domain.Vo{}, err
+	}
+	svc.produceReadEvent(ctx, bizID, uid)
+	return res, nil
 }
 
 func (svc *articleService) Collect(ctx context.Context, biz string, bizID int64, cid int64, uid int64) error {
 	return svc.repo.Collect(ctx, biz, bizID, cid, uid)
 }
 
+func (svc *articleService) Uncollect(ctx context.Context, biz string, bizID int64, uid int64) error {
+	return svc.repo.Uncollect(ctx, biz, bizID, uid)
+}
+
 func (svc *articleService) Like(ctx context.Context, biz string, bizID int64, uid int64, like bool) error {
 	return svc.repo.Like(ctx, biz, bizID, uid, like)
 }
+
+func (svc *articleService) ListRevisions(ctx context.Context, uid, id int64, offset, limit int) ([]domain.ArticleRevision, error) {
+	return svc.repo.ListRevisions(ctx, uid, id, offset, limit)
+}
+
+func (svc *articleService) DiffRevisions(ctx context.Context, uid, id, from, to int64) (domain.ArticleRevisionDiff, error) {
+	var (
+		eg       errgroup.Group
+		src, dst domain.ArticleRevision
+	)
+	eg.Go(func() (err error) {
+		src, err = svc.repo.GetRevision(ctx, uid, id, from)
+		return
+	})
+	eg.Go(func() (err error) {
+		dst, err = svc.repo.GetRevision(ctx, uid, id, to)
+		return
+	})
+	if err := eg.Wait(); err != nil {
+		return domain.ArticleRevisionDiff{}, err
+	}
+	return domain.ArticleRevisionDiff{From: src, To: dst, Lines: diffx.Lines(src.Content, dst.Content)}, nil
+}
+
+func (svc *articleService) RestoreRevision(ctx context.Context, uid, id, version int64) (int64, error) {
+	rev, err := svc.repo.GetRevision(ctx, uid, id, version)
+	if err != nil {
+		return 0, err
+	}
+	// 恢复本身也是一次保存，所以会产生一个新的历史版本
+	return svc.Save(ctx, domain.Article{
+		ID:      rev.ArticleID,
+		Title:   rev.Title,
+		Content: rev.Content,
+		Author:  domain.Author{ID: uid},
+	})
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
 	"geektime-basic-go/webook/internal/domain"
+	events "geektime-basic-go/webook/internal/events/article"
 	"geektime-basic-go/webook/internal/repository"
-	"geektime-basic-go/webook/internal/repository/mocks"
+	mocks "geektime-basic-go/webook/internal/repository/mocks"
+	"geektime-basic-go/webook/pkg/diffx"
+	"geektime-basic-go/webook/pkg/logger"
 )
 
 func TestArticleService_Save(t *testing.T) {
@@ -109,7 +114,7 @@ func TestArticleService_Save(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			svc := NewArticleService(tc.mock(ctrl), nil, nil)
+			svc := NewArticleService(tc.mock(ctrl), nil, nil, nil)
 			id, err := svc.Save(context.Background(), tc.art)
 			require.Equal(t, tc.wantErr, err)
 			require.Equal(t, tc.wantID, id)
@@ -173,10 +178,257 @@ func TestArticleService_Publish(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			svc := NewArticleService(tc.mock(ctrl), nil, nil)
+			svc := NewArticleService(tc.mock(ctrl), nil, nopProducer{}, nopTransactor{})
 			id, err := svc.Publish(context.Background(), tc.art)
 			require.Equal(t, tc.wantErr, err)
 			require.Equal(t, tc.wantID, id)
 		})
 	}
 }
+
+func TestArticleService_PublishScheduled(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	scheduled := func(id int64) domain.Article {
+		return domain.Article{ID: id, Title: "定时", Author: domain.Author{ID: 123}, Status: domain.ArticleStatusScheduled,
+			UpdateAt: now.Add(-time.Hour), PublishAt: now}
+	}
+	published := func(id int64) domain.Article {
+		art := scheduled(id)
+		art.Status = domain.ArticleStatusPublished
+		return art
+	}
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) repository.ArticleRepository
+		wantCnt int
+		wantErr error
+	}{
+		{
+			name: "发表查出来的版本",
+			mock: func(ctrl *gomock.Controller) repository.ArticleRepository {
+				repo := mocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().ListScheduled(gomock.Any(), now, 2).Return([]domain.Article{scheduled(1)}, nil)
+				repo.EXPECT().PublishScheduled(gomock.Any(), published(1)).Return(nil)
+				return repo
+			},
+			wantCnt: 1,
+		},
+		{
+			name: "查出来之后被修改了，跳过",
+			mock: func(ctrl *gomock.Controller) repository.ArticleRepository {
+				repo := mocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().ListScheduled(gomock.Any(), now, 2).Return([]domain.Article{scheduled(1), scheduled(2)}, nil)
+				repo.EXPECT().PublishScheduled(gomock.Any(), published(1)).Return(repository.ErrScheduleChanged)
+				repo.EXPECT().PublishScheduled(gomock.Any(), published(2)).Return(nil)
+				repo.EXPECT().ListScheduled(gomock.Any(), now, 2).Return(nil, nil)
+				return repo
+			},
+			wantCnt: 1,
+		},
+		{
+			name: "整批都失败，不会死循环",
+			mock: func(ctrl *gomock.Controller) repository.ArticleRepository {
+				repo := mocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().ListScheduled(gomock.Any(), now, 2).Return([]domain.Article{scheduled(1), scheduled(2)}, nil)
+				repo.EXPECT().PublishScheduled(gomock.Any(), gomock.Any()).Return(errors.New("模拟失败")).Times(2)
+				return repo
+			},
+		},
+		{
+			name: "查询失败",
+			mock: func(ctrl *gomock.Controller) repository.ArticleRepository {
+				repo := mocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().ListScheduled(gomock.Any(), now, 2).Return(nil, errors.New("模拟失败"))
+				return repo
+			},
+			wantErr: errors.New("模拟失败"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewArticleService(tc.mock(ctrl), logger.NewNoOpLogger(), nopProducer{}, nopTransactor{})
+			cnt, err := svc.PublishScheduled(context.Background(), now, 2)
+			require.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCnt, cnt)
+		})
+	}
+}
+
+func TestArticleService_DiffRevisions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) repository.ArticleRepository
+		from, to int64
+
+		wantErr   error
+		wantLines []diffx.Line
+	}{
+		{
+			name: "比较成功",
+			mock: func(ctrl *gomock.Controller) repository.ArticleRepository {
+				repo := mocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().GetRevision(gomock.Any(), int64(123), int64(1), int64(1)).
+					Return(domain.ArticleRevision{ArticleID: 1, Version: 1, Content: "a\nb"}, nil)
+				repo.EXPECT().GetRevision(gomock.Any(), int64(123), int64(1), int64(2)).
+					Return(domain.ArticleRevision{ArticleID: 1, Version: 2, Content: "a\nc"}, nil)
+				return repo
+			},
+			from: 1,
+			to:   2,
+			wantLines: []diffx.Line{
+				{Op: diffx.OpEqual, Text: "a"},
+				{Op: diffx.OpDelete, Text: "b"},
+				{Op: diffx.OpInsert, Text: "c"},
+			},
+		},
+		{
+			name: "版本不存在",
+			mock: func(ctrl *gomock.Controller) repository.ArticleRepository {
+				repo := mocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().GetRevision(gomock.Any(), int64(123), int64(1), int64(1)).
+					Return(domain.ArticleRevision{ArticleID: 1, Version: 1, Content: "a"}, nil)
+				repo.EXPECT().GetRevision(gomock.Any(), int64(123), int64(1), int64(3)).
+					Return(domain.ArticleRevision{}, repository.ErrRevisionNotFound)
+				return repo
+			},
+			from:    1,
+			to:      3,
+			wantErr: ErrRevisionNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewArticleService(tc.mock(ctrl), nil, nil, nil)
+			diff, err := svc.DiffRevisions(context.Background(), 123, 1, tc.from, tc.to)
+			require.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.from, diff.From.Version)
+			assert.Equal(t, tc.to, diff.To.Version)
+			assert.Equal(t, tc.wantLines, diff.Lines)
+		})
+	}
+}
+
+func TestArticleService_RestoreRevision(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.ArticleRepository
+
+		wantErr error
+		wantID  int64
+	}{
+		{
+			name: "恢复成草稿",
+			mock: func(ctrl *gomock.Controller) repository.ArticleRepository {
+				repo := mocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().GetRevision(gomock.Any(), int64(123), int64(1), int64(2)).
+					Return(domain.ArticleRevision{ArticleID: 1, Version: 2, Title: "旧标题", Content: "旧内容",
+						Status: domain.ArticleStatusPublished}, nil)
+				// 恢复的时候不管历史版本是什么状态，都是未发表的草稿
+				repo.EXPECT().Update(gomock.Any(), domain.Article{
+					ID:      1,
+					Title:   "旧标题",
+					Content: "旧内容",
+					Status:  domain.ArticleStatusUnpublished,
+					Author:  domain.Author{ID: 123},
+				}).Return(nil)
+				return repo
+			},
+			wantID: 1,
+		},
+		{
+			name: "版本不存在",
+			mock: func(ctrl *gomock.Controller) repository.ArticleRepository {
+				repo := mocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().GetRevision(gomock.Any(), int64(123), int64(1), int64(2)).
+					Return(domain.ArticleRevision{}, repository.ErrRevisionNotFound)
+				return repo
+			},
+			wantErr: ErrRevisionNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewArticleService(tc.mock(ctrl), nil, nil, nil)
+			id, err := svc.RestoreRevision(context.Background(), 123, 1, 2)
+			require.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantID, id)
+		})
+	}
+}
+
+// nopTransactor 直接在当前 ctx 里面执行，单元测试不需要真的事务
+type nopTransactor struct{}
+
+func (nopTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type nopProducer struct{}
+
+func (nopProducer) ProduceReadEvent(ctx context.Context, evt events.ReadEvent) error {
+	return nil
+}
+
+func (nopProducer) ProducePublishEvent(ctx context.Context, evt events.PublishEvent) error {
+	return nil
+}
+
+func (nopProducer) ProduceWithdrawEvent(ctx context.Context, evt events.WithdrawEvent) error {
+	return nil
+}
+
+func TestArticleService_ListInvalidLimit(t *testing.T) {
+	testCases := []struct {
+		name string
+		list func(svc ArticleService, limit int) error
+	}{
+		{
+			name: "作者的文章",
+			list: func(svc ArticleService, limit int) error {
+				_, err := svc.List(context.Background(), 123, domain.ArticleCursor{}, limit)
+				return err
+			},
+		},
+		{
+			name: "已发表的文章",
+			list: func(svc ArticleService, limit int) error {
+				_, err := svc.ListPub(context.Background(), domain.ArticleCursor{}, limit)
+				return err
+			},
+		},
+		{
+			name: "标签下的文章",
+			list: func(svc ArticleService, limit int) error {
+				_, err := svc.ListPubByTag(context.Background(), "go", domain.ArticleCursor{}, limit)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// 不会查询数据库
+			svc := NewArticleService(mocks.NewMockArticleRepository(ctrl), nil, nopProducer{}, nopTransactor{})
+			assert.Equal(t, ErrInvalidLimit, tc.list(svc, 0))
+			assert.Equal(t, ErrInvalidLimit, tc.list(svc, -1))
+		})
+	}
+}
@@ -10,7 +10,7 @@ import (
 	"go.uber.org/mock/gomock"
 
 	"geektime-basic-go/webook/internal/repository"
-	"geektime-basic-go/webook/internal/repository/mocks"
+	mocks "geektime-basic-go/webook/internal/repository/mocks"
 	"geektime-basic-go/webook/internal/service/sms"
 	smsMocks "geektime-basic-go/webook/internal/service/sms/mocks"
 )
@@ -2,8 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/ecodeclub/ekit/slice"
+
 	"geektime-basic-go/webook/internal/domain"
 	"geektime-basic-go/webook/internal/repository"
 	"geektime-basic-go/webook/pkg/logger"
@@ -12,10 +17,55 @@ import (
 //go:generate mockgen -source=cron_job.go -package=svcmocks -destination=mocks/cron_job_mock_gen.go CronJobService
 type CronJobService interface {
 	Preempt(ctx context.Context) (domain.CronJob, error)
+	// AddJob 同名的任务已经存在的时候返回 ErrCronJobNameConflict
 	AddJob(ctx context.Context, j domain.CronJob) error
-	ResetNextTime(ctx context.Context, j domain.CronJob) error
+	// RegisterJob 启动的时候注册代码里面的任务。已经存在的任务保持不变，后台修改过的配置和依赖不会被覆盖；
+	// 被删除的任务按照 j 恢复，所以代码注册的任务删除之后下次启动又会调度，要停掉只能暂停
+	RegisterJob(ctx context.Context, j domain.CronJob) error
+	// Complete 一次执行结束之后调用，execErr 为 nil 代表执行成功。
+	// 失败了还有重试次数就按照退避时间重试，否则按照 Misfire 计算下次执行的时间。
+	// 返回更新之后的任务，FailCount 是连续失败的次数
+	Complete(ctx context.Context, j domain.CronJob, execErr error) (domain.CronJob, error)
+
+	GetByID(ctx context.Context, id int64) (domain.CronJob, error)
+	List(ctx context.Context, offset, limit int) ([]domain.CronJob, error)
+	// Update 修改执行方式、配置和表达式，按照新的表达式重新计算下次执行时间
+	Update(ctx context.Context, j domain.CronJob) error
+	Pause(ctx context.Context, id int64) error
+	// Resume 恢复暂停的任务，从现在开始计算下次执行时间
+	Resume(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id int64) error
+	// RunNow 让等待中的任务马上被调度一次
+	RunNow(ctx context.Context, id int64) error
+
+	// StartExecution 开始执行的时候调用，返回执行记录的 ID
+	StartExecution(ctx context.Context, j domain.CronJob, node string) (int64, error)
+	// FinishExecution err 为 nil 代表执行成功，ErrJobInterrupted 代表调度器退出的时候被中断
+	FinishExecution(ctx context.Context, id int64, err error) error
+	ListExecutions(ctx context.Context, jobID int64, offset, limit int) ([]domain.JobExecution, error)
+
+	// TriggerDownstream j 在 j.Window 这个窗口里面执行结束，不会再重试之后调用。
+	// 成功了就触发上游都成功了的下游任务，失败了就让所有的下游任务跳过这个窗口
+	TriggerDownstream(ctx context.Context, j domain.CronJob, succeeded bool) error
+	// Workflow 任务所在的整个 DAG 在 window 这个窗口里面的执行情况，window 为零值的时候用任务最近一次执行的窗口
+	Workflow(ctx context.Context, id int64, window time.Time) (domain.WorkflowRun, error)
 }
 
+var (
+	ErrInvalidCronExpression = errors.New("cron 表达式不合法")
+	ErrCronJobNotFound       = repository.ErrCronJobNotFound
+	// ErrCronJobStatusConflict 比如说恢复一个没有暂停的任务
+	ErrCronJobStatusConflict = repository.ErrCronJobStatusConflict
+	ErrCronJobNameConflict   = repository.ErrCronJobNameConflict
+	// ErrJobDependencyCycle 依赖自己，或者上游直接间接地依赖了这个任务
+	ErrJobDependencyCycle = errors.New("任务依赖有环")
+	// ErrJobInterrupted 调度器退出的时候任务还没有执行完
+	ErrJobInterrupted = errors.New("任务执行被中断")
+)
+
+// maxExecutionErrLen 执行记录里面错误信息的最大长度，按照字符计算
+const maxExecutionErrLen = 4096
+
 type cronJobService struct {
 	repo            repository.CronJobRepository
 	l               logger.Logger
@@ -23,17 +73,300 @@ type cronJobService struct {
 }
 
 func (svc *cronJobService) AddJob(ctx context.Context, j domain.CronJob) error {
-	j.NextTime = j.Next(time.Now())
+	if err := svc.check(ctx, j); err != nil {
+		return err
+	}
+	j.NextTime = j.Schedule(time.Now())
 	return svc.repo.AddJob(ctx, j)
 }
 
-func (svc *cronJobService) ResetNextTime(ctx context.Context, j domain.CronJob) error {
-	if t := j.Next(time.Now()); !t.IsZero() {
-		return svc.repo.UpdateNextTime(ctx, j.ID, t)
+func (svc *cronJobService) RegisterJob(ctx context.Context, j domain.CronJob) error {
+	err := svc.AddJob(ctx, j)
+	if !errors.Is(err, ErrCronJobNameConflict) {
+		return err
+	}
+	jobs, err := svc.repo.GetByNames(ctx, []string{j.Name})
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 || jobs[0].Status != domain.CronJobStatusEnd {
+		return nil
+	}
+	svc.l.Warn("恢复被删除的任务", logger.String("name", j.Name), logger.Int("id", jobs[0].ID))
+	j.ID = jobs[0].ID
+	j.NextTime = j.Schedule(time.Now())
+	err = svc.repo.Restore(ctx, j)
+	if errors.Is(err, ErrCronJobStatusConflict) {
+		// 别的实例已经恢复了
+		return nil
+	}
+	return err
+}
+
+// check 有上游的任务不需要表达式
+func (svc *cronJobService) check(ctx context.Context, j domain.CronJob) error {
+	if !j.Dependent() {
+		if err := j.ValidExpression(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidCronExpression, err)
+		}
+		return nil
+	}
+	return svc.checkCycle(ctx, j)
+}
+
+// checkCycle 沿着上游一层一层往上找，找到 j 自己就是有环。还没有创建的上游任务忽略
+func (svc *cronJobService) checkCycle(ctx context.Context, j domain.CronJob) error {
+	visited := map[string]bool{}
+	names := j.Upstreams
+	for len(names) > 0 {
+		next := make([]string, 0, len(names))
+		for _, name := range names {
+			if name == j.Name {
+				return fmt.Errorf("%w: %s", ErrJobDependencyCycle, j.Name)
+			}
+			if !visited[name] {
+				visited[name] = true
+				next = append(next, name)
+			}
+		}
+		ups, err := svc.repo.GetByNames(ctx, next)
+		if err != nil {
+			return err
+		}
+		names = names[:0:0]
+		for _, up := range ups {
+			names = append(names, up.Upstreams...)
+		}
 	}
 	return nil
 }
 
+func (svc *cronJobService) GetByID(ctx context.Context, id int64) (domain.CronJob, error) {
+	return svc.repo.GetByID(ctx, id)
+}
+
+func (svc *cronJobService) List(ctx context.Context, offset, limit int) ([]domain.CronJob, error) {
+	return svc.repo.List(ctx, offset, limit)
+}
+
+// Update 名字不能修改，检查依赖和替换上游都用数据库里面的名字
+func (svc *cronJobService) Update(ctx context.Context, j domain.CronJob) error {
+	old, err := svc.repo.GetByID(ctx, j.ID)
+	if err != nil {
+		return err
+	}
+	j.Name = old.Name
+	if err = svc.check(ctx, j); err != nil {
+		return err
+	}
+	j.NextTime = j.Schedule(time.Now())
+	return svc.repo.Update(ctx, j)
+}
+
+func (svc *cronJobService) Pause(ctx context.Context, id int64) error {
+	return svc.repo.Pause(ctx, id)
+}
+
+func (svc *cronJobService) Resume(ctx context.Context, id int64) error {
+	j, err := svc.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return svc.repo.Resume(ctx, id, j.Schedule(time.Now()))
+}
+
+func (svc *cronJobService) Delete(ctx context.Context, id int64) error {
+	return svc.repo.Delete(ctx, id)
+}
+
+func (svc *cronJobService) RunNow(ctx context.Context, id int64) error {
+	return svc.repo.RunNow(ctx, id)
+}
+
+func (svc *cronJobService) StartExecution(ctx context.Context, j domain.CronJob, node string) (int64, error) {
+	return svc.repo.AddExecution(ctx, domain.JobExecution{
+		JobID:   j.ID,
+		JobName: j.Name,
+		Node:    node,
+		StartAt: time.Now(),
+		Status:  domain.JobExecutionStatusRunning,
+		Window:  j.Window,
+	})
+}
+
+func (svc *cronJobService) FinishExecution(ctx context.Context, id int64, err error) error {
+	if err == nil {
+		return svc.repo.FinishExecution(ctx, id, domain.JobExecutionStatusSuccess, "")
+	}
+	msg := err.Error()
+	if rs := []rune(msg); len(rs) > maxExecutionErrLen {
+		msg = string(rs[:maxExecutionErrLen])
+	}
+	status := domain.JobExecutionStatusFailed
+	if errors.Is(err, ErrJobInterrupted) {
+		status = domain.JobExecutionStatusInterrupted
+	}
+	return svc.repo.FinishExecution(ctx, id, status, msg)
+}
+
+func (svc *cronJobService) ListExecutions(ctx context.Context, jobID int64, offset, limit int) ([]domain.JobExecution, error) {
+	return svc.repo.ListExecutions(ctx, jobID, offset, limit)
+}
+
+func (svc *cronJobService) TriggerDownstream(ctx context.Context, j domain.CronJob, succeeded bool) error {
+	downs, err := svc.repo.FindDownstreams(ctx, []string{j.Name})
+	if err != nil {
+		return err
+	}
+	for _, d := range downs {
+		if succeeded {
+			err = errors.Join(err, svc.trigger(ctx, d, j.Window))
+		} else {
+			err = errors.Join(err, svc.skip(ctx, d, j))
+		}
+	}
+	return err
+}
+
+// trigger 所有的上游在 window 里面都有成功的执行才触发
+func (svc *cronJobService) trigger(ctx context.Context, d domain.CronJob, window time.Time) error {
+	ups, err := svc.repo.GetByNames(ctx, d.Upstreams)
+	if err != nil {
+		return err
+	}
+	if len(ups) < len(d.Upstreams) {
+		// 有上游还没有创建，永远凑不齐
+		return nil
+	}
+	execs, err := svc.repo.ListExecutionsByWindow(ctx, slice.Map(ups, func(idx int, src domain.CronJob) int64 {
+		return src.ID
+	}), window)
+	if err != nil {
+		return err
+	}
+	succeeded := make(map[int64]bool, len(ups))
+	for _, e := range execs {
+		if e.Status == domain.JobExecutionStatusSuccess {
+			succeeded[e.JobID] = true
+		}
+	}
+	if len(succeeded) < len(ups) {
+		return nil
+	}
+	err = svc.repo.Trigger(ctx, d.ID, window)
+	if errors.Is(err, ErrCronJobStatusConflict) {
+		// 别的上游已经触发了；或者下游被暂停、删除了，那么就错过这个窗口
+		svc.l.Warn("没有触发下游任务", logger.Int("id", d.ID), logger.Int("window", window.UnixMilli()))
+		return nil
+	}
+	return err
+}
+
+// skip 下游跳过这个窗口，并且继续往下传递
+func (svc *cronJobService) skip(ctx context.Context, d domain.CronJob, failed domain.CronJob) error {
+	err := svc.repo.Skip(ctx, d.ID, failed.Window)
+	if errors.Is(err, ErrCronJobStatusConflict) {
+		// 已经因为别的上游失败跳过了
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = svc.repo.AddExecution(ctx, domain.JobExecution{
+		JobID:   d.ID,
+		JobName: d.Name,
+		StartAt: now,
+		EndAt:   now,
+		Status:  domain.JobExecutionStatusSkipped,
+		Err:     fmt.Sprintf("上游任务 %s 执行失败", failed.Name),
+		Window:  failed.Window,
+	})
+	if err != nil {
+		return err
+	}
+	d.Window = failed.Window
+	return svc.TriggerDownstream(ctx, d, false)
+}
+
+func (svc *cronJobService) Workflow(ctx context.Context, id int64, window time.Time) (domain.WorkflowRun, error) {
+	j, err := svc.repo.GetByID(ctx, id)
+	if err != nil {
+		return domain.WorkflowRun{}, err
+	}
+	if window.IsZero() {
+		window = j.Window
+	}
+	jobs, err := svc.connected(ctx, j)
+	if err != nil {
+		return domain.WorkflowRun{}, err
+	}
+	execs, err := svc.repo.ListExecutionsByWindow(ctx, slice.Map(jobs, func(idx int, src domain.CronJob) int64 {
+		return src.ID
+	}), window)
+	if err != nil {
+		return domain.WorkflowRun{}, err
+	}
+	return domain.NewWorkflowRun(window, jobs, execs), nil
+}
+
+// connected 和 j 连通的所有任务，上游的上游、下游的下游，还有下游的其它上游
+func (svc *cronJobService) connected(ctx context.Context, j domain.CronJob) ([]domain.CronJob, error) {
+	visited := map[string]bool{j.Name: true}
+	res := []domain.CronJob{j}
+	frontier := []domain.CronJob{j}
+	for len(frontier) > 0 {
+		names := slice.Map(frontier, func(idx int, src domain.CronJob) string {
+			return src.Name
+		})
+		downs, err := svc.repo.FindDownstreams(ctx, names)
+		if err != nil {
+			return nil, err
+		}
+		var upNames []string
+		for _, f := range frontier {
+			upNames = append(upNames, f.Upstreams...)
+		}
+		ups, err := svc.repo.GetByNames(ctx, upNames)
+		if err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0:0]
+		for _, n := range append(downs, ups...) {
+			if !visited[n.Name] {
+				visited[n.Name] = true
+				res = append(res, n)
+				frontier = append(frontier, n)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (svc *cronJobService) Complete(ctx context.Context, j domain.CronJob, execErr error) (domain.CronJob, error) {
+	now := time.Now()
+	if execErr == nil {
+		j.FailCount = 0
+	} else {
+		j.FailCount++
+	}
+	if execErr != nil && j.Retries < j.MaxRetries {
+		j.Retries++
+		j.NextTime = j.RetryAt(now, j.Retries)
+		return j, svc.repo.Complete(ctx, j)
+	}
+	// 成功了，或者重试次数用完了，都等下一次调度
+	j.Retries = 0
+	next := j.NextFire(now)
+	if next.IsZero() {
+		// 表达式解析不了，不能让任务一直被调度，等人来修改表达式
+		svc.l.Error("计算下次执行时间失败", logger.Int("id", j.ID), logger.String("expression", j.Expression))
+		next = now.Add(24 * time.Hour)
+	}
+	j.NextTime = next
+	return j, svc.repo.Complete(ctx, j)
+}
+
 func NewCronJobService(repo repository.CronJobRepository, l logger.Logger) CronJobService {
 	return &cronJobService{repo: repo, l: l, refreshInterval: 10 * time.Second}
 }
@@ -45,22 +378,33 @@ func (svc *cronJobService) Preempt(ctx context.Context) (domain.CronJob, error)
 	}
 
 	ticker := time.NewTicker(svc.refreshInterval)
+	done := make(chan struct{})
 	go func() {
 		// 这边要启动一个 goroutine 开始续约，也就是在持续占有期间
 		// 假定说我们这里是十秒钟续约一次
-		for range ticker.C {
-			svc.refresh(j.ID)
+		for {
+			select {
+			case <-ticker.C:
+				svc.refresh(j.ID)
+			case <-done:
+				return
+			}
 		}
 	}()
 
-	// 只能调用一次，也就是放弃续约。这时候要把状态还原回去
+	// 放弃续约，这时候要把状态还原回去。
+	// 退出的时候调度器可能会提前释放，所以多次调用只有第一次生效
+	var once sync.Once
 	j.CancelFunc = func() {
-		ticker.Stop()
-		releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
-		if e := svc.repo.Release(releaseCtx, j.ID); e != nil {
-			svc.l.Error("释放任务失败", logger.Int("id", j.ID), logger.Error(e))
-		}
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if e := svc.repo.Release(releaseCtx, j.ID); e != nil {
+				svc.l.Error("释放任务失败", logger.Int("id", j.ID), logger.Error(e))
+			}
+		})
 	}
 	return j, nil
 }
@@ -0,0 +1,527 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/repository"
+	repomocks "geektime-basic-go/webook/internal/repository/mocks"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func TestCronJobService_Complete(t *testing.T) {
+	window := time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local)
+	testCases := []struct {
+		name    string
+		job     domain.CronJob
+		execErr error
+		repoErr error
+
+		wantRetries   int
+		wantFailCount int
+		// wantNext 根据调用的时间计算下次执行的时间
+		wantNext func(now time.Time) time.Time
+		wantErr  error
+	}{
+		{
+			name: "执行成功，清空连续失败的次数",
+			job: domain.CronJob{ID: 1, Expression: "@every 1h", FailCount: 2,
+				MaxRetries: 3, Window: window},
+			wantNext: func(now time.Time) time.Time {
+				return now.Add(time.Hour).Truncate(time.Second)
+			},
+		},
+		{
+			name: "失败了还有重试次数",
+			job: domain.CronJob{ID: 1, Expression: "@every 1h", FailCount: 2,
+				MaxRetries: 3, Retries: 1, RetryBackoff: time.Minute, Window: window},
+			execErr:       errors.New("执行失败"),
+			wantRetries:   2,
+			wantFailCount: 3,
+			wantNext: func(now time.Time) time.Time {
+				return now.Add(2 * time.Minute)
+			},
+		},
+		{
+			name: "重试次数用完了，等下一次调度",
+			job: domain.CronJob{ID: 1, Expression: "@every 1h", FailCount: 3,
+				MaxRetries: 3, Retries: 3, Window: window},
+			execErr:       errors.New("执行失败"),
+			wantFailCount: 4,
+			wantNext: func(now time.Time) time.Time {
+				return now.Add(time.Hour).Truncate(time.Second)
+			},
+		},
+		{
+			name: "补上错过的，从窗口开始算",
+			job: domain.CronJob{ID: 1, Expression: "0 * * * * ?", Misfire: domain.MisfireCatchUp,
+				Retries: 2, MaxRetries: 2, Window: window, NextTime: window.Add(10 * time.Minute)},
+			execErr:       errors.New("执行失败"),
+			wantFailCount: 1,
+			wantNext: func(now time.Time) time.Time {
+				return window.Add(time.Minute)
+			},
+		},
+		{
+			name: "下游任务等上游触发",
+			job: domain.CronJob{ID: 1, Upstreams: []string{"up"}, Window: window,
+				NextTime: window},
+			wantNext: func(now time.Time) time.Time {
+				return time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+			},
+		},
+		{
+			name:          "表达式不合法，一天之后再调度",
+			job:           domain.CronJob{ID: 1, Expression: "abc", Window: window},
+			execErr:       errors.New("执行失败"),
+			wantFailCount: 1,
+			wantNext: func(now time.Time) time.Time {
+				return now.Add(24 * time.Hour)
+			},
+		},
+		{
+			name:    "保存失败",
+			job:     domain.CronJob{ID: 1, Expression: "@every 1h", Window: window},
+			repoErr: errors.New("db 错误"),
+			wantNext: func(now time.Time) time.Time {
+				return now.Add(time.Hour).Truncate(time.Second)
+			},
+			wantErr: errors.New("db 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := repomocks.NewMockCronJobRepository(ctrl)
+			var saved domain.CronJob
+			repo.EXPECT().Complete(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, j domain.CronJob) error {
+					saved = j
+					return tc.repoErr
+				})
+			svc := NewCronJobService(repo, logger.NewNoOpLogger())
+
+			before := time.Now()
+			j, err := svc.Complete(context.Background(), tc.job, tc.execErr)
+			after := time.Now()
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, saved, j)
+			assert.Equal(t, tc.wantRetries, j.Retries)
+			assert.Equal(t, tc.wantFailCount, j.FailCount)
+			assert.Equal(t, tc.job.Window, j.Window)
+			// 下次执行的时间落在调用前后两个时间点算出来的范围里面
+			assert.False(t, j.NextTime.Before(tc.wantNext(before)))
+			assert.False(t, j.NextTime.After(tc.wantNext(after)))
+		})
+	}
+}
+
+func TestCronJobService_FinishExecution(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+
+		wantStatus domain.JobExecutionStatus
+		wantErr    string
+	}{
+		{
+			name:       "成功",
+			wantStatus: domain.JobExecutionStatusSuccess,
+		},
+		{
+			name:       "失败",
+			err:        errors.New("执行失败"),
+			wantStatus: domain.JobExecutionStatusFailed,
+			wantErr:    "执行失败",
+		},
+		{
+			name:       "调度器退出被中断",
+			err:        errors.Join(ErrJobInterrupted, context.Canceled),
+			wantStatus: domain.JobExecutionStatusInterrupted,
+			wantErr:    errors.Join(ErrJobInterrupted, context.Canceled).Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := repomocks.NewMockCronJobRepository(ctrl)
+			repo.EXPECT().FinishExecution(gomock.Any(), int64(1), tc.wantStatus, tc.wantErr).Return(nil)
+			svc := NewCronJobService(repo, logger.NewNoOpLogger())
+			assert.NoError(t, svc.FinishExecution(context.Background(), 1, tc.err))
+		})
+	}
+}
+
+func TestCronJobService_AddJob(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.CronJobRepository
+		job  domain.CronJob
+
+		wantErr error
+	}{
+		{
+			name: "按照表达式调度",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().AddJob(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, j domain.CronJob) error {
+						assert.True(t, j.NextTime.After(time.Now()))
+						return nil
+					})
+				return repo
+			},
+			job: domain.CronJob{Name: "a", Expression: "@every 1h"},
+		},
+		{
+			name: "表达式不合法",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				return repomocks.NewMockCronJobRepository(ctrl)
+			},
+			job:     domain.CronJob{Name: "a", Expression: "abc"},
+			wantErr: ErrInvalidCronExpression,
+		},
+		{
+			name: "依赖自己",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				return repomocks.NewMockCronJobRepository(ctrl)
+			},
+			job:     domain.CronJob{Name: "a", Upstreams: []string{"a"}},
+			wantErr: ErrJobDependencyCycle,
+		},
+		{
+			name: "间接依赖自己",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"b"}).
+					Return([]domain.CronJob{{Name: "b", Upstreams: []string{"c"}}}, nil)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"c"}).
+					Return([]domain.CronJob{{Name: "c", Upstreams: []string{"a"}}}, nil)
+				return repo
+			},
+			job:     domain.CronJob{Name: "a", Upstreams: []string{"b"}},
+			wantErr: ErrJobDependencyCycle,
+		},
+		{
+			name: "菱形依赖不是环",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"b", "c"}).Return([]domain.CronJob{
+					{Name: "b", Upstreams: []string{"d"}},
+					{Name: "c", Upstreams: []string{"d"}},
+				}, nil)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"d"}).Return([]domain.CronJob{{Name: "d"}}, nil)
+				repo.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(nil)
+				return repo
+			},
+			job: domain.CronJob{Name: "a", Upstreams: []string{"b", "c"}},
+		},
+		{
+			name: "上游还没有创建，等上游触发",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"b"}).Return([]domain.CronJob{}, nil)
+				repo.EXPECT().AddJob(gomock.Any(), domain.CronJob{
+					Name:      "a",
+					Upstreams: []string{"b"},
+					NextTime:  time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+				}).Return(nil)
+				return repo
+			},
+			job: domain.CronJob{Name: "a", Upstreams: []string{"b"}},
+		},
+		{
+			name: "名字冲突",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(ErrCronJobNameConflict)
+				return repo
+			},
+			job:     domain.CronJob{Name: "a", Expression: "@every 1h"},
+			wantErr: ErrCronJobNameConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewCronJobService(tc.mock(ctrl), logger.NewNoOpLogger())
+			err := svc.AddJob(context.Background(), tc.job)
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestCronJobService_Update(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.CronJobRepository
+		// job 请求里面的名字不可信
+		job domain.CronJob
+
+		wantErr error
+	}{
+		{
+			name: "用数据库里面的名字替换上游",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{ID: 1, Name: "a"}, nil)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"b"}).Return([]domain.CronJob{{Name: "b"}}, nil)
+				repo.EXPECT().Update(gomock.Any(), domain.CronJob{
+					ID:        1,
+					Name:      "a",
+					Executor:  "local",
+					Upstreams: []string{"b"},
+					NextTime:  time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+				}).Return(nil)
+				return repo
+			},
+			job: domain.CronJob{ID: 1, Name: "x", Executor: "local", Upstreams: []string{"b"}},
+		},
+		{
+			name: "用数据库里面的名字检查环",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{ID: 1, Name: "a"}, nil)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"b"}).
+					Return([]domain.CronJob{{Name: "b", Upstreams: []string{"a"}}}, nil)
+				return repo
+			},
+			job:     domain.CronJob{ID: 1, Upstreams: []string{"b"}},
+			wantErr: ErrJobDependencyCycle,
+		},
+		{
+			name: "任务不存在",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{}, ErrCronJobNotFound)
+				return repo
+			},
+			job:     domain.CronJob{ID: 1, Expression: "@every 1h"},
+			wantErr: ErrCronJobNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewCronJobService(tc.mock(ctrl), logger.NewNoOpLogger())
+			err := svc.Update(context.Background(), tc.job)
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestCronJobService_RegisterJob(t *testing.T) {
+	j := domain.CronJob{Name: "a", Executor: "local", Expression: "@every 1h"}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.CronJobRepository
+
+		wantErr error
+	}{
+		{
+			name: "新的任务",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(nil)
+				return repo
+			},
+		},
+		{
+			name: "已经存在的任务不修改",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(ErrCronJobNameConflict)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"a"}).
+					Return([]domain.CronJob{{ID: 1, Name: "a", Status: domain.CronJobStatusPaused}}, nil)
+				return repo
+			},
+		},
+		{
+			name: "恢复被删除的任务",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(ErrCronJobNameConflict)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"a"}).
+					Return([]domain.CronJob{{ID: 1, Name: "a", Executor: "http", Status: domain.CronJobStatusEnd}}, nil)
+				repo.EXPECT().Restore(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, restored domain.CronJob) error {
+						// 用代码里面的配置恢复
+						assert.Equal(t, int64(1), restored.ID)
+						assert.Equal(t, "local", restored.Executor)
+						assert.True(t, restored.NextTime.After(time.Now()))
+						return nil
+					})
+				return repo
+			},
+		},
+		{
+			name: "别的实例已经恢复了",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(ErrCronJobNameConflict)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"a"}).
+					Return([]domain.CronJob{{ID: 1, Name: "a", Status: domain.CronJobStatusEnd}}, nil)
+				repo.EXPECT().Restore(gomock.Any(), gomock.Any()).Return(ErrCronJobStatusConflict)
+				return repo
+			},
+		},
+		{
+			name: "数据库错误",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(errors.New("db 错误"))
+				return repo
+			},
+			wantErr: errors.New("db 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewCronJobService(tc.mock(ctrl), logger.NewNoOpLogger())
+			err := svc.RegisterJob(context.Background(), j)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestCronJobService_TriggerDownstream(t *testing.T) {
+	window := time.UnixMilli(1000)
+	up := domain.CronJob{ID: 1, Name: "a", Window: window}
+	down := domain.CronJob{ID: 3, Name: "c", Upstreams: []string{"a", "b"}}
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) repository.CronJobRepository
+		succeeded bool
+
+		wantErr error
+	}{
+		{
+			name: "上游都成功了，触发下游",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"a"}).Return([]domain.CronJob{down}, nil)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"a", "b"}).
+					Return([]domain.CronJob{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}, nil)
+				repo.EXPECT().ListExecutionsByWindow(gomock.Any(), []int64{1, 2}, window).Return([]domain.JobExecution{
+					{JobID: 1, Status: domain.JobExecutionStatusSuccess},
+					{JobID: 2, Status: domain.JobExecutionStatusFailed},
+					{JobID: 2, Status: domain.JobExecutionStatusSuccess},
+				}, nil)
+				repo.EXPECT().Trigger(gomock.Any(), int64(3), window).Return(nil)
+				return repo
+			},
+			succeeded: true,
+		},
+		{
+			name: "还有上游没有成功",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"a"}).Return([]domain.CronJob{down}, nil)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"a", "b"}).
+					Return([]domain.CronJob{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}, nil)
+				repo.EXPECT().ListExecutionsByWindow(gomock.Any(), []int64{1, 2}, window).Return([]domain.JobExecution{
+					{JobID: 1, Status: domain.JobExecutionStatusSuccess},
+					{JobID: 2, Status: domain.JobExecutionStatusRunning},
+				}, nil)
+				return repo
+			},
+			succeeded: true,
+		},
+		{
+			name: "有上游还没有创建",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"a"}).Return([]domain.CronJob{down}, nil)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"a", "b"}).
+					Return([]domain.CronJob{{ID: 1, Name: "a"}}, nil)
+				return repo
+			},
+			succeeded: true,
+		},
+		{
+			name: "已经被别的上游触发了",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"a"}).Return([]domain.CronJob{down}, nil)
+				repo.EXPECT().GetByNames(gomock.Any(), []string{"a", "b"}).
+					Return([]domain.CronJob{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}, nil)
+				repo.EXPECT().ListExecutionsByWindow(gomock.Any(), []int64{1, 2}, window).Return([]domain.JobExecution{
+					{JobID: 1, Status: domain.JobExecutionStatusSuccess},
+					{JobID: 2, Status: domain.JobExecutionStatusSuccess},
+				}, nil)
+				repo.EXPECT().Trigger(gomock.Any(), int64(3), window).Return(ErrCronJobStatusConflict)
+				return repo
+			},
+			succeeded: true,
+		},
+		{
+			name: "失败了，下游跳过并且继续往下传递",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"a"}).Return([]domain.CronJob{down}, nil)
+				repo.EXPECT().Skip(gomock.Any(), int64(3), window).Return(nil)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"c"}).
+					Return([]domain.CronJob{{ID: 4, Name: "d", Upstreams: []string{"c"}}}, nil)
+				repo.EXPECT().Skip(gomock.Any(), int64(4), window).Return(nil)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"d"}).Return([]domain.CronJob{}, nil)
+				// 每个跳过的任务都有一条执行记录，原因是最开始失败的上游
+				var skipped []int64
+				repo.EXPECT().AddExecution(gomock.Any(), gomock.Any()).Times(2).
+					DoAndReturn(func(ctx context.Context, e domain.JobExecution) (int64, error) {
+						skipped = append(skipped, e.JobID)
+						assert.Equal(t, domain.JobExecutionStatusSkipped, e.Status)
+						assert.Equal(t, window, e.Window)
+						assert.Equal(t, "上游任务 "+map[int64]string{3: "a", 4: "c"}[e.JobID]+" 执行失败", e.Err)
+						return int64(len(skipped)), nil
+					})
+				return repo
+			},
+		},
+		{
+			name: "已经因为别的上游失败跳过了",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"a"}).Return([]domain.CronJob{down}, nil)
+				repo.EXPECT().Skip(gomock.Any(), int64(3), window).Return(ErrCronJobStatusConflict)
+				return repo
+			},
+		},
+		{
+			name: "查询下游失败",
+			mock: func(ctrl *gomock.Controller) repository.CronJobRepository {
+				repo := repomocks.NewMockCronJobRepository(ctrl)
+				repo.EXPECT().FindDownstreams(gomock.Any(), []string{"a"}).Return(nil, errors.New("db 错误"))
+				return repo
+			},
+			succeeded: true,
+			wantErr:   errors.New("db 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewCronJobService(tc.mock(ctrl), logger.NewNoOpLogger())
+			err := svc.TriggerDownstream(context.Background(), up, tc.succeeded)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
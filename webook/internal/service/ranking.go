@@ -2,7 +2,8 @@ package service
 
 import (
 	"context"
-	"math"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ecodeclub/ekit/queue"
@@ -13,10 +14,28 @@ import (
 	"geektime-basic-go/webook/internal/repository"
 )
 
+var (
+	ErrUnknownRankingBoard     = errors.New("榜单不存在")
+	ErrRankingSnapshotNotFound = repository.ErrRankingSnapshotNotFound
+)
+
 //go:generate mockgen -source=ranking.go -package=svcmocks -destination=mocks/ranking_mock_gen.go RankingService
 type RankingService interface {
+	// RankTopN 重新计算所有的榜单，增量模式的榜单顺便对账
 	RankTopN(ctx context.Context) error
-	TopN(ctx context.Context) ([]domain.Article, error)
+	TopN(ctx context.Context, board string) ([]domain.Article, error)
+	// Incr 增量模式下根据互动事件更新榜单
+	Incr(ctx context.Context, evts []domain.RankingEvent) error
+	// Snapshot 在 at 或者 at 之前最新的一次计算结果
+	Snapshot(ctx context.Context, board string, at time.Time) (domain.RankingSnapshot, error)
+	// DiffSnapshots 比较 from 和 to 两个时刻的快照
+	DiffSnapshots(ctx context.Context, board string, from, to time.Time) (domain.RankingDiff, error)
+}
+
+// RankingConfig SnapshotRetention 为 0 的时候快照一直保留
+type RankingConfig struct {
+	Boards            []RankingBoard
+	SnapshotRetention time.Duration
 }
 
 type batchRankingService struct {
@@ -24,52 +43,234 @@ type batchRankingService struct {
 	intrClient intr.InteractiveServiceClient
 	repo       repository.RankingRepository
 	BatchSize  int
-	N          int
-	soreFunc   func(likeCnt int64, updateAt time.Time) float64
+	// boards 按照业务分组
+	boards map[string][]RankingBoard
+	names  map[string]RankingBoard
+	// retention 快照保留的时间
+	retention time.Duration
+	now       func() time.Time
 }
 
-func NewBatchRankingService(artSvc ArticleService, intrClient intr.InteractiveServiceClient, repo repository.RankingRepository) RankingService {
-	svc := &batchRankingService{artSvc: artSvc, intrClient: intrClient, repo: repo, BatchSize: 100, N: 100}
-	svc.soreFunc = svc.score
+func NewBatchRankingService(artSvc ArticleService, intrClient intr.InteractiveServiceClient,
+	repo repository.RankingRepository, cfg RankingConfig) RankingService {
+	svc := &batchRankingService{artSvc: artSvc, intrClient: intrClient, repo: repo, BatchSize: 100,
+		boards: make(map[string][]RankingBoard), names: make(map[string]RankingBoard, len(cfg.Boards)),
+		retention: cfg.SnapshotRetention, now: time.Now}
+	for _, b := range cfg.Boards {
+		// Scorer 不支持增量计算的话只能定时全量计算
+		if _, ok := b.Scorer.(IncrementalScorer); !ok || b.Bucket <= 0 {
+			b.Incremental = false
+		}
+		svc.boards[b.Biz] = append(svc.boards[b.Biz], b)
+		svc.names[b.Name] = b
+	}
 	return svc
 }
 
 func (svc *batchRankingService) RankTopN(ctx context.Context) error {
-	arts, err := svc.rankTopN(ctx)
+	now := svc.now()
+	for biz, boards := range svc.boards {
+		res, live, err := svc.rankTopN(ctx, biz, boards, now)
+		if err != nil {
+			return err
+		}
+		for _, b := range boards {
+			scored := res[b.Name]
+			arts := slice.Map(scored, func(idx int, src scoredArticle) domain.Article {
+				return src.art
+			})
+			if err = svc.repo.ReplaceTopN(ctx, b.Name, arts); err != nil {
+				return err
+			}
+			if _, err = svc.repo.SaveSnapshot(ctx, svc.toSnapshot(b.Name, now, scored)); err != nil {
+				return err
+			}
+			if !b.Incremental {
+				continue
+			}
+			// 用全量计算的结果覆盖实时榜单，撤回的文章、超出时间窗口的文章都不会继续留在里面。
+			// 计算过程中的互动会丢掉，热榜只要求大致准确
+			buckets, _ := svc.buckets(b, now)
+			if err = svc.repo.ResetLive(ctx, b.Name, buckets, live[b.Name], b.Window+b.Bucket); err != nil {
+				return err
+			}
+		}
+	}
+	if svc.retention <= 0 {
+		return nil
+	}
+	_, err := svc.repo.DeleteSnapshotsBefore(ctx, now.Add(-svc.retention))
+	return err
+}
+
+func (svc *batchRankingService) toSnapshot(board string, now time.Time, scored []scoredArticle) domain.RankingSnapshot {
+	return domain.RankingSnapshot{
+		Board:    board,
+		CreateAt: now,
+		Items: slice.Map(scored, func(idx int, src scoredArticle) domain.RankingItem {
+			return domain.RankingItem{BizID: src.art.ID, Rank: idx + 1, Score: src.score, Title: src.art.Title}
+		}),
+	}
+}
+
+func (svc *batchRankingService) Snapshot(ctx context.Context, board string, at time.Time) (domain.RankingSnapshot, error) {
+	if _, ok := svc.names[board]; !ok {
+		return domain.RankingSnapshot{}, fmt.Errorf("%w: %s", ErrUnknownRankingBoard, board)
+	}
+	return svc.repo.GetSnapshot(ctx, board, at)
+}
+
+func (svc *batchRankingService) DiffSnapshots(ctx context.Context, board string, from, to time.Time) (domain.RankingDiff, error) {
+	fromSnapshot, err := svc.Snapshot(ctx, board, from)
 	if err != nil {
-		return err
+		return domain.RankingDiff{}, err
 	}
-	return svc.repo.ReplaceTopN(ctx, arts)
+	toSnapshot, err := svc.Snapshot(ctx, board, to)
+	if err != nil {
+		return domain.RankingDiff{}, err
+	}
+	return domain.DiffRankingSnapshots(fromSnapshot, toSnapshot), nil
 }
 
-func (svc *batchRankingService) TopN(ctx context.Context) ([]domain.Article, error) {
-	return svc.repo.GetTopN(ctx)
+func (svc *batchRankingService) TopN(ctx context.Context, board string) ([]domain.Article, error) {
+	b, ok := svc.names[board]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRankingBoard, board)
+	}
+	if b.Incremental {
+		arts, err := svc.liveTopN(ctx, b)
+		// 实时榜单还没有数据，或者 redis 出问题了，用定时任务计算的结果兜底
+		if err == nil && len(arts) > 0 {
+			return arts, nil
+		}
+	}
+	return svc.repo.GetTopN(ctx, board)
 }
 
-func (svc *batchRankingService) rankTopN(ctx context.Context) ([]domain.Article, error) {
-	now := time.Now()
-	// 只计算7天内, 超过7天我们可以认为不能成为热榜
-	ddl := now.Add(-7 * 24 * time.Hour)
-	offset := 0
-	type Score struct {
-		art   domain.Article
-		score float64
+func (svc *batchRankingService) liveTopN(ctx context.Context, b RankingBoard) ([]domain.Article, error) {
+	buckets, weights := svc.buckets(b, svc.now())
+	ids, err := svc.repo.GetLiveTopN(ctx, b.Name, buckets, weights, b.N)
+	if err != nil || len(ids) == 0 {
+		return nil, err
 	}
-	que := queue.NewConcurrentPriorityQueue(svc.N, func(src Score, dst Score) int {
-		if src.score > dst.score {
-			return 1
+	return svc.artSvc.ListPubByIDs(ctx, ids)
+}
+
+// Incr 和全量计算的 Score 一样按照文章的更新时间衰减，所以互动放到文章更新时间所在的桶里。
+// 撤回的文章，以及更新时间不在时间窗口内的文章，全量计算的时候不会上榜，这里也跳过
+func (svc *batchRankingService) Incr(ctx context.Context, evts []domain.RankingEvent) error {
+	updateAts, err := svc.updateAts(ctx, evts)
+	if err != nil {
+		return err
+	}
+	now := svc.now()
+	type bucketKey struct {
+		board  string
+		bucket time.Time
+	}
+	scores := make(map[bucketKey]map[int64]float64)
+	for _, evt := range evts {
+		updateAt, ok := updateAts[evt.BizID]
+		if evt.Biz != "article" || !ok {
+			continue
 		}
-		if src.score < dst.score {
-			return -1
+		for _, b := range svc.boards[evt.Biz] {
+			if !b.Incremental || updateAt.Before(now.Add(-b.Window)) {
+				continue
+			}
+			scorer := b.Scorer.(IncrementalScorer)
+			key := bucketKey{board: b.Name, bucket: updateAt.Truncate(b.Bucket)}
+			if scores[key] == nil {
+				scores[key] = make(map[int64]float64)
+			}
+			scores[key][evt.BizID] += scorer.Delta(evt.Delta)
 		}
-		return 0
-	})
+	}
+	for key, val := range scores {
+		b := svc.names[key.board]
+		if err = svc.repo.IncrLiveScores(ctx, key.board, key.bucket, val, b.Window+b.Bucket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	minScore := float64(0)
+// updateAts 增量模式的榜单涉及到的文章的更新时间，撤回的文章查不到。目前只有文章有榜单
+func (svc *batchRankingService) updateAts(ctx context.Context, evts []domain.RankingEvent) (map[int64]time.Time, error) {
+	if !svc.incremental("article") {
+		return nil, nil
+	}
+	ids := make([]int64, 0, len(evts))
+	seen := make(map[int64]struct{}, len(evts))
+	for _, evt := range evts {
+		if _, ok := seen[evt.BizID]; ok || evt.Biz != "article" {
+			continue
+		}
+		seen[evt.BizID] = struct{}{}
+		ids = append(ids, evt.BizID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	arts, err := svc.artSvc.ListPubByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]time.Time, len(arts))
+	for _, art := range arts {
+		res[art.ID] = art.UpdateAt
+	}
+	return res, nil
+}
+
+func (svc *batchRankingService) incremental(biz string) bool {
+	for _, b := range svc.boards[biz] {
+		if b.Incremental {
+			return true
+		}
+	}
+	return false
+}
+
+// buckets 时间窗口内所有的桶，以及每个桶的衰减系数。桶里是这段时间内更新的文章，
+// 桶的年龄按照桶的中间时刻计算，用来近似文章的更新时间
+func (svc *batchRankingService) buckets(b RankingBoard, now time.Time) ([]time.Time, []float64) {
+	scorer := b.Scorer.(IncrementalScorer)
+	oldest := now.Add(-b.Window).Truncate(b.Bucket)
+	var buckets []time.Time
+	var weights []float64
+	for t := now.Truncate(b.Bucket); !t.Before(oldest); t = t.Add(-b.Bucket) {
+		buckets = append(buckets, t)
+		weights = append(weights, scorer.Decay(now.Sub(t.Add(b.Bucket/2))))
+	}
+	return buckets, weights
+}
+
+// rankTopN 同一个业务的榜单只遍历一遍，遍历的范围是最大的那个时间窗口。
+// live 是增量模式的榜单每个桶里文章没有衰减的分数，用来对账
+func (svc *batchRankingService) rankTopN(ctx context.Context, biz string, boards []RankingBoard,
+	now time.Time) (res map[string][]scoredArticle, live map[string]map[time.Time]map[int64]float64, err error) {
+	// 目前只有文章有榜单
+	if biz != "article" {
+		return nil, nil, fmt.Errorf("不支持的业务 %s", biz)
+	}
+	live = make(map[string]map[time.Time]map[int64]float64)
+	var window time.Duration
+	tops := make([]*topN, 0, len(boards))
+	for _, b := range boards {
+		window = max(window, b.Window)
+		tops = append(tops, newTopN(b.N))
+		if b.Incremental {
+			live[b.Name] = make(map[time.Time]map[int64]float64)
+		}
+	}
+	ddl := now.Add(-window)
+	// 只看开始计算之前更新过的文章，计算过程中新发表的文章不会让后面的批次错位
+	cursor := domain.ArticleCursor{UpdateAt: now}
 	for {
-		arts, err := svc.artSvc.ListPub(ctx, now, offset, svc.BatchSize)
+		arts, err := svc.artSvc.ListPub(ctx, cursor, svc.BatchSize)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if len(arts) < 1 {
 			break
@@ -78,60 +279,102 @@ func (svc *batchRankingService) rankTopN(ctx context.Context) ([]domain.Article,
 		artIDs := slice.Map(arts, func(idx int, src domain.Article) int64 {
 			return src.ID
 		})
-		res, err := svc.intrClient.GetByIDs(ctx, &intr.GetByIDsRequest{Biz: "article", Ids: artIDs})
+		intrs, err := svc.intrClient.GetByIDs(ctx, &intr.GetByIDsRequest{Biz: biz, Ids: artIDs})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		intrMap := res.GetIntrs()
+		intrMap := intrs.GetIntrs()
 		for _, art := range arts {
+			// 线上库里还有撤回的文章
+			if art.Status != domain.ArticleStatusPublished {
+				continue
+			}
 			interactive, ok := intrMap[art.ID]
 			if !ok {
 				continue
 			}
-
-			score := svc.soreFunc(interactive.LikeCnt, art.UpdateAt)
-			// 当前分数小于最小分数, 不做处理
-			if score < minScore && que.Len() > 0 {
-				continue
+			in := domain.Interactive{
+				BizID:      art.ID,
+				ReadCnt:    interactive.GetReadCnt(),
+				LikeCnt:    interactive.GetLikeCnt(),
+				CollectCnt: interactive.GetCollectCnt(),
+				CommentCnt: interactive.GetCommentCnt(),
 			}
-
-			ele := Score{art: art, score: score}
-			queMinScore, e := que.Peek()
-			// 队列为空或队列不满, 直接入队
-			if e != nil || que.Len() < svc.N {
-				_ = que.Enqueue(ele)
-				minScore = score
-				if queMinScore.score < score {
-					minScore = queMinScore.score
+			for i, b := range boards {
+				// 不在这个榜单的时间窗口内
+				if art.UpdateAt.Before(now.Add(-b.Window)) {
+					continue
+				}
+				tops[i].add(art, b.Scorer.Score(in, art.UpdateAt, now))
+				if buckets, ok := live[b.Name]; ok {
+					bucket := art.UpdateAt.Truncate(b.Bucket)
+					if buckets[bucket] == nil {
+						buckets[bucket] = make(map[int64]float64)
+					}
+					buckets[bucket][art.ID] = b.Scorer.(IncrementalScorer).Delta(in)
 				}
-				continue
 			}
-
-			// 出队, 当前值入队
-			_, _ = que.Dequeue()
-			_ = que.Enqueue(ele)
-			val, _ := que.Peek()
-			minScore = val.score
 		}
 
 		length := len(arts)
-		if length == 0 || length < svc.BatchSize || arts[length-1].UpdateAt.Before(ddl) {
+		if length < svc.BatchSize || arts[length-1].UpdateAt.Before(ddl) {
 			break
 		}
-		offset += length
+		cursor = domain.NextArticleCursor(arts, svc.BatchSize)
 	}
 
-	ql := que.Len()
-	res := make([]domain.Article, ql)
-	for i := ql - 1; i >= 0; i-- {
-		val, _ := que.Dequeue()
-		res[i] = val.art
+	res = make(map[string][]scoredArticle, len(boards))
+	for i, b := range boards {
+		res[b.Name] = tops[i].result()
 	}
-	return res, nil
+	return res, live, nil
+}
+
+type scoredArticle struct {
+	art   domain.Article
+	score float64
+}
+
+// topN 小顶堆，堆顶是当前分数最低的
+type topN struct {
+	n   int
+	que *queue.ConcurrentPriorityQueue[scoredArticle]
+}
+
+func newTopN(n int) *topN {
+	return &topN{n: n, que: queue.NewConcurrentPriorityQueue(n, func(src scoredArticle, dst scoredArticle) int {
+		if src.score > dst.score {
+			return 1
+		}
+		if src.score < dst.score {
+			return -1
+		}
+		return 0
+	})}
 }
 
-func (svc *batchRankingService) score(likeCnt int64, UpdateAt time.Time) float64 {
-	const factor = 1.5
-	return float64(likeCnt-1) / math.Pow(time.Since(UpdateAt).Hours()+2, factor)
+func (t *topN) add(art domain.Article, score float64) {
+	ele := scoredArticle{art: art, score: score}
+	if t.que.Len() < t.n {
+		_ = t.que.Enqueue(ele)
+		return
+	}
+	// 满了，比堆顶分数高才替换
+	top, err := t.que.Peek()
+	if err != nil || top.score >= score {
+		return
+	}
+	_, _ = t.que.Dequeue()
+	_ = t.que.Enqueue(ele)
+}
+
+// result 按照分数从高到低
+func (t *topN) result() []scoredArticle {
+	ql := t.que.Len()
+	res := make([]scoredArticle, ql)
+	for i := ql - 1; i >= 0; i-- {
+		res[i], _ = t.que.Dequeue()
+	}
+	return res
 }
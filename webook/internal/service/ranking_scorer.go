@@ -0,0 +1,92 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"geektime-basic-go/webook/internal/domain"
+)
+
+// Scorer 计算热度，分数越高越靠前
+type Scorer interface {
+	// Score updateAt 是内容最后一次更新的时间，用来做时间衰减
+	Score(intr domain.Interactive, updateAt time.Time, now time.Time) float64
+}
+
+// WeightedScorer 阅读、点赞、收藏数加权求和，再按照更新时间衰减：
+// (ReadWeight*read + LikeWeight*like + CollectWeight*collect) / (hours + Offset)^Gravity
+// Gravity 越大，时间衰减得越快，新内容越容易上榜
+type WeightedScorer struct {
+	ReadWeight    float64 `yaml:"readWeight"`
+	LikeWeight    float64 `yaml:"likeWeight"`
+	CollectWeight float64 `yaml:"collectWeight"`
+	Gravity       float64 `yaml:"gravity"`
+	Offset        float64 `yaml:"offset"`
+}
+
+// IncrementalScorer 分数可以拆成互动带来的分数和时间衰减两部分，
+// 这样每一次互动都可以单独计算，再按照互动发生的时间衰减之后累加
+type IncrementalScorer interface {
+	Scorer
+	// Delta 没有衰减之前的分数，取消点赞这种互动 intr 里面是负数
+	Delta(intr domain.Interactive) float64
+	// Decay 过了 age 之后的衰减系数
+	Decay(age time.Duration) float64
+}
+
+func (s WeightedScorer) Score(intr domain.Interactive, updateAt time.Time, now time.Time) float64 {
+	return s.Delta(intr) * s.Decay(now.Sub(updateAt))
+}
+
+func (s WeightedScorer) Delta(intr domain.Interactive) float64 {
+	return s.ReadWeight*float64(intr.ReadCnt) +
+		s.LikeWeight*float64(intr.LikeCnt) +
+		s.CollectWeight*float64(intr.CollectCnt)
+}
+
+func (s WeightedScorer) Decay(age time.Duration) float64 {
+	hours := math.Max(age.Hours(), 0)
+	return 1 / math.Pow(hours+s.Offset, s.Gravity)
+}
+
+// RankingBoard 一个榜单，同一个业务可以有多个榜单，比如说今日热榜、本周热榜、飙升榜
+type RankingBoard struct {
+	// Name 榜单的名字，全局唯一
+	Name string
+	Biz  string
+	// Window 只计算这段时间内更新过的内容
+	Window time.Duration
+	N      int
+	Scorer Scorer
+	// Incremental 根据互动事件实时更新榜单，Scorer 必须实现 IncrementalScorer。
+	// 定时任务全量计算的结果用来兜底和对账
+	Incremental bool
+	// Bucket 增量模式下，同一个时间段内的互动放在同一个桶里，按照桶的时间衰减
+	Bucket time.Duration
+}
+
+// DefaultRankingConfig 没有配置的时候使用，快照保留 7 天
+func DefaultRankingConfig() RankingConfig {
+	return RankingConfig{Boards: DefaultRankingBoards(), SnapshotRetention: 7 * 24 * time.Hour}
+}
+
+// DefaultRankingBoards 没有配置的时候使用的榜单
+func DefaultRankingBoards() []RankingBoard {
+	return []RankingBoard{
+		{
+			Name: "hot_today", Biz: "article", Window: 24 * time.Hour, N: 100,
+			Scorer:      WeightedScorer{ReadWeight: 0.1, LikeWeight: 1, CollectWeight: 2, Gravity: 1.5, Offset: 2},
+			Incremental: true, Bucket: time.Hour,
+		},
+		{
+			Name: "hot_week", Biz: "article", Window: 7 * 24 * time.Hour, N: 100,
+			Scorer: WeightedScorer{ReadWeight: 0.1, LikeWeight: 1, CollectWeight: 2, Gravity: 1.2, Offset: 2},
+		},
+		// 飙升榜时间窗口短，衰减快，刚发表就有互动的内容容易上榜
+		{
+			Name: "rising", Biz: "article", Window: 6 * time.Hour, N: 50,
+			Scorer:      WeightedScorer{ReadWeight: 0.1, LikeWeight: 1, CollectWeight: 2, Gravity: 2.5, Offset: 1},
+			Incremental: true, Bucket: 10 * time.Minute,
+		},
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"geektime-basic-go/webook/internal/domain"
+)
+
+func TestWeightedScorer_Score(t *testing.T) {
+	now := time.Now()
+	scorer := WeightedScorer{ReadWeight: 0.1, LikeWeight: 1, CollectWeight: 2, Gravity: 1.5, Offset: 2}
+	testCases := []struct {
+		name     string
+		scorer   WeightedScorer
+		intr     domain.Interactive
+		updateAt time.Time
+
+		wantScore float64
+	}{
+		{
+			name:      "刚更新，没有衰减",
+			scorer:    WeightedScorer{ReadWeight: 0.1, LikeWeight: 1, CollectWeight: 2, Gravity: 1.5, Offset: 1},
+			intr:      domain.Interactive{ReadCnt: 10, LikeCnt: 2, CollectCnt: 3},
+			updateAt:  now,
+			wantScore: 9,
+		},
+		{
+			name:      "按照小时衰减",
+			scorer:    scorer,
+			intr:      domain.Interactive{ReadCnt: 10, LikeCnt: 2, CollectCnt: 3},
+			updateAt:  now.Add(-2 * time.Hour),
+			wantScore: 9 / math.Pow(4, 1.5),
+		},
+		{
+			name:      "更新时间在未来，按照刚更新计算",
+			scorer:    scorer,
+			intr:      domain.Interactive{LikeCnt: 4},
+			updateAt:  now.Add(time.Hour),
+			wantScore: 4 / math.Pow(2, 1.5),
+		},
+		{
+			name:      "没有互动",
+			scorer:    scorer,
+			updateAt:  now.Add(-time.Hour),
+			wantScore: 0,
+		},
+		{
+			name:      "没有配置权重",
+			scorer:    WeightedScorer{Gravity: 1.5, Offset: 2},
+			intr:      domain.Interactive{ReadCnt: 10, LikeCnt: 2, CollectCnt: 3},
+			updateAt:  now,
+			wantScore: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			score := tc.scorer.Score(tc.intr, tc.updateAt, now)
+			assert.InDelta(t, tc.wantScore, score, 1e-9)
+		})
+	}
+}
+
+func TestWeightedScorer_Incremental(t *testing.T) {
+	scorer := WeightedScorer{ReadWeight: 0.1, LikeWeight: 1, CollectWeight: 2, Gravity: 2, Offset: 1}
+	testCases := []struct {
+		name string
+		intr domain.Interactive
+		age  time.Duration
+
+		wantDelta float64
+		wantDecay float64
+	}{
+		{
+			name:      "点赞",
+			intr:      domain.Interactive{LikeCnt: 1},
+			wantDelta: 1,
+			wantDecay: 1,
+		},
+		{
+			name:      "取消收藏是负数",
+			intr:      domain.Interactive{CollectCnt: -1},
+			age:       time.Hour,
+			wantDelta: -2,
+			wantDecay: 0.25,
+		},
+		{
+			name:      "阅读按照半小时衰减",
+			intr:      domain.Interactive{ReadCnt: 5},
+			age:       30 * time.Minute,
+			wantDelta: 0.5,
+			wantDecay: 1 / 2.25,
+		},
+		{
+			name:      "年龄是负数",
+			intr:      domain.Interactive{LikeCnt: 1},
+			age:       -time.Hour,
+			wantDelta: 1,
+			wantDecay: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InDelta(t, tc.wantDelta, scorer.Delta(tc.intr), 1e-9)
+			assert.InDelta(t, tc.wantDecay, scorer.Decay(tc.age), 1e-9)
+			// 增量计算的结果要和全量计算的一致
+			now := time.Now()
+			assert.InDelta(t, tc.wantDelta*tc.wantDecay,
+				scorer.Score(tc.intr, now.Add(-tc.age), now), 1e-9)
+		})
+	}
+}
+
+func TestNewBatchRankingService_Boards(t *testing.T) {
+	incr := WeightedScorer{LikeWeight: 1, Gravity: 1.5, Offset: 2}
+	testCases := []struct {
+		name   string
+		boards []RankingBoard
+
+		wantBoards map[string][]string
+		// wantIncremental 开启了增量模式的榜单
+		wantIncremental []string
+	}{
+		{
+			name:       "没有榜单",
+			wantBoards: map[string][]string{},
+		},
+		{
+			name: "按照业务分组",
+			boards: []RankingBoard{
+				{Name: "a1", Biz: "article", Scorer: incr},
+				{Name: "v1", Biz: "video", Scorer: incr},
+				{Name: "a2", Biz: "article", Scorer: incr},
+			},
+			wantBoards: map[string][]string{"article": {"a1", "a2"}, "video": {"v1"}},
+		},
+		{
+			name: "增量模式",
+			boards: []RankingBoard{
+				{Name: "a1", Biz: "article", Scorer: incr, Incremental: true, Bucket: time.Hour},
+			},
+			wantBoards:      map[string][]string{"article": {"a1"}},
+			wantIncremental: []string{"a1"},
+		},
+		{
+			name: "不支持增量计算的 Scorer 只能全量计算",
+			boards: []RankingBoard{
+				{Name: "a1", Biz: "article", Scorer: likeScorer{}, Incremental: true, Bucket: time.Hour},
+			},
+			wantBoards: map[string][]string{"article": {"a1"}},
+		},
+		{
+			name: "没有配置桶的大小只能全量计算",
+			boards: []RankingBoard{
+				{Name: "a1", Biz: "article", Scorer: incr, Incremental: true},
+			},
+			wantBoards: map[string][]string{"article": {"a1"}},
+		},
+		{
+			name:            "默认的榜单",
+			boards:          DefaultRankingBoards(),
+			wantBoards:      map[string][]string{"article": {"hot_today", "hot_week", "rising"}},
+			wantIncremental: []string{"hot_today", "rising"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewBatchRankingService(nil, nil, nil,
+				RankingConfig{Boards: tc.boards}).(*batchRankingService)
+			boards := make(map[string][]string, len(svc.boards))
+			var incremental []string
+			for biz, bs := range svc.boards {
+				for _, b := range bs {
+					boards[biz] = append(boards[biz], b.Name)
+					if b.Incremental {
+						incremental = append(incremental, b.Name)
+						assert.True(t, svc.names[b.Name].Incremental)
+					}
+				}
+			}
+			assert.Equal(t, tc.wantBoards, boards)
+			assert.ElementsMatch(t, tc.wantIncremental, incremental)
+			assert.Len(t, svc.names, len(tc.boards))
+		})
+	}
+}
@@ -6,220 +6,248 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ecodeclub/ekit/slice"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 
+	intr "geektime-basic-go/webook/api/proto/gen/interactive"
 	"geektime-basic-go/webook/internal/domain"
-	"geektime-basic-go/webook/internal/service/mocks"
+	"geektime-basic-go/webook/internal/repository"
+	repomocks "geektime-basic-go/webook/internal/repository/mocks"
+	intrmocks "geektime-basic-go/webook/internal/repository/rpc/interactive/mocks"
+	svcmocks "geektime-basic-go/webook/internal/service/mocks"
 )
 
 func TestBatchRankingService_rankTopN(t *testing.T) {
 	const batchSize = 2
 	now := time.Now()
 	mockErr := errors.New("模拟失败")
+	const published = domain.ArticleStatusPublished
 	testCases := []struct {
 		name    string
-		mock    func(ctrl *gomock.Controller) (ArticleService, InteractiveService)
+		mock    func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient)
 		wantErr error
 		wantRes []domain.Article
 	}{
 		{
 			name: "计算成功-单批",
-			mock: func(ctrl *gomock.Controller) (ArticleService, InteractiveService) {
-				artSvc := mocks.NewMockArticleService(ctrl)
-				intrSvc := mocks.NewMockInteractiveService(ctrl)
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 0, batchSize).Return([]domain.Article{
-					{ID: 1, UpdateAt: now},
-					{ID: 2, UpdateAt: now},
-				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{1, 2}).Return(map[int64]domain.Interactive{
+			mock: func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				intrSvc := intrmocks.NewMockInteractiveServiceClient(ctrl)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, batchSize).Return([]domain.Article{
+					{ID: 1, UpdateAt: now, Status: published},
+					{ID: 2, UpdateAt: now, Status: published},
+				}, nil)
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{1, 2}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					1: {LikeCnt: 1},
 					2: {LikeCnt: 2},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 2, batchSize).Return([]domain.Article{}, nil)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 2}, batchSize).Return([]domain.Article{}, nil)
 				return artSvc, intrSvc
 			},
 			wantRes: []domain.Article{
-				{ID: 2, UpdateAt: now},
-				{ID: 1, UpdateAt: now},
+				{ID: 2, UpdateAt: now, Status: published},
+				{ID: 1, UpdateAt: now, Status: published},
 			},
 		},
 		{
 			name: "计算成功-两批次",
-			mock: func(ctrl *gomock.Controller) (ArticleService, InteractiveService) {
-				artSvc := mocks.NewMockArticleService(ctrl)
-				intrSvc := mocks.NewMockInteractiveService(ctrl)
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 0, batchSize).Return([]domain.Article{
-					{ID: 1, UpdateAt: now},
-					{ID: 2, UpdateAt: now},
-				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{1, 2}).Return(map[int64]domain.Interactive{
+			mock: func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				intrSvc := intrmocks.NewMockInteractiveServiceClient(ctrl)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, batchSize).Return([]domain.Article{
+					{ID: 1, UpdateAt: now, Status: published},
+					{ID: 2, UpdateAt: now, Status: published},
+				}, nil)
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{1, 2}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					1: {LikeCnt: 1},
 					2: {LikeCnt: 2},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 2, batchSize).Return([]domain.Article{
-					{ID: 3, UpdateAt: now},
-					{ID: 4, UpdateAt: now},
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 2}, batchSize).Return([]domain.Article{
+					{ID: 3, UpdateAt: now, Status: published},
+					{ID: 4, UpdateAt: now, Status: published},
 				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{3, 4}).Return(map[int64]domain.Interactive{
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{3, 4}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					3: {LikeCnt: 3},
 					4: {LikeCnt: 4},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 4, batchSize).Return([]domain.Article{}, nil)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 4}, batchSize).Return([]domain.Article{}, nil)
 				return artSvc, intrSvc
 			},
 			wantRes: []domain.Article{
-				{ID: 4, UpdateAt: now},
-				{ID: 3, UpdateAt: now},
-				{ID: 2, UpdateAt: now},
+				{ID: 4, UpdateAt: now, Status: published},
+				{ID: 3, UpdateAt: now, Status: published},
+				{ID: 2, UpdateAt: now, Status: published},
 			},
 		},
 		{
 			name: "计算成功-队列最小值大于分数",
-			mock: func(ctrl *gomock.Controller) (ArticleService, InteractiveService) {
-				artSvc := mocks.NewMockArticleService(ctrl)
-				intrSvc := mocks.NewMockInteractiveService(ctrl)
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 0, batchSize).Return([]domain.Article{
-					{ID: 1, UpdateAt: now},
-					{ID: 2, UpdateAt: now},
-				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{1, 2}).Return(map[int64]domain.Interactive{
+			mock: func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				intrSvc := intrmocks.NewMockInteractiveServiceClient(ctrl)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, batchSize).Return([]domain.Article{
+					{ID: 1, UpdateAt: now, Status: published},
+					{ID: 2, UpdateAt: now, Status: published},
+				}, nil)
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{1, 2}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					1: {LikeCnt: 5},
 					2: {LikeCnt: 2},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 2, batchSize).Return([]domain.Article{
-					{ID: 3, UpdateAt: now},
-					{ID: 4, UpdateAt: now},
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 2}, batchSize).Return([]domain.Article{
+					{ID: 3, UpdateAt: now, Status: published},
+					{ID: 4, UpdateAt: now, Status: published},
 				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{3, 4}).Return(map[int64]domain.Interactive{
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{3, 4}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					3: {LikeCnt: 5},
 					4: {LikeCnt: 1},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 4, batchSize).Return([]domain.Article{
-					{ID: 5, UpdateAt: now},
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 4}, batchSize).Return([]domain.Article{
+					{ID: 5, UpdateAt: now, Status: published},
 				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{5}).Return(map[int64]domain.Interactive{
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{5}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					5: {LikeCnt: 3},
-				}, nil)
+				}}, nil)
 
 				return artSvc, intrSvc
 			},
 			wantRes: []domain.Article{
-				{ID: 1, UpdateAt: now},
-				{ID: 3, UpdateAt: now},
-				{ID: 5, UpdateAt: now},
+				{ID: 1, UpdateAt: now, Status: published},
+				{ID: 3, UpdateAt: now, Status: published},
+				{ID: 5, UpdateAt: now, Status: published},
 			},
 		},
 		{
 			name: "计算成功-最小值大于分数",
-			mock: func(ctrl *gomock.Controller) (ArticleService, InteractiveService) {
-				artSvc := mocks.NewMockArticleService(ctrl)
-				intrSvc := mocks.NewMockInteractiveService(ctrl)
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 0, batchSize).Return([]domain.Article{
-					{ID: 1, UpdateAt: now},
-					{ID: 2, UpdateAt: now},
-				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{1, 2}).Return(map[int64]domain.Interactive{
+			mock: func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				intrSvc := intrmocks.NewMockInteractiveServiceClient(ctrl)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, batchSize).Return([]domain.Article{
+					{ID: 1, UpdateAt: now, Status: published},
+					{ID: 2, UpdateAt: now, Status: published},
+				}, nil)
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{1, 2}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					1: {LikeCnt: 5},
 					2: {LikeCnt: 2},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 2, batchSize).Return([]domain.Article{
-					{ID: 3, UpdateAt: now},
-					{ID: 4, UpdateAt: now},
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 2}, batchSize).Return([]domain.Article{
+					{ID: 3, UpdateAt: now, Status: published},
+					{ID: 4, UpdateAt: now, Status: published},
 				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{3, 4}).Return(map[int64]domain.Interactive{
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{3, 4}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					3: {LikeCnt: 5},
 					4: {LikeCnt: 1},
+				}}, nil)
+
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 4}, batchSize).Return([]domain.Article{}, nil)
+				return artSvc, intrSvc
+			},
+			wantRes: []domain.Article{
+				{ID: 1, UpdateAt: now, Status: published},
+				{ID: 3, UpdateAt: now, Status: published},
+				{ID: 2, UpdateAt: now, Status: published},
+			},
+		},
+		{
+			name: "撤回的文章不上榜",
+			mock: func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				intrSvc := intrmocks.NewMockInteractiveServiceClient(ctrl)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, batchSize).Return([]domain.Article{
+					{ID: 1, UpdateAt: now, Status: published},
+					{ID: 2, UpdateAt: now, Status: domain.ArticleStatusPrivate},
 				}, nil)
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{1, 2}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
+					1: {LikeCnt: 1},
+					2: {LikeCnt: 10},
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 4, batchSize).Return([]domain.Article{}, nil)
+				// 撤回的文章也要参与翻页
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 2}, batchSize).Return([]domain.Article{}, nil)
 				return artSvc, intrSvc
 			},
 			wantRes: []domain.Article{
-				{ID: 1, UpdateAt: now},
-				{ID: 3, UpdateAt: now},
-				{ID: 2, UpdateAt: now},
+				{ID: 1, UpdateAt: now, Status: published},
 			},
 		},
 		{
 			name: "art失败",
-			mock: func(ctrl *gomock.Controller) (ArticleService, InteractiveService) {
-				artSvc := mocks.NewMockArticleService(ctrl)
-				intrSvc := mocks.NewMockInteractiveService(ctrl)
+			mock: func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				intrSvc := intrmocks.NewMockInteractiveServiceClient(ctrl)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 0, batchSize).Return([]domain.Article{
-					{ID: 1, UpdateAt: now},
-					{ID: 2, UpdateAt: now},
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, batchSize).Return([]domain.Article{
+					{ID: 1, UpdateAt: now, Status: published},
+					{ID: 2, UpdateAt: now, Status: published},
 				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{1, 2}).Return(map[int64]domain.Interactive{
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{1, 2}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					1: {LikeCnt: 1},
 					2: {LikeCnt: 2},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 2, batchSize).Return([]domain.Article{}, mockErr)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 2}, batchSize).Return([]domain.Article{}, mockErr)
 				return artSvc, intrSvc
 			},
 			wantErr: mockErr,
 		},
 		{
 			name: "intr失败",
-			mock: func(ctrl *gomock.Controller) (ArticleService, InteractiveService) {
-				artSvc := mocks.NewMockArticleService(ctrl)
-				intrSvc := mocks.NewMockInteractiveService(ctrl)
+			mock: func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				intrSvc := intrmocks.NewMockInteractiveServiceClient(ctrl)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 0, batchSize).Return([]domain.Article{
-					{ID: 1, UpdateAt: now},
-					{ID: 2, UpdateAt: now},
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, batchSize).Return([]domain.Article{
+					{ID: 1, UpdateAt: now, Status: published},
+					{ID: 2, UpdateAt: now, Status: published},
 				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{1, 2}).Return(map[int64]domain.Interactive{
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{1, 2}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					1: {LikeCnt: 1},
 					2: {LikeCnt: 2},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 2, batchSize).Return([]domain.Article{
-					{ID: 3, UpdateAt: now},
-					{ID: 4, UpdateAt: now},
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 2}, batchSize).Return([]domain.Article{
+					{ID: 3, UpdateAt: now, Status: published},
+					{ID: 4, UpdateAt: now, Status: published},
 				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{3, 4}).Return(map[int64]domain.Interactive{}, mockErr)
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{3, 4}}).Return(nil, mockErr)
 				return artSvc, intrSvc
 			},
 			wantErr: mockErr,
 		},
 		{
 			name: "intr不存在",
-			mock: func(ctrl *gomock.Controller) (ArticleService, InteractiveService) {
-				artSvc := mocks.NewMockArticleService(ctrl)
-				intrSvc := mocks.NewMockInteractiveService(ctrl)
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 0, batchSize).Return([]domain.Article{
-					{ID: 1, UpdateAt: now},
-					{ID: 2, UpdateAt: now},
-				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{1, 2}).Return(map[int64]domain.Interactive{
+			mock: func(ctrl *gomock.Controller) (ArticleService, intr.InteractiveServiceClient) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				intrSvc := intrmocks.NewMockInteractiveServiceClient(ctrl)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, batchSize).Return([]domain.Article{
+					{ID: 1, UpdateAt: now, Status: published},
+					{ID: 2, UpdateAt: now, Status: published},
+				}, nil)
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{1, 2}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					1: {LikeCnt: 1},
 					2: {LikeCnt: 2},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 2, batchSize).Return([]domain.Article{
-					{ID: 3, UpdateAt: now},
-					{ID: 4, UpdateAt: now},
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 2}, batchSize).Return([]domain.Article{
+					{ID: 3, UpdateAt: now, Status: published},
+					{ID: 4, UpdateAt: now, Status: published},
 				}, nil)
-				intrSvc.EXPECT().GetByIDs(gomock.Any(), "article", []int64{3, 4}).Return(map[int64]domain.Interactive{
+				intrSvc.EXPECT().GetByIDs(gomock.Any(), &intr.GetByIDsRequest{Biz: "article", Ids: []int64{3, 4}}).Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{
 					3: {LikeCnt: 3},
-				}, nil)
+				}}, nil)
 
-				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 4, batchSize).Return([]domain.Article{}, nil)
+				artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now, ID: 4}, batchSize).Return([]domain.Article{}, nil)
 				return artSvc, intrSvc
 			},
 			wantRes: []domain.Article{
-				{ID: 3, UpdateAt: now},
-				{ID: 2, UpdateAt: now},
-				{ID: 1, UpdateAt: now},
+				{ID: 3, UpdateAt: now, Status: published},
+				{ID: 2, UpdateAt: now, Status: published},
+				{ID: 1, UpdateAt: now, Status: published},
 			},
 		},
 	}
@@ -228,19 +256,255 @@ func TestBatchRankingService_rankTopN(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			artSvc, intrSvc := tc.mock(ctrl)
+			artSvc, intrClient := tc.mock(ctrl)
 			svc := &batchRankingService{
-				artSvc:    artSvc,
-				intrSvc:   intrSvc,
-				BatchSize: batchSize,
-				N:         3,
+				artSvc:     artSvc,
+				intrClient: intrClient,
+				BatchSize:  batchSize,
 			}
-			svc.soreFunc = svc.score
+			boards := []RankingBoard{{Name: "test", Biz: "article", Window: time.Hour, N: 3, Scorer: likeScorer{}}}
 			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 			defer cancel()
-			res, err := svc.rankTopN(ctx)
+			res, _, err := svc.rankTopN(ctx, "article", boards, now)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, slice.Map(res["test"], func(idx int, src scoredArticle) domain.Article {
+				return src.art
+			}))
+		})
+	}
+}
+
+func TestBatchRankingService_buckets(t *testing.T) {
+	now := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+	// 衰减系数是 1 / (hours + 1)
+	scorer := WeightedScorer{LikeWeight: 1, Gravity: 1, Offset: 1}
+	testCases := []struct {
+		name  string
+		board RankingBoard
+
+		wantBuckets []time.Time
+		wantWeights []float64
+	}{
+		{
+			name:  "按小时分桶",
+			board: RankingBoard{Window: 2 * time.Hour, Bucket: time.Hour, Scorer: scorer},
+			wantBuckets: []time.Time{
+				time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC),
+			},
+			wantWeights: []float64{1, 0.5, 1.0 / 3},
+		},
+		{
+			name:  "时间窗口比桶小",
+			board: RankingBoard{Window: 30 * time.Minute, Bucket: time.Hour, Scorer: scorer},
+			wantBuckets: []time.Time{
+				time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+			},
+			wantWeights: []float64{1},
+		},
+		{
+			name:  "时间窗口不是桶的整数倍",
+			board: RankingBoard{Window: time.Hour, Bucket: 20 * time.Minute, Scorer: scorer},
+			wantBuckets: []time.Time{
+				time.Date(2026, 10, 17, 10, 20, 0, 0, time.UTC),
+				time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 17, 9, 40, 0, 0, time.UTC),
+				time.Date(2026, 10, 17, 9, 20, 0, 0, time.UTC),
+			},
+			wantWeights: []float64{1, 0.75, 0.6, 0.5},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &batchRankingService{}
+			buckets, weights := svc.buckets(tc.board, now)
+			assert.Equal(t, tc.wantBuckets, buckets)
+			assert.InDeltaSlice(t, tc.wantWeights, weights, 1e-9)
+		})
+	}
+}
+
+func TestBatchRankingService_Incr(t *testing.T) {
+	now := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 17, hour, minute, 0, 0, time.UTC)
+	}
+	mockErr := errors.New("模拟失败")
+	board := RankingBoard{Name: "live", Biz: "article", Window: 2 * time.Hour, N: 10,
+		Scorer:      WeightedScorer{ReadWeight: 0.1, LikeWeight: 1, CollectWeight: 2, Gravity: 1, Offset: 1},
+		Incremental: true, Bucket: time.Hour}
+	testCases := []struct {
+		name   string
+		boards []RankingBoard
+		evts   []domain.RankingEvent
+		mock   func(ctrl *gomock.Controller) (ArticleService, repository.RankingRepository)
+
+		wantErr error
+	}{
+		{
+			name:   "按照文章的更新时间分桶",
+			boards: []RankingBoard{board},
+			evts: []domain.RankingEvent{
+				{Biz: "article", BizID: 1, Delta: domain.Interactive{LikeCnt: 1}},
+				{Biz: "article", BizID: 2, Delta: domain.Interactive{CollectCnt: 1}},
+				{Biz: "article", BizID: 1, Delta: domain.Interactive{LikeCnt: 1}},
+				{Biz: "article", BizID: 1, Delta: domain.Interactive{CollectCnt: -1}},
+			},
+			mock: func(ctrl *gomock.Controller) (ArticleService, repository.RankingRepository) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				repo := repomocks.NewMockRankingRepository(ctrl)
+				artSvc.EXPECT().ListPubByIDs(gomock.Any(), []int64{1, 2}).Return([]domain.Article{
+					{ID: 1, UpdateAt: at(9, 10)},
+					{ID: 2, UpdateAt: at(10, 5)},
+				}, nil)
+				repo.EXPECT().IncrLiveScores(gomock.Any(), "live", at(9, 0),
+					map[int64]float64{1: 0}, 3*time.Hour).Return(nil)
+				repo.EXPECT().IncrLiveScores(gomock.Any(), "live", at(10, 0),
+					map[int64]float64{2: 2}, 3*time.Hour).Return(nil)
+				return artSvc, repo
+			},
+		},
+		{
+			name:   "撤回的文章和不在时间窗口内的文章跳过",
+			boards: []RankingBoard{board},
+			evts: []domain.RankingEvent{
+				{Biz: "article", BizID: 3, Delta: domain.Interactive{LikeCnt: 1}},
+				{Biz: "article", BizID: 4, Delta: domain.Interactive{LikeCnt: 1}},
+				{Biz: "article", BizID: 5, Delta: domain.Interactive{ReadCnt: 1}},
+			},
+			mock: func(ctrl *gomock.Controller) (ArticleService, repository.RankingRepository) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				repo := repomocks.NewMockRankingRepository(ctrl)
+				artSvc.EXPECT().ListPubByIDs(gomock.Any(), []int64{3, 4, 5}).Return([]domain.Article{
+					{ID: 4, UpdateAt: at(8, 29)},
+					{ID: 5, UpdateAt: at(8, 30)},
+				}, nil)
+				repo.EXPECT().IncrLiveScores(gomock.Any(), "live", at(8, 0),
+					map[int64]float64{5: 0.1}, 3*time.Hour).Return(nil)
+				return artSvc, repo
+			},
+		},
+		{
+			name:   "其它业务的事件跳过",
+			boards: []RankingBoard{board},
+			evts: []domain.RankingEvent{
+				{Biz: "video", BizID: 1, Delta: domain.Interactive{LikeCnt: 1}},
+			},
+			mock: func(ctrl *gomock.Controller) (ArticleService, repository.RankingRepository) {
+				return svcmocks.NewMockArticleService(ctrl), repomocks.NewMockRankingRepository(ctrl)
+			},
+		},
+		{
+			name: "没有增量模式的榜单",
+			boards: []RankingBoard{{Name: "batch", Biz: "article", Window: time.Hour, N: 10,
+				Scorer: board.Scorer}},
+			evts: []domain.RankingEvent{
+				{Biz: "article", BizID: 1, Delta: domain.Interactive{LikeCnt: 1}},
+			},
+			mock: func(ctrl *gomock.Controller) (ArticleService, repository.RankingRepository) {
+				return svcmocks.NewMockArticleService(ctrl), repomocks.NewMockRankingRepository(ctrl)
+			},
+		},
+		{
+			name:   "查询文章失败",
+			boards: []RankingBoard{board},
+			evts: []domain.RankingEvent{
+				{Biz: "article", BizID: 1, Delta: domain.Interactive{LikeCnt: 1}},
+			},
+			mock: func(ctrl *gomock.Controller) (ArticleService, repository.RankingRepository) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				artSvc.EXPECT().ListPubByIDs(gomock.Any(), []int64{1}).Return(nil, mockErr)
+				return artSvc, repomocks.NewMockRankingRepository(ctrl)
+			},
+			wantErr: mockErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			artSvc, repo := tc.mock(ctrl)
+			svc := NewBatchRankingService(artSvc, nil, repo, RankingConfig{Boards: tc.boards}).(*batchRankingService)
+			svc.now = func() time.Time { return now }
+			err := svc.Incr(context.Background(), tc.evts)
 			assert.Equal(t, tc.wantErr, err)
-			assert.Equal(t, tc.wantRes, res)
 		})
 	}
 }
+
+// TestBatchRankingService_live 对账写回实时榜单的分数，按照桶合并之后要和全量计算的分数一致
+func TestBatchRankingService_live(t *testing.T) {
+	now := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+	scorer := WeightedScorer{ReadWeight: 0.1, LikeWeight: 1, CollectWeight: 2, Gravity: 1.5, Offset: 2}
+	testCases := []struct {
+		name     string
+		bucket   time.Duration
+		updateAt time.Time
+		intr     *intr.Interactive
+
+		wantBucket time.Time
+		wantDelta  float64
+	}{
+		{
+			name:       "刚更新",
+			bucket:     time.Hour,
+			updateAt:   time.Date(2026, 10, 17, 10, 25, 0, 0, time.UTC),
+			intr:       &intr.Interactive{ReadCnt: 10, LikeCnt: 3},
+			wantBucket: time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+			wantDelta:  4,
+		},
+		{
+			name:       "一天之前更新",
+			bucket:     10 * time.Minute,
+			updateAt:   time.Date(2026, 10, 16, 10, 43, 0, 0, time.UTC),
+			intr:       &intr.Interactive{LikeCnt: 5, CollectCnt: 2},
+			wantBucket: time.Date(2026, 10, 16, 10, 40, 0, 0, time.UTC),
+			wantDelta:  9,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			art := domain.Article{ID: 1, UpdateAt: tc.updateAt, Status: domain.ArticleStatusPublished}
+			artSvc := svcmocks.NewMockArticleService(ctrl)
+			intrClient := intrmocks.NewMockInteractiveServiceClient(ctrl)
+			artSvc.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{UpdateAt: now}, 10).
+				Return([]domain.Article{art}, nil)
+			intrClient.EXPECT().GetByIDs(gomock.Any(), gomock.Any()).
+				Return(&intr.GetByIDsResponse{Intrs: map[int64]*intr.Interactive{1: tc.intr}}, nil)
+			svc := &batchRankingService{artSvc: artSvc, intrClient: intrClient, BatchSize: 10}
+			b := RankingBoard{Name: "live", Biz: "article", Window: 48 * time.Hour, N: 10,
+				Scorer: scorer, Incremental: true, Bucket: tc.bucket}
+
+			res, live, err := svc.rankTopN(context.Background(), "article", []RankingBoard{b}, now)
+			assert.NoError(t, err)
+			assert.Equal(t, map[time.Time]map[int64]float64{tc.wantBucket: {1: tc.wantDelta}}, live["live"])
+
+			buckets, weights := svc.buckets(b, now)
+			var merged float64
+			for i, bucket := range buckets {
+				merged += live["live"][bucket][1] * weights[i]
+			}
+			// 合并之后相当于把更新时间近似成桶的中间时刻，再用全量计算的公式计算
+			in := domain.Interactive{ReadCnt: tc.intr.GetReadCnt(), LikeCnt: tc.intr.GetLikeCnt(),
+				CollectCnt: tc.intr.GetCollectCnt()}
+			assert.InDelta(t, scorer.Score(in, tc.wantBucket.Add(tc.bucket/2), now), merged, 1e-9)
+			assert.InDelta(t, res["live"][0].score, merged, res["live"][0].score*0.1)
+		})
+	}
+}
+
+// likeScorer 只看点赞数，方便构造测试数据
+type likeScorer struct{}
+
+func (likeScorer) Score(intr domain.Interactive, updateAt time.Time, now time.Time) float64 {
+	return float64(intr.LikeCnt)
+}
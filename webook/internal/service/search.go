@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/repository"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+//go:generate mockgen -source=search.go -package=svcmocks -destination=mocks/search_mock_gen.go SearchService
+type SearchService interface {
+	SearchArticle(ctx context.Context, query string, offset, limit int) (domain.ArticleSearchResult, error)
+	// RebuildArticleIndex 用线上库中已发表的文章重建索引，返回建立索引的文章数量
+	RebuildArticleIndex(ctx context.Context) (int, error)
+	// SyncArticle 按照线上库的最新状态更新一篇文章的索引，已发表的加入索引，否则删除。
+	// 发表和撤回事件都调用它，所以重复消费和乱序都没有关系
+	SyncArticle(ctx context.Context, aid int64) error
+}
+
+type searchService struct {
+	repo      repository.SearchRepository
+	artRepo   repository.ArticleRepository
+	userRepo  repository.UserRepository
+	l         logger.Logger
+	batchSize int
+
+	// syncMu 同一时间只同步一篇文章，避免先查到的旧状态覆盖后查到的新状态
+	syncMu sync.Mutex
+	mu     sync.Mutex
+	// pending 重建期间收到事件的文章。重建用的快照可能比事件旧，重建完之后要再同步一次，
+	// 不在重建的时候为 nil
+	pending map[int64]struct{}
+}
+
+func NewSearchService(repo repository.SearchRepository, artRepo repository.ArticleRepository,
+	userRepo repository.UserRepository, l logger.Logger) SearchService {
+	return &searchService{repo: repo, artRepo: artRepo, userRepo: userRepo, l: l, batchSize: 100}
+}
+
+func (svc *searchService) SearchArticle(ctx context.Context, query string, offset, limit int) (domain.ArticleSearchResult, error) {
+	res, err := svc.repo.SearchArticle(ctx, query, offset, limit)
+	if err != nil {
+		return res, err
+	}
+	svc.fillAuthors(ctx, res.Hits)
+	return res, nil
+}
+
+// fillAuthors 索引里面只保证有作者的 ID，名字在查询的时候补上，这样改名之后也不用重建索引。
+// 查询失败的作者只是不展示名字
+func (svc *searchService) fillAuthors(ctx context.Context, hits []domain.ArticleSearchHit) {
+	if len(hits) == 0 {
+		return
+	}
+	uids := make([]int64, 0, len(hits))
+	for i := range hits {
+		uids = append(uids, hits[i].Article.Author.ID)
+	}
+	users, err := svc.userRepo.FindByIDs(ctx, uids)
+	if err != nil {
+		svc.l.Error("查询文章作者失败", logger.Any("uids", uids), logger.Error(err))
+	}
+	for i := range hits {
+		hits[i].Article.Author.Name = users[hits[i].Article.Author.ID].Nickname
+	}
+}
+
+func (svc *searchService) SyncArticle(ctx context.Context, aid int64) error {
+	svc.mu.Lock()
+	if svc.pending != nil {
+		svc.pending[aid] = struct{}{}
+	}
+	svc.mu.Unlock()
+	return svc.syncArticle(ctx, aid)
+}
+
+func (svc *searchService) syncArticle(ctx context.Context, aid int64) error {
+	svc.syncMu.Lock()
+	defer svc.syncMu.Unlock()
+	arts, err := svc.artRepo.ListPubByIDs(ctx, []int64{aid})
+	if err != nil {
+		return err
+	}
+	if len(arts) == 0 || arts[0].Status != domain.ArticleStatusPublished {
+		return svc.repo.DeleteArticle(ctx, aid)
+	}
+	return svc.repo.IndexArticle(ctx, arts[0])
+}
+
+// RebuildArticleIndex 重建的同时事件也在更新索引，快照里的旧数据可能覆盖事件的结果，
+// 比如撤回的文章又被加回索引，所以重建完之后把这期间收到事件的文章按照最新状态再同步一次
+func (svc *searchService) RebuildArticleIndex(ctx context.Context) (int, error) {
+	svc.mu.Lock()
+	svc.pending = make(map[int64]struct{})
+	svc.mu.Unlock()
+
+	cnt, err := svc.rebuild(ctx)
+
+	svc.mu.Lock()
+	pending := svc.pending
+	svc.pending = nil
+	svc.mu.Unlock()
+	for aid := range pending {
+		if er := svc.syncArticle(ctx, aid); er != nil {
+			svc.l.Error("重建索引之后同步文章失败", logger.Int("aid", aid), logger.Error(er))
+		}
+	}
+	return cnt, err
+}
+
+func (svc *searchService) rebuild(ctx context.Context) (int, error) {
+	var (
+		cursor domain.ArticleCursor
+		cnt    int
+	)
+	for {
+		arts, err := svc.artRepo.ListPub(ctx, cursor, svc.batchSize)
+		if err != nil {
+			return cnt, err
+		}
+		for _, art := range arts {
+			// 线上库里还有撤回的文章
+			if art.Status != domain.ArticleStatusPublished {
+				continue
+			}
+			if err = svc.repo.IndexArticle(ctx, art); err != nil {
+				return cnt, err
+			}
+			cnt++
+		}
+		cursor = domain.NextArticleCursor(arts, svc.batchSize)
+		if cursor.IsZero() {
+			return cnt, nil
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"geektime-basic-go/webook/internal/domain"
+	mocks "geektime-basic-go/webook/internal/repository/mocks"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func TestSearchService_SearchArticle(t *testing.T) {
+	hit := func(aid, uid int64) domain.ArticleSearchHit {
+		return domain.ArticleSearchHit{Article: domain.Article{ID: aid, Author: domain.Author{ID: uid}}}
+	}
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) (*mocks.MockSearchRepository, *mocks.MockUserRepository)
+		wantNames []string
+		wantErr   error
+	}{
+		{
+			name: "批量补上作者名字",
+			mock: func(ctrl *gomock.Controller) (*mocks.MockSearchRepository, *mocks.MockUserRepository) {
+				repo := mocks.NewMockSearchRepository(ctrl)
+				repo.EXPECT().SearchArticle(gomock.Any(), "go", 0, 10).Return(domain.ArticleSearchResult{
+					Total: 3,
+					Hits:  []domain.ArticleSearchHit{hit(1, 11), hit(2, 12), hit(3, 11)},
+				}, nil)
+				userRepo := mocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindByIDs(gomock.Any(), []int64{11, 12, 11}).Return(map[int64]domain.User{
+					11: {ID: 11, Nickname: "Tom"},
+					12: {ID: 12, Nickname: "Jerry"},
+				}, nil)
+				return repo, userRepo
+			},
+			wantNames: []string{"Tom", "Jerry", "Tom"},
+		},
+		{
+			name: "查询作者失败不影响搜索结果",
+			mock: func(ctrl *gomock.Controller) (*mocks.MockSearchRepository, *mocks.MockUserRepository) {
+				repo := mocks.NewMockSearchRepository(ctrl)
+				repo.EXPECT().SearchArticle(gomock.Any(), "go", 0, 10).Return(domain.ArticleSearchResult{
+					Total: 2,
+					Hits:  []domain.ArticleSearchHit{hit(1, 11), hit(2, 12)},
+				}, nil)
+				userRepo := mocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindByIDs(gomock.Any(), []int64{11, 12}).Return(map[int64]domain.User{
+					12: {ID: 12, Nickname: "Jerry"},
+				}, errors.New("db 错误"))
+				return repo, userRepo
+			},
+			wantNames: []string{"", "Jerry"},
+		},
+		{
+			name: "搜索失败",
+			mock: func(ctrl *gomock.Controller) (*mocks.MockSearchRepository, *mocks.MockUserRepository) {
+				repo := mocks.NewMockSearchRepository(ctrl)
+				repo.EXPECT().SearchArticle(gomock.Any(), "go", 0, 10).
+					Return(domain.ArticleSearchResult{}, errors.New("索引错误"))
+				return repo, mocks.NewMockUserRepository(ctrl)
+			},
+			wantErr: errors.New("索引错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, userRepo := tc.mock(ctrl)
+			svc := NewSearchService(repo, nil, userRepo, logger.NewNoOpLogger())
+			res, err := svc.SearchArticle(context.Background(), "go", 0, 10)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			require.Len(t, res.Hits, len(tc.wantNames))
+			for i, name := range tc.wantNames {
+				assert.Equal(t, name, res.Hits[i].Article.Author.Name)
+			}
+		})
+	}
+}
+
+func TestSearchService_SyncArticle(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (*mocks.MockSearchRepository, *mocks.MockArticleRepository)
+		wantErr error
+	}{
+		{
+			name: "已发表，加入索引",
+			mock: func(ctrl *gomock.Controller) (*mocks.MockSearchRepository, *mocks.MockArticleRepository) {
+				art := domain.Article{ID: 1, Title: "go", Status: domain.ArticleStatusPublished}
+				artRepo := mocks.NewMockArticleRepository(ctrl)
+				artRepo.EXPECT().ListPubByIDs(gomock.Any(), []int64{1}).Return([]domain.Article{art}, nil)
+				repo := mocks.NewMockSearchRepository(ctrl)
+				repo.EXPECT().IndexArticle(gomock.Any(), art).Return(nil)
+				return repo, artRepo
+			},
+		},
+		{
+			name: "已撤回，删除索引",
+			mock: func(ctrl *gomock.Controller) (*mocks.MockSearchRepository, *mocks.MockArticleRepository) {
+				artRepo := mocks.NewMockArticleRepository(ctrl)
+				artRepo.EXPECT().ListPubByIDs(gomock.Any(), []int64{1}).Return(nil, nil)
+				repo := mocks.NewMockSearchRepository(ctrl)
+				repo.EXPECT().DeleteArticle(gomock.Any(), int64(1)).Return(nil)
+				return repo, artRepo
+			},
+		},
+		{
+			name: "查询文章失败",
+			mock: func(ctrl *gomock.Controller) (*mocks.MockSearchRepository, *mocks.MockArticleRepository) {
+				artRepo := mocks.NewMockArticleRepository(ctrl)
+				artRepo.EXPECT().ListPubByIDs(gomock.Any(), []int64{1}).Return(nil, errors.New("db 错误"))
+				return mocks.NewMockSearchRepository(ctrl), artRepo
+			},
+			wantErr: errors.New("db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, artRepo := tc.mock(ctrl)
+			svc := NewSearchService(repo, artRepo, nil, logger.NewNoOpLogger())
+			err := svc.SyncArticle(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+// TestSearchService_RebuildArticleIndex 重建期间撤回的文章，快照里还是发表的状态，
+// 重建完之后要按照最新状态删掉
+func TestSearchService_RebuildArticleIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	art := domain.Article{ID: 1, Title: "go", Status: domain.ArticleStatusPublished}
+	repo := mocks.NewMockSearchRepository(ctrl)
+	artRepo := mocks.NewMockArticleRepository(ctrl)
+	svc := NewSearchService(repo, artRepo, nil, logger.NewNoOpLogger())
+
+	artRepo.EXPECT().ListPub(gomock.Any(), domain.ArticleCursor{}, 100).
+		DoAndReturn(func(ctx context.Context, cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+			// 读完快照之后，文章被撤回了，撤回事件先于快照写进索引
+			require.NoError(t, svc.SyncArticle(ctx, 1))
+			return []domain.Article{art}, nil
+		})
+	gomock.InOrder(
+		artRepo.EXPECT().ListPubByIDs(gomock.Any(), []int64{1}).Return(nil, nil),
+		repo.EXPECT().DeleteArticle(gomock.Any(), int64(1)).Return(nil),
+		repo.EXPECT().IndexArticle(gomock.Any(), art).Return(nil),
+		artRepo.EXPECT().ListPubByIDs(gomock.Any(), []int64{1}).Return(nil, nil),
+		repo.EXPECT().DeleteArticle(gomock.Any(), int64(1)).Return(nil),
+	)
+
+	cnt, err := svc.RebuildArticleIndex(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	// 重建完之后的事件不再记录
+	artRepo.EXPECT().ListPubByIDs(gomock.Any(), []int64{2}).Return(nil, nil)
+	repo.EXPECT().DeleteArticle(gomock.Any(), int64(2)).Return(nil)
+	require.NoError(t, svc.SyncArticle(context.Background(), 2))
+}
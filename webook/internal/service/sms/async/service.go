@@ -7,64 +7,101 @@ import (
 	"strings"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"geektime-basic-go/webook/internal/domain"
 	"geektime-basic-go/webook/internal/repository"
 	"geektime-basic-go/webook/internal/service/sms"
 )
 
-type service struct {
+// Service 发送失败的短信先存起来，后台定时重试。
+// 不再使用的时候要调用 Close，等待正在重试的这一批结束
+type Service struct {
 	svc           sms.Service
 	repo          repository.SMSRepository
 	retryInterval time.Duration
 	maxRetry      int64
 	ticker        *time.Ticker
+	cancel        context.CancelFunc
+	done          chan struct{}
 }
 
-func NewService(svc sms.Service, repo repository.SMSRepository, retryInterval time.Duration, maxRetry int64) sms.Service {
-	s := &service{svc: svc, repo: repo, ticker: time.NewTicker(retryInterval), maxRetry: maxRetry}
+func NewService(svc sms.Service, repo repository.SMSRepository, retryInterval time.Duration, maxRetry int64) *Service {
+	s := &Service{
+		svc:           svc,
+		repo:          repo,
+		retryInterval: retryInterval,
+		ticker:        time.NewTicker(retryInterval),
+		maxRetry:      maxRetry,
+		done:          make(chan struct{}),
+	}
 	s.startAsync()
 	return s
 }
 
-func (s *service) startAsync() {
+func (s *Service) startAsync() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
 	go func() {
-		for range s.ticker.C {
-			ctx, cancel := context.WithTimeout(context.Background(), 4*s.retryInterval/5)
-			reqs, err := s.repo.FindRetryWithMaxRetry(ctx, s.maxRetry, 2)
-			if err != nil {
-				//todo log
-				cancel()
-				continue
+		defer close(s.done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.ticker.C:
+				s.retry()
 			}
+		}
+	}()
+}
 
-			success := make([]int64, 0, len(reqs))
-			failed := make([]int64, 0, len(reqs))
-			for _, req := range reqs {
-				args := strings.Split(req.Args, ";")
-				numbers := strings.Split(req.Numbers, ",")
-				if err = s.svc.Send(ctx, req.Biz, args, numbers...); err != nil {
-					failed = append(failed, req.ID)
-					continue
-				}
-				success = append(success, req.ID)
-			}
+// retry 不受 Close 影响，正在重试的这一批会在超时时间内做完
+func (s *Service) retry() {
+	ctx, cancel := context.WithTimeout(context.Background(), 4*s.retryInterval/5)
+	defer cancel()
+	reqs, err := s.repo.FindRetryWithMaxRetry(ctx, s.maxRetry, 2)
+	if err != nil {
+		//todo log
+		return
+	}
 
-			go func() {
-				if err = s.repo.UpdateRetryCnt(ctx, failed); err != nil {
-					//todo log
-				}
-			}()
-			go func() {
-				if err = s.repo.UpdateStatus(ctx, success, 1); err != nil {
-					//todo log
-				}
-			}()
-			cancel()
+	success := make([]int64, 0, len(reqs))
+	failed := make([]int64, 0, len(reqs))
+	for _, req := range reqs {
+		args := strings.Split(req.Args, ";")
+		numbers := strings.Split(req.Numbers, ",")
+		if err = s.svc.Send(ctx, req.Biz, args, numbers...); err != nil {
+			failed = append(failed, req.ID)
+			continue
 		}
-	}()
+		success = append(success, req.ID)
+	}
+
+	var eg errgroup.Group
+	eg.Go(func() error {
+		return s.repo.UpdateRetryCnt(ctx, failed)
+	})
+	eg.Go(func() error {
+		return s.repo.UpdateStatus(ctx, success, 1)
+	})
+	if err = eg.Wait(); err != nil {
+		//todo log
+	}
+}
+
+// Close 停止重试，等待正在重试的这一批结束
+func (s *Service) Close(ctx context.Context) error {
+	s.ticker.Stop()
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (s *service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
 	err := s.svc.Send(ctx, tplId, args, numbers...)
 	if errors.Is(err, sms.ErrLimited) || errors.Is(err, sms.ErrServiceProviderException) {
 		bs, er := json.Marshal(&args)
@@ -13,7 +13,7 @@ import (
 
 	"geektime-basic-go/webook/internal/domain"
 	"geektime-basic-go/webook/internal/repository"
-	"geektime-basic-go/webook/internal/repository/mocks"
+	mocks "geektime-basic-go/webook/internal/repository/mocks"
 )
 
 func TestUserService_Signup(t *testing.T) {
@@ -8,41 +8,67 @@ import (
 
 	"github.com/ecodeclub/ekit/slice"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"geektime-basic-go/webook/internal/domain"
 	"geektime-basic-go/webook/internal/errs"
 	"geektime-basic-go/webook/internal/service"
+	"geektime-basic-go/webook/pkg/diffx"
 	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
 	"geektime-basic-go/webook/pkg/logger"
 )
 
 type Handler struct {
-	svc service.ArticleService
-	l   logger.Logger
-	biz string
+	svc       service.ArticleService
+	searchSvc service.SearchService
+	rankSvc   service.RankingService
+	l         logger.Logger
+	biz       string
 }
 
-func NewArticleHandler(svc service.ArticleService, l logger.Logger) *Handler {
-	return &Handler{svc: svc, l: l, biz: "article"}
+func NewArticleHandler(svc service.ArticleService, searchSvc service.SearchService, rankSvc service.RankingService, l logger.Logger) *Handler {
+	return &Handler{svc: svc, searchSvc: searchSvc, rankSvc: rankSvc, l: l, biz: "article"}
 }
 
 func (ah *Handler) RegisterRoutes(s *gin.Engine) {
 	g := s.Group("/articles")
 	g.GET("/detail/:id", hf.WrapClaims(ah.Detail))
-	g.POST("/list", hf.WrapClaimsAndReq[LimitReq](ah.List))
+	g.POST("/list", hf.WrapClaimsAndReq[ListReq](ah.List))
+	g.GET("/search", hf.WrapReq[SearchReq](ah.Search))
+	g.GET("/tags/suggest", hf.WrapReq[TagSuggestReq](ah.SuggestTags))
 
 	g.POST("/edit", hf.WrapClaimsAndReq[Req](ah.Edit))
 	g.POST("/publish", hf.WrapClaimsAndReq[Req](ah.Publish))
 	g.POST("/withdraw", hf.WrapClaimsAndReq[Req](ah.Withdraw))
+	// 定时发表，重复调用就是修改发表时间
+	g.POST("/schedule", hf.WrapClaimsAndReq[Req](ah.SchedulePublish))
+	g.POST("/schedule/cancel", hf.WrapClaimsAndReq[Req](ah.CancelSchedule))
+
+	rev := g.Group("/revisions")
+	rev.POST("/list", hf.WrapClaimsAndReq[RevisionListReq](ah.ListRevisions))
+	rev.POST("/diff", hf.WrapClaimsAndReq[RevisionDiffReq](ah.DiffRevisions))
+	rev.POST("/restore", hf.WrapClaimsAndReq[RevisionRestoreReq](ah.RestoreRevision))
 
 	pub := g.Group("/pub")
 	pub.GET("/:id", hf.WrapClaims(ah.PubDetail))
 	pub.POST("/like", hf.WrapClaimsAndReq[LikeReq](ah.Like))
 	pub.POST("/collect", hf.WrapClaimsAndReq[CollectReq](ah.Collect))
+	pub.POST("/uncollect", hf.WrapClaimsAndReq[CollectReq](ah.Uncollect))
+	// 某个标签下的文章
+	pub.POST("/tag", hf.WrapClaimsAndReq[TagListReq](ah.ListPubByTag))
+	// 热榜
+	pub.GET("/top", hf.WrapClaimsAndReq[TopNReq](ah.TopN))
+	// 热榜的历史快照，以及两个时刻之间的排名变化
+	pub.POST("/top/snapshot", hf.WrapReq[RankingSnapshotReq](ah.RankingSnapshot))
+	pub.POST("/top/diff", hf.WrapReq[RankingDiffReq](ah.RankingDiff))
 }
 
 func (ah *Handler) Edit(ctx *gin.Context, req Req, uc hf.UserClaims) (hf.Response, error) {
 	id, err := ah.svc.Save(ctx, req.toDomain(uc.ID))
+	if errors.Is(err, service.ErrInvalidTags) {
+		return hf.Response{Code: errs.ArticleInvalidInput, Msg: err.Error()}, err
+	}
 	if err != nil {
 		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("保存数据失败: %w", err)
 	}
@@ -51,12 +77,40 @@ func (ah *Handler) Edit(ctx *gin.Context, req Req, uc hf.UserClaims) (hf.Respons
 
 func (ah *Handler) Publish(ctx *gin.Context, req Req, uc hf.UserClaims) (hf.Response, error) {
 	id, err := ah.svc.Publish(ctx, req.toDomain(uc.ID))
+	if errors.Is(err, service.ErrInvalidTags) {
+		return hf.Response{Code: errs.ArticleInvalidInput, Msg: err.Error()}, err
+	}
 	if err != nil {
 		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("发表失败: %w", err)
 	}
 	return hf.Response{Data: id}, nil
 }
 
+func (ah *Handler) SchedulePublish(ctx *gin.Context, req Req, uc hf.UserClaims) (hf.Response, error) {
+	id, err := ah.svc.SchedulePublish(ctx, req.toDomain(uc.ID))
+	if errors.Is(err, service.ErrInvalidPublishTime) {
+		return hf.Response{Code: errs.ArticleInvalidInput, Msg: "发表时间必须晚于当前时间"}, err
+	}
+	if errors.Is(err, service.ErrInvalidTags) {
+		return hf.Response{Code: errs.ArticleInvalidInput, Msg: err.Error()}, err
+	}
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("设置定时发表失败: %w", err)
+	}
+	return hf.Response{Data: id}, nil
+}
+
+func (ah *Handler) CancelSchedule(ctx *gin.Context, req Req, uc hf.UserClaims) (hf.Response, error) {
+	err := ah.svc.CancelSchedule(ctx, uc.ID, req.ID)
+	if errors.Is(err, service.ErrNotScheduled) {
+		return hf.Response{Code: errs.ArticleNotScheduled, Msg: "文章不存在或者没有定时发表"}, err
+	}
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("取消定时发表失败: %w", err)
+	}
+	return hf.Response{Msg: "OK"}, nil
+}
+
 func (ah *Handler) Withdraw(ctx *gin.Context, req Req, uc hf.UserClaims) (hf.Response, error) {
 	if err := ah.svc.Withdraw(ctx, uc.ID, req.ID); err != nil {
 		ah.l.Error("设置为尽自己可见失败", logger.Error(err), logger.Field{Key: "id", Value: req.ID})
@@ -100,32 +154,163 @@ func (ah *Handler) Detail(ctx *gin.Context, uc hf.UserClaims) (hf.Response, erro
 		Title:    art.Title,
 		Status:   art.Status.ToUint8(),
 		Content:  art.Content,
+		Tags:     art.Tags,
 		CreateAt: art.CreateAt.Format(time.DateTime),
 		UpdateAt: art.UpdateAt.Format(time.DateTime),
 	}}, nil
 }
 
-func (ah *Handler) List(ctx *gin.Context, req LimitReq, uc hf.UserClaims) (hf.Response, error) {
-	if req.Limit > 100 {
-		return hf.BadRequestError("请求错误"), fmt.Errorf("分页过大 %d", req.Limit)
+func (ah *Handler) List(ctx *gin.Context, req ListReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Limit <= 0 || req.Limit > 100 {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("分页大小不正确 %d", req.Limit)
+	}
+	cursor, err := domain.ParseArticleCursor(req.Cursor)
+	if err != nil {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("%w %s", err, req.Cursor)
 	}
 
-	arts, err := ah.svc.List(ctx, uc.ID, req.Offset, req.Limit)
+	arts, err := ah.svc.List(ctx, uc.ID, cursor, req.Limit)
 	if err != nil {
 		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("获得用户会话信息失败: %w", err)
 	}
 
-	fn := func(idx int, src domain.Article) Vo {
-		return Vo{
-			ID:       src.ID,
-			Title:    src.Title,
-			Abstract: src.Abstract(),
-			Status:   src.Status.ToUint8(),
-			CreateAt: src.CreateAt.Format(time.DateTime),
-			UpdateAt: src.UpdateAt.Format(time.DateTime),
-		}
+	return hf.Response{Data: ListVo{
+		Arts:       ah.toListVos(ctx, arts, uc.ID),
+		NextCursor: domain.NextArticleCursor(arts, req.Limit).Encode(),
+	}}, nil
+}
+
+func (ah *Handler) ListPubByTag(ctx *gin.Context, req TagListReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Tag == "" || req.Limit <= 0 || req.Limit > 100 {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("按标签查询参数错误 %+v", req)
+	}
+	cursor, err := domain.ParseArticleCursor(req.Cursor)
+	if err != nil {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("%w %s", err, req.Cursor)
+	}
+
+	arts, err := ah.svc.ListPubByTag(ctx, req.Tag, cursor, req.Limit)
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("按标签查询文章失败: %w", err)
+	}
+	return hf.Response{Data: ListVo{
+		Arts:       ah.toListVos(ctx, arts, uc.ID),
+		NextCursor: domain.NextArticleCursor(arts, req.Limit).Encode(),
+	}}, nil
+}
+
+func (ah *Handler) TopN(ctx *gin.Context, req TopNReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Board == "" {
+		req.Board = "hot_today"
+	}
+	arts, err := ah.rankSvc.TopN(ctx, req.Board)
+	if errors.Is(err, service.ErrUnknownRankingBoard) {
+		return hf.Response{Code: errs.ArticleInvalidInput, Msg: "榜单不存在"}, err
+	}
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("查询热榜失败: %w", err)
+	}
+	return hf.Response{Data: ah.toListVos(ctx, arts, uc.ID)}, nil
+}
+
+func (ah *Handler) RankingSnapshot(ctx *gin.Context, req RankingSnapshotReq) (hf.Response, error) {
+	s, err := ah.rankSvc.Snapshot(ctx, req.Board, ah.toTime(req.At))
+	if resp, ok := ah.rankingErrResp(err); ok {
+		return resp, err
+	}
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("查询热榜快照失败: %w", err)
+	}
+	return hf.Response{Data: newRankingSnapshotVo(s)}, nil
+}
+
+func (ah *Handler) RankingDiff(ctx *gin.Context, req RankingDiffReq) (hf.Response, error) {
+	diff, err := ah.rankSvc.DiffSnapshots(ctx, req.Board, time.UnixMilli(req.From), ah.toTime(req.To))
+	if resp, ok := ah.rankingErrResp(err); ok {
+		return resp, err
+	}
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("比较热榜快照失败: %w", err)
+	}
+	return hf.Response{Data: newRankingDiffVo(diff)}, nil
+}
+
+// toTime 毫秒数，为 0 的时候是当前时间
+func (ah *Handler) toTime(ms int64) time.Time {
+	if ms <= 0 {
+		return time.Now()
+	}
+	return time.UnixMilli(ms)
+}
+
+func (ah *Handler) rankingErrResp(err error) (hf.Response, bool) {
+	switch {
+	case errors.Is(err, service.ErrUnknownRankingBoard):
+		return hf.Response{Code: errs.ArticleInvalidInput, Msg: "榜单不存在"}, true
+	case errors.Is(err, service.ErrRankingSnapshotNotFound):
+		return hf.Response{Code: errs.ArticleInvalidInput, Msg: "这个时间之前没有快照"}, true
+	default:
+		return hf.Response{}, false
+	}
+}
+
+// toListVos 带上每篇文章的互动数据，以及当前用户是否点赞、收藏
+// 互动数据查询失败的时候降级，只返回文章本身
+func (ah *Handler) toListVos(ctx *gin.Context, arts []domain.Article, uid int64) []Vo {
+	vos := slice.Map(arts, func(idx int, src domain.Article) Vo {
+		return newAbstractVo(src)
+	})
+	ids := slice.Map(arts, func(idx int, src domain.Article) int64 {
+		return src.ID
+	})
+	intrs, err := ah.svc.GetInteractives(ctx.Request.Context(), ids, uid)
+	if err != nil {
+		ah.l.Warn("批量查询文章互动数据失败", logger.Int("uid", uid), logger.Error(err))
+		return vos
+	}
+	for i := range vos {
+		vos[i].setInteractive(intrs[vos[i].ID])
+	}
+	return vos
+}
+
+func (ah *Handler) SuggestTags(ctx *gin.Context, req TagSuggestReq) (hf.Response, error) {
+	if req.Limit <= 0 || req.Limit > 20 {
+		req.Limit = 10
+	}
+	tags, err := ah.svc.SuggestTags(ctx, req.Prefix, req.Limit)
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("标签自动补全失败: %w", err)
+	}
+	return hf.Response{Data: slice.Map(tags, func(idx int, src domain.Tag) TagVo {
+		return TagVo{Name: src.Name, ArticleCnt: src.ArticleCnt}
+	})}, nil
+}
+
+func (ah *Handler) Search(ctx *gin.Context, req SearchReq) (hf.Response, error) {
+	if req.Q == "" || req.Offset < 0 || req.Limit > 100 {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("搜索参数错误 %+v", req)
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+
+	res, err := ah.searchSvc.SearchArticle(ctx, req.Q, req.Offset, req.Limit)
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("搜索文章失败: %w", err)
 	}
-	return hf.Response{Data: slice.Map[domain.Article, Vo](arts, fn)}, nil
+	return hf.Response{Data: SearchVo{
+		Total: res.Total,
+		Arts: slice.Map(res.Hits, func(idx int, src domain.ArticleSearchHit) Vo {
+			return Vo{
+				ID:       src.Article.ID,
+				Title:    src.Title,
+				Abstract: src.Abstract,
+				Author:   src.Article.Author.Name,
+				UpdateAt: src.Article.UpdateAt.Format(time.DateTime),
+			}
+		}),
+	}}, nil
 }
 
 func (ah *Handler) Like(ctx *gin.Context, req LikeReq, uc hf.UserClaims) (hf.Response, error) {
@@ -136,8 +321,64 @@ func (ah *Handler) Like(ctx *gin.Context, req LikeReq, uc hf.UserClaims) (hf.Res
 }
 
 func (ah *Handler) Collect(ctx *gin.Context, req CollectReq, uc hf.UserClaims) (hf.Response, error) {
-	if err := ah.svc.Collect(ctx.Request.Context(), ah.biz, req.ID, req.Cid, uc.ID); err != nil {
+	err := ah.svc.Collect(ctx.Request.Context(), ah.biz, req.ID, req.Cid, uc.ID)
+	switch status.Code(err) {
+	case codes.OK:
+		return hf.RespSuccess("OK"), nil
+	case codes.NotFound:
+		return hf.Response{Code: errs.CollectionNotFound, Msg: "收藏夹不存在"}, err
+	case codes.AlreadyExists:
+		return hf.Response{Code: errs.CollectionItemExists, Msg: "已经收藏到了别的收藏夹"}, err
+	default:
 		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), err
 	}
+}
+
+func (ah *Handler) Uncollect(ctx *gin.Context, req CollectReq, uc hf.UserClaims) (hf.Response, error) {
+	if err := ah.svc.Uncollect(ctx.Request.Context(), ah.biz, req.ID, uc.ID); err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("取消收藏失败: %w", err)
+	}
 	return hf.RespSuccess("OK"), nil
 }
+
+func (ah *Handler) ListRevisions(ctx *gin.Context, req RevisionListReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Offset < 0 || req.Limit <= 0 || req.Limit > 100 {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("分页参数不正确 %+v", req)
+	}
+
+	revs, err := ah.svc.ListRevisions(ctx, uc.ID, req.ID, req.Offset, req.Limit)
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("获得文章历史版本失败: %w", err)
+	}
+	return hf.Response{Data: slice.Map(revs, func(idx int, src domain.ArticleRevision) RevisionVo {
+		return newRevisionVo(src)
+	})}, nil
+}
+
+func (ah *Handler) DiffRevisions(ctx *gin.Context, req RevisionDiffReq, uc hf.UserClaims) (hf.Response, error) {
+	diff, err := ah.svc.DiffRevisions(ctx, uc.ID, req.ID, req.From, req.To)
+	if errors.Is(err, service.ErrRevisionNotFound) {
+		return hf.Response{Code: errs.ArticleRevisionNotFound, Msg: "历史版本不存在"}, err
+	}
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("比较文章历史版本失败: %w", err)
+	}
+	return hf.Response{Data: RevisionDiffVo{
+		From: newRevisionVo(diff.From),
+		To:   newRevisionVo(diff.To),
+		Lines: slice.Map(diff.Lines, func(idx int, src diffx.Line) DiffLineVo {
+			return DiffLineVo{Op: src.Op.String(), Text: src.Text}
+		}),
+	}}, nil
+}
+
+func (ah *Handler) RestoreRevision(ctx *gin.Context, req RevisionRestoreReq, uc hf.UserClaims) (hf.Response, error) {
+	id, err := ah.svc.RestoreRevision(ctx, uc.ID, req.ID, req.Version)
+	if errors.Is(err, service.ErrRevisionNotFound) {
+		return hf.Response{Code: errs.ArticleRevisionNotFound, Msg: "历史版本不存在"}, err
+	}
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.ArticleInternalServerError), fmt.Errorf("恢复文章历史版本失败: %w", err)
+	}
+	return hf.Response{Data: id}, nil
+}
@@ -7,22 +7,32 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
 
 	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/errs"
 	"geektime-basic-go/webook/internal/service"
 	svcmocks "geektime-basic-go/webook/internal/service/mocks"
 	myjwt "geektime-basic-go/webook/internal/web/jwt"
+	"geektime-basic-go/webook/pkg/diffx"
 	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
 	"geektime-basic-go/webook/pkg/logger"
 )
 
+func TestMain(m *testing.M) {
+	hf.InitCounter(prometheus.CounterOpts{Namespace: "test", Name: "article_resp_code"})
+	os.Exit(m.Run())
+}
+
 func TestArticleHandler_Edit(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -99,7 +109,7 @@ func TestArticleHandler_Edit(t *testing.T) {
 			server.Use(func(ctx *gin.Context) {
 				ctx.Set("user", myjwt.UserClaims{ID: 123})
 			})
-			uh := NewArticleHandler(tc.mock(ctrl), logger.NewZapLogger(zap.NewNop()))
+			uh := NewArticleHandler(tc.mock(ctrl), nil, nil, logger.NewZapLogger(zap.NewNop(), zap.NewAtomicLevel()))
 			uh.RegisterRoutes(server)
 
 			req := reqBuilder(t, http.MethodPost, "/articles/edit", bytes.NewBuffer(tc.reqBody))
@@ -194,7 +204,7 @@ func TestArticleHandler_Publish(t *testing.T) {
 			server.Use(func(ctx *gin.Context) {
 				ctx.Set("user", myjwt.UserClaims{ID: 123})
 			})
-			uh := NewArticleHandler(tc.mock(ctrl), logger.NewZapLogger(zap.NewNop()))
+			uh := NewArticleHandler(tc.mock(ctrl), nil, nil, logger.NewZapLogger(zap.NewNop(), zap.NewAtomicLevel()))
 			uh.RegisterRoutes(server)
 
 			req := reqBuilder(t, http.MethodPost, "/articles/publish", bytes.NewBuffer(tc.reqBody))
@@ -213,6 +223,207 @@ func TestArticleHandler_Publish(t *testing.T) {
 	}
 }
 
+func TestArticleHandler_Revisions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) service.ArticleService
+		path    string
+		reqBody []byte
+
+		wantRes hf.Response
+	}{
+		{
+			name: "查询历史版本",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().ListRevisions(gomock.Any(), int64(123), int64(1), 0, 10).
+					Return([]domain.ArticleRevision{{Version: 1}}, nil)
+				return svc
+			},
+			path:    "/articles/revisions/list",
+			reqBody: []byte(`{"id":1,"offset":0,"limit":10}`),
+			wantRes: hf.Response{Data: []any{
+				map[string]any{"version": float64(1), "title": "", "abstract": "", "status": float64(0), "create_at": time.Time{}.Format(time.DateTime)},
+			}},
+		},
+		{
+			name: "查询历史版本，分页大小不正确",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				return svcmocks.NewMockArticleService(ctrl)
+			},
+			path:    "/articles/revisions/list",
+			reqBody: []byte(`{"id":1,"offset":0,"limit":0}`),
+			wantRes: hf.BadRequestError("请求错误"),
+		},
+		{
+			name: "查询历史版本，分页过大",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				return svcmocks.NewMockArticleService(ctrl)
+			},
+			path:    "/articles/revisions/list",
+			reqBody: []byte(`{"id":1,"offset":0,"limit":101}`),
+			wantRes: hf.BadRequestError("请求错误"),
+		},
+		{
+			name: "查询历史版本，偏移量是负数",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				return svcmocks.NewMockArticleService(ctrl)
+			},
+			path:    "/articles/revisions/list",
+			reqBody: []byte(`{"id":1,"offset":-1,"limit":10}`),
+			wantRes: hf.BadRequestError("请求错误"),
+		},
+		{
+			name: "比较两个版本",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().DiffRevisions(gomock.Any(), int64(123), int64(1), int64(1), int64(2)).
+					Return(domain.ArticleRevisionDiff{
+						From:  domain.ArticleRevision{Version: 1},
+						To:    domain.ArticleRevision{Version: 2},
+						Lines: []diffx.Line{{Op: diffx.OpInsert, Text: "a"}},
+					}, nil)
+				return svc
+			},
+			path:    "/articles/revisions/diff",
+			reqBody: []byte(`{"id":1,"from":1,"to":2}`),
+			wantRes: hf.Response{Data: map[string]any{
+				"from":  map[string]any{"version": float64(1), "title": "", "abstract": "", "status": float64(0), "create_at": time.Time{}.Format(time.DateTime)},
+				"to":    map[string]any{"version": float64(2), "title": "", "abstract": "", "status": float64(0), "create_at": time.Time{}.Format(time.DateTime)},
+				"lines": []any{map[string]any{"op": "insert", "text": "a"}},
+			}},
+		},
+		{
+			name: "比较的版本不存在",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().DiffRevisions(gomock.Any(), int64(123), int64(1), int64(1), int64(3)).
+					Return(domain.ArticleRevisionDiff{}, service.ErrRevisionNotFound)
+				return svc
+			},
+			path:    "/articles/revisions/diff",
+			reqBody: []byte(`{"id":1,"from":1,"to":3}`),
+			wantRes: hf.Response{Code: errs.ArticleRevisionNotFound, Msg: "历史版本不存在"},
+		},
+		{
+			name: "比较失败",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().DiffRevisions(gomock.Any(), int64(123), int64(1), int64(1), int64(2)).
+					Return(domain.ArticleRevisionDiff{}, errors.New("模拟失败"))
+				return svc
+			},
+			path:    "/articles/revisions/diff",
+			reqBody: []byte(`{"id":1,"from":1,"to":2}`),
+			wantRes: hf.InternalServerErrorWith(errs.ArticleInternalServerError),
+		},
+		{
+			name: "恢复成功",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().RestoreRevision(gomock.Any(), int64(123), int64(1), int64(2)).Return(int64(1), nil)
+				return svc
+			},
+			path:    "/articles/revisions/restore",
+			reqBody: []byte(`{"id":1,"version":2}`),
+			wantRes: hf.Response{Data: float64(1)},
+		},
+		{
+			name: "恢复的版本不存在",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().RestoreRevision(gomock.Any(), int64(123), int64(1), int64(2)).
+					Return(int64(0), service.ErrRevisionNotFound)
+				return svc
+			},
+			path:    "/articles/revisions/restore",
+			reqBody: []byte(`{"id":1,"version":2}`),
+			wantRes: hf.Response{Code: errs.ArticleRevisionNotFound, Msg: "历史版本不存在"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", myjwt.UserClaims{ID: 123})
+			})
+			NewArticleHandler(tc.mock(ctrl), nil, nil, logger.NewNoOpLogger()).RegisterRoutes(server)
+
+			req := reqBuilder(t, http.MethodPost, tc.path, bytes.NewBuffer(tc.reqBody))
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			var webRes hf.Response
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&webRes))
+			assert.Equal(t, tc.wantRes, webRes)
+		})
+	}
+}
+
+func TestArticleHandler_CancelSchedule(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.ArticleService
+
+		wantRes hf.Response
+	}{
+		{
+			name: "取消成功",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().CancelSchedule(gomock.Any(), int64(123), int64(1)).Return(nil)
+				return svc
+			},
+			wantRes: hf.Response{Msg: "OK"},
+		},
+		{
+			name: "文章不存在或者没有定时发表",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().CancelSchedule(gomock.Any(), int64(123), int64(1)).Return(service.ErrNotScheduled)
+				return svc
+			},
+			wantRes: hf.Response{Code: errs.ArticleNotScheduled, Msg: "文章不存在或者没有定时发表"},
+		},
+		{
+			name: "取消失败",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().CancelSchedule(gomock.Any(), int64(123), int64(1)).Return(errors.New("模拟失败"))
+				return svc
+			},
+			wantRes: hf.InternalServerErrorWith(errs.ArticleInternalServerError),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", myjwt.UserClaims{ID: 123})
+			})
+			NewArticleHandler(tc.mock(ctrl), nil, nil, logger.NewNoOpLogger()).RegisterRoutes(server)
+
+			req := reqBuilder(t, http.MethodPost, "/articles/schedule/cancel", bytes.NewBuffer([]byte(`{"id":1}`)))
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			var webRes hf.Response
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&webRes))
+			assert.Equal(t, tc.wantRes, webRes)
+		})
+	}
+}
+
 func reqBuilder(t *testing.T, method, url string, body io.Reader, headers ...[]string) *http.Request {
 	req, err := http.NewRequest(method, url, body)
 	require.NoError(t, err)
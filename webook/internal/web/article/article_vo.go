@@ -1,6 +1,10 @@
 package article
 
-import "geektime-basic-go/webook/internal/domain"
+import (
+	"time"
+
+	"geektime-basic-go/webook/internal/domain"
+)
 
 type Vo struct {
 	ID    int64  `json:"id"`
@@ -8,16 +12,18 @@ type Vo struct {
 	// 摘要
 	Abstract string `json:"abstract"`
 	// 内容
-	Content  string `json:"content"`
-	Status   uint8  `json:"status"`
-	Author   string `json:"author"`
-	CreateAt string `json:"create_at"`
-	UpdateAt string `json:"update_at"`
+	Content  string   `json:"content"`
+	Status   uint8    `json:"status"`
+	Author   string   `json:"author"`
+	Tags     []string `json:"tags"`
+	CreateAt string   `json:"create_at"`
+	UpdateAt string   `json:"update_at"`
 
 	// 点赞之类的信息
 	LikeCnt    int64 `json:"likeCnt"`
 	CollectCnt int64 `json:"collectCnt"`
 	ReadCnt    int64 `json:"readCnt"`
+	CommentCnt int64 `json:"commentCnt"`
 
 	// 个人是否点赞的信息
 	Liked     bool `json:"liked"`
@@ -28,11 +34,98 @@ type Req struct {
 	ID      int64  `json:"id"`
 	Title   string `json:"title"`
 	Content string `json:"content"`
+	// PublishAt 定时发表的时间，毫秒数
+	PublishAt int64 `json:"publish_at"`
+	// Tags 不传就不修改原有的标签，传空数组会清空标签
+	Tags []string `json:"tags"`
+}
+
+type ListReq struct {
+	// Cursor 上一页返回的 next_cursor，第一页传空
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+}
+
+type TagListReq struct {
+	Tag    string `json:"tag"`
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+}
+
+// TopNReq Board 为空的时候是今日热榜
+type TopNReq struct {
+	Board string `form:"board"`
+}
+
+// RankingSnapshotReq At 毫秒数，为 0 的时候是最新的快照
+type RankingSnapshotReq struct {
+	Board string `json:"board"`
+	At    int64  `json:"at"`
+}
+
+// RankingDiffReq From 和 To 都是毫秒数，To 为 0 的时候和最新的快照比较
+type RankingDiffReq struct {
+	Board string `json:"board"`
+	From  int64  `json:"from"`
+	To    int64  `json:"to"`
+}
+
+type RankingItemVo struct {
+	ID    int64   `json:"id"`
+	Title string  `json:"title"`
+	Rank  int     `json:"rank"`
+	Score float64 `json:"score"`
+}
+
+type RankingSnapshotVo struct {
+	Version  int64           `json:"version"`
+	Board    string          `json:"board"`
+	CreateAt string          `json:"create_at"`
+	Items    []RankingItemVo `json:"items"`
 }
 
-type LimitReq struct {
-	Offset int `json:"offset"`
-	Limit  int `json:"limit"`
+type RankingChangeVo struct {
+	RankingItemVo
+	// PrevRank 为 0 代表新上榜
+	PrevRank int  `json:"prev_rank"`
+	IsNew    bool `json:"is_new"`
+	// Move 正数是上升，负数是下降
+	Move int `json:"move"`
+}
+
+type RankingDiffVo struct {
+	From    RankingSnapshotVo `json:"from"`
+	To      RankingSnapshotVo `json:"to"`
+	Changes []RankingChangeVo `json:"changes"`
+	Dropped []RankingItemVo   `json:"dropped"`
+}
+
+type TagSuggestReq struct {
+	Prefix string `form:"prefix"`
+	Limit  int    `form:"limit"`
+}
+
+type TagVo struct {
+	Name       string `json:"name"`
+	ArticleCnt int64  `json:"article_cnt"`
+}
+
+type ListVo struct {
+	Arts []Vo `json:"arts"`
+	// NextCursor 为空说明没有下一页了
+	NextCursor string `json:"next_cursor"`
+}
+
+type SearchReq struct {
+	Q      string `form:"q"`
+	Offset int    `form:"offset"`
+	Limit  int    `form:"limit"`
+}
+
+type SearchVo struct {
+	Total int `json:"total"`
+	// Arts 中的 Title 和 Abstract 命中的部分用 <em> 标签高亮
+	Arts []Vo `json:"arts"`
 }
 
 type LikeReq struct {
@@ -41,15 +134,128 @@ type LikeReq struct {
 }
 
 type CollectReq struct {
-	ID  int64 `json:"id"`
+	ID int64 `json:"id"`
+	// Cid 收藏到哪个收藏夹，取消收藏的时候不需要
 	Cid int64 `json:"cid"`
 }
 
+type RevisionListReq struct {
+	ID     int64 `json:"id"`
+	Offset int   `json:"offset"`
+	Limit  int   `json:"limit"`
+}
+
+type RevisionDiffReq struct {
+	ID   int64 `json:"id"`
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+type RevisionRestoreReq struct {
+	ID      int64 `json:"id"`
+	Version int64 `json:"version"`
+}
+
+type RevisionVo struct {
+	Version  int64  `json:"version"`
+	Title    string `json:"title"`
+	Abstract string `json:"abstract"`
+	Status   uint8  `json:"status"`
+	CreateAt string `json:"create_at"`
+}
+
+type DiffLineVo struct {
+	// Op 取值 equal, insert, delete
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type RevisionDiffVo struct {
+	From  RevisionVo   `json:"from"`
+	To    RevisionVo   `json:"to"`
+	Lines []DiffLineVo `json:"lines"`
+}
+
+func newRevisionVo(rev domain.ArticleRevision) RevisionVo {
+	return RevisionVo{
+		Version:  rev.Version,
+		Title:    rev.Title,
+		Abstract: rev.Abstract(),
+		Status:   rev.Status.ToUint8(),
+		CreateAt: rev.CreateAt.Format(time.DateTime),
+	}
+}
+
+// newAbstractVo 列表页只展示摘要
+func newAbstractVo(art domain.Article) Vo {
+	return Vo{
+		ID:       art.ID,
+		Title:    art.Title,
+		Abstract: art.Abstract(),
+		Status:   art.Status.ToUint8(),
+		Tags:     art.Tags,
+		CreateAt: art.CreateAt.Format(time.DateTime),
+		UpdateAt: art.UpdateAt.Format(time.DateTime),
+	}
+}
+
+func (vo *Vo) setInteractive(intr domain.Interactive) {
+	vo.ReadCnt = intr.ReadCnt
+	vo.LikeCnt = intr.LikeCnt
+	vo.CollectCnt = intr.CollectCnt
+	vo.CommentCnt = intr.CommentCnt
+	vo.Liked = intr.Liked
+	vo.Collected = intr.Collected
+}
+
 func (req *Req) toDomain(uid int64) domain.Article {
-	return domain.Article{
+	art := domain.Article{
 		ID:      req.ID,
 		Title:   req.Title,
 		Content: req.Content,
 		Author:  domain.Author{ID: uid},
+		Tags:    req.Tags,
+	}
+	if req.PublishAt > 0 {
+		art.PublishAt = time.UnixMilli(req.PublishAt)
+	}
+	return art
+}
+
+func newRankingItemVo(item domain.RankingItem) RankingItemVo {
+	return RankingItemVo{ID: item.BizID, Title: item.Title, Rank: item.Rank, Score: item.Score}
+}
+
+func newRankingSnapshotVo(s domain.RankingSnapshot) RankingSnapshotVo {
+	items := make([]RankingItemVo, 0, len(s.Items))
+	for _, item := range s.Items {
+		items = append(items, newRankingItemVo(item))
+	}
+	return RankingSnapshotVo{
+		Version:  s.ID,
+		Board:    s.Board,
+		CreateAt: s.CreateAt.Format(time.DateTime),
+		Items:    items,
+	}
+}
+
+func newRankingDiffVo(diff domain.RankingDiff) RankingDiffVo {
+	res := RankingDiffVo{
+		From:    newRankingSnapshotVo(diff.From),
+		To:      newRankingSnapshotVo(diff.To),
+		Changes: make([]RankingChangeVo, 0, len(diff.Changes)),
+		Dropped: make([]RankingItemVo, 0, len(diff.Dropped)),
+	}
+	for _, c := range diff.Changes {
+		res.Changes = append(res.Changes, RankingChangeVo{
+			RankingItemVo: newRankingItemVo(c.RankingItem),
+			PrevRank:      c.PrevRank,
+			IsNew:         c.IsNew(),
+			Move:          c.Move(),
+		})
+	}
+	for _, item := range diff.Dropped {
+		res.Dropped = append(res.Dropped, newRankingItemVo(item))
 	}
+	return res
 }
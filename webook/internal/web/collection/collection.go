@@ -0,0 +1,158 @@
+package collection
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	intrv1 "geektime-basic-go/webook/api/proto/gen/interactive"
+	"geektime-basic-go/webook/internal/errs"
+	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
+)
+
+// maxNameLen 收藏夹名字的最大长度，按字符计算
+const maxNameLen = 64
+
+type Handler struct {
+	client intrv1.InteractiveServiceClient
+}
+
+func NewCollectionHandler(client intrv1.InteractiveServiceClient) *Handler {
+	return &Handler{client: client}
+}
+
+func (h *Handler) RegisterRoutes(s *gin.Engine) {
+	g := s.Group("/collections")
+	g.POST("/create", hf.WrapClaimsAndReq[CreateReq](h.Create))
+	g.POST("/rename", hf.WrapClaimsAndReq[RenameReq](h.Rename))
+	g.POST("/visibility", hf.WrapClaimsAndReq[VisibilityReq](h.SetVisibility))
+	g.POST("/delete", hf.WrapClaimsAndReq[DeleteReq](h.Delete))
+	g.GET("/list", hf.WrapClaims(h.List))
+	// 浏览别人公开的收藏夹
+	g.POST("/user", hf.WrapClaimsAndReq[UserReq](h.ListByUser))
+	g.POST("/items", hf.WrapClaimsAndReq[ItemsReq](h.Items))
+	g.POST("/move", hf.WrapClaimsAndReq[MoveReq](h.Move))
+}
+
+func (h *Handler) Create(ctx *gin.Context, req CreateReq, uc hf.UserClaims) (hf.Response, error) {
+	if err := h.checkName(req.Name); err != nil {
+		return hf.Response{Code: errs.CollectionInvalidInput, Msg: "收藏夹名字不合法"}, err
+	}
+	resp, err := h.client.CreateCollection(ctx, &intrv1.CreateCollectionRequest{Uid: uc.ID, Name: req.Name, Public: req.Public})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.CollectionInternalServerError), fmt.Errorf("创建收藏夹失败: %w", err)
+	}
+	return hf.Response{Data: resp.GetCid()}, nil
+}
+
+func (h *Handler) Rename(ctx *gin.Context, req RenameReq, uc hf.UserClaims) (hf.Response, error) {
+	if err := h.checkName(req.Name); err != nil {
+		return hf.Response{Code: errs.CollectionInvalidInput, Msg: "收藏夹名字不合法"}, err
+	}
+	_, err := h.client.RenameCollection(ctx, &intrv1.RenameCollectionRequest{Uid: uc.ID, Cid: req.Cid, Name: req.Name})
+	return h.toResponse(err, "重命名收藏夹失败")
+}
+
+func (h *Handler) SetVisibility(ctx *gin.Context, req VisibilityReq, uc hf.UserClaims) (hf.Response, error) {
+	_, err := h.client.SetCollectionPublic(ctx, &intrv1.SetCollectionPublicRequest{Uid: uc.ID, Cid: req.Cid, Public: req.Public})
+	return h.toResponse(err, "修改收藏夹可见性失败")
+}
+
+func (h *Handler) Delete(ctx *gin.Context, req DeleteReq, uc hf.UserClaims) (hf.Response, error) {
+	_, err := h.client.DeleteCollection(ctx, &intrv1.DeleteCollectionRequest{Uid: uc.ID, Cid: req.Cid})
+	return h.toResponse(err, "删除收藏夹失败")
+}
+
+func (h *Handler) List(ctx *gin.Context, uc hf.UserClaims) (hf.Response, error) {
+	resp, err := h.client.ListCollections(ctx, &intrv1.ListCollectionsRequest{Uid: uc.ID})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.CollectionInternalServerError), fmt.Errorf("查询收藏夹列表失败: %w", err)
+	}
+	return hf.Response{Data: h.toVos(resp.GetCollections())}, nil
+}
+
+func (h *Handler) ListByUser(ctx *gin.Context, req UserReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Uid <= 0 || req.Uid == uc.ID {
+		return h.List(ctx, uc)
+	}
+	resp, err := h.client.ListPublicCollections(ctx, &intrv1.ListPublicCollectionsRequest{Uid: req.Uid})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.CollectionInternalServerError), fmt.Errorf("查询公开收藏夹失败: %w", err)
+	}
+	return hf.Response{Data: h.toVos(resp.GetCollections())}, nil
+}
+
+func (h *Handler) Items(ctx *gin.Context, req ItemsReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Limit > 100 {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("分页过大 %d", req.Limit)
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	resp, err := h.client.ListCollectionItems(ctx, &intrv1.ListCollectionItemsRequest{
+		Uid:   uc.ID,
+		Cid:   req.Cid,
+		MaxId: req.MaxID,
+		Limit: req.Limit,
+	})
+	if err != nil {
+		return h.toResponse(err, "查询收藏列表失败")
+	}
+	return hf.Response{Data: slice.Map(resp.GetItems(), func(idx int, src *intrv1.CollectionItem) ItemVo {
+		return ItemVo{
+			ID:       src.GetId(),
+			Cid:      src.GetCid(),
+			Biz:      src.GetBiz(),
+			BizID:    src.GetBizId(),
+			CreateAt: time.UnixMilli(src.GetCreateAt()).Format(time.DateTime),
+		}
+	})}, nil
+}
+
+func (h *Handler) Move(ctx *gin.Context, req MoveReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Biz == "" || req.BizID <= 0 {
+		return hf.Response{Code: errs.CollectionInvalidInput, Msg: "参数错误"}, fmt.Errorf("移动的收藏不正确 %s %d", req.Biz, req.BizID)
+	}
+	_, err := h.client.MoveCollectionItem(ctx, &intrv1.MoveCollectionItemRequest{
+		Biz:   req.Biz,
+		BizId: req.BizID,
+		Cid:   req.Cid,
+		Uid:   uc.ID,
+	})
+	return h.toResponse(err, "移动收藏失败")
+}
+
+func (h *Handler) toVos(cs []*intrv1.Collection) []Vo {
+	return slice.Map(cs, func(idx int, src *intrv1.Collection) Vo {
+		return Vo{
+			ID:       src.GetId(),
+			Name:     src.GetName(),
+			Public:   src.GetPublic(),
+			CreateAt: time.UnixMilli(src.GetCreateAt()).Format(time.DateTime),
+			UpdateAt: time.UnixMilli(src.GetUpdateAt()).Format(time.DateTime),
+		}
+	})
+}
+
+func (h *Handler) checkName(name string) error {
+	if n := utf8.RuneCountInString(name); n == 0 || n > maxNameLen {
+		return fmt.Errorf("收藏夹名字长度不正确 %d", n)
+	}
+	return nil
+}
+
+func (h *Handler) toResponse(err error, msg string) (hf.Response, error) {
+	switch status.Code(err) {
+	case codes.OK:
+		return hf.Response{Msg: "OK"}, nil
+	case codes.NotFound:
+		return hf.Response{Code: errs.CollectionNotFound, Msg: "收藏夹或者收藏不存在"}, err
+	default:
+		return hf.InternalServerErrorWith(errs.CollectionInternalServerError), fmt.Errorf("%s: %w", msg, err)
+	}
+}
@@ -0,0 +1,55 @@
+package collection
+
+type CreateReq struct {
+	Name   string `json:"name"`
+	Public bool   `json:"public"`
+}
+
+type RenameReq struct {
+	Cid  int64  `json:"cid"`
+	Name string `json:"name"`
+}
+
+type VisibilityReq struct {
+	Cid    int64 `json:"cid"`
+	Public bool  `json:"public"`
+}
+
+type UserReq struct {
+	Uid int64 `json:"uid"`
+}
+
+type DeleteReq struct {
+	Cid int64 `json:"cid"`
+}
+
+type ItemsReq struct {
+	// Cid 为 0 代表自己的默认收藏夹，别人的收藏夹只能看公开的
+	Cid int64 `json:"cid"`
+	// MaxID 上一页最后一条收藏的 ID，第一页不传
+	MaxID int64 `json:"max_id"`
+	Limit int64 `json:"limit"`
+}
+
+type MoveReq struct {
+	Biz   string `json:"biz"`
+	BizID int64  `json:"biz_id"`
+	// Cid 目标收藏夹
+	Cid int64 `json:"cid"`
+}
+
+type Vo struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Public   bool   `json:"public"`
+	CreateAt string `json:"create_at"`
+	UpdateAt string `json:"update_at"`
+}
+
+type ItemVo struct {
+	ID       int64  `json:"id"`
+	Cid      int64  `json:"cid"`
+	Biz      string `json:"biz"`
+	BizID    int64  `json:"biz_id"`
+	CreateAt string `json:"create_at"`
+}
@@ -0,0 +1,124 @@
+package comment
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commentv1 "geektime-basic-go/webook/api/proto/gen/comment"
+	"geektime-basic-go/webook/internal/errs"
+	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// maxContentLen 评论内容的最大长度，按字符计算
+const maxContentLen = 1000
+
+type Handler struct {
+	client commentv1.CommentServiceClient
+	l      logger.Logger
+}
+
+func NewCommentHandler(client commentv1.CommentServiceClient, l logger.Logger) *Handler {
+	return &Handler{client: client, l: l}
+}
+
+func (h *Handler) RegisterRoutes(s *gin.Engine) {
+	g := s.Group("/comments")
+	g.POST("/create", hf.WrapClaimsAndReq[CreateReq](h.Create))
+	g.POST("/delete", hf.WrapClaimsAndReq[DeleteReq](h.Delete))
+	g.POST("/list", hf.WrapReq[ListReq](h.List))
+	g.POST("/replies", hf.WrapReq[RepliesReq](h.Replies))
+}
+
+func (h *Handler) Create(ctx *gin.Context, req CreateReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Biz == "" || req.BizID <= 0 || req.Content == "" || utf8.RuneCountInString(req.Content) > maxContentLen {
+		return hf.Response{Code: errs.CommentInvalidInput, Msg: "参数错误"}, fmt.Errorf("创建评论参数错误 %+v", req)
+	}
+
+	resp, err := h.client.CreateComment(ctx, &commentv1.CreateCommentRequest{Comment: &commentv1.Comment{
+		Biz:      req.Biz,
+		BizId:    req.BizID,
+		Uid:      uc.ID,
+		ParentId: req.ParentID,
+		Content:  req.Content,
+	}})
+	switch status.Code(err) {
+	case codes.OK:
+		return hf.Response{Data: resp.GetId()}, nil
+	case codes.NotFound, codes.InvalidArgument:
+		return hf.Response{Code: errs.CommentInvalidInput, Msg: "回复的评论不存在"}, err
+	default:
+		return hf.InternalServerErrorWith(errs.CommentInternalServerError), fmt.Errorf("创建评论失败: %w", err)
+	}
+}
+
+func (h *Handler) Delete(ctx *gin.Context, req DeleteReq, uc hf.UserClaims) (hf.Response, error) {
+	_, err := h.client.DeleteComment(ctx, &commentv1.DeleteCommentRequest{Id: req.ID, Uid: uc.ID})
+	switch status.Code(err) {
+	case codes.OK:
+		return hf.Response{Msg: "OK"}, nil
+	case codes.NotFound, codes.PermissionDenied:
+		return hf.Response{Code: errs.CommentInvalidInput, Msg: "评论不存在"}, fmt.Errorf("删除评论失败, uid %d: %w", uc.ID, err)
+	default:
+		return hf.InternalServerErrorWith(errs.CommentInternalServerError), fmt.Errorf("删除评论失败: %w", err)
+	}
+}
+
+func (h *Handler) List(ctx *gin.Context, req ListReq) (hf.Response, error) {
+	if req.Biz == "" || req.Limit > 100 {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("查询评论参数错误 %+v", req)
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	resp, err := h.client.GetCommentList(ctx, &commentv1.GetCommentListRequest{
+		Biz:   req.Biz,
+		BizId: req.BizID,
+		MinId: req.MinID,
+		Limit: req.Limit,
+	})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.CommentInternalServerError), fmt.Errorf("查询评论失败: %w", err)
+	}
+	return hf.Response{Data: h.toVos(resp.GetComments())}, nil
+}
+
+func (h *Handler) Replies(ctx *gin.Context, req RepliesReq) (hf.Response, error) {
+	if req.RootID <= 0 || req.Limit > 100 {
+		return hf.BadRequestError("请求错误"), fmt.Errorf("查询回复参数错误 %+v", req)
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	resp, err := h.client.GetMoreReplies(ctx, &commentv1.GetMoreRepliesRequest{
+		RootId: req.RootID,
+		MaxId:  req.MaxID,
+		Limit:  req.Limit,
+	})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.CommentInternalServerError), fmt.Errorf("查询回复失败: %w", err)
+	}
+	return hf.Response{Data: h.toVos(resp.GetReplies())}, nil
+}
+
+func (h *Handler) toVos(comments []*commentv1.Comment) []Vo {
+	return slice.Map(comments, func(idx int, src *commentv1.Comment) Vo {
+		return Vo{
+			ID:       src.GetId(),
+			Uid:      src.GetUid(),
+			RootID:   src.GetRootId(),
+			ParentID: src.GetParentId(),
+			Content:  src.GetContent(),
+			CreateAt: time.UnixMilli(src.GetCreateAt()).Format(time.DateTime),
+			Replies:  h.toVos(src.GetReplies()),
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package comment
+
+type CreateReq struct {
+	Biz   string `json:"biz"`
+	BizID int64  `json:"biz_id"`
+	// ParentID 回复的评论，不传就是根评论
+	ParentID int64  `json:"parent_id"`
+	Content  string `json:"content"`
+}
+
+type DeleteReq struct {
+	ID int64 `json:"id"`
+}
+
+type ListReq struct {
+	Biz   string `json:"biz"`
+	BizID int64  `json:"biz_id"`
+	// MinID 上一页最后一条根评论的 ID，第一页不传
+	MinID int64 `json:"min_id"`
+	Limit int64 `json:"limit"`
+}
+
+type RepliesReq struct {
+	RootID int64 `json:"root_id"`
+	// MaxID 上一页最后一条回复的 ID，第一页不传
+	MaxID int64 `json:"max_id"`
+	Limit int64 `json:"limit"`
+}
+
+type Vo struct {
+	ID       int64  `json:"id"`
+	Uid      int64  `json:"uid"`
+	RootID   int64  `json:"root_id"`
+	ParentID int64  `json:"parent_id"`
+	Content  string `json:"content"`
+	CreateAt string `json:"create_at"`
+	Replies  []Vo   `json:"replies,omitempty"`
+}
@@ -0,0 +1,166 @@
+package cronjob
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/gin-gonic/gin"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/errs"
+	"geektime-basic-go/webook/internal/service"
+	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
+)
+
+// maxLimit 列表一页最多返回的数量
+const maxLimit = 100
+
+// Executors 调度器支持的执行方式
+type Executors interface {
+	// CanExecute executor 这种执行方式能不能执行名字为 name 的任务。
+	// HTTP、gRPC 这些执行方式按照任务名字找配置的接口，没有配置的任务是执行不了的
+	CanExecute(executor, name string) bool
+}
+
+// Handler 定时任务的管理后台
+type Handler struct {
+	svc   service.CronJobService
+	execs Executors
+}
+
+func NewCronJobHandler(svc service.CronJobService, execs Executors) *Handler {
+	return &Handler{svc: svc, execs: execs}
+}
+
+func (h *Handler) RegisterRoutes(s *gin.Engine) {
+	g := s.Group("/cron_jobs")
+	g.POST("/list", hf.WrapReq[ListReq](h.List))
+	g.POST("/detail", hf.WrapReq[IDReq](h.Detail))
+	g.POST("/create", hf.WrapReq[Req](h.Create))
+	g.POST("/update", hf.WrapReq[Req](h.Update))
+	g.POST("/pause", hf.WrapReq[IDReq](h.Pause))
+	g.POST("/resume", hf.WrapReq[IDReq](h.Resume))
+	g.POST("/delete", hf.WrapReq[IDReq](h.Delete))
+	// 马上执行一次，不影响后续的调度
+	g.POST("/run", hf.WrapReq[IDReq](h.RunNow))
+	// 执行记录
+	g.POST("/executions", hf.WrapReq[ExecutionListReq](h.ListExecutions))
+	// 任务所在的整个 DAG 在某个窗口里面的执行情况
+	g.POST("/workflow", hf.WrapReq[WorkflowReq](h.Workflow))
+}
+
+func (h *Handler) List(ctx *gin.Context, req ListReq) (hf.Response, error) {
+	jobs, err := h.svc.List(ctx, req.Offset, h.limit(req.Limit))
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.CronJobInternalServerError), fmt.Errorf("查询定时任务列表失败: %w", err)
+	}
+	return hf.Response{Data: slice.Map(jobs, func(idx int, src domain.CronJob) Vo {
+		return newVo(src)
+	})}, nil
+}
+
+func (h *Handler) Detail(ctx *gin.Context, req IDReq) (hf.Response, error) {
+	j, err := h.svc.GetByID(ctx, req.ID)
+	if err != nil {
+		return h.toResponse(err, "查询定时任务失败")
+	}
+	return hf.Response{Data: newVo(j)}, nil
+}
+
+func (h *Handler) Create(ctx *gin.Context, req Req) (hf.Response, error) {
+	if err := req.check(); err != nil {
+		return hf.Response{Code: errs.CronJobInvalidInput, Msg: "参数错误"}, err
+	}
+	if req.Name == "" || req.Executor == "" {
+		return hf.Response{Code: errs.CronJobInvalidInput, Msg: "名字和执行方式不能为空"}, nil
+	}
+	if !h.execs.CanExecute(req.Executor, req.Name) {
+		return hf.Response{Code: errs.CronJobInvalidInput, Msg: "不支持的执行方式"}, nil
+	}
+	return h.toResponse(h.svc.AddJob(ctx, req.toDomain()), "创建定时任务失败")
+}
+
+func (h *Handler) Update(ctx *gin.Context, req Req) (hf.Response, error) {
+	if err := req.check(); err != nil {
+		return hf.Response{Code: errs.CronJobInvalidInput, Msg: "参数错误"}, err
+	}
+	if req.Executor == "" {
+		return hf.Response{Code: errs.CronJobInvalidInput, Msg: "执行方式不能为空"}, nil
+	}
+	// 名字不能修改，用原本的名字校验
+	old, err := h.svc.GetByID(ctx, req.ID)
+	if err != nil {
+		return h.toResponse(err, "修改定时任务失败")
+	}
+	if !h.execs.CanExecute(req.Executor, old.Name) {
+		return hf.Response{Code: errs.CronJobInvalidInput, Msg: "不支持的执行方式"}, nil
+	}
+	return h.toResponse(h.svc.Update(ctx, req.toDomain()), "修改定时任务失败")
+}
+
+func (h *Handler) Pause(ctx *gin.Context, req IDReq) (hf.Response, error) {
+	return h.toResponse(h.svc.Pause(ctx, req.ID), "暂停定时任务失败")
+}
+
+func (h *Handler) Resume(ctx *gin.Context, req IDReq) (hf.Response, error) {
+	return h.toResponse(h.svc.Resume(ctx, req.ID), "恢复定时任务失败")
+}
+
+func (h *Handler) Delete(ctx *gin.Context, req IDReq) (hf.Response, error) {
+	return h.toResponse(h.svc.Delete(ctx, req.ID), "删除定时任务失败")
+}
+
+func (h *Handler) RunNow(ctx *gin.Context, req IDReq) (hf.Response, error) {
+	return h.toResponse(h.svc.RunNow(ctx, req.ID), "立即执行定时任务失败")
+}
+
+func (h *Handler) ListExecutions(ctx *gin.Context, req ExecutionListReq) (hf.Response, error) {
+	execs, err := h.svc.ListExecutions(ctx, req.ID, req.Offset, h.limit(req.Limit))
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.CronJobInternalServerError), fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	return hf.Response{Data: slice.Map(execs, func(idx int, src domain.JobExecution) ExecutionVo {
+		return newExecutionVo(src)
+	})}, nil
+}
+
+func (h *Handler) Workflow(ctx *gin.Context, req WorkflowReq) (hf.Response, error) {
+	var window time.Time
+	if req.Window > 0 {
+		window = time.UnixMilli(req.Window)
+	}
+	run, err := h.svc.Workflow(ctx, req.ID, window)
+	if err != nil {
+		return h.toResponse(err, "查询工作流失败")
+	}
+	return hf.Response{Data: newWorkflowVo(run)}, nil
+}
+
+func (h *Handler) limit(limit int) int {
+	if limit <= 0 || limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+// toResponse 把业务错误转成对应的错误码
+func (h *Handler) toResponse(err error, msg string) (hf.Response, error) {
+	switch {
+	case err == nil:
+		return hf.Response{Msg: "OK"}, nil
+	case errors.Is(err, service.ErrInvalidCronExpression):
+		return hf.Response{Code: errs.CronJobInvalidInput, Msg: "cron 表达式不合法"}, err
+	case errors.Is(err, service.ErrJobDependencyCycle):
+		return hf.Response{Code: errs.CronJobInvalidInput, Msg: "任务依赖有环"}, err
+	case errors.Is(err, service.ErrCronJobNotFound):
+		return hf.Response{Code: errs.CronJobNotFound, Msg: "定时任务不存在"}, err
+	case errors.Is(err, service.ErrCronJobNameConflict):
+		return hf.Response{Code: errs.CronJobNameConflict, Msg: "同名的定时任务已经存在"}, err
+	case errors.Is(err, service.ErrCronJobStatusConflict):
+		return hf.Response{Code: errs.CronJobStatusConflict, Msg: "定时任务不存在，或者当前状态不允许这个操作"}, err
+	default:
+		return hf.InternalServerErrorWith(errs.CronJobInternalServerError), fmt.Errorf("%s: %w", msg, err)
+	}
+}
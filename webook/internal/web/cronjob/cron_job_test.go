@@ -0,0 +1,265 @@
+package cronjob
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/errs"
+	"geektime-basic-go/webook/internal/service"
+	svcmocks "geektime-basic-go/webook/internal/service/mocks"
+	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
+)
+
+func init() {
+	gin.SetMode(gin.ReleaseMode)
+}
+
+// fakeExecutors 支持 local，http 只配置了 remote_job 的接口
+type fakeExecutors struct{}
+
+func (fakeExecutors) CanExecute(executor, name string) bool {
+	return executor == "local" || executor == "http" && name == "remote_job"
+}
+
+func TestHandler(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.CronJobService
+		path string
+		body string
+
+		wantRes hf.Response
+	}{
+		{
+			name: "创建任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().AddJob(gomock.Any(), domain.CronJob{
+					Name:         "job",
+					Executor:     "local",
+					Expression:   "*/5 * * * * ?",
+					MaxRetries:   2,
+					RetryBackoff: time.Second,
+				}).Return(nil)
+				return svc
+			},
+			path:    "/cron_jobs/create",
+			body:    `{"name":"job","executor":"local","expression":"*/5 * * * * ?","max_retries":2,"retry_backoff":1000}`,
+			wantRes: hf.Response{Msg: "OK"},
+		},
+		{
+			name:    "创建任务，没有名字",
+			path:    "/cron_jobs/create",
+			body:    `{"executor":"local","expression":"*/5 * * * * ?"}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "名字和执行方式不能为空"},
+		},
+		{
+			name:    "创建任务，不支持的执行方式",
+			path:    "/cron_jobs/create",
+			body:    `{"name":"job","executor":"unknown","expression":"*/5 * * * * ?"}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "不支持的执行方式"},
+		},
+		{
+			name: "创建任务，配置了接口的 HTTP 任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(nil)
+				return svc
+			},
+			path:    "/cron_jobs/create",
+			body:    `{"name":"remote_job","executor":"http","expression":"*/5 * * * * ?"}`,
+			wantRes: hf.Response{Msg: "OK"},
+		},
+		{
+			name:    "创建任务，HTTP 任务没有配置接口",
+			path:    "/cron_jobs/create",
+			body:    `{"name":"job","executor":"http","expression":"*/5 * * * * ?"}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "不支持的执行方式"},
+		},
+		{
+			name:    "创建任务，重试配置是负数",
+			path:    "/cron_jobs/create",
+			body:    `{"name":"job","executor":"local","expression":"*/5 * * * * ?","max_retries":-1}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "参数错误"},
+		},
+		{
+			name:    "创建任务，重试次数太多",
+			path:    "/cron_jobs/create",
+			body:    `{"name":"job","executor":"local","expression":"*/5 * * * * ?","max_retries":11}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "参数错误"},
+		},
+		{
+			name: "创建任务，名字冲突",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(service.ErrCronJobNameConflict)
+				return svc
+			},
+			path:    "/cron_jobs/create",
+			body:    `{"name":"job","executor":"local","expression":"*/5 * * * * ?"}`,
+			wantRes: hf.Response{Code: errs.CronJobNameConflict, Msg: "同名的定时任务已经存在"},
+		},
+		{
+			name: "创建任务，表达式不合法",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().AddJob(gomock.Any(), gomock.Any()).Return(service.ErrInvalidCronExpression)
+				return svc
+			},
+			path:    "/cron_jobs/create",
+			body:    `{"name":"job","executor":"local","expression":"abc"}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "cron 表达式不合法"},
+		},
+		{
+			name: "修改任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{ID: 1, Name: "job"}, nil)
+				svc.EXPECT().Update(gomock.Any(), domain.CronJob{
+					ID:        1,
+					Executor:  "local",
+					Upstreams: []string{"up"},
+				}).Return(nil)
+				return svc
+			},
+			path:    "/cron_jobs/update",
+			body:    `{"id":1,"executor":"local","upstreams":["up"]}`,
+			wantRes: hf.Response{Msg: "OK"},
+		},
+		{
+			name: "修改任务，不支持的执行方式",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{ID: 1, Name: "job"}, nil)
+				return svc
+			},
+			path:    "/cron_jobs/update",
+			body:    `{"id":1,"executor":"unknown","expression":"*/5 * * * * ?"}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "不支持的执行方式"},
+		},
+		{
+			name: "修改任务，改成没有配置接口的 HTTP 任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{ID: 1, Name: "job"}, nil)
+				return svc
+			},
+			path:    "/cron_jobs/update",
+			body:    `{"id":1,"executor":"http","expression":"*/5 * * * * ?"}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "不支持的执行方式"},
+		},
+		{
+			name: "修改不存在的任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{}, service.ErrCronJobNotFound)
+				return svc
+			},
+			path:    "/cron_jobs/update",
+			body:    `{"id":1,"executor":"local","expression":"*/5 * * * * ?"}`,
+			wantRes: hf.Response{Code: errs.CronJobNotFound, Msg: "定时任务不存在"},
+		},
+		{
+			name: "修改任务，依赖有环",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{ID: 1, Name: "job"}, nil)
+				svc.EXPECT().Update(gomock.Any(), gomock.Any()).Return(service.ErrJobDependencyCycle)
+				return svc
+			},
+			path:    "/cron_jobs/update",
+			body:    `{"id":1,"executor":"local","upstreams":["up"]}`,
+			wantRes: hf.Response{Code: errs.CronJobInvalidInput, Msg: "任务依赖有环"},
+		},
+		{
+			name: "暂停任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().Pause(gomock.Any(), int64(1)).Return(nil)
+				return svc
+			},
+			path:    "/cron_jobs/pause",
+			body:    `{"id":1}`,
+			wantRes: hf.Response{Msg: "OK"},
+		},
+		{
+			name: "恢复没有暂停的任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().Resume(gomock.Any(), int64(1)).Return(service.ErrCronJobStatusConflict)
+				return svc
+			},
+			path:    "/cron_jobs/resume",
+			body:    `{"id":1}`,
+			wantRes: hf.Response{Code: errs.CronJobStatusConflict, Msg: "定时任务不存在，或者当前状态不允许这个操作"},
+		},
+		{
+			name: "删除任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().Delete(gomock.Any(), int64(1)).Return(nil)
+				return svc
+			},
+			path:    "/cron_jobs/delete",
+			body:    `{"id":1}`,
+			wantRes: hf.Response{Msg: "OK"},
+		},
+		{
+			name: "立即执行，数据库错误",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().RunNow(gomock.Any(), int64(1)).Return(errors.New("db 错误"))
+				return svc
+			},
+			path:    "/cron_jobs/run",
+			body:    `{"id":1}`,
+			wantRes: hf.InternalServerErrorWith(errs.CronJobInternalServerError),
+		},
+		{
+			name: "查询不存在的任务",
+			mock: func(ctrl *gomock.Controller) service.CronJobService {
+				svc := svcmocks.NewMockCronJobService(ctrl)
+				svc.EXPECT().GetByID(gomock.Any(), int64(1)).Return(domain.CronJob{}, service.ErrCronJobNotFound)
+				return svc
+			},
+			path:    "/cron_jobs/detail",
+			body:    `{"id":1}`,
+			wantRes: hf.Response{Code: errs.CronJobNotFound, Msg: "定时任务不存在"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			var svc service.CronJobService
+			if tc.mock != nil {
+				svc = tc.mock(ctrl)
+			}
+			server := gin.New()
+			NewCronJobHandler(svc, fakeExecutors{}).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			var res hf.Response
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
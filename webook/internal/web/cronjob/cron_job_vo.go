@@ -0,0 +1,204 @@
+package cronjob
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"geektime-basic-go/webook/internal/domain"
+)
+
+type IDReq struct {
+	ID int64 `json:"id"`
+}
+
+type ListReq struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// Req ID 为 0 的时候是创建，Name 创建之后不能修改
+type Req struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Executor   string `json:"executor"`
+	Cfg        string `json:"cfg"`
+	Expression string `json:"expression"`
+
+	// MaxRetries 失败之后最多重试几次，不能超过 10 次。
+	// RetryBackoff 第一次重试的间隔，毫秒数，之后每次翻倍，最多一个小时
+	MaxRetries   int   `json:"max_retries"`
+	RetryBackoff int64 `json:"retry_backoff"`
+	// Timeout 执行的超时时间，毫秒数，0 代表不限制
+	Timeout int64 `json:"timeout"`
+	// Misfire 错过调度时间之后，0 跳过，1 补上
+	Misfire uint8 `json:"misfire"`
+	// AlertThreshold 连续失败多少次告警，0 使用默认值
+	AlertThreshold int `json:"alert_threshold"`
+	// Upstreams 上游任务的名字，有上游的任务不需要 Expression，等上游都执行成功之后触发。
+	// 修改的时候会替换原来的上游
+	Upstreams []string `json:"upstreams"`
+}
+
+// WorkflowReq Window 毫秒数，为 0 的时候看任务最近一次执行的窗口
+type WorkflowReq struct {
+	ID     int64 `json:"id"`
+	Window int64 `json:"window"`
+}
+
+type ExecutionListReq struct {
+	ID     int64 `json:"id"`
+	Offset int   `json:"offset"`
+	Limit  int   `json:"limit"`
+}
+
+type Vo struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Executor   string `json:"executor"`
+	Cfg        string `json:"cfg"`
+	Expression string `json:"expression"`
+	// Status 1 等待调度，2 正在执行，3 已经删除，4 暂停
+	Status uint8 `json:"status"`
+	// NextTime 下游任务等待上游触发的时候为空
+	NextTime string `json:"next_time"`
+	CreateAt string `json:"create_at"`
+	UpdateAt string `json:"update_at"`
+
+	MaxRetries     int   `json:"max_retries"`
+	RetryBackoff   int64 `json:"retry_backoff"`
+	Timeout        int64 `json:"timeout"`
+	Misfire        uint8 `json:"misfire"`
+	AlertThreshold int   `json:"alert_threshold"`
+	// Retries 这一次调度已经重试的次数，FailCount 连续失败的次数
+	Retries   int `json:"retries"`
+	FailCount int `json:"fail_count"`
+
+	Upstreams []string `json:"upstreams"`
+	// Window 最近一次执行的窗口，毫秒数
+	Window int64 `json:"window"`
+}
+
+type ExecutionVo struct {
+	ID      int64  `json:"id"`
+	JobID   int64  `json:"job_id"`
+	JobName string `json:"job_name"`
+	Node    string `json:"node"`
+	StartAt string `json:"start_at"`
+	// EndAt 还没有执行完的时候为空
+	EndAt string `json:"end_at"`
+	// Status 1 正在执行，2 成功，3 失败，4 上游失败跳过，5 调度器退出被中断
+	Status uint8  `json:"status"`
+	Err    string `json:"err"`
+	Window int64  `json:"window"`
+}
+
+type WorkflowVo struct {
+	Window int64 `json:"window"`
+	// Status 1 还没有开始，2 正在执行，3 全部成功，4 有任务失败或者跳过
+	Status uint8            `json:"status"`
+	Nodes  []WorkflowNodeVo `json:"nodes"`
+}
+
+type WorkflowNodeVo struct {
+	Job Vo `json:"job"`
+	// Execution 这个窗口里面还没有执行的时候为空
+	Execution *ExecutionVo `json:"execution"`
+}
+
+// check 重试和告警的配置不能是负数，重试次数有上限，Misfire 只有两种
+func (req Req) check() error {
+	if req.MaxRetries < 0 || req.RetryBackoff < 0 || req.Timeout < 0 || req.AlertThreshold < 0 {
+		return fmt.Errorf("重试或者告警配置不合法 %d %d %d %d", req.MaxRetries, req.RetryBackoff, req.Timeout, req.AlertThreshold)
+	}
+	if req.MaxRetries > domain.MaxJobRetries {
+		return fmt.Errorf("重试次数太多 %d", req.MaxRetries)
+	}
+	if domain.MisfirePolicy(req.Misfire) > domain.MisfireCatchUp {
+		return fmt.Errorf("未知的 misfire 策略 %d", req.Misfire)
+	}
+	for _, up := range req.Upstreams {
+		if up == "" {
+			return errors.New("上游任务的名字不能为空")
+		}
+	}
+	return nil
+}
+
+func (req Req) toDomain() domain.CronJob {
+	return domain.CronJob{
+		ID:         req.ID,
+		Name:       req.Name,
+		Executor:   req.Executor,
+		Cfg:        req.Cfg,
+		Expression: req.Expression,
+
+		MaxRetries:     req.MaxRetries,
+		RetryBackoff:   time.Duration(req.RetryBackoff) * time.Millisecond,
+		Timeout:        time.Duration(req.Timeout) * time.Millisecond,
+		Misfire:        domain.MisfirePolicy(req.Misfire),
+		AlertThreshold: req.AlertThreshold,
+		Upstreams:      req.Upstreams,
+	}
+}
+
+func newVo(j domain.CronJob) Vo {
+	vo := Vo{
+		ID:         j.ID,
+		Name:       j.Name,
+		Executor:   j.Executor,
+		Cfg:        j.Cfg,
+		Expression: j.Expression,
+		Status:     j.Status.ToUint8(),
+		CreateAt:   j.CreateAt.Format(time.DateTime),
+		UpdateAt:   j.UpdateAt.Format(time.DateTime),
+
+		MaxRetries:     j.MaxRetries,
+		RetryBackoff:   j.RetryBackoff.Milliseconds(),
+		Timeout:        j.Timeout.Milliseconds(),
+		Misfire:        j.Misfire.ToUint8(),
+		AlertThreshold: j.AlertThreshold,
+		Retries:        j.Retries,
+		FailCount:      j.FailCount,
+		Upstreams:      j.Upstreams,
+		Window:         j.Window.UnixMilli(),
+	}
+	if !j.WaitingUpstream() {
+		vo.NextTime = j.NextTime.Format(time.DateTime)
+	}
+	return vo
+}
+
+func newExecutionVo(e domain.JobExecution) ExecutionVo {
+	vo := ExecutionVo{
+		ID:      e.ID,
+		JobID:   e.JobID,
+		JobName: e.JobName,
+		Node:    e.Node,
+		StartAt: e.StartAt.Format(time.DateTime),
+		Status:  e.Status.ToUint8(),
+		Err:     e.Err,
+		Window:  e.Window.UnixMilli(),
+	}
+	if !e.EndAt.IsZero() {
+		vo.EndAt = e.EndAt.Format(time.DateTime)
+	}
+	return vo
+}
+
+func newWorkflowVo(run domain.WorkflowRun) WorkflowVo {
+	vo := WorkflowVo{
+		Window: run.Window.UnixMilli(),
+		Status: run.Status().ToUint8(),
+		Nodes:  make([]WorkflowNodeVo, 0, len(run.Nodes)),
+	}
+	for _, n := range run.Nodes {
+		node := WorkflowNodeVo{Job: newVo(n.Job)}
+		if n.Execution.ID > 0 {
+			e := newExecutionVo(n.Execution)
+			node.Execution = &e
+		}
+		vo.Nodes = append(vo.Nodes, node)
+	}
+	return vo
+}
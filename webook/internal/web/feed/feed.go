@@ -0,0 +1,55 @@
+package feed
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/gin-gonic/gin"
+
+	feedv1 "geektime-basic-go/webook/api/proto/gen/feed"
+	"geektime-basic-go/webook/internal/errs"
+	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
+)
+
+type Handler struct {
+	client feedv1.FeedServiceClient
+}
+
+func NewFeedHandler(client feedv1.FeedServiceClient) *Handler {
+	return &Handler{client: client}
+}
+
+func (h *Handler) RegisterRoutes(s *gin.Engine) {
+	s.GET("/feed", hf.WrapClaimsAndReq[ListReq](h.List))
+}
+
+func (h *Handler) List(ctx *gin.Context, req ListReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Limit > 100 || req.MaxTime < 0 || req.MaxID < 0 {
+		return hf.Response{Code: errs.FeedInvalidInput, Msg: "参数错误"}, fmt.Errorf("分页参数不正确 %d %d %d", req.MaxTime, req.MaxID, req.Limit)
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	resp, err := h.client.FindFeed(ctx, &feedv1.FindFeedRequest{Uid: uc.ID, MaxTime: req.MaxTime, MaxId: req.MaxID, Limit: req.Limit})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.FeedInternalServerError), fmt.Errorf("查询 feed 流失败: %w", err)
+	}
+
+	items := resp.GetItems()
+	res := ListVo{Items: slice.Map(items, func(idx int, src *feedv1.FeedItem) Vo {
+		return Vo{
+			ID:       src.GetId(),
+			Author:   src.GetAuthor(),
+			Biz:      src.GetBiz(),
+			BizID:    src.GetBizId(),
+			Ext:      src.GetExt(),
+			CreateAt: time.UnixMilli(src.GetCreateAt()).Format(time.DateTime),
+		}
+	})}
+	if int64(len(items)) == req.Limit {
+		last := items[len(items)-1]
+		res.NextMaxTime, res.NextMaxID = last.GetCreateAt(), last.GetId()
+	}
+	return hf.Response{Data: res}, nil
+}
@@ -0,0 +1,24 @@
+package feed
+
+type ListReq struct {
+	// MaxTime 和 MaxID 是上一页返回的 next_max_time 和 next_max_id，第一页不传
+	MaxTime int64 `form:"max_time"`
+	MaxID   int64 `form:"max_id"`
+	Limit   int64 `form:"limit"`
+}
+
+type ListVo struct {
+	Items []Vo `json:"items"`
+	// NextMaxTime 和 NextMaxID 查询下一页的时候带上，为 0 说明没有更多了
+	NextMaxTime int64 `json:"next_max_time"`
+	NextMaxID   int64 `json:"next_max_id"`
+}
+
+type Vo struct {
+	ID       int64             `json:"id"`
+	Author   int64             `json:"author"`
+	Biz      string            `json:"biz"`
+	BizID    int64             `json:"biz_id"`
+	Ext      map[string]string `json:"ext"`
+	CreateAt string            `json:"create_at"`
+}
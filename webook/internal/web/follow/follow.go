@@ -0,0 +1,100 @@
+package follow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
+	"geektime-basic-go/webook/internal/errs"
+	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
+)
+
+type Handler struct {
+	client followv1.FollowServiceClient
+}
+
+func NewFollowHandler(client followv1.FollowServiceClient) *Handler {
+	return &Handler{client: client}
+}
+
+func (h *Handler) RegisterRoutes(s *gin.Engine) {
+	g := s.Group("/follow")
+	g.POST("/follow", hf.WrapClaimsAndReq[FollowReq](h.Follow))
+	g.POST("/unfollow", hf.WrapClaimsAndReq[FollowReq](h.Unfollow))
+	g.POST("/followers", hf.WrapClaimsAndReq[ListReq](h.ListFollowers))
+	g.POST("/followees", hf.WrapClaimsAndReq[ListReq](h.ListFollowees))
+}
+
+func (h *Handler) Follow(ctx *gin.Context, req FollowReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Followee <= 0 {
+		return hf.Response{Code: errs.FollowInvalidInput, Msg: "参数错误"}, fmt.Errorf("关注的用户 ID 不正确 %d", req.Followee)
+	}
+	_, err := h.client.Follow(ctx, &followv1.FollowRequest{Follower: uc.ID, Followee: req.Followee})
+	switch status.Code(err) {
+	case codes.OK:
+		return hf.Response{Msg: "OK"}, nil
+	case codes.InvalidArgument:
+		return hf.Response{Code: errs.FollowInvalidInput, Msg: "不能关注自己"}, err
+	default:
+		return hf.InternalServerErrorWith(errs.FollowInternalServerError), fmt.Errorf("关注失败: %w", err)
+	}
+}
+
+func (h *Handler) Unfollow(ctx *gin.Context, req FollowReq, uc hf.UserClaims) (hf.Response, error) {
+	_, err := h.client.Unfollow(ctx, &followv1.UnfollowRequest{Follower: uc.ID, Followee: req.Followee})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.FollowInternalServerError), fmt.Errorf("取消关注失败: %w", err)
+	}
+	return hf.Response{Msg: "OK"}, nil
+}
+
+func (h *Handler) ListFollowers(ctx *gin.Context, req ListReq, uc hf.UserClaims) (hf.Response, error) {
+	if err := h.normalize(&req, uc); err != nil {
+		return hf.BadRequestError("请求错误"), err
+	}
+	resp, err := h.client.ListFollowers(ctx, &followv1.ListFollowersRequest{Followee: req.Uid, MinId: req.MinID, Limit: req.Limit})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.FollowInternalServerError), fmt.Errorf("查询粉丝列表失败: %w", err)
+	}
+	return hf.Response{Data: h.toVos(resp.GetFollowRelations())}, nil
+}
+
+func (h *Handler) ListFollowees(ctx *gin.Context, req ListReq, uc hf.UserClaims) (hf.Response, error) {
+	if err := h.normalize(&req, uc); err != nil {
+		return hf.BadRequestError("请求错误"), err
+	}
+	resp, err := h.client.ListFollowees(ctx, &followv1.ListFolloweesRequest{Follower: req.Uid, MinId: req.MinID, Limit: req.Limit})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.FollowInternalServerError), fmt.Errorf("查询关注列表失败: %w", err)
+	}
+	return hf.Response{Data: h.toVos(resp.GetFollowRelations())}, nil
+}
+
+func (h *Handler) normalize(req *ListReq, uc hf.UserClaims) error {
+	if req.Limit > 100 {
+		return fmt.Errorf("分页过大 %d", req.Limit)
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Uid <= 0 {
+		req.Uid = uc.ID
+	}
+	return nil
+}
+
+func (h *Handler) toVos(relations []*followv1.FollowRelation) []Vo {
+	return slice.Map(relations, func(idx int, src *followv1.FollowRelation) Vo {
+		return Vo{
+			ID:       src.GetId(),
+			Follower: src.GetFollower(),
+			Followee: src.GetFollowee(),
+			CreateAt: time.UnixMilli(src.GetCreateAt()).Format(time.DateTime),
+		}
+	})
+}
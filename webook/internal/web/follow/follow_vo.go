@@ -0,0 +1,20 @@
+package follow
+
+type FollowReq struct {
+	Followee int64 `json:"followee"`
+}
+
+type ListReq struct {
+	// Uid 不传就是查询自己的
+	Uid int64 `json:"uid"`
+	// MinID 上一页最后一条记录的 ID，第一页不传
+	MinID int64 `json:"min_id"`
+	Limit int64 `json:"limit"`
+}
+
+type Vo struct {
+	ID       int64  `json:"id"`
+	Follower int64  `json:"follower"`
+	Followee int64  `json:"followee"`
+	CreateAt string `json:"create_at"`
+}
@@ -0,0 +1,79 @@
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/gin-gonic/gin"
+
+	historyv1 "geektime-basic-go/webook/api/proto/gen/history"
+	"geektime-basic-go/webook/internal/errs"
+	hf "geektime-basic-go/webook/pkg/ginx/handlefunc"
+)
+
+type Handler struct {
+	client historyv1.HistoryServiceClient
+}
+
+func NewHistoryHandler(client historyv1.HistoryServiceClient) *Handler {
+	return &Handler{client: client}
+}
+
+func (h *Handler) RegisterRoutes(s *gin.Engine) {
+	g := s.Group("/users/history")
+	g.GET("", hf.WrapClaimsAndReq[ListReq](h.List))
+	g.POST("/delete", hf.WrapClaimsAndReq[DeleteReq](h.Delete))
+	g.POST("/clear", hf.WrapClaims(h.Clear))
+}
+
+func (h *Handler) List(ctx *gin.Context, req ListReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Limit > 100 || req.MaxReadAt < 0 {
+		return hf.Response{Code: errs.HistoryInvalidInput, Msg: "参数错误"}, fmt.Errorf("分页参数不正确 %d %d", req.MaxReadAt, req.Limit)
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	resp, err := h.client.ListHistory(ctx, &historyv1.ListHistoryRequest{
+		Uid:       uc.ID,
+		MaxReadAt: req.MaxReadAt,
+		MaxId:     req.MaxID,
+		Limit:     req.Limit,
+	})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.HistoryInternalServerError), fmt.Errorf("查询阅读历史失败: %w", err)
+	}
+
+	records := resp.GetRecords()
+	res := ListVo{Records: slice.Map(records, func(idx int, src *historyv1.HistoryRecord) Vo {
+		return Vo{
+			Biz:    src.GetBiz(),
+			BizID:  src.GetBizId(),
+			ReadAt: time.UnixMilli(src.GetReadAt()).Format(time.DateTime),
+		}
+	})}
+	if int64(len(records)) == req.Limit {
+		last := records[len(records)-1]
+		res.NextMaxReadAt, res.NextMaxID = last.GetReadAt(), last.GetId()
+	}
+	return hf.Response{Data: res}, nil
+}
+
+func (h *Handler) Delete(ctx *gin.Context, req DeleteReq, uc hf.UserClaims) (hf.Response, error) {
+	if req.Biz == "" || req.BizID <= 0 {
+		return hf.Response{Code: errs.HistoryInvalidInput, Msg: "参数错误"}, fmt.Errorf("删除的阅读记录不正确 %s %d", req.Biz, req.BizID)
+	}
+	_, err := h.client.DeleteHistory(ctx, &historyv1.DeleteHistoryRequest{Uid: uc.ID, Biz: req.Biz, BizId: req.BizID})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.HistoryInternalServerError), fmt.Errorf("删除阅读记录失败: %w", err)
+	}
+	return hf.Response{Msg: "OK"}, nil
+}
+
+func (h *Handler) Clear(ctx *gin.Context, uc hf.UserClaims) (hf.Response, error) {
+	_, err := h.client.ClearHistory(ctx, &historyv1.ClearHistoryRequest{Uid: uc.ID})
+	if err != nil {
+		return hf.InternalServerErrorWith(errs.HistoryInternalServerError), fmt.Errorf("清空阅读历史失败: %w", err)
+	}
+	return hf.Response{Msg: "OK"}, nil
+}
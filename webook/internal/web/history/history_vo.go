@@ -0,0 +1,26 @@
+package history
+
+type ListReq struct {
+	// MaxReadAt 和 MaxID 是上一页返回的 next_max_read_at 和 next_max_id，第一页不传
+	MaxReadAt int64 `form:"max_read_at"`
+	MaxID     int64 `form:"max_id"`
+	Limit     int64 `form:"limit"`
+}
+
+type DeleteReq struct {
+	Biz   string `json:"biz"`
+	BizID int64  `json:"biz_id"`
+}
+
+type ListVo struct {
+	Records []Vo `json:"records"`
+	// NextMaxReadAt 查询下一页的时候带上，为 0 说明没有更多了
+	NextMaxReadAt int64 `json:"next_max_read_at"`
+	NextMaxID     int64 `json:"next_max_id"`
+}
+
+type Vo struct {
+	Biz    string `json:"biz"`
+	BizID  int64  `json:"biz_id"`
+	ReadAt string `json:"read_at"`
+}
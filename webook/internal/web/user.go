@@ -9,12 +9,15 @@ import (
 	regexp "github.com/dlclark/regexp2"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/errgroup"
 
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
 	"geektime-basic-go/webook/internal/domain"
 	"geektime-basic-go/webook/internal/errs"
 	"geektime-basic-go/webook/internal/service"
 	myjwt "geektime-basic-go/webook/internal/web/jwt"
 	"geektime-basic-go/webook/pkg/ginx/handlefunc"
+	"geektime-basic-go/webook/pkg/logger"
 )
 
 const bizLogin = "login"
@@ -24,13 +27,16 @@ var _ handler = (*UserHandler)(nil)
 type UserHandler struct {
 	svc              service.UserService
 	codeSvc          service.CodeService
+	followRPC        followv1.FollowServiceClient
 	emailRegexExp    *regexp.Regexp
 	passwordRegexExp *regexp.Regexp
 	phoneRegexExp    *regexp.Regexp
+	l                logger.Logger
 	myjwt.Handler
 }
 
-func NewUserHandler(svc service.UserService, codeSvc service.CodeService, jwtHandler myjwt.Handler) *UserHandler {
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService, followRPC followv1.FollowServiceClient,
+	jwtHandler myjwt.Handler, l logger.Logger) *UserHandler {
 	const (
 		emailRegexPattern  = `^[a-zA-Z0-9_-]+@[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)+$`
 		passwdRegexPattern = `^^(?=.*[0-9])(?=.*[a-zA-Z])[0-9A-Za-z~!@#$%^&*._?]{8,15}$`
@@ -40,9 +46,11 @@ func NewUserHandler(svc service.UserService, codeSvc service.CodeService, jwtHan
 	return &UserHandler{
 		svc:              svc,
 		codeSvc:          codeSvc,
+		followRPC:        followRPC,
 		emailRegexExp:    regexp.MustCompile(emailRegexPattern, regexp.None),
 		passwordRegexExp: regexp.MustCompile(passwdRegexPattern, regexp.None),
 		phoneRegexExp:    regexp.MustCompile(phoneRegexPattern, regexp.None),
+		l:                l,
 		Handler:          jwtHandler,
 	}
 }
@@ -172,10 +180,31 @@ func (uh *UserHandler) Profile(ctx *gin.Context) {
 		Nickname string `json:"nickname"`
 		Birthday string `json:"birthday"`
 		AboutMe  string `json:"aboutMe"`
+		// Followers 粉丝数
+		Followers int64 `json:"followers"`
+		// Followees 关注数
+		Followees int64 `json:"followees"`
 	}
 	uc := ctx.MustGet("user").(myjwt.UserClaims)
-	user, err := uh.svc.Profile(ctx, uc.ID)
-	if err != nil {
+	var (
+		eg      errgroup.Group
+		user    domain.User
+		statics *followv1.GetFollowStaticsResponse
+	)
+	eg.Go(func() (err error) {
+		user, err = uh.svc.Profile(ctx, uc.ID)
+		return
+	})
+	eg.Go(func() error {
+		// 关注数据查不到不影响查看个人信息，展示为 0
+		var err error
+		statics, err = uh.followRPC.GetFollowStatics(ctx, &followv1.GetFollowStaticsRequest{Uid: uc.ID})
+		if err != nil {
+			uh.l.Error("查询关注数据失败", logger.Int("uid", uc.ID), logger.Error(err))
+		}
+		return nil
+	})
+	if err := eg.Wait(); err != nil {
 		ctx.JSON(http.StatusOK, InternalServerError)
 		return
 	}
@@ -185,6 +214,9 @@ func (uh *UserHandler) Profile(ctx *gin.Context) {
 		Nickname: user.Nickname,
 		Birthday: user.Birthday.Format(time.DateOnly),
 		AboutMe:  user.AboutMe,
+
+		Followers: statics.GetStatics().GetFollowers(),
+		Followees: statics.GetStatics().GetFollowees(),
 	}})
 }
 
@@ -17,12 +17,15 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
+	followv1 "geektime-basic-go/webook/api/proto/gen/follow"
 	"geektime-basic-go/webook/internal/domain"
+	followmocks "geektime-basic-go/webook/internal/repository/rpc/follow/mocks"
 	"geektime-basic-go/webook/internal/service"
 	svcmocks "geektime-basic-go/webook/internal/service/mocks"
 	myjwt "geektime-basic-go/webook/internal/web/jwt"
 	jwtmocks "geektime-basic-go/webook/internal/web/jwt/mocks"
 	"geektime-basic-go/webook/pkg/ginx/handlefunc"
+	"geektime-basic-go/webook/pkg/logger"
 )
 
 func init() {
@@ -129,7 +132,7 @@ func TestUserHandler_SignUp(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			uh := NewUserHandler(tc.mock(ctrl), nil, nil)
+			uh := NewUserHandler(tc.mock(ctrl), nil, nil, nil, logger.NewNoOpLogger())
 			server := gin.New()
 			uh.RegisterRoutes(server)
 			req := reqBuilder(t, http.MethodPost, "/users/signup", tc.body)
@@ -239,7 +242,7 @@ func TestUserHandler_Login(t *testing.T) {
 			defer ctrl.Finish()
 
 			us, jh := tc.mock(ctrl)
-			uh := NewUserHandler(us, nil, jh)
+			uh := NewUserHandler(us, nil, nil, jh, logger.NewNoOpLogger())
 			req := reqBuilder(t, http.MethodPost, "/users/login", tc.body)
 			recorder := httptest.NewRecorder()
 
@@ -358,7 +361,7 @@ func TestUserHandler_Edit(t *testing.T) {
 			defer ctrl.Finish()
 
 			us := tc.mock(ctrl)
-			uh := NewUserHandler(us, nil, nil)
+			uh := NewUserHandler(us, nil, nil, nil, logger.NewNoOpLogger())
 			req := reqBuilder(t, http.MethodPost, "/users/edit", tc.body)
 			recorder := httptest.NewRecorder()
 
@@ -394,7 +397,7 @@ func TestUserHandler_Profile(t *testing.T) {
 	testCases := []struct {
 		name string
 
-		mock func(ctrl *gomock.Controller) service.UserService
+		mock func(ctrl *gomock.Controller) (service.UserService, followv1.FollowServiceClient)
 		body io.Reader
 		ID   int64
 
@@ -403,32 +406,62 @@ func TestUserHandler_Profile(t *testing.T) {
 	}{
 		{
 			name: "成功",
-			mock: func(ctrl *gomock.Controller) service.UserService {
+			mock: func(ctrl *gomock.Controller) (service.UserService, followv1.FollowServiceClient) {
 				us := svcmocks.NewMockUserService(ctrl)
 				us.EXPECT().Profile(gomock.Any(), int64(1)).Return(userDomain, nil)
-				return us
+				fc := followmocks.NewMockFollowServiceClient(ctrl)
+				fc.EXPECT().GetFollowStatics(gomock.Any(), &followv1.GetFollowStaticsRequest{Uid: 1}).
+					Return(&followv1.GetFollowStaticsResponse{Statics: &followv1.FollowStatics{Followers: 10, Followees: 2}}, nil)
+				return us, fc
 			},
 			ID:       1,
 			wantCode: http.StatusOK,
 			wantRes: handlefunc.Response{Code: 0, Msg: "OK", Data: map[string]interface{}{
-				"aboutMe":  "泰裤辣",
-				"birthday": "2023-09-11",
-				"email":    "123@qq.com",
-				"nickname": "泰裤辣",
-				"phone":    "13888888888",
+				"aboutMe":   "泰裤辣",
+				"birthday":  "2023-09-11",
+				"email":     "123@qq.com",
+				"nickname":  "泰裤辣",
+				"phone":     "13888888888",
+				"followers": float64(10),
+				"followees": float64(2),
 			}},
 		},
 		{
 			name: "失败",
-			mock: func(ctrl *gomock.Controller) service.UserService {
+			mock: func(ctrl *gomock.Controller) (service.UserService, followv1.FollowServiceClient) {
 				us := svcmocks.NewMockUserService(ctrl)
 				us.EXPECT().Profile(gomock.Any(), int64(1)).Return(domain.User{}, errors.New("模拟系统错误"))
-				return us
+				fc := followmocks.NewMockFollowServiceClient(ctrl)
+				fc.EXPECT().GetFollowStatics(gomock.Any(), gomock.Any()).
+					Return(&followv1.GetFollowStaticsResponse{}, nil)
+				return us, fc
 			},
 			ID:       1,
 			wantCode: http.StatusOK,
 			wantRes:  InternalServerError,
 		},
+		{
+			name: "查询关注数据失败",
+			mock: func(ctrl *gomock.Controller) (service.UserService, followv1.FollowServiceClient) {
+				us := svcmocks.NewMockUserService(ctrl)
+				us.EXPECT().Profile(gomock.Any(), int64(1)).Return(userDomain, nil)
+				fc := followmocks.NewMockFollowServiceClient(ctrl)
+				fc.EXPECT().GetFollowStatics(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("模拟系统错误"))
+				return us, fc
+			},
+			ID:       1,
+			wantCode: http.StatusOK,
+			wantRes: handlefunc.Response{Code: 0, Msg: "OK", Data: map[string]interface{}{
+				"aboutMe":   "泰裤辣",
+				"birthday":  "2023-09-11",
+				"email":     "123@qq.com",
+				"nickname":  "泰裤辣",
+				"phone":     "13888888888",
+				"followers": float64(0),
+				"followees": float64(0),
+			}},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -436,8 +469,8 @@ func TestUserHandler_Profile(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			us := tc.mock(ctrl)
-			uh := NewUserHandler(us, nil, nil)
+			us, fc := tc.mock(ctrl)
+			uh := NewUserHandler(us, nil, fc, nil, logger.NewNoOpLogger())
 			req := reqBuilder(t, http.MethodGet, "/users/profile", tc.body)
 			recorder := httptest.NewRecorder()
 
@@ -527,7 +560,7 @@ func TestUserHandler_SendSMSLoginCode(t *testing.T) {
 			defer ctrl.Finish()
 
 			cs := tc.mock(ctrl)
-			uh := NewUserHandler(nil, cs, nil)
+			uh := NewUserHandler(nil, cs, nil, nil, logger.NewNoOpLogger())
 			req := reqBuilder(t, http.MethodPost, "/users/login_sms/code/send", tc.body)
 			recorder := httptest.NewRecorder()
 
@@ -639,7 +672,7 @@ func TestUserHandler_LoginSMS(t *testing.T) {
 			defer ctrl.Finish()
 
 			us, cs, jh := tc.mock(ctrl)
-			uh := NewUserHandler(us, cs, jh)
+			uh := NewUserHandler(us, cs, nil, jh, logger.NewNoOpLogger())
 			req := reqBuilder(t, http.MethodPost, "/users/login_sms", tc.body)
 			recorder := httptest.NewRecorder()
 
@@ -772,7 +805,7 @@ func TestUserHandler_RefreshToken(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			uh := NewUserHandler(nil, nil, tc.mock(ctrl))
+			uh := NewUserHandler(nil, nil, nil, tc.mock(ctrl), logger.NewNoOpLogger())
 
 			req := reqBuilder(t, http.MethodPost, "/users/refresh_token", nil)
 			recorder := httptest.NewRecorder()
@@ -824,7 +857,7 @@ func TestUserHandler_Logout(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			uh := NewUserHandler(nil, nil, tc.mock(ctrl))
+			uh := NewUserHandler(nil, nil, nil, tc.mock(ctrl), logger.NewNoOpLogger())
 
 			req := reqBuilder(t, http.MethodPost, "/users/logout", nil)
 			recorder := httptest.NewRecorder()
@@ -865,7 +898,7 @@ func TestEmailPattern(t *testing.T) {
 		},
 	}
 
-	uh := NewUserHandler(nil, nil, nil)
+	uh := NewUserHandler(nil, nil, nil, nil, logger.NewNoOpLogger())
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			match, err := uh.emailRegexExp.MatchString(tc.email)
@@ -903,7 +936,7 @@ func TestPasswordPattern(t *testing.T) {
 		},
 	}
 
-	uh := NewUserHandler(nil, nil, nil)
+	uh := NewUserHandler(nil, nil, nil, nil, logger.NewNoOpLogger())
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			match, err := uh.phoneRegexExp.MatchString(tc.phone)
@@ -941,7 +974,7 @@ func TestPhonePattern(t *testing.T) {
 		},
 	}
 
-	uh := NewUserHandler(nil, nil, nil)
+	uh := NewUserHandler(nil, nil, nil, nil, logger.NewNoOpLogger())
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			match, err := uh.passwordRegexExp.MatchString(tc.password)
@@ -0,0 +1,40 @@
+package ioc
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/resolver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	commentv1 "geektime-basic-go/webook/api/proto/gen/comment"
+)
+
+func InitCommentGRPC(client *clientv3.Client) commentv1.CommentServiceClient {
+	type Config struct {
+		Name string `json:"name"`
+	}
+
+	var cfg Config
+	if err := viper.UnmarshalKey("grpc.client.comment", &cfg); err != nil {
+		panic(fmt.Sprintf("初始化 grpc client 失败, 反序列化配置失败: %s", err))
+	}
+
+	bd, err := resolver.NewBuilder(client)
+	if err != nil {
+		panic(fmt.Sprintf("初始化 grpc resolver 失败: %s", err))
+	}
+
+	cc, err := grpc.Dial(
+		"etcd:///service/"+cfg.Name,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithResolvers(bd),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("初始化 grpc client 失败, 连接失败: %s", err))
+	}
+
+	return commentv1.NewCommentServiceClient(cc)
+}
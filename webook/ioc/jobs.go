@@ -1,13 +1,17 @@
 package ioc
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	rlock "github.com/gotomicro/redis-lock"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
 
 	"geektime-basic-go/webook/internal/job"
 	"geektime-basic-go/webook/internal/service"
@@ -32,3 +36,68 @@ func InitJobs(l logger.Logger, rankingJob *job.RankingJob) *cron.Cron {
 	}
 	return expr
 }
+
+func InitScheduler(svc service.CronJobService, l logger.Logger, etcdClient *clientv3.Client,
+	publishJob *job.ScheduledPublishJob) *job.Scheduler {
+	scheduler := job.NewScheduler(svc, l)
+	executor := job.NewLocalFuncExecutor()
+	executor.AddLocalFunc(publishJob.Name(), publishJob.Exec)
+	scheduler.RegisterExecutor(executor)
+	scheduler.RegisterExecutor(initHTTPExecutor())
+	scheduler.RegisterExecutor(initGRPCExecutor(etcdClient))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := scheduler.RegisterJob(ctx, publishJob.CronJob()); err != nil {
+		panic(fmt.Sprintf("注册定时发表任务失败: %s", err))
+	}
+	return scheduler
+}
+
+// remoteJobConfig 远程执行的任务，Name 是任务的名字
+type remoteJobConfig struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`
+	Header  map[string]string `yaml:"header"`
+	Service string            `yaml:"service"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Retries int               `yaml:"retries"`
+	Backoff time.Duration     `yaml:"backoff"`
+}
+
+func (c remoteJobConfig) retryConfig() job.RetryConfig {
+	return job.RetryConfig{Timeout: c.Timeout, Retries: c.Retries, Backoff: c.Backoff}
+}
+
+// initHTTPExecutor 任务对应的接口配置在 cron.executors.http 下
+func initHTTPExecutor() *job.HTTPExecutor {
+	var cfgs []remoteJobConfig
+	if err := viper.UnmarshalKey("cron.executors.http", &cfgs); err != nil {
+		panic(fmt.Sprintf("初始化 HTTP 执行器失败, 反序列化配置失败: %s", err))
+	}
+	// 超时由每个任务自己的配置控制
+	executor := job.NewHTTPExecutor(&http.Client{})
+	for _, cfg := range cfgs {
+		executor.AddEndpoint(cfg.Name, job.HTTPEndpoint{
+			URL: cfg.URL, Method: cfg.Method, Header: cfg.Header, RetryConfig: cfg.retryConfig(),
+		})
+	}
+	return executor
+}
+
+// initGRPCExecutor 任务对应的服务配置在 cron.executors.grpc 下
+func initGRPCExecutor(client *clientv3.Client) *job.GRPCExecutor {
+	var cfgs []remoteJobConfig
+	if err := viper.UnmarshalKey("cron.executors.grpc", &cfgs); err != nil {
+		panic(fmt.Sprintf("初始化 gRPC 执行器失败, 反序列化配置失败: %s", err))
+	}
+	executor, err := job.NewGRPCExecutor(client)
+	if err != nil {
+		panic(fmt.Sprintf("初始化 gRPC 执行器失败: %s", err))
+	}
+	for _, cfg := range cfgs {
+		executor.AddTarget(cfg.Name, job.GRPCTarget{Service: cfg.Service, RetryConfig: cfg.retryConfig()})
+	}
+	return executor
+}
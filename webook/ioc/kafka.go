@@ -8,6 +8,9 @@ import (
 
 	"geektime-basic-go/webook/interactive/events"
 	"geektime-basic-go/webook/interactive/events/article"
+	webookevents "geektime-basic-go/webook/internal/events"
+	"geektime-basic-go/webook/internal/events/ranking"
+	"geektime-basic-go/webook/internal/events/search"
 )
 
 func InitKafka() sarama.Client {
@@ -40,3 +43,9 @@ func NewSyncProducer(client sarama.Client) sarama.SyncProducer {
 func NewConsumers(c1 *article.InteractiveReadEventConsumer, c2 *article.ChangeLikeEventConsumer) []events.Consumer {
 	return []events.Consumer{c1, c2}
 }
+
+// InitConsumers webook 自己的消费者
+func InitConsumers(rankingConsumer *ranking.RankingEventConsumer,
+	searchConsumer *search.ArticleEventConsumer) []webookevents.Consumer {
+	return []webookevents.Consumer{rankingConsumer, searchConsumer}
+}
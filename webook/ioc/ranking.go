@@ -0,0 +1,70 @@
+package ioc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"geektime-basic-go/webook/internal/service"
+)
+
+// InitRankingConfig 快照保留的时间配置在 ranking.snapshotRetention 下，配置成 0 就是一直保留
+func InitRankingConfig() service.RankingConfig {
+	cfg := service.DefaultRankingConfig()
+	cfg.Boards = initRankingBoards()
+	if viper.IsSet("ranking.snapshotRetention") {
+		cfg.SnapshotRetention = viper.GetDuration("ranking.snapshotRetention")
+	}
+	return cfg
+}
+
+// initRankingBoards 榜单配置在 ranking.boards 下，没有配置的时候使用默认的榜单
+func initRankingBoards() []service.RankingBoard {
+	type board struct {
+		Name        string                 `yaml:"name"`
+		Biz         string                 `yaml:"biz"`
+		Window      time.Duration          `yaml:"window"`
+		N           int                    `yaml:"n"`
+		Scorer      service.WeightedScorer `yaml:"scorer"`
+		Incremental bool                   `yaml:"incremental"`
+		Bucket      time.Duration          `yaml:"bucket"`
+	}
+	var cfgs []board
+	if err := viper.UnmarshalKey("ranking.boards", &cfgs); err != nil {
+		panic(fmt.Sprintf("初始化榜单失败, 反序列化配置失败: %s", err))
+	}
+	if len(cfgs) == 0 {
+		return service.DefaultRankingBoards()
+	}
+
+	res := make([]service.RankingBoard, 0, len(cfgs))
+	names := make(map[string]struct{}, len(cfgs))
+	for _, cfg := range cfgs {
+		if _, ok := names[cfg.Name]; ok {
+			panic(fmt.Sprintf("初始化榜单失败, 榜单 %s 重复了", cfg.Name))
+		}
+		names[cfg.Name] = struct{}{}
+		if cfg.Biz == "" {
+			cfg.Biz = "article"
+		}
+		if cfg.Window <= 0 {
+			cfg.Window = 7 * 24 * time.Hour
+		}
+		if cfg.N <= 0 {
+			cfg.N = 100
+		}
+		// 刚更新的内容 hours 是 0，Offset 不能小于等于 0
+		if cfg.Scorer.Offset <= 0 {
+			cfg.Scorer.Offset = 2
+		}
+		if cfg.Incremental && cfg.Bucket <= 0 {
+			cfg.Bucket = time.Hour
+		}
+		res = append(res, service.RankingBoard{
+			Name: cfg.Name, Biz: cfg.Biz, Window: cfg.Window, N: cfg.N, Scorer: cfg.Scorer,
+			Incremental: cfg.Incremental, Bucket: cfg.Bucket,
+		})
+	}
+	return res
+}
@@ -0,0 +1,35 @@
+package ioc
+
+import (
+	"context"
+	"time"
+
+	"geektime-basic-go/webook/internal/domain"
+	"geektime-basic-go/webook/internal/repository"
+	"geektime-basic-go/webook/internal/service"
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/searchx"
+)
+
+func InitArticleSearchIndex() *searchx.Index[domain.Article] {
+	// 标题命中比内容命中更重要
+	return searchx.NewIndex[domain.Article](map[string]float64{"title": 3, "content": 1})
+}
+
+// InitSearchService 索引在内存中，启动的时候从线上库重建。
+// 之后由发表、撤回事件更新。每个实例用自己的消费者组消费全部的事件，所以多实例部署的时候索引最终也是一致的
+func InitSearchService(repo repository.SearchRepository, artRepo repository.ArticleRepository,
+	userRepo repository.UserRepository, l logger.Logger) service.SearchService {
+	svc := service.NewSearchService(repo, artRepo, userRepo, l)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		cnt, err := svc.RebuildArticleIndex(ctx)
+		if err != nil {
+			l.Error("重建文章搜索索引失败", logger.Int("cnt", cnt), logger.Error(err))
+			return
+		}
+		l.Info("重建文章搜索索引完成", logger.Int("cnt", cnt))
+	}()
+	return svc
+}
@@ -8,10 +8,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	"geektime-basic-go/webook/internal/web"
 	"geektime-basic-go/webook/internal/web/article"
+	"geektime-basic-go/webook/internal/web/collection"
+	"geektime-basic-go/webook/internal/web/comment"
+	"geektime-basic-go/webook/internal/web/cronjob"
+	"geektime-basic-go/webook/internal/web/feed"
+	"geektime-basic-go/webook/internal/web/follow"
+	"geektime-basic-go/webook/internal/web/history"
 	myjwt "geektime-basic-go/webook/internal/web/jwt"
 	"geektime-basic-go/webook/internal/web/middleware/login"
 	"geektime-basic-go/webook/pkg/ginx/accesslog"
@@ -23,6 +30,11 @@ import (
 func InitWebServer(fn []gin.HandlerFunc,
 	uh *web.UserHandler,
 	ah *article.Handler,
+	ch *comment.Handler,
+	fh *follow.Handler,
+	feh *feed.Handler,
+	hh *history.Handler,
+	colh *collection.Handler,
 	oh *web.OAuth2WechatHandler,
 	l logger.Logger,
 ) *gin.Engine {
@@ -31,10 +43,35 @@ func InitWebServer(fn []gin.HandlerFunc,
 	server.Use(fn...)
 	uh.RegisterRoutes(server)
 	ah.RegisterRoutes(server)
+	ch.RegisterRoutes(server)
+	fh.RegisterRoutes(server)
+	feh.RegisterRoutes(server)
+	hh.RegisterRoutes(server)
+	colh.RegisterRoutes(server)
 	oh.RegisterRoutes(server)
 	return server
 }
 
+// AdminServer 管理后台，和对外的 Web 服务器分开，只在内网访问
+type AdminServer struct {
+	*gin.Engine
+	Addr string
+}
+
+// InitAdminServer 不经过登录校验，所以默认只监听本机，部署的时候配置成内网地址
+func InitAdminServer(cjh *cronjob.Handler, l logger.Logger) *AdminServer {
+	cfg := struct {
+		Addr string `yaml:"addr"`
+	}{Addr: "127.0.0.1:8090"}
+	if err := viper.UnmarshalKey("admin", &cfg); err != nil {
+		panic(err)
+	}
+	server := gin.Default()
+	server.Use(accesslog.NewBuilder(accesslog.DefaultLogFunc(l)).AllowReqBody().AllowRespBody().Build())
+	cjh.RegisterRoutes(server)
+	return &AdminServer{Engine: server, Addr: cfg.Addr}
+}
+
 func Middlewares(cmd redis.Cmdable, jwtHandler myjwt.Handler, l logger.Logger) []gin.HandlerFunc {
 	pb := &metrics.PrometheusBuilder{
 		NameSpace:  "hkxpz",
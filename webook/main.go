@@ -2,15 +2,21 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"geektime-basic-go/webook/ioc"
+	"geektime-basic-go/webook/pkg/lifecycle"
 )
 
+// shutdownTimeout 收到退出信号之后，所有组件关闭的总时间
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	gin.SetMode(gin.ReleaseMode)
 	ioc.InitViperWatch()
@@ -19,17 +25,53 @@ func main() {
 	defer cancel(context.Background())
 
 	app := InitApp()
+	// 后注册的先关闭，所以先注册后台任务，最后注册 Web 服务器
+	m := lifecycle.NewManager(app.l, shutdownTimeout)
+
+	app.relay.Start()
+	m.OnShutdown("outbox relay", func(ctx context.Context) error {
+		return lifecycle.Wait(ctx, app.relay.Close)
+	})
+
+	for _, c := range app.consumers {
+		if err := c.Start(); err != nil {
+			panic(err)
+		}
+		m.OnShutdown(fmt.Sprintf("consumer %T", c), c.Close)
+	}
+
 	app.cron.Start()
-	defer func() {
-		<-app.cron.Stop().Done()
+	m.OnShutdown("cron", func(ctx context.Context) error {
+		return lifecycle.Wait(ctx, func() {
+			<-app.cron.Stop().Done()
+		})
+	})
+
+	schedulerCtx, schedulerCancel := context.WithCancel(context.Background())
+	go func() {
+		if err := app.scheduler.Start(schedulerCtx); err != nil {
+			log.Println("退出任务调度", err)
+		}
 	}()
+	m.OnShutdown("scheduler", func(ctx context.Context) error {
+		schedulerCancel()
+		return app.scheduler.Close(ctx)
+	})
 
-	server := app.web
-	server.GET("/PING", func(ctx *gin.Context) {
+	app.web.GET("/PING", func(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "PONG")
 	})
+	server := &http.Server{Addr: ":8080", Handler: app.web}
+	m.Go("web", server.ListenAndServe)
+	m.OnShutdown("web", server.Shutdown)
+
+	admin := &http.Server{Addr: app.admin.Addr, Handler: app.admin}
+	m.Go("admin", admin.ListenAndServe)
+	m.OnShutdown("admin", admin.Shutdown)
 
-	log.Fatalln(server.Run(":8080"))
+	if err := m.Wait(); err != nil {
+		log.Println("退出异常", err)
+	}
 }
 
 func initPrometheus() {
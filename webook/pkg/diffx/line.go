@@ -0,0 +1,180 @@
+package diffx
+
+import "strings"
+
+// Op 一行文本在两个版本之间的变化
+type Op uint8
+
+const (
+	// OpEqual 两个版本都有这一行
+	OpEqual Op = iota
+	// OpInsert 新版本增加的行
+	OpInsert
+	// OpDelete 旧版本被删除的行
+	OpDelete
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpInsert:
+		return "insert"
+	case OpDelete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines 按行比较两段文本
+func Lines(src, dst string) []Line {
+	return Diff(split(src), split(dst))
+}
+
+// MaxEdits 编辑距离超过这个值就不再寻找最短编辑脚本，直接认为整段替换
+// 避免两段完全不相关的长文本把 CPU 打满
+const MaxEdits = 2000
+
+// Diff 使用线性空间的 Myers 算法（middle snake 分治）计算从 src 变为 dst 的最短编辑脚本
+// 时间复杂度 O((N+M)D)，空间复杂度 O(N+M)，D 超过 MaxEdits 的时候退化为删除全部再插入全部
+func Diff(src, dst []string) []Line {
+	res := make([]Line, 0, len(src)+len(dst))
+	return diff(res, src, dst, MaxEdits)
+}
+
+// diff 把 src 到 dst 的编辑脚本追加到 res 后面，maxEdits 小于 0 表示不限制
+func diff(res []Line, src, dst []string, maxEdits int) []Line {
+	// 先去掉公共的头尾，大部分修改只动了其中几行
+	prefix := 0
+	for prefix < len(src) && prefix < len(dst) && src[prefix] == dst[prefix] {
+		prefix++
+	}
+	res = appendLines(res, OpEqual, src[:prefix])
+	src, dst = src[prefix:], dst[prefix:]
+
+	suffix := 0
+	for suffix < len(src) && suffix < len(dst) && src[len(src)-1-suffix] == dst[len(dst)-1-suffix] {
+		suffix++
+	}
+	tail := src[len(src)-suffix:]
+	src, dst = src[:len(src)-suffix], dst[:len(dst)-suffix]
+
+	switch {
+	case len(src) == 0:
+		res = appendLines(res, OpInsert, dst)
+	case len(dst) == 0:
+		res = appendLines(res, OpDelete, src)
+	default:
+		x, y, ok := middleSnake(src, dst, maxEdits)
+		if ok {
+			// 找到了最短路径上的中间点，两边分别求解，子问题的编辑距离不会超过原问题，不需要再限制
+			res = diff(res, src[:x], dst[:y], -1)
+			res = diff(res, src[x:], dst[y:], -1)
+		} else {
+			res = appendLines(res, OpDelete, src)
+			res = appendLines(res, OpInsert, dst)
+		}
+	}
+	return appendLines(res, OpEqual, tail)
+}
+
+// middleSnake 同时从头和尾出发搜索，两边的路径重叠的地方就是最短编辑路径的中点
+// 没有找到或者编辑距离超过 maxEdits 的时候返回 false
+func middleSnake(src, dst []string, maxEdits int) (int, int, bool) {
+	n, m := len(src), len(dst)
+	maxD := (n + m + 1) / 2
+	if maxEdits >= 0 {
+		maxD = min(maxD, (maxEdits+1)/2)
+	}
+	offset := maxD + 1
+	// forward[k] 正向在对角线 k 上走到的最远的 x，backward[k] 反向在对角线 k 上走过的最远的距离
+	forward, backward := make([]int, 2*offset+1), make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	// delta 是奇数的时候由正向检查重叠，偶数的时候由反向检查重叠
+	front := delta%2 != 0
+	// 已经越界的对角线不需要再搜索
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			idx := offset + k
+			var x int
+			if k == -d || (k != d && forward[idx-1] < forward[idx+1]) {
+				x = forward[idx+1]
+			} else {
+				x = forward[idx-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && src[x] == dst[y] {
+				x++
+				y++
+			}
+			forward[idx] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case front:
+				bIdx := offset + delta - k
+				if bIdx >= 0 && bIdx < len(backward) && backward[bIdx] != -1 && x >= n-backward[bIdx] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			idx := offset + k
+			var x int
+			if k == -d || (k != d && backward[idx-1] < backward[idx+1]) {
+				x = backward[idx+1]
+			} else {
+				x = backward[idx-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && src[n-x-1] == dst[m-y-1] {
+				x++
+				y++
+			}
+			backward[idx] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !front:
+				fIdx := offset + delta - k
+				if fIdx >= 0 && fIdx < len(forward) && forward[fIdx] != -1 {
+					fx := forward[fIdx]
+					fy := fx - (fIdx - offset)
+					if fx >= n-x {
+						return fx, fy, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func appendLines(res []Line, op Op, lines []string) []Line {
+	for _, l := range lines {
+		res = append(res, Line{Op: op, Text: l})
+	}
+	return res
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
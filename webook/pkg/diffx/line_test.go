@@ -0,0 +1,184 @@
+package diffx
+
+import (
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		dst  string
+
+		wantRes []Line
+	}{
+		{
+			name: "都为空",
+		},
+		{
+			name: "内容相同",
+			src:  "a\nb",
+			dst:  "a\nb",
+			wantRes: []Line{
+				{Op: OpEqual, Text: "a"},
+				{Op: OpEqual, Text: "b"},
+			},
+		},
+		{
+			name: "新建内容",
+			dst:  "a\nb",
+			wantRes: []Line{
+				{Op: OpInsert, Text: "a"},
+				{Op: OpInsert, Text: "b"},
+			},
+		},
+		{
+			name: "清空内容",
+			src:  "a\nb",
+			wantRes: []Line{
+				{Op: OpDelete, Text: "a"},
+				{Op: OpDelete, Text: "b"},
+			},
+		},
+		{
+			name: "中间修改了一行",
+			src:  "a\nb\nc",
+			dst:  "a\nd\nc",
+			wantRes: []Line{
+				{Op: OpEqual, Text: "a"},
+				{Op: OpDelete, Text: "b"},
+				{Op: OpInsert, Text: "d"},
+				{Op: OpEqual, Text: "c"},
+			},
+		},
+		{
+			name: "头尾增删",
+			src:  "a\nb\nc",
+			dst:  "b\nc\nd",
+			wantRes: []Line{
+				{Op: OpDelete, Text: "a"},
+				{Op: OpEqual, Text: "b"},
+				{Op: OpEqual, Text: "c"},
+				{Op: OpInsert, Text: "d"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := Lines(tc.src, tc.dst)
+			if len(tc.wantRes) == 0 {
+				assert.Empty(t, res)
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+// TestDiff_Minimal 随机生成的序列，编辑脚本要能还原两边，并且增删的行数等于 n+m-2*LCS
+func TestDiff_Minimal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "d"}
+	gen := func() []string {
+		res := make([]string, r.Intn(30))
+		for i := range res {
+			res[i] = alphabet[r.Intn(len(alphabet))]
+		}
+		return res
+	}
+	for i := 0; i < 2000; i++ {
+		src, dst := gen(), gen()
+		res := Diff(src, dst)
+		var gotSrc, gotDst []string
+		edits := 0
+		for _, l := range res {
+			switch l.Op {
+			case OpEqual:
+				gotSrc = append(gotSrc, l.Text)
+				gotDst = append(gotDst, l.Text)
+			case OpDelete:
+				gotSrc = append(gotSrc, l.Text)
+				edits++
+			case OpInsert:
+				gotDst = append(gotDst, l.Text)
+				edits++
+			}
+		}
+		require.True(t, slices.Equal(src, gotSrc), "src %v dst %v", src, dst)
+		require.True(t, slices.Equal(dst, gotDst), "src %v dst %v", src, dst)
+		require.Equal(t, len(src)+len(dst)-2*lcs(src, dst), edits, "src %v dst %v", src, dst)
+	}
+}
+
+func TestDiff_Large(t *testing.T) {
+	src := make([]string, 50000)
+	for i := range src {
+		src[i] = strconv.Itoa(i)
+	}
+	dst := slices.Clone(src)
+	dst[100], dst[25000] = "changed", "changed"
+	dst = append(dst[:40000], dst[40010:]...)
+	res := Diff(src, dst)
+	assert.Len(t, res, len(src)+2)
+}
+
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else {
+				dp[i][j] = max(dp[i-1][j], dp[i][j-1])
+			}
+		}
+	}
+	return dp[len(a)][len(b)]
+}
+
+// TestDiff_Unrelated 两段完全不相关的长文本，编辑距离超过 MaxEdits，直接整段替换
+func TestDiff_Unrelated(t *testing.T) {
+	src, dst := make([]string, 4000), make([]string, 4000)
+	for i := range src {
+		src[i], dst[i] = "a"+strconv.Itoa(i), "b"+strconv.Itoa(i)
+	}
+	res := Diff(src, dst)
+	require.Len(t, res, len(src)+len(dst))
+	for i, l := range res {
+		if i < len(src) {
+			assert.Equal(t, Line{Op: OpDelete, Text: src[i]}, l)
+		} else {
+			assert.Equal(t, Line{Op: OpInsert, Text: dst[i-len(src)]}, l)
+		}
+	}
+}
+
+// TestDiff_UnderMaxEdits 编辑距离没有超过 MaxEdits 的时候依旧是最短编辑脚本
+func TestDiff_UnderMaxEdits(t *testing.T) {
+	src := make([]string, 3000)
+	for i := range src {
+		src[i] = strconv.Itoa(i)
+	}
+	dst := slices.Clone(src)
+	for i := 0; i < len(dst); i += 5 {
+		dst[i] = "changed"
+	}
+	res := Diff(src, dst)
+	edits := 0
+	for _, l := range res {
+		if l.Op != OpEqual {
+			edits++
+		}
+	}
+	assert.Equal(t, 2*600, edits)
+}
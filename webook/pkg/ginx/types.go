@@ -1,12 +1,36 @@
 package ginx
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
 
 type Server struct {
 	*gin.Engine
 	Addr string
+	lock sync.Mutex
+	srv  *http.Server
 }
 
+// Start 阻塞直到服务器退出，调用 Shutdown 之后返回 http.ErrServerClosed
 func (s *Server) Start() error {
-	return s.Engine.Run(s.Addr)
+	s.lock.Lock()
+	s.srv = &http.Server{Addr: s.Addr, Handler: s.Engine}
+	srv := s.srv
+	s.lock.Unlock()
+	return srv.ListenAndServe()
+}
+
+// Shutdown 不再接收新的请求，等待正在处理的请求结束
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	srv := s.srv
+	s.lock.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
 }
@@ -0,0 +1,74 @@
+package gormx
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+type afterCommitKey struct{}
+
+// afterCommit 最外层的事务提交之后要执行的操作
+type afterCommit struct {
+	ctx context.Context
+	fns []func(ctx context.Context)
+}
+
+// Transactor 把事务放到 ctx 里面，DAO 通过 DB 拿到同一个事务
+// 这样上层可以把多个 DAO 的操作放到一个事务里，而不需要知道 gorm
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type gormTransactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) Transactor {
+	return &gormTransactor{db: db}
+}
+
+func (t *gormTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	hooks, nested := ctx.Value(afterCommitKey{}).(*afterCommit)
+	if !nested {
+		hooks = &afterCommit{ctx: ctx}
+		ctx = context.WithValue(ctx, afterCommitKey{}, hooks)
+	}
+	mark := len(hooks.fns)
+	// 已经在事务里了，gorm 会使用 SavePoint
+	err := DB(ctx, t.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+	if err != nil {
+		// 回滚了，这个事务里注册的操作都不需要执行
+		hooks.fns = hooks.fns[:mark]
+		return err
+	}
+	if !nested {
+		for _, f := range hooks.fns {
+			f(hooks.ctx)
+		}
+	}
+	return nil
+}
+
+// AfterCommit ctx 里面有 Transactor 开启的事务，就等最外层的事务提交之后再执行 fn，否则马上执行。
+// 更新缓存之类的副作用放在这里，避免事务回滚了缓存却已经更新了。
+// fn 拿到的 ctx 不带事务，可以直接查询数据库
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*afterCommit); ok {
+		hooks.fns = append(hooks.fns, fn)
+		return
+	}
+	fn(ctx)
+}
+
+// DB ctx 里面有事务就返回事务，否则返回 db
+func DB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db
+}
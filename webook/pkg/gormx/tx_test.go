@@ -0,0 +1,141 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestTransactor_AfterCommit(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+		fn   func(tx Transactor, ran *[]string) func(ctx context.Context) error
+
+		wantErr error
+		wantRan []string
+	}{
+		{
+			name: "提交之后执行",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			fn: func(tx Transactor, ran *[]string) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					AfterCommit(ctx, func(ctx context.Context) {
+						*ran = append(*ran, "outer")
+					})
+					// 还没有提交
+					assert.Empty(t, *ran)
+					return nil
+				}
+			},
+			wantRan: []string{"outer"},
+		},
+		{
+			name: "回滚之后不执行",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(tx Transactor, ran *[]string) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					AfterCommit(ctx, func(ctx context.Context) {
+						*ran = append(*ran, "outer")
+					})
+					return errors.New("模拟业务失败")
+				}
+			},
+			wantErr: errors.New("模拟业务失败"),
+		},
+		{
+			name: "嵌套事务等最外层提交",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT .*").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			fn: func(tx Transactor, ran *[]string) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					err := tx.Transaction(ctx, func(ctx context.Context) error {
+						AfterCommit(ctx, func(ctx context.Context) {
+							*ran = append(*ran, "inner")
+						})
+						return nil
+					})
+					require.NoError(t, err)
+					// 里面的事务结束了，但是外面还没有提交
+					assert.Empty(t, *ran)
+					AfterCommit(ctx, func(ctx context.Context) {
+						*ran = append(*ran, "outer")
+					})
+					return nil
+				}
+			},
+			wantRan: []string{"inner", "outer"},
+		},
+		{
+			name: "嵌套事务回滚，外层提交",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT .*").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT .*").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			fn: func(tx Transactor, ran *[]string) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					AfterCommit(ctx, func(ctx context.Context) {
+						*ran = append(*ran, "outer")
+					})
+					err := tx.Transaction(ctx, func(ctx context.Context) error {
+						AfterCommit(ctx, func(ctx context.Context) {
+							*ran = append(*ran, "inner")
+						})
+						return errors.New("模拟业务失败")
+					})
+					assert.Error(t, err)
+					return nil
+				}
+			},
+			wantRan: []string{"outer"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			tc.mock(mock)
+			db, err := gorm.Open(mysql.New(mysql.Config{
+				Conn:                      sqlDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+
+			tx := NewTransactor(db)
+			var ran []string
+			err = tx.Transaction(context.Background(), tc.fn(tx, &ran))
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRan, ran)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAfterCommit_NoTransaction(t *testing.T) {
+	var ran bool
+	AfterCommit(context.Background(), func(ctx context.Context) {
+		ran = true
+	})
+	assert.True(t, ran)
+}
@@ -2,6 +2,7 @@ package grpcx
 
 import (
 	"context"
+	"errors"
 	"net"
 	"strconv"
 	"time"
@@ -58,6 +59,9 @@ func (s *Server) register(ctx context.Context, port string) error {
 	s.etcdKey = serviceName + "/" + ip
 	addr := ip + ":" + port
 	leaseResp, err := ec.Grant(ctx, s.EtcdTTL)
+	if err != nil {
+		return err
+	}
 	ch, err := ec.KeepAlive(ctx, leaseResp.ID)
 	if err != nil {
 		return err
@@ -71,17 +75,37 @@ func (s *Server) register(ctx context.Context, port string) error {
 }
 
 func (s *Server) Close() error {
-	s.cancel()
+	return s.Shutdown(context.Background())
+}
+
+// Shutdown 先从 etcd 注销，客户端就不会再把新的请求发过来，
+// 然后等待正在处理的请求结束，ctx 过期了就直接断开所有的连接
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.cancel != nil {
+		// 停止续约
+		s.cancel()
+	}
+	var err error
 	if s.etcdManager != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
-		if err := s.etcdManager.DeleteEndpoint(ctx, s.etcdKey); err != nil {
-			return err
-		}
+		deleteCtx, cancel := context.WithTimeout(ctx, time.Second)
+		err = s.etcdManager.DeleteEndpoint(deleteCtx, s.etcdKey)
+		cancel()
 	}
-	if err := s.etcdClient.Close(); err != nil {
-		return err
+
+	done := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Server.Stop()
+		err = errors.Join(err, ctx.Err())
+	}
+
+	if s.etcdClient != nil {
+		err = errors.Join(err, s.etcdClient.Close())
 	}
-	s.Server.GracefulStop()
-	return nil
+	return err
 }
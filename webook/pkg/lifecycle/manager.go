@@ -0,0 +1,116 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// CloseFunc 在 ctx 过期之前完成关闭，过期了就尽快返回
+type CloseFunc func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   CloseFunc
+}
+
+// Manager 管理各个组件的启动和优雅退出。
+// 收到 SIGINT/SIGTERM，或者任何一个通过 Go 启动的服务退出了，
+// 就按照注册的反顺序（和 defer 一样）调用 CloseFunc，所有的关闭动作共享同一个超时时间
+type Manager struct {
+	l       logger.Logger
+	timeout time.Duration
+
+	mu    sync.Mutex
+	hooks []hook
+
+	// stop 有服务异常退出的时候通知 Wait
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewManager(l logger.Logger, timeout time.Duration) *Manager {
+	return &Manager{l: l, timeout: timeout, stop: make(chan struct{})}
+}
+
+// OnShutdown 注册关闭动作，后注册的先关闭。
+// 所以应该先注册最底层的组件，比如说先注册消息队列的消费者，再注册 Web 服务器
+func (m *Manager) OnShutdown(name string, fn CloseFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Go 在后台运行一个阻塞的服务，比如说 HTTP 或者 gRPC 服务器。
+// 服务因为关闭以外的原因退出，会触发整个进程的退出流程
+func (m *Manager) Go(name string, fn func() error) {
+	go func() {
+		err := fn()
+		if err == nil || errors.Is(err, http.ErrServerClosed) || errors.Is(err, grpc.ErrServerStopped) {
+			return
+		}
+		m.l.Error("服务异常退出", logger.String("name", name), logger.Error(err))
+		m.stopOnce.Do(func() { close(m.stop) })
+	}()
+}
+
+// Wait 阻塞直到收到退出信号或者有服务异常退出，然后执行所有的关闭动作
+func (m *Manager) Wait() error {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(ch)
+	select {
+	case sig := <-ch:
+		m.l.Info("收到退出信号", logger.String("signal", sig.String()))
+	case <-m.stop:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	return m.Shutdown(ctx)
+}
+
+// Shutdown 按照注册的反顺序关闭，某一个关闭失败了也会继续关闭后面的
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := m.hooks
+	m.hooks = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		start := time.Now()
+		if err := h.fn(ctx); err != nil {
+			m.l.Error("关闭失败", logger.String("name", h.name), logger.Error(err))
+			errs = append(errs, err)
+			continue
+		}
+		m.l.Info("关闭成功", logger.String("name", h.name),
+			logger.Int("duration_ms", time.Since(start).Milliseconds()))
+	}
+	return errors.Join(errs...)
+}
+
+// Wait 等待 wait 返回，或者 ctx 过期
+func Wait(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
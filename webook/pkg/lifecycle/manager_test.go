@@ -0,0 +1,65 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+func TestManager_Shutdown(t *testing.T) {
+	m := NewManager(logger.NewNoOpLogger(), time.Second)
+	var order []string
+	m.OnShutdown("db", func(ctx context.Context) error {
+		order = append(order, "db")
+		return nil
+	})
+	m.OnShutdown("consumer", func(ctx context.Context) error {
+		order = append(order, "consumer")
+		return errors.New("mock error")
+	})
+	m.OnShutdown("web", func(ctx context.Context) error {
+		order = append(order, "web")
+		return nil
+	})
+
+	err := m.Shutdown(context.Background())
+	assert.Error(t, err)
+	// 反顺序关闭，出错了也要继续关闭后面的
+	assert.Equal(t, []string{"web", "consumer", "db"}, order)
+
+	// 已经关闭过了，再次调用什么也不做
+	order = nil
+	assert.NoError(t, m.Shutdown(context.Background()))
+	assert.Nil(t, order)
+}
+
+func TestManager_Go(t *testing.T) {
+	m := NewManager(logger.NewNoOpLogger(), time.Second)
+	closed := false
+	m.OnShutdown("web", func(ctx context.Context) error {
+		closed = true
+		return nil
+	})
+	m.Go("web", func() error {
+		return errors.New("端口被占用")
+	})
+	// 服务异常退出会触发关闭流程
+	assert.NoError(t, m.Wait())
+	assert.True(t, closed)
+}
+
+func TestWait(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := Wait(ctx, func() {
+		time.Sleep(time.Second)
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	assert.NoError(t, Wait(context.Background(), func() {}))
+}
@@ -15,11 +15,9 @@ import (
 )
 
 type Consumer[T migrator.Entity] struct {
-	client   sarama.Client
-	l        logger.Logger
+	*saramax.Consumer[events.InconsistentEvent]
 	srcFirst *fixer.OverrideFixer[T]
 	dstFirst *fixer.OverrideFixer[T]
-	topic    string
 }
 
 func NewConsumer[T migrator.Entity](client sarama.Client, l logger.Logger, src *gorm.DB, dst *gorm.DB, topic string) (*Consumer[T], error) {
@@ -31,24 +29,14 @@ func NewConsumer[T migrator.Entity](client sarama.Client, l logger.Logger, src *
 	if err != nil {
 		return nil, err
 	}
-	return &Consumer[T]{client: client, l: l, srcFirst: srcFirst, dstFirst: dstFirst, topic: topic}, nil
+	c := &Consumer[T]{srcFirst: srcFirst, dstFirst: dstFirst}
+	c.Consumer = saramax.NewConsumer[events.InconsistentEvent](client, l, saramax.Each(c.Consume),
+		saramax.WithGroupID("migrator-fix"),
+		saramax.WithTopics(topic))
+	return c, nil
 }
 
-func (c *Consumer[T]) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("migrator-fix", c.client)
-	if err != nil {
-		return err
-	}
-	go func() {
-		err = cg.Consume(context.Background(), []string{c.topic}, saramax.NewHandler[events.InconsistentEvent](c.l, c.Consume))
-		if err != nil {
-			c.l.Error("退出消费循环异常", logger.Error(err))
-		}
-	}()
-	return nil
-}
-
-func (c *Consumer[T]) Consume(msg *sarama.ConsumerMessage, evt events.InconsistentEvent) error {
+func (c *Consumer[T]) Consume(ctx context.Context, msg *sarama.ConsumerMessage, evt events.InconsistentEvent) error {
 	switch evt.Direction {
 	default:
 		return errors.New("未知检验方向")
@@ -58,8 +46,3 @@ func (c *Consumer[T]) Consume(msg *sarama.ConsumerMessage, evt events.Inconsiste
 		return c.dstFirst.Fix(evt)
 	}
 }
-
-func (c *Consumer[T]) StartBatch() error {
-	//TODO implement me
-	panic("implement me")
-}
@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"geektime-basic-go/webook/pkg/logger"
+	"geektime-basic-go/webook/pkg/saramax"
+)
+
+// Relay 把 outbox 表里待发送的消息发到 kafka。
+// 每一批消息先在一个短事务里认领：把 NextRetryAt 推迟 ClaimTimeout，提交之后再发送，
+// 所以不会拿着行锁等 kafka。多个实例同时运行也不会重复认领，同一个 Key 的消息也不会乱序。
+// 认领之后实例崩溃了，租约到期之后消息会被重新认领，所以是至少一次，消费者用消息头里的事件 ID 去重
+type Relay struct {
+	db       *gorm.DB
+	producer sarama.SyncProducer
+	l        logger.Logger
+
+	// BatchSize 每一批最多处理多少条
+	BatchSize int
+	// Interval 没有消息的时候，隔多久再查一次
+	Interval time.Duration
+	// ClaimTimeout 认领之后多久没有处理完，别的实例可以重新认领
+	ClaimTimeout time.Duration
+	// MaxRetries 超过之后消息被标记为 StatusFailed，不再重试
+	MaxRetries int
+	// Backoff 第 n 次重试前等待的时间
+	Backoff func(retries int) time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRelay(db *gorm.DB, producer sarama.SyncProducer, l logger.Logger) *Relay {
+	return &Relay{
+		db:           db,
+		producer:     producer,
+		l:            l,
+		BatchSize:    100,
+		Interval:     time.Second,
+		ClaimTimeout: time.Minute,
+		MaxRetries:   10,
+		Backoff:      ExponentialBackoff(time.Second, time.Minute),
+	}
+}
+
+// ExponentialBackoff 从 initial 开始每次翻倍，最多等待 max
+func ExponentialBackoff(initial, max time.Duration) func(retries int) time.Duration {
+	return func(retries int) time.Duration {
+		d := initial
+		for i := 1; i < retries && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// Start 在后台循环发送，Close 之后退出
+func (r *Relay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			cnt, err := r.RelayOnce(ctx)
+			if err != nil {
+				r.l.Error("发送 outbox 消息失败", logger.Error(err))
+			}
+			// 这一批是满的，说明后面可能还有，马上处理下一批
+			if err == nil && cnt >= r.BatchSize {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.Interval):
+			}
+		}
+	}()
+}
+
+// Close 等待正在处理的这一批结束
+func (r *Relay) Close() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// RelayOnce 处理一批消息，返回这一批认领到的消息数量
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	msgs, err := r.claim(ctx, time.Now())
+	if err != nil || len(msgs) == 0 {
+		return 0, err
+	}
+	// 已经认领了，关闭的时候也要把这一批处理完，不然要等租约到期才会重新发送
+	ctx = context.WithoutCancel(ctx)
+
+	// 前面有消息没有发出去的 Key，后面的消息也不能发，不然就乱序了
+	blocked := make(map[string]struct{})
+	for _, msg := range msgs {
+		if _, ok := blocked[msg.Key]; ok && msg.Key != "" {
+			// 释放租约，等前面那条重试成功之后再发
+			err = r.db.WithContext(ctx).Model(&Message{}).Where("id = ?", msg.ID).
+				Update("next_retry_at", time.Now().UnixMilli()).Error
+			if err != nil {
+				return len(msgs), err
+			}
+			continue
+		}
+		if err = r.send(msg); err == nil {
+			if err = r.db.WithContext(ctx).Delete(&Message{}, msg.ID).Error; err != nil {
+				return len(msgs), err
+			}
+			continue
+		}
+
+		r.l.Warn("发送 outbox 消息失败",
+			logger.Int("id", msg.ID),
+			logger.String("topic", msg.Topic),
+			logger.Int("retries", msg.Retries),
+			logger.Error(err))
+		if err = r.fail(ctx, msg, time.Now()); err != nil {
+			return len(msgs), err
+		}
+		blocked[msg.Key] = struct{}{}
+	}
+	return len(msgs), nil
+}
+
+// claim 在一个事务里找出到期的消息，把 NextRetryAt 推迟 ClaimTimeout 作为租约。
+// 同一个 Key 前面还有没到期的消息（等待重试或者被别的实例认领了），后面的消息这次就不认领
+func (r *Relay) claim(ctx context.Context, now time.Time) ([]Message, error) {
+	var res []Message
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var msgs []Message
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND next_retry_at <= ?", StatusPending, now.UnixMilli()).
+			Order("id ASC").
+			Limit(r.BatchSize).
+			Find(&msgs).Error
+		if err != nil || len(msgs) == 0 {
+			return err
+		}
+
+		firstBlocked, err := r.firstBlocked(tx, msgs, now)
+		if err != nil {
+			return err
+		}
+		ids := make([]int64, 0, len(msgs))
+		for _, msg := range msgs {
+			if id, ok := firstBlocked[msg.Key]; ok && msg.Key != "" && id < msg.ID {
+				continue
+			}
+			ids = append(ids, msg.ID)
+			res = append(res, msg)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&Message{}).Where("id IN ?", ids).Updates(map[string]any{
+			"next_retry_at": now.Add(r.ClaimTimeout).UnixMilli(),
+			"update_at":     now.UnixMilli(),
+		}).Error
+	})
+	return res, err
+}
+
+// firstBlocked 找出 msgs 涉及的 Key 里，没有到期的消息中最小的 ID
+func (r *Relay) firstBlocked(tx *gorm.DB, msgs []Message, now time.Time) (map[string]int64, error) {
+	keys := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Key != "" {
+			keys = append(keys, msg.Key)
+		}
+	}
+	res := make(map[string]int64, len(keys))
+	if len(keys) == 0 {
+		return res, nil
+	}
+	var rows []struct {
+		Key string
+		ID  int64
+	}
+	err := tx.Model(&Message{}).
+		Select("`key`, MIN(id) AS id").
+		Where("status = ? AND next_retry_at > ? AND `key` IN ?", StatusPending, now.UnixMilli(), keys).
+		Group("`key`").
+		Scan(&rows).Error
+	for _, row := range rows {
+		res[row.Key] = row.ID
+	}
+	return res, err
+}
+
+// send 至少一次，重新发送的消息带着同一个事件 ID
+func (r *Relay) send(msg Message) error {
+	pm := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	if msg.Key != "" {
+		pm.Key = sarama.StringEncoder(msg.Key)
+	}
+	// 升级之前写入的消息没有事件 ID，消费者只能按照消息的位置去重
+	if msg.EventID != "" {
+		pm.Headers = []sarama.RecordHeader{saramax.EventIDHeader(msg.EventID)}
+	}
+	_, _, err := r.producer.SendMessage(pm)
+	return err
+}
+
+func (r *Relay) fail(ctx context.Context, msg Message, now time.Time) error {
+	retries := msg.Retries + 1
+	updates := map[string]any{
+		"retries":       retries,
+		"next_retry_at": now.Add(r.Backoff(retries)).UnixMilli(),
+		"update_at":     now.UnixMilli(),
+	}
+	if retries >= r.MaxRetries {
+		// 后面同一个 Key 的消息会继续发送，顺序没办法保证了，只能记录下来
+		updates["status"] = StatusFailed
+		r.l.Error("outbox 消息重试次数耗尽",
+			logger.Int("id", msg.ID),
+			logger.String("topic", msg.Topic),
+			logger.String("key", msg.Key))
+	}
+	return r.db.WithContext(ctx).Model(&Message{}).Where("id = ?", msg.ID).Updates(updates).Error
+}
@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"geektime-basic-go/webook/pkg/gormx"
+)
+
+// Store 把消息写到 outbox 表。
+// ctx 里面有 gormx.Transactor 开启的事务就在这个事务里写，这样消息和业务数据一起提交或者回滚
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Save val 会被序列化成 JSON
+func (s *Store) Save(ctx context.Context, topic string, key string, val any) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	return gormx.DB(ctx, s.db).WithContext(ctx).Create(&Message{
+		Topic:       topic,
+		Key:         key,
+		Value:       data,
+		EventID:     uuid.NewString(),
+		Status:      StatusPending,
+		NextRetryAt: now,
+		CreateAt:    now,
+		UpdateAt:    now,
+	}).Error
+}
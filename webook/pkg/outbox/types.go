@@ -0,0 +1,30 @@
+package outbox
+
+const (
+	// StatusPending 等待发送，包括发送失败等待重试的
+	StatusPending uint8 = iota
+	// StatusFailed 重试次数用完了，需要人工介入
+	StatusFailed
+)
+
+// Message 待发送的消息，发送成功之后就删除
+type Message struct {
+	ID    int64  `gorm:"primaryKey,autoIncrement"`
+	Topic string `gorm:"type:varchar(256)"`
+	// Key 同一个 Key 的消息按照 ID 的顺序发送，并且会被发到同一个分区
+	Key   string `gorm:"type:varchar(256);index"`
+	Value []byte `gorm:"type:blob"`
+	// EventID 保存的时候生成，每次发送都放在消息头里，消费者用它识别重新发送的消息
+	EventID string `gorm:"type:varchar(64)"`
+	// Relay 扫描到期的待发送消息
+	Status  uint8 `gorm:"index:status_next_retry_at"`
+	Retries int
+	// NextRetryAt 下一次重试的时间，毫秒数。被 Relay 认领之后是租约的到期时间
+	NextRetryAt int64 `gorm:"index:status_next_retry_at"`
+	CreateAt    int64
+	UpdateAt    int64
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
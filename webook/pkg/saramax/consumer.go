@@ -0,0 +1,380 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// BatchFunc 业务处理函数，批次大小为 1 的时候每次只有一条消息。
+// ctx 带有 WithTimeout 设置的超时时间，不会因为消费者退出而被取消
+type BatchFunc[T any] func(ctx context.Context, msgs []*sarama.ConsumerMessage, ts []T) error
+
+// Each 把处理单条消息的函数转换成 BatchFunc，按照顺序一条条处理
+func Each[T any](fn func(ctx context.Context, msg *sarama.ConsumerMessage, t T) error) BatchFunc[T] {
+	return func(ctx context.Context, msgs []*sarama.ConsumerMessage, ts []T) error {
+		for i := range msgs {
+			if err := fn(ctx, msgs[i], ts[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AckStrategy 什么时候提交偏移量
+type AckStrategy uint8
+
+const (
+	// AckAfterProcess 一批处理完（包括投递到死信队列）之后标记，由 sarama 定时提交，至少一次
+	AckAfterProcess AckStrategy = iota
+	// AckAfterProcessSync 和 AckAfterProcess 一样，但是每一批都同步提交，宕机之后重复消费的更少
+	AckAfterProcessSync
+	// AckBeforeProcess 拿到消息就标记，最多一次，适合丢了也没关系的消息
+	AckBeforeProcess
+)
+
+type consumerConfig struct {
+	groupID      string
+	topics       []string
+	batchSize    int
+	maxWait      time.Duration
+	concurrency  int
+	orderedByKey bool
+	ack          AckStrategy
+	timeout      time.Duration
+	dl           *DeadLetter
+}
+
+type ConsumerOption func(cfg *consumerConfig)
+
+func WithGroupID(groupID string) ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.groupID = groupID
+	}
+}
+
+func WithTopics(topics ...string) ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.topics = topics
+	}
+}
+
+// WithBatchSize 一批最多的消息数量，默认是 1，也就是一条条处理
+func WithBatchSize(size int) ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.batchSize = size
+	}
+}
+
+// WithMaxWait 收到一批的第一条消息之后，最多等待多久就开始处理，默认是 1 秒
+func WithMaxWait(d time.Duration) ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.maxWait = d
+	}
+}
+
+// WithConcurrency 每个分区同时处理的 goroutine 数量，一批消息会拆开并发处理，默认是 1
+func WithConcurrency(n int) ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.concurrency = n
+	}
+}
+
+// WithOrderedByKey 并发处理的时候，同一个 key 的消息分到同一个 goroutine，保证按照顺序处理
+func WithOrderedByKey() ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.orderedByKey = true
+	}
+}
+
+func WithAckStrategy(ack AckStrategy) ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.ack = ack
+	}
+}
+
+// WithTimeout 每次调用业务处理函数的超时时间，默认是 1 秒
+func WithTimeout(d time.Duration) ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.timeout = d
+	}
+}
+
+// WithDeadLetter 开启失败重试和死信队列，传入 nil 相当于不开启
+func WithDeadLetter(dl *DeadLetter) ConsumerOption {
+	return func(cfg *consumerConfig) {
+		cfg.dl = dl
+	}
+}
+
+// Consumer 通用的消费者，业务方只需要提供处理函数。
+// 负责加入消费者组、反序列化、凑批、分区内并发、重试和死信队列、提交偏移量以及监控
+type Consumer[T any] struct {
+	client  sarama.Client
+	l       logger.Logger
+	fn      BatchFunc[T]
+	cfg     consumerConfig
+	metrics *consumerMetrics
+	gc      *GroupConsumer
+
+	// newGroup 测试的时候替换成内存实现
+	newGroup func(groupID string) (sarama.ConsumerGroup, error)
+}
+
+func NewConsumer[T any](client sarama.Client, l logger.Logger, fn BatchFunc[T], opts ...ConsumerOption) *Consumer[T] {
+	cfg := consumerConfig{
+		batchSize:   1,
+		maxWait:     time.Second,
+		concurrency: 1,
+		ack:         AckAfterProcess,
+		timeout:     time.Second,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Consumer[T]{
+		client:  client,
+		l:       l,
+		fn:      fn,
+		cfg:     cfg,
+		metrics: newConsumerMetrics(cfg.groupID),
+		newGroup: func(groupID string) (sarama.ConsumerGroup, error) {
+			return sarama.NewConsumerGroupFromClient(groupID, client)
+		},
+	}
+}
+
+// Start 加入消费者组，在后台开始消费
+func (c *Consumer[T]) Start() error {
+	if c.cfg.groupID == "" || len(c.cfg.topics) == 0 {
+		return errors.New("saramax: 没有设置消费者组或者 topic")
+	}
+	cg, err := c.newGroup(c.cfg.groupID)
+	if err != nil {
+		c.l.Error("获取消费者组失败", logger.String("group", c.cfg.groupID), logger.Error(err))
+		return err
+	}
+	c.gc = StartGroupConsumer(cg, c.cfg.topics, c, c.l)
+	return nil
+}
+
+// Close 停止消费，等待正在处理的消息处理完
+func (c *Consumer[T]) Close(ctx context.Context) error {
+	if c.gc == nil {
+		return nil
+	}
+	return c.gc.Close(ctx)
+}
+
+func (c *Consumer[T]) Setup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (c *Consumer[T]) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (c *Consumer[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	msgCh := claim.Messages()
+	for {
+		msgs, ok := c.collect(msgCh)
+		if len(msgs) > 0 {
+			if !c.process(session, msgs) {
+				// 发生了 rebalance，没有提交的消息交给下一个消费者重新处理
+				return nil
+			}
+			c.metrics.setOffsets(claim, msgs[len(msgs)-1].Offset)
+		}
+		if !ok {
+			// channel 被关闭了
+			return nil
+		}
+	}
+}
+
+// collect 收到第一条消息之后开始计时，凑够一批或者超时就返回。channel 关闭的时候 ok 为 false
+func (c *Consumer[T]) collect(msgCh <-chan *sarama.ConsumerMessage) ([]*sarama.ConsumerMessage, bool) {
+	msg, ok := <-msgCh
+	if !ok {
+		return nil, false
+	}
+	msgs := make([]*sarama.ConsumerMessage, 0, c.cfg.batchSize)
+	msgs = append(msgs, msg)
+	if c.cfg.batchSize <= 1 {
+		return msgs, true
+	}
+	timer := time.NewTimer(c.cfg.maxWait)
+	defer timer.Stop()
+	for len(msgs) < c.cfg.batchSize {
+		select {
+		case msg, ok = <-msgCh:
+			if !ok {
+				return msgs, false
+			}
+			msgs = append(msgs, msg)
+		case <-timer.C:
+			return msgs, true
+		}
+	}
+	return msgs, true
+}
+
+// process 处理一批消息，同一批的消息都来自同一个分区。
+// 返回 false 表示处理过程中发生了 rebalance，或者投递死信队列一直失败，这一批都不能提交
+func (c *Consumer[T]) process(session sarama.ConsumerGroupSession, msgs []*sarama.ConsumerMessage) bool {
+	last := msgs[len(msgs)-1]
+	if c.cfg.ack == AckBeforeProcess {
+		session.MarkMessage(last, "")
+	}
+
+	valid := make([]*sarama.ConsumerMessage, 0, len(msgs))
+	ts := make([]T, 0, len(msgs))
+	for _, msg := range msgs {
+		var t T
+		if err := json.Unmarshal(msg.Value, &t); err != nil {
+			c.l.Error(
+				"反序列化消息体失败",
+				logger.String("topic", msg.Topic),
+				logger.Int("partition", msg.Partition),
+				logger.Int("offset", msg.Offset),
+				logger.Error(err),
+			)
+			c.metrics.incError(msg.Topic)
+			// 反序列化失败重试也没有用，直接进死信队列
+			if sendDeadLetter(session.Context(), c.l, c.cfg.dl, msg, err, 0) != nil {
+				return false
+			}
+			continue
+		}
+		valid = append(valid, msg)
+		ts = append(ts, t)
+	}
+
+	groups := c.split(valid, ts)
+	results := make([]bool, len(groups))
+	var wg sync.WaitGroup
+	for i := range groups {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.handle(session.Context(), groups[i])
+		}()
+	}
+	wg.Wait()
+	for _, ok := range results {
+		if !ok {
+			return false
+		}
+	}
+
+	switch c.cfg.ack {
+	case AckAfterProcess:
+		session.MarkMessage(last, "")
+	case AckAfterProcessSync:
+		session.MarkMessage(last, "")
+		session.Commit()
+	}
+	return true
+}
+
+type msgGroup[T any] struct {
+	msgs []*sarama.ConsumerMessage
+	ts   []T
+}
+
+// split 按照并发度把一批消息拆开。按照 key 拆的时候同一个 key 的消息保持原来的顺序
+func (c *Consumer[T]) split(msgs []*sarama.ConsumerMessage, ts []T) []msgGroup[T] {
+	if len(msgs) == 0 {
+		return nil
+	}
+	n := c.cfg.concurrency
+	if n <= 1 || len(msgs) == 1 {
+		return []msgGroup[T]{{msgs: msgs, ts: ts}}
+	}
+	if !c.cfg.orderedByKey {
+		size := (len(msgs) + n - 1) / n
+		res := make([]msgGroup[T], 0, n)
+		for start := 0; start < len(msgs); start += size {
+			end := min(start+size, len(msgs))
+			res = append(res, msgGroup[T]{msgs: msgs[start:end], ts: ts[start:end]})
+		}
+		return res
+	}
+	buckets := make([]msgGroup[T], n)
+	for i, msg := range msgs {
+		h := fnv.New32a()
+		_, _ = h.Write(msg.Key)
+		idx := h.Sum32() % uint32(n)
+		buckets[idx].msgs = append(buckets[idx].msgs, msg)
+		buckets[idx].ts = append(buckets[idx].ts, ts[i])
+	}
+	res := make([]msgGroup[T], 0, n)
+	for _, b := range buckets {
+		if len(b.msgs) > 0 {
+			res = append(res, b)
+		}
+	}
+	return res
+}
+
+// handle 按照重试策略处理一组消息，重试之后还是失败就一条条处理，只把真正失败的消息投递到死信队列。
+// 返回 false 表示重试的时候发生了 rebalance，死信队列没有投递成功
+func (c *Consumer[T]) handle(ctx context.Context, g msgGroup[T]) bool {
+	attempts, err := c.cfg.dl.retry(ctx, func() error {
+		return c.call(g.msgs, g.ts)
+	})
+	if err == nil {
+		return true
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	last := g.msgs[len(g.msgs)-1]
+	c.l.Error(
+		"处理消息失败",
+		logger.String("topic", last.Topic),
+		logger.Int("partition", last.Partition),
+		logger.Int("offset", last.Offset),
+		logger.Int("cnt", len(g.msgs)),
+		logger.Int("attempts", attempts),
+		logger.Error(err),
+	)
+	if c.cfg.dl == nil {
+		// 没有开启死信队列，只能记录日志
+		return true
+	}
+	if len(g.msgs) == 1 {
+		return sendDeadLetter(ctx, c.l, c.cfg.dl, last, err, attempts) == nil
+	}
+	for i := range g.msgs {
+		if er := c.call(g.msgs[i:i+1], g.ts[i:i+1]); er != nil {
+			if sendDeadLetter(ctx, c.l, c.cfg.dl, g.msgs[i], er, attempts+1) != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// call 调用一次业务处理函数，并且记录耗时和错误
+func (c *Consumer[T]) call(msgs []*sarama.ConsumerMessage, ts []T) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.timeout)
+	defer cancel()
+	start := time.Now()
+	err := c.fn(ctx, msgs, ts)
+	topic := msgs[0].Topic
+	c.metrics.observe(topic, len(msgs), time.Since(start))
+	if err != nil {
+		c.metrics.incError(topic)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+type seqEvent struct {
+	Key string
+	Seq int
+}
+
+func TestConsumer(t *testing.T) {
+	const topic = "test_consumer"
+	testCases := []struct {
+		name string
+		opts []ConsumerOption
+		// failSeq 处理这个序号的消息一直失败
+		failSeq int
+		dlq     bool
+		// unordered 不按照 key 拆分的时候，同一个 key 的消息可能并发处理
+		unordered bool
+
+		wantDLQ []int
+	}{
+		{
+			name: "一条条处理",
+		},
+		{
+			name: "批量按照 key 并发处理",
+			opts: []ConsumerOption{WithBatchSize(7), WithMaxWait(10 * time.Millisecond),
+				WithConcurrency(3), WithOrderedByKey()},
+		},
+		{
+			name: "批量并发处理，同步提交",
+			opts: []ConsumerOption{WithBatchSize(5), WithMaxWait(10 * time.Millisecond),
+				WithConcurrency(2), WithAckStrategy(AckAfterProcessSync)},
+			unordered: true,
+		},
+		{
+			name:    "失败的消息进入死信队列，同一批的其它消息正常处理",
+			opts:    []ConsumerOption{WithBatchSize(5), WithMaxWait(10 * time.Millisecond)},
+			failSeq: 3,
+			dlq:     true,
+			wantDLQ: []int{3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			broker := newMemBroker()
+			broker.CreateTopic(topic, 2)
+			keys := []string{"a", "b", "c", "d"}
+			const total = 40
+			for i := 1; i <= total; i++ {
+				key := keys[i%len(keys)]
+				val, err := json.Marshal(seqEvent{Key: key, Seq: i})
+				require.NoError(t, err)
+				broker.Produce(topic, int32(i%2), key, val)
+			}
+
+			producer := mocks.NewSyncProducer(t, nil)
+			defer producer.Close()
+			var dlqLock sync.Mutex
+			var gotDLQ []int
+			for range tc.wantDLQ {
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+					val, err := msg.Value.Encode()
+					require.NoError(t, err)
+					var evt seqEvent
+					require.NoError(t, json.Unmarshal(val, &evt))
+					dlqLock.Lock()
+					gotDLQ = append(gotDLQ, evt.Seq)
+					dlqLock.Unlock()
+					return nil
+				})
+			}
+			opts := append([]ConsumerOption{WithGroupID("test"), WithTopics(topic)}, tc.opts...)
+			if tc.dlq {
+				dl := NewDeadLetter(producer, logger.NewNoOpLogger())
+				dl.MaxRetries = 1
+				dl.Backoff = func(retries int) time.Duration { return time.Millisecond }
+				opts = append(opts, WithDeadLetter(dl))
+			}
+
+			var lock sync.Mutex
+			handled := make(map[string][]int)
+			seen := make(map[int]bool, total)
+			c := NewConsumer[seqEvent](nil, logger.NewNoOpLogger(),
+				Each(func(ctx context.Context, msg *sarama.ConsumerMessage, evt seqEvent) error {
+					if evt.Seq == tc.failSeq {
+						return errors.New("mock error")
+					}
+					lock.Lock()
+					defer lock.Unlock()
+					// 整批重试的时候，前面成功的消息会被重复处理，所以业务要做到幂等
+					if !seen[evt.Seq] {
+						seen[evt.Seq] = true
+						handled[evt.Key] = append(handled[evt.Key], evt.Seq)
+					}
+					return nil
+				}), opts...)
+			c.newGroup = func(groupID string) (sarama.ConsumerGroup, error) {
+				return broker.Group(), nil
+			}
+			require.NoError(t, c.Start())
+			require.Eventually(t, func() bool {
+				return broker.Committed(topic) == total
+			}, 3*time.Second, 10*time.Millisecond)
+			require.NoError(t, c.Close(context.Background()))
+
+			cnt := 0
+			for key, seqs := range handled {
+				cnt += len(seqs)
+				if !tc.unordered {
+					// 同一个 key 的消息都在同一个分区，处理的顺序要和写入的顺序一致
+					assert.IsIncreasing(t, seqs, fmt.Sprintf("key %s", key))
+				}
+			}
+			assert.Equal(t, total-len(tc.wantDLQ), cnt)
+			assert.Equal(t, tc.wantDLQ, gotDLQ)
+		})
+	}
+}
+
+func TestConsumer_Start(t *testing.T) {
+	c := NewConsumer[seqEvent](nil, logger.NewNoOpLogger(), nil)
+	assert.Error(t, c.Start())
+}
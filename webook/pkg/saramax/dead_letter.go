@@ -0,0 +1,156 @@
+package saramax
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// 投递到死信队列的消息会带上这些 header，方便排查和重放
+const (
+	HeaderOriginTopic     = "x-origin-topic"
+	HeaderOriginPartition = "x-origin-partition"
+	HeaderOriginOffset    = "x-origin-offset"
+	HeaderError           = "x-error"
+	HeaderAttempts        = "x-attempts"
+	HeaderReplayedFrom    = "x-replayed-from"
+)
+
+const deadLetterSuffix = ".dlq"
+
+// DeadLetterTopic 死信队列的 topic 就是原 topic 加上 .dlq 后缀
+func DeadLetterTopic(topic string) string {
+	return topic + deadLetterSuffix
+}
+
+// DeadLetter 业务处理失败之后先在本地按照退避策略重试，
+// 重试次数用完了就投递到 <topic>.dlq，然后提交偏移量继续往后消费。
+// 为 nil 的时候相当于没有开启，只执行一次，失败了记录日志
+type DeadLetter struct {
+	producer sarama.SyncProducer
+	l        logger.Logger
+	// MaxRetries 第一次失败之后最多重试的次数
+	MaxRetries int
+	// Backoff 第 n 次重试前等待的时间
+	Backoff func(retries int) time.Duration
+}
+
+func NewDeadLetter(producer sarama.SyncProducer, l logger.Logger) *DeadLetter {
+	return &DeadLetter{
+		producer:   producer,
+		l:          l,
+		MaxRetries: 3,
+		Backoff:    ExponentialBackoff(100*time.Millisecond, 2*time.Second),
+	}
+}
+
+// ExponentialBackoff 从 initial 开始每次翻倍，最多等待 max
+func ExponentialBackoff(initial, max time.Duration) func(retries int) time.Duration {
+	return func(retries int) time.Duration {
+		d := initial
+		for i := 1; i < retries && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// retry 执行 fn，失败了按照退避策略重试，返回一共执行的次数和最后一次的错误。
+// ctx 被取消的时候（一般是发生了 rebalance）立刻返回
+func (d *DeadLetter) retry(ctx context.Context, fn func() error) (int, error) {
+	attempts := 1
+	err := fn()
+	if d == nil {
+		return attempts, err
+	}
+	for ; err != nil && attempts <= d.MaxRetries; attempts++ {
+		select {
+		case <-ctx.Done():
+			return attempts, err
+		case <-time.After(d.Backoff(attempts)):
+		}
+		err = fn()
+	}
+	return attempts, err
+}
+
+// send 把处理失败的消息投递到死信队列，没有开启的时候什么也不做
+func (d *DeadLetter) send(msg *sarama.ConsumerMessage, cause error, attempts int) error {
+	if d == nil {
+		return nil
+	}
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+5)
+	for _, h := range msg.Headers {
+		if h != nil {
+			headers = append(headers, *h)
+		}
+	}
+	headers = append(headers,
+		header(HeaderOriginTopic, msg.Topic),
+		header(HeaderOriginPartition, strconv.FormatInt(int64(msg.Partition), 10)),
+		header(HeaderOriginOffset, strconv.FormatInt(msg.Offset, 10)),
+		header(HeaderError, cause.Error()),
+		header(HeaderAttempts, strconv.Itoa(attempts)),
+	)
+	_, _, err := d.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   DeadLetterTopic(msg.Topic),
+		Key:     sarama.ByteEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		return err
+	}
+	d.l.Warn("消息投递到死信队列",
+		logger.String("topic", msg.Topic),
+		logger.Int("partition", msg.Partition),
+		logger.Int("offset", msg.Offset),
+		logger.Int("attempts", attempts),
+		logger.Error(cause))
+	return nil
+}
+
+// sendDeadLetter 投递失败会按照退避策略一直重试，直到成功或者 ctx 被取消（一般是发生了 rebalance），
+// 返回最后一次投递的错误。投递成功之前不能提交偏移量，不然这条消息就丢了，所以这个分区会停在这里
+func sendDeadLetter(ctx context.Context, l logger.Logger, dl *DeadLetter, msg *sarama.ConsumerMessage, cause error, attempts int) error {
+	for retries := 1; ; retries++ {
+		err := dl.send(msg, cause, attempts)
+		if err == nil {
+			return nil
+		}
+		l.Error(
+			"投递死信队列失败",
+			logger.String("topic", msg.Topic),
+			logger.Int("partition", msg.Partition),
+			logger.Int("offset", msg.Offset),
+			logger.Int("retries", retries),
+			logger.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(dl.Backoff(retries)):
+		}
+	}
+}
+
+func header(key, val string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(val)}
+}
+
+// headerValue 找不到的时候返回空字符串
+func headerValue(headers []*sarama.RecordHeader, key string) string {
+	for _, h := range headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+package saramax
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+
+	"geektime-basic-go/webook/pkg/ginx/handlefunc"
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// DeadLetterAdmin 查看死信队列里面的消息，并且把它们重新投递到原来的 topic
+type DeadLetterAdmin struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	l        logger.Logger
+	// fetchTimeout 读取死信队列的最长时间
+	fetchTimeout time.Duration
+}
+
+func NewDeadLetterAdmin(client sarama.Client, producer sarama.SyncProducer, l logger.Logger) *DeadLetterAdmin {
+	return &DeadLetterAdmin{client: client, producer: producer, l: l, fetchTimeout: 3 * time.Second}
+}
+
+func (a *DeadLetterAdmin) RegisterRoutes(server *gin.RouterGroup) {
+	server.POST("/list", handlefunc.WrapReq[ListDeadLetterRequest](a.List))
+	server.POST("/replay", handlefunc.WrapReq[ReplayDeadLetterRequest](a.Replay))
+}
+
+type ListDeadLetterRequest struct {
+	// Topic 死信队列的 topic，也就是 <topic>.dlq
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	// Offset 从哪里开始读，小于 0 表示从头开始
+	Offset int64 `json:"offset"`
+	Limit  int   `json:"limit"`
+}
+
+type ReplayDeadLetterRequest struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+type DeadLetterMessage struct {
+	Partition       int32  `json:"partition"`
+	Offset          int64  `json:"offset"`
+	Key             string `json:"key"`
+	Value           string `json:"value"`
+	OriginTopic     string `json:"originTopic"`
+	OriginPartition string `json:"originPartition"`
+	OriginOffset    string `json:"originOffset"`
+	Error           string `json:"error"`
+	Attempts        string `json:"attempts"`
+	ReplayedFrom    string `json:"replayedFrom"`
+	Timestamp       int64  `json:"timestamp"`
+}
+
+func (a *DeadLetterAdmin) List(ctx *gin.Context, req ListDeadLetterRequest) (handlefunc.Response, error) {
+	if !strings.HasSuffix(req.Topic, deadLetterSuffix) {
+		return handlefunc.BadRequestError("不是死信队列的 topic"), nil
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	msgs, err := a.fetch(req.Topic, req.Partition, req.Offset, req.Limit)
+	if err != nil {
+		return handlefunc.InternalServerError(), err
+	}
+	res := make([]DeadLetterMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		res = append(res, a.toMessage(msg))
+	}
+	return handlefunc.Response{Data: res}, nil
+}
+
+// Replay 把死信队列里面的一条消息原样投递回原来的 topic，重放出来的消息会带上 x-replayed-from
+func (a *DeadLetterAdmin) Replay(ctx *gin.Context, req ReplayDeadLetterRequest) (handlefunc.Response, error) {
+	if !strings.HasSuffix(req.Topic, deadLetterSuffix) || req.Offset < 0 {
+		return handlefunc.BadRequestError("参数错误"), nil
+	}
+	msgs, err := a.fetch(req.Topic, req.Partition, req.Offset, 1)
+	if err != nil {
+		return handlefunc.InternalServerError(), err
+	}
+	if len(msgs) < 1 || msgs[0].Offset != req.Offset {
+		return handlefunc.BadRequestError("消息不存在"), nil
+	}
+	msg := msgs[0]
+	origin := headerValue(msg.Headers, HeaderOriginTopic)
+	if origin == "" {
+		origin = strings.TrimSuffix(req.Topic, deadLetterSuffix)
+	}
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+1)
+	for _, h := range msg.Headers {
+		if h != nil && !a.isDeadLetterHeader(string(h.Key)) {
+			headers = append(headers, *h)
+		}
+	}
+	headers = append(headers, header(HeaderReplayedFrom, fmt.Sprintf("%s:%d:%d", req.Topic, req.Partition, req.Offset)))
+	partition, offset, err := a.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   origin,
+		Key:     sarama.ByteEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		return handlefunc.InternalServerError(), err
+	}
+	a.l.Info("重放死信消息",
+		logger.String("dlq", req.Topic),
+		logger.Int("dlq_partition", req.Partition),
+		logger.Int("dlq_offset", req.Offset),
+		logger.String("topic", origin),
+		logger.Int("partition", partition),
+		logger.Int("offset", offset))
+	return handlefunc.RespSuccess("OK"), nil
+}
+
+// fetch 从 offset 开始最多读 limit 条消息，读到最新的消息或者超时就返回
+func (a *DeadLetterAdmin) fetch(topic string, partition int32, offset int64, limit int) ([]*sarama.ConsumerMessage, error) {
+	oldest, err := a.client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return nil, err
+	}
+	newest, err := a.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return nil, err
+	}
+	if offset < oldest {
+		offset = oldest
+	}
+	if offset >= newest {
+		return nil, nil
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(a.client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+	pc, err := consumer.ConsumePartition(topic, partition, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer pc.Close()
+
+	timer := time.NewTimer(a.fetchTimeout)
+	defer timer.Stop()
+	res := make([]*sarama.ConsumerMessage, 0, limit)
+	for len(res) < limit {
+		select {
+		case msg := <-pc.Messages():
+			res = append(res, msg)
+			if msg.Offset >= newest-1 {
+				return res, nil
+			}
+		case <-timer.C:
+			return res, nil
+		}
+	}
+	return res, nil
+}
+
+func (a *DeadLetterAdmin) isDeadLetterHeader(key string) bool {
+	switch key {
+	case HeaderOriginTopic, HeaderOriginPartition, HeaderOriginOffset, HeaderError, HeaderAttempts, HeaderReplayedFrom:
+		return true
+	}
+	return false
+}
+
+func (a *DeadLetterAdmin) toMessage(msg *sarama.ConsumerMessage) DeadLetterMessage {
+	return DeadLetterMessage{
+		Partition:       msg.Partition,
+		Offset:          msg.Offset,
+		Key:             string(msg.Key),
+		Value:           string(msg.Value),
+		OriginTopic:     headerValue(msg.Headers, HeaderOriginTopic),
+		OriginPartition: headerValue(msg.Headers, HeaderOriginPartition),
+		OriginOffset:    headerValue(msg.Headers, HeaderOriginOffset),
+		Error:           headerValue(msg.Headers, HeaderError),
+		Attempts:        headerValue(msg.Headers, HeaderAttempts),
+		ReplayedFrom:    headerValue(msg.Headers, HeaderReplayedFrom),
+		Timestamp:       msg.Timestamp.UnixMilli(),
+	}
+}
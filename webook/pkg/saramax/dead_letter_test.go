@@ -0,0 +1,164 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+type testEvent struct {
+	ID int64
+}
+
+func TestConsumer_DeadLetter(t *testing.T) {
+	val, err := json.Marshal(testEvent{ID: 1})
+	require.NoError(t, err)
+	testCases := []struct {
+		name    string
+		value   []byte
+		fnErrs  []error
+		enabled bool
+		// dlqErr 投递死信队列失败
+		dlqErr error
+
+		wantCalls    int
+		wantDLQ      bool
+		wantAttempts string
+		wantMarked   []int64
+	}{
+		{
+			name:       "一次成功",
+			value:      val,
+			fnErrs:     []error{nil},
+			enabled:    true,
+			wantCalls:  1,
+			wantMarked: []int64{10},
+		},
+		{
+			name:       "重试之后成功",
+			value:      val,
+			fnErrs:     []error{errors.New("mock error"), nil},
+			enabled:    true,
+			wantCalls:  2,
+			wantMarked: []int64{10},
+		},
+		{
+			name:         "重试耗尽进入死信队列",
+			value:        val,
+			fnErrs:       []error{errors.New("mock error"), errors.New("mock error"), errors.New("mock error")},
+			enabled:      true,
+			wantCalls:    3,
+			wantDLQ:      true,
+			wantAttempts: "3",
+			wantMarked:   []int64{10},
+		},
+		{
+			name:         "反序列化失败直接进入死信队列",
+			value:        []byte("{"),
+			enabled:      true,
+			wantDLQ:      true,
+			wantAttempts: "0",
+			wantMarked:   []int64{10},
+		},
+		{
+			name:    "死信队列投递失败，不提交偏移量",
+			value:   []byte("{"),
+			enabled: true,
+			dlqErr:  errors.New("mock dlq error"),
+		},
+		{
+			name:       "没有开启死信队列",
+			value:      val,
+			fnErrs:     []error{errors.New("mock error")},
+			wantCalls:  1,
+			wantMarked: []int64{10},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			defer producer.Close()
+			if tc.dlqErr != nil {
+				producer.ExpectSendMessageAndFail(tc.dlqErr)
+			}
+			if tc.wantDLQ {
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+					assert.Equal(t, "test_topic.dlq", msg.Topic)
+					got := make(map[string]string, len(msg.Headers))
+					for _, h := range msg.Headers {
+						got[string(h.Key)] = string(h.Value)
+					}
+					assert.Equal(t, "test_topic", got[HeaderOriginTopic])
+					assert.Equal(t, "2", got[HeaderOriginPartition])
+					assert.Equal(t, "10", got[HeaderOriginOffset])
+					assert.Equal(t, tc.wantAttempts, got[HeaderAttempts])
+					assert.NotEmpty(t, got[HeaderError])
+					return nil
+				})
+			}
+
+			opts := []ConsumerOption{WithGroupID("test"), WithTopics("test_topic")}
+			if tc.enabled {
+				dl := NewDeadLetter(producer, logger.NewNoOpLogger())
+				dl.MaxRetries = 2
+				dl.Backoff = func(retries int) time.Duration { return time.Millisecond }
+				if tc.dlqErr != nil {
+					// 等待重新投递的时候 ctx 被取消，模拟 rebalance
+					dl.Backoff = func(retries int) time.Duration { return time.Hour }
+				}
+				opts = append(opts, WithDeadLetter(dl))
+			}
+			calls := 0
+			c := NewConsumer[testEvent](nil, logger.NewNoOpLogger(),
+				Each(func(ctx context.Context, msg *sarama.ConsumerMessage, evt testEvent) error {
+					err := tc.fnErrs[calls]
+					calls++
+					return err
+				}), opts...)
+
+			msg := &sarama.ConsumerMessage{Topic: "test_topic", Partition: 2, Offset: 10, Value: tc.value}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			session := &fakeSession{ctx: ctx}
+			err := c.ConsumeClaim(session, newMemClaim(msg))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCalls, calls)
+			assert.Equal(t, tc.wantMarked, session.marked)
+		})
+	}
+}
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+// newMemClaim 消息都来自同一个分区，channel 提前关闭
+func newMemClaim(msgs ...*sarama.ConsumerMessage) *memClaim {
+	ch := make(chan *sarama.ConsumerMessage, len(msgs))
+	for _, msg := range msgs {
+		ch <- msg
+	}
+	close(ch)
+	last := msgs[len(msgs)-1]
+	return &memClaim{topic: last.Topic, partition: last.Partition, hwm: last.Offset + 1, ch: ch}
+}
@@ -0,0 +1,29 @@
+package saramax
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+)
+
+// HeaderEventID 生产者生成的事件 ID。同一个事件重新发送（例如 outbox.Relay 的重试）ID 不变，
+// 但是会落在新的位置上，所以消费者要用它而不是消息的位置去重
+const HeaderEventID = "x-event-id"
+
+// EventIDHeader id 为空的时候生成一个新的 ID
+func EventIDHeader(id string) sarama.RecordHeader {
+	if id == "" {
+		id = uuid.NewString()
+	}
+	return header(HeaderEventID, id)
+}
+
+// EventKey 消费者去重用的 Key。
+// 没有带上事件 ID 的消息只能用消息的位置，这样只能识别同一个位置的重复投递
+func EventKey(msg *sarama.ConsumerMessage) string {
+	if id := headerValue(msg.Headers, HeaderEventID); id != "" {
+		return msg.Topic + ":" + id
+	}
+	return fmt.Sprintf("%s:%d:%d", msg.Topic, msg.Partition, msg.Offset)
+}
@@ -0,0 +1,51 @@
+package saramax
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventKey(t *testing.T) {
+	idHeader := EventIDHeader("abc")
+	testCases := []struct {
+		name string
+		msg  *sarama.ConsumerMessage
+
+		wantKey string
+	}{
+		{
+			name: "用事件 ID",
+			msg: &sarama.ConsumerMessage{Topic: "test", Partition: 1, Offset: 10,
+				Headers: []*sarama.RecordHeader{&idHeader}},
+			wantKey: "test:abc",
+		},
+		{
+			// outbox 重新发送的消息位置变了
+			name: "重新发送的消息",
+			msg: &sarama.ConsumerMessage{Topic: "test", Partition: 2, Offset: 20,
+				Headers: []*sarama.RecordHeader{&idHeader}},
+			wantKey: "test:abc",
+		},
+		{
+			name:    "没有事件 ID 用消息的位置",
+			msg:     &sarama.ConsumerMessage{Topic: "test", Partition: 1, Offset: 10},
+			wantKey: "test:1:10",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantKey, EventKey(tc.msg))
+		})
+	}
+}
+
+func TestEventIDHeader(t *testing.T) {
+	h1, h2 := EventIDHeader(""), EventIDHeader("")
+	assert.Equal(t, HeaderEventID, string(h1.Key))
+	assert.NotEmpty(t, h1.Value)
+	// 每次生成的都不一样
+	assert.NotEqual(t, h1.Value, h2.Value)
+}
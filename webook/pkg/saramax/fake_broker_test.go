@@ -0,0 +1,156 @@
+package saramax
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// memBroker 内存实现的 kafka，只支持单个消费者组，消息在 Start 之前写进去
+type memBroker struct {
+	lock       sync.Mutex
+	partitions map[string][][]*sarama.ConsumerMessage
+	committed  map[string][]int64
+}
+
+func newMemBroker() *memBroker {
+	return &memBroker{
+		partitions: make(map[string][][]*sarama.ConsumerMessage),
+		committed:  make(map[string][]int64),
+	}
+}
+
+func (b *memBroker) CreateTopic(topic string, partitions int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.partitions[topic] = make([][]*sarama.ConsumerMessage, partitions)
+	b.committed[topic] = make([]int64, partitions)
+}
+
+func (b *memBroker) Produce(topic string, partition int32, key string, val []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	msgs := b.partitions[topic][partition]
+	b.partitions[topic][partition] = append(msgs, &sarama.ConsumerMessage{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    int64(len(msgs)),
+		Key:       []byte(key),
+		Value:     val,
+		Timestamp: time.Now(),
+	})
+}
+
+// Committed 某个 topic 所有分区提交的偏移量之和，也就是处理完的消息数量
+func (b *memBroker) Committed(topic string) int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var res int64
+	for _, offset := range b.committed[topic] {
+		res += offset
+	}
+	return res
+}
+
+func (b *memBroker) mark(msg *sarama.ConsumerMessage) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if msg.Offset+1 > b.committed[msg.Topic][msg.Partition] {
+		b.committed[msg.Topic][msg.Partition] = msg.Offset + 1
+	}
+}
+
+func (b *memBroker) Group() sarama.ConsumerGroup {
+	return &memGroup{broker: b}
+}
+
+type memGroup struct {
+	sarama.ConsumerGroup
+	broker *memBroker
+}
+
+// Consume 每个分区一个 claim，从提交的偏移量开始投递，ctx 取消之后关闭 claim
+func (g *memGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	session := &memSession{ctx: ctx, broker: g.broker}
+	if err := handler.Setup(session); err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		g.broker.lock.Lock()
+		partitions := g.broker.partitions[topic]
+		committed := g.broker.committed[topic]
+		claims := make([]*memClaim, 0, len(partitions))
+		for p, msgs := range partitions {
+			pending := msgs[committed[p]:]
+			claim := &memClaim{topic: topic, partition: int32(p), hwm: int64(len(msgs)),
+				ch: make(chan *sarama.ConsumerMessage, len(pending))}
+			for _, msg := range pending {
+				claim.ch <- msg
+			}
+			claims = append(claims, claim)
+		}
+		g.broker.lock.Unlock()
+
+		for _, claim := range claims {
+			claim := claim
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = handler.ConsumeClaim(session, claim)
+			}()
+			go func() {
+				<-ctx.Done()
+				close(claim.ch)
+			}()
+		}
+	}
+	wg.Wait()
+	return handler.Cleanup(session)
+}
+
+func (g *memGroup) Close() error {
+	return nil
+}
+
+type memSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	broker *memBroker
+}
+
+func (s *memSession) Context() context.Context {
+	return s.ctx
+}
+
+func (s *memSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.broker.mark(msg)
+}
+
+func (s *memSession) Commit() {}
+
+type memClaim struct {
+	sarama.ConsumerGroupClaim
+	topic     string
+	partition int32
+	hwm       int64
+	ch        chan *sarama.ConsumerMessage
+}
+
+func (c *memClaim) Topic() string {
+	return c.topic
+}
+
+func (c *memClaim) Partition() int32 {
+	return c.partition
+}
+
+func (c *memClaim) HighWaterMarkOffset() int64 {
+	return c.hwm
+}
+
+func (c *memClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.ch
+}
@@ -0,0 +1,55 @@
+package saramax
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"geektime-basic-go/webook/pkg/logger"
+)
+
+// GroupConsumer 在后台循环消费，每次 rebalance 之后重新加入消费者组。
+// Close 的时候取消消费，并且等待正在处理的消息（包括正在处理的一批）结束
+type GroupConsumer struct {
+	cg     sarama.ConsumerGroup
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func StartGroupConsumer(cg sarama.ConsumerGroup, topics []string, handler sarama.ConsumerGroupHandler, l logger.Logger) *GroupConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	gc := &GroupConsumer{cg: cg, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(gc.done)
+		for ctx.Err() == nil {
+			err := cg.Consume(ctx, topics, handler)
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			if err != nil {
+				l.Error("退出消费者循环异常", logger.Error(err))
+				// 避免 kafka 不可用的时候空转
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
+			}
+		}
+	}()
+	return gc
+}
+
+// Close ctx 过期之前正在处理的消息还没有结束，也会关闭消费者组，
+// 没有提交的消息会被重新消费
+func (g *GroupConsumer) Close(ctx context.Context) error {
+	g.cancel()
+	var err error
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return errors.Join(err, g.cg.Close())
+}
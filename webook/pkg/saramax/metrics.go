@@ -0,0 +1,151 @@
+package saramax
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsConfig 所有的消费者共用同一组指标，用 group、topic 和 partition 区分
+type MetricsConfig struct {
+	Namespace string `yaml:"namespace"`
+	Subsystem string `yaml:"subsystem"`
+	// InstanceID 为空的时候使用主机名
+	InstanceID string `yaml:"instanceID"`
+}
+
+var (
+	metricsOnce sync.Once
+	vecs        *metricVecs
+)
+
+// InitMetrics 要在创建消费者之前调用，只有第一次调用生效。
+// 不调用的话使用默认的配置
+func InitMetrics(cfg MetricsConfig) {
+	metricsOnce.Do(func() {
+		if cfg.Namespace == "" {
+			cfg.Namespace = "hkxpz"
+		}
+		if cfg.Subsystem == "" {
+			cfg.Subsystem = "webook"
+		}
+		if cfg.InstanceID == "" {
+			cfg.InstanceID, _ = os.Hostname()
+		}
+		vecs = newMetricVecs(cfg)
+	})
+}
+
+type metricVecs struct {
+	committed *prometheus.GaugeVec
+	hwm       *prometheus.GaugeVec
+	lag       *prometheus.GaugeVec
+	batchSize *prometheus.HistogramVec
+	duration  *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+}
+
+func newMetricVecs(cfg MetricsConfig) *metricVecs {
+	constLabels := prometheus.Labels{"instance_id": cfg.InstanceID}
+	partitionLabels := []string{"group", "topic", "partition"}
+	topicLabels := []string{"group", "topic"}
+	return &metricVecs{
+		committed: register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "kafka_consumer_committed_offset",
+			Help:        "已经处理完并且标记提交的偏移量",
+			ConstLabels: constLabels,
+		}, partitionLabels)),
+		hwm: register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "kafka_consumer_high_water_mark",
+			Help:        "分区最新的偏移量",
+			ConstLabels: constLabels,
+		}, partitionLabels)),
+		lag: register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "kafka_consumer_lag",
+			Help:        "分区最新的偏移量和已经处理的偏移量之间的差距",
+			ConstLabels: constLabels,
+		}, partitionLabels)),
+		batchSize: register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "kafka_consumer_batch_size",
+			Help:        "每次调用业务处理函数的消息数量",
+			ConstLabels: constLabels,
+			Buckets:     []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+		}, topicLabels)),
+		duration: register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "kafka_consumer_process_seconds",
+			Help:        "每次调用业务处理函数的耗时",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.DefBuckets,
+		}, topicLabels)),
+		errors: register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "kafka_consumer_errors_total",
+			Help:        "反序列化失败和业务处理失败的次数",
+			ConstLabels: constLabels,
+		}, topicLabels)),
+	}
+}
+
+// register 已经注册过了就复用之前注册的，不会 panic
+func register[C prometheus.Collector](c C) C {
+	if err := prometheus.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// consumerMetrics 每个 Consumer 一个，共用全局的指标，用 group 区分
+type consumerMetrics struct {
+	group string
+	vecs  *metricVecs
+}
+
+func newConsumerMetrics(group string) *consumerMetrics {
+	InitMetrics(MetricsConfig{})
+	return &consumerMetrics{group: group, vecs: vecs}
+}
+
+// setOffsets offset 是刚刚处理完的消息
+func (m *consumerMetrics) setOffsets(claim sarama.ConsumerGroupClaim, offset int64) {
+	partition := strconv.FormatInt(int64(claim.Partition()), 10)
+	hwm := claim.HighWaterMarkOffset()
+	lag := hwm - offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	m.vecs.committed.WithLabelValues(m.group, claim.Topic(), partition).Set(float64(offset + 1))
+	m.vecs.hwm.WithLabelValues(m.group, claim.Topic(), partition).Set(float64(hwm))
+	m.vecs.lag.WithLabelValues(m.group, claim.Topic(), partition).Set(float64(lag))
+}
+
+func (m *consumerMetrics) incError(topic string) {
+	m.vecs.errors.WithLabelValues(m.group, topic).Inc()
+}
+
+// observe 记录一次业务处理函数的调用
+func (m *consumerMetrics) observe(topic string, size int, d time.Duration) {
+	m.vecs.batchSize.WithLabelValues(m.group, topic).Observe(float64(size))
+	m.vecs.duration.WithLabelValues(m.group, topic).Observe(d.Seconds())
+}
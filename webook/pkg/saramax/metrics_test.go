@@ -0,0 +1,34 @@
+package saramax
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMetricVecs(t *testing.T) {
+	cfg := MetricsConfig{Namespace: "test", Subsystem: "saramax", InstanceID: "instance-1"}
+	first := newMetricVecs(cfg)
+	// 重复注册复用之前注册的，不会 panic
+	second := newMetricVecs(cfg)
+	assert.Same(t, first.lag, second.lag)
+	assert.Same(t, first.errors, second.errors)
+}
+
+func TestConsumerMetrics(t *testing.T) {
+	m := &consumerMetrics{group: "test_group", vecs: newMetricVecs(MetricsConfig{
+		Namespace: "test", Subsystem: "consumer", InstanceID: "instance-1"})}
+	claim := &memClaim{topic: "test_topic", partition: 3, hwm: 100}
+	m.setOffsets(claim, 89)
+	assert.Equal(t, float64(90), testutil.ToFloat64(m.vecs.committed.WithLabelValues("test_group", "test_topic", "3")))
+	assert.Equal(t, float64(100), testutil.ToFloat64(m.vecs.hwm.WithLabelValues("test_group", "test_topic", "3")))
+	assert.Equal(t, float64(10), testutil.ToFloat64(m.vecs.lag.WithLabelValues("test_group", "test_topic", "3")))
+
+	m.observe("test_topic", 20, time.Millisecond)
+	m.incError("test_topic")
+	m.incError("test_topic")
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.vecs.errors.WithLabelValues("test_group", "test_topic")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.vecs.batchSize))
+}
@@ -0,0 +1,89 @@
+package searchx
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Highlighter 把原文中命中查询的部分用 Pre 和 Post 包起来
+type Highlighter struct {
+	Pre  string
+	Post string
+	// FragmentSize 摘要的长度，按字符计算
+	FragmentSize int
+	// Escape 对原文转义，例如 html.EscapeString，为 nil 则不转义
+	Escape func(string) string
+}
+
+// Highlight 高亮整段文本
+func (h Highlighter) Highlight(text, query string) string {
+	spans := matchSpans(text, query)
+	var sb strings.Builder
+	last := 0
+	for _, sp := range spans {
+		sb.WriteString(h.escape(text[last:sp.Start]))
+		sb.WriteString(h.Pre)
+		sb.WriteString(h.escape(text[sp.Start:sp.End]))
+		sb.WriteString(h.Post)
+		last = sp.End
+	}
+	sb.WriteString(h.escape(text[last:]))
+	return sb.String()
+}
+
+// Fragment 截取第一个命中位置附近 FragmentSize 个字符并高亮
+// 没有命中就截取开头
+func (h Highlighter) Fragment(text, query string) string {
+	if utf8.RuneCountInString(text) <= h.FragmentSize {
+		return h.Highlight(text, query)
+	}
+
+	start := 0
+	if spans := matchSpans(text, query); len(spans) > 0 {
+		// 命中位置前面留一点上下文
+		start = spans[0].Start
+		for i := 0; i < h.FragmentSize/4 && start > 0; i++ {
+			_, size := utf8.DecodeLastRuneInString(text[:start])
+			start -= size
+		}
+	}
+	end := start
+	for i := 0; i < h.FragmentSize && end < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	return h.Highlight(text[start:end], query)
+}
+
+func (h Highlighter) escape(s string) string {
+	if h.Escape == nil {
+		return s
+	}
+	return h.Escape(s)
+}
+
+// matchSpans 找出原文中命中查询词的区间，相交或者相邻的区间会被合并
+func matchSpans(text, query string) []Token {
+	terms := make(map[string]struct{})
+	for _, tk := range TokenizeQuery(query) {
+		terms[tk.Term] = struct{}{}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var res []Token
+	for _, tk := range Tokenize(text) {
+		if _, ok := terms[tk.Term]; !ok {
+			continue
+		}
+		if n := len(res); n > 0 && tk.Start <= res[n-1].End {
+			if tk.End > res[n-1].End {
+				res[n-1].End = tk.End
+			}
+			continue
+		}
+		res = append(res, tk)
+	}
+	return res
+}
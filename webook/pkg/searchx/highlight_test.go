@@ -0,0 +1,65 @@
+package searchx
+
+import (
+	"html"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlighter(t *testing.T) {
+	h := Highlighter{Pre: "<em>", Post: "</em>", FragmentSize: 6, Escape: html.EscapeString}
+	testCases := []struct {
+		name     string
+		text     string
+		query    string
+		fragment bool
+
+		wantRes string
+	}{
+		{
+			name:    "合并相邻的命中",
+			text:    "学习数据库",
+			query:   "数据库",
+			wantRes: "学习<em>数据库</em>",
+		},
+		{
+			name:    "英文不区分大小写",
+			text:    "Go and go",
+			query:   "GO",
+			wantRes: "<em>Go</em> and <em>go</em>",
+		},
+		{
+			name:    "转义原文",
+			text:    "<b>数据</b>",
+			query:   "数据",
+			wantRes: "&lt;b&gt;<em>数据</em>&lt;/b&gt;",
+		},
+		{
+			name:     "截取命中位置附近",
+			text:     "一二三四五六七八九十数据库",
+			query:    "数据库",
+			fragment: true,
+			wantRes:  "十<em>数据库</em>",
+		},
+		{
+			name:     "没有命中截取开头",
+			text:     "一二三四五六七八九十",
+			query:    "数据库",
+			fragment: true,
+			wantRes:  "一二三四五六",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var res string
+			if tc.fragment {
+				res = h.Fragment(tc.text, tc.query)
+			} else {
+				res = h.Highlight(tc.text, tc.query)
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package searchx
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+type Document[T any] struct {
+	ID int64
+	// Fields 需要建立索引的文本，key 是字段名
+	Fields map[string]string
+	// Payload 不参与索引，搜索的时候原样返回
+	Payload T
+}
+
+type Hit[T any] struct {
+	Document[T]
+	Score float64
+}
+
+// Index 内存中的倒排索引，并发安全
+// 多个查询词之间是 AND 的关系，使用 TF-IDF 打分
+type Index[T any] struct {
+	mu sync.RWMutex
+	// boosts 字段的权重，没有配置的字段权重为 1
+	boosts map[string]float64
+	docs   map[int64]Document[T]
+	// postings 词 -> 文档 -> 字段 -> 词频
+	postings map[string]map[int64]map[string]int
+}
+
+func NewIndex[T any](boosts map[string]float64) *Index[T] {
+	return &Index[T]{
+		boosts:   boosts,
+		docs:     make(map[int64]Document[T]),
+		postings: make(map[string]map[int64]map[string]int),
+	}
+}
+
+// Put 新增或者覆盖一篇文档
+func (idx *Index[T]) Put(doc Document[T]) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(doc.ID)
+
+	idx.docs[doc.ID] = doc
+	for field, text := range doc.Fields {
+		for _, tk := range Tokenize(text) {
+			docs, ok := idx.postings[tk.Term]
+			if !ok {
+				docs = make(map[int64]map[string]int)
+				idx.postings[tk.Term] = docs
+			}
+			fields, ok := docs[doc.ID]
+			if !ok {
+				fields = make(map[string]int, len(doc.Fields))
+				docs[doc.ID] = fields
+			}
+			fields[field]++
+		}
+	}
+}
+
+func (idx *Index[T]) Delete(id int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *Index[T]) remove(id int64) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	delete(idx.docs, id)
+	for _, text := range doc.Fields {
+		for _, tk := range Tokenize(text) {
+			docs, ok := idx.postings[tk.Term]
+			if !ok {
+				continue
+			}
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(idx.postings, tk.Term)
+			}
+		}
+	}
+}
+
+func (idx *Index[T]) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Search 返回命中的总数和 [offset, offset+limit) 这一页的结果
+// 分数相同的情况下 ID 大的排在前面
+func (idx *Index[T]) Search(query string, offset, limit int) (int, []Hit[T]) {
+	terms := uniqueTerms(TokenizeQuery(query))
+	if len(terms) == 0 {
+		return 0, nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// 从文档最少的词开始找候选集合
+	sort.Slice(terms, func(i, j int) bool {
+		return len(idx.postings[terms[i]]) < len(idx.postings[terms[j]])
+	})
+	candidates := idx.postings[terms[0]]
+	total := float64(len(idx.docs))
+	hits := make([]Hit[T], 0, len(candidates))
+	for id := range candidates {
+		var score float64
+		matched := true
+		for _, term := range terms {
+			docs := idx.postings[term]
+			fields, ok := docs[id]
+			if !ok {
+				matched = false
+				break
+			}
+			idf := math.Log(1 + total/float64(len(docs)))
+			for field, tf := range fields {
+				score += idx.boost(field) * (1 + math.Log(float64(tf))) * idf
+			}
+		}
+		if matched {
+			hits = append(hits, Hit[T]{Document: idx.docs[id], Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+	if offset >= len(hits) {
+		return len(hits), nil
+	}
+	end := offset + limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	return len(hits), hits[offset:end]
+}
+
+func (idx *Index[T]) boost(field string) float64 {
+	if b, ok := idx.boosts[field]; ok {
+		return b
+	}
+	return 1
+}
+
+func uniqueTerms(tokens []Token) []string {
+	seen := make(map[string]struct{}, len(tokens))
+	res := make([]string, 0, len(tokens))
+	for _, tk := range tokens {
+		if _, ok := seen[tk.Term]; ok {
+			continue
+		}
+		seen[tk.Term] = struct{}{}
+		res = append(res, tk.Term)
+	}
+	return res
+}
@@ -0,0 +1,98 @@
+package searchx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex_Search(t *testing.T) {
+	newIndex := func() *Index[string] {
+		idx := NewIndex[string](map[string]float64{"title": 2})
+		idx.Put(Document[string]{ID: 1, Fields: map[string]string{"title": "Go 入门", "content": "学习数据库"}, Payload: "a"})
+		idx.Put(Document[string]{ID: 2, Fields: map[string]string{"title": "数据库原理", "content": "索引"}, Payload: "b"})
+		idx.Put(Document[string]{ID: 3, Fields: map[string]string{"title": "随笔", "content": "今天天气不错"}, Payload: "c"})
+		return idx
+	}
+	testCases := []struct {
+		name   string
+		before func(idx *Index[string])
+		query  string
+		offset int
+		limit  int
+
+		wantTotal int
+		wantIDs   []int64
+	}{
+		{
+			name:      "标题权重更高",
+			query:     "数据库",
+			limit:     10,
+			wantTotal: 2,
+			wantIDs:   []int64{2, 1},
+		},
+		{
+			name:      "多个词同时命中",
+			query:     "go 数据",
+			limit:     10,
+			wantTotal: 1,
+			wantIDs:   []int64{1},
+		},
+		{
+			name:      "分页",
+			query:     "数据库",
+			offset:    1,
+			limit:     1,
+			wantTotal: 2,
+			wantIDs:   []int64{1},
+		},
+		{
+			name:      "超出范围",
+			query:     "数据库",
+			offset:    5,
+			limit:     1,
+			wantTotal: 2,
+		},
+		{
+			name:  "没有命中",
+			query: "kafka",
+			limit: 10,
+		},
+		{
+			name: "覆盖之后旧内容搜不到",
+			before: func(idx *Index[string]) {
+				idx.Put(Document[string]{ID: 2, Fields: map[string]string{"title": "消息队列"}})
+			},
+			query:     "数据库",
+			limit:     10,
+			wantTotal: 1,
+			wantIDs:   []int64{1},
+		},
+		{
+			name: "删除",
+			before: func(idx *Index[string]) {
+				idx.Delete(1)
+			},
+			query:     "数据库",
+			limit:     10,
+			wantTotal: 1,
+			wantIDs:   []int64{2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			idx := newIndex()
+			if tc.before != nil {
+				tc.before(idx)
+			}
+			total, hits := idx.Search(tc.query, tc.offset, tc.limit)
+			assert.Equal(t, tc.wantTotal, total)
+			var ids []int64
+			for _, hit := range hits {
+				ids = append(ids, hit.ID)
+			}
+			assert.Equal(t, tc.wantIDs, ids)
+		})
+	}
+}
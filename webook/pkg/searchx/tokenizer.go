@@ -0,0 +1,103 @@
+package searchx
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token 分词的结果，Start 和 End 是 Term 在原文中的字节下标
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokenize 对文档分词
+// 中文没有词典可用，所以按照二元切分，同时保留单字，这样单个汉字的查询也能命中。
+// 英文和数字按照连续的字母数字切分，统一转为小写
+func Tokenize(text string) []Token {
+	return tokenize(text, true)
+}
+
+// TokenizeQuery 对查询分词，和 Tokenize 的区别是连续的中文只做二元切分
+func TokenizeQuery(text string) []Token {
+	return tokenize(text, false)
+}
+
+func tokenize(text string, unigram bool) []Token {
+	var (
+		res []Token
+		// 当前正在处理的连续片段的起始位置，-1 表示不在片段中
+		start = -1
+		cjk   bool
+	)
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		seg := text[start:end]
+		if cjk {
+			res = append(res, cjkTokens(seg, start, unigram)...)
+		} else {
+			res = append(res, Token{Term: strings.ToLower(seg), Start: start, End: end})
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			if start >= 0 && !cjk {
+				flush(i)
+			}
+			if start < 0 {
+				start, cjk = i, true
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start >= 0 && cjk {
+				flush(i)
+			}
+			if start < 0 {
+				start, cjk = i, false
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return res
+}
+
+func cjkTokens(seg string, offset int, unigram bool) []Token {
+	type char struct {
+		start, end int
+	}
+	chars := make([]char, 0, utf8.RuneCountInString(seg))
+	for i, r := range seg {
+		chars = append(chars, char{start: offset + i, end: offset + i + utf8.RuneLen(r)})
+	}
+
+	if len(chars) == 1 {
+		return []Token{{Term: seg, Start: chars[0].start, End: chars[0].end}}
+	}
+
+	res := make([]Token, 0, 2*len(chars))
+	for i, c := range chars {
+		if unigram {
+			res = append(res, Token{Term: seg[c.start-offset : c.end-offset], Start: c.start, End: c.end})
+		}
+		if i+1 < len(chars) {
+			next := chars[i+1]
+			res = append(res, Token{Term: seg[c.start-offset : next.end-offset], Start: c.start, End: next.end})
+		}
+	}
+	return res
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
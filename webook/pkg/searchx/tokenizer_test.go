@@ -0,0 +1,78 @@
+package searchx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	testCases := []struct {
+		name  string
+		text  string
+		query bool
+
+		wantRes []Token
+	}{
+		{
+			name: "空字符串",
+		},
+		{
+			name: "英文转小写",
+			text: "Hello, Go1",
+			wantRes: []Token{
+				{Term: "hello", Start: 0, End: 5},
+				{Term: "go1", Start: 7, End: 10},
+			},
+		},
+		{
+			name: "单个汉字",
+			text: "库",
+			wantRes: []Token{
+				{Term: "库", Start: 0, End: 3},
+			},
+		},
+		{
+			name: "中文二元切分并保留单字",
+			text: "数据库",
+			wantRes: []Token{
+				{Term: "数", Start: 0, End: 3},
+				{Term: "数据", Start: 0, End: 6},
+				{Term: "据", Start: 3, End: 6},
+				{Term: "据库", Start: 3, End: 9},
+				{Term: "库", Start: 6, End: 9},
+			},
+		},
+		{
+			name:  "查询只做二元切分",
+			text:  "数据库",
+			query: true,
+			wantRes: []Token{
+				{Term: "数据", Start: 0, End: 6},
+				{Term: "据库", Start: 3, End: 9},
+			},
+		},
+		{
+			name:  "中英文混合",
+			text:  "用Go写",
+			query: true,
+			wantRes: []Token{
+				{Term: "用", Start: 0, End: 3},
+				{Term: "go", Start: 3, End: 5},
+				{Term: "写", Start: 5, End: 8},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var res []Token
+			if tc.query {
+				res = TokenizeQuery(tc.text)
+			} else {
+				res = Tokenize(tc.text)
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
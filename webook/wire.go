@@ -6,6 +6,9 @@ import (
 	"github.com/google/wire"
 
 	events "geektime-basic-go/webook/internal/events/article"
+	"geektime-basic-go/webook/internal/events/ranking"
+	"geektime-basic-go/webook/internal/events/search"
+	"geektime-basic-go/webook/internal/job"
 	"geektime-basic-go/webook/internal/repository"
 	"geektime-basic-go/webook/internal/repository/cache/memory"
 	cache "geektime-basic-go/webook/internal/repository/cache/redis"
@@ -14,9 +17,17 @@ import (
 	"geektime-basic-go/webook/internal/service"
 	"geektime-basic-go/webook/internal/web"
 	webarticle "geektime-basic-go/webook/internal/web/article"
+	webcollection "geektime-basic-go/webook/internal/web/collection"
+	webcomment "geektime-basic-go/webook/internal/web/comment"
+	webcronjob "geektime-basic-go/webook/internal/web/cronjob"
+	webfeed "geektime-basic-go/webook/internal/web/feed"
+	webfollow "geektime-basic-go/webook/internal/web/follow"
+	webhistory "geektime-basic-go/webook/internal/web/history"
 	myjwt "geektime-basic-go/webook/internal/web/jwt"
 	"geektime-basic-go/webook/ioc"
 	"geektime-basic-go/webook/ioc/sms"
+	"geektime-basic-go/webook/pkg/gormx"
+	"geektime-basic-go/webook/pkg/outbox"
 )
 
 // todo 后续移除
@@ -54,15 +65,26 @@ var codeSvcProvider = wire.NewSet(
 )
 
 var articleSvcProvider = wire.NewSet(
+	gormx.NewTransactor,
 	service.NewArticleService,
 	repository.NewCacheArticleRepository,
 	article.NewGormArticleDAO,
+	article.NewGormRevisionDAO,
+	article.NewGormTagDAO,
 	cache.NewArticleCache,
 )
 
+var searchSvcProvider = wire.NewSet(
+	ioc.InitSearchService,
+	ioc.InitArticleSearchIndex,
+	repository.NewIndexSearchRepository,
+)
+
 var rankServiceProvider = wire.NewSet(
 	service.NewBatchRankingService,
+	ioc.InitRankingConfig,
 	repository.NewCacheRankingRepository,
+	dao.NewGormRankingSnapshotDAO,
 	cache.NewRankingCache,
 	memory.NewRankingCache,
 )
@@ -72,21 +94,47 @@ var HandlerProvider = wire.NewSet(
 	web.NewUserHandler,
 	web.NewOAuth2WechatHandler,
 	webarticle.NewArticleHandler,
+	webcomment.NewCommentHandler,
+	webfollow.NewFollowHandler,
+	webfeed.NewFeedHandler,
+	webhistory.NewHistoryHandler,
+	webcollection.NewCollectionHandler,
+	webcronjob.NewCronJobHandler,
+	wire.Bind(new(webcronjob.Executors), new(*job.Scheduler)),
 )
 
 var producerProvider = wire.NewSet(
 	ioc.NewSyncProducer,
-	events.NewSaramaSyncProducer,
+	outbox.NewStore,
+	ioc.InitOutboxRelay,
+	events.NewOutboxProducer,
+)
+
+var consumerProvider = wire.NewSet(
+	ranking.NewRankingEventConsumer,
+	search.NewArticleEventConsumer,
+	ioc.InitConsumers,
 )
 
 var grpcClientProvider = wire.NewSet(
 	ioc.InitEtcd,
 	ioc.InitInteractiveGRPC,
+	ioc.InitCommentGRPC,
+	ioc.InitFollowGRPC,
+	ioc.InitFeedGRPC,
+	ioc.InitHistoryGRPC,
 )
 
 var jobProvider = wire.NewSet(
 	ioc.InitJobs,
 	ioc.InitRankingJob,
+	ioc.InitScheduler,
+	job.NewScheduledPublishJob,
+	service.NewCronJobService,
+	repository.NewPreemptCronJobRepository,
+	dao.NewGormCronJobDAO,
+	dao.NewGormJobExecutionDAO,
+	dao.NewGormJobDependencyDAO,
 )
 
 func InitApp() *App {
@@ -99,6 +147,7 @@ func InitApp() *App {
 
 		// events 部分
 		producerProvider,
+		consumerProvider,
 
 		// job 部分
 		jobProvider,
@@ -107,6 +156,7 @@ func InitApp() *App {
 		userSvcProvider,
 		codeSvcProvider,
 		articleSvcProvider,
+		searchSvcProvider,
 		rankServiceProvider,
 		ioc.InitLocalWechatService,
 
@@ -118,6 +168,7 @@ func InitApp() *App {
 
 		// Web 服务器
 		ioc.InitWebServer,
+		ioc.InitAdminServer,
 
 		wire.Struct(new(App), "*"),
 	)